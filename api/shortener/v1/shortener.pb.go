@@ -0,0 +1,1444 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: shortener/v1/shortener.proto
+
+package shortenerv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// URL is a short link.
+type URL struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Branded domain of the link, empty for the default domain.
+	Domain   string `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	Original string `protobuf:"bytes,3,opt,name=original,proto3" json:"original,omitempty"`
+	Short    string `protobuf:"bytes,4,opt,name=short,proto3" json:"short,omitempty"`
+	// Full short link, e.g. https://brand.link/abc123.
+	ShortUrl  string                 `protobuf:"bytes,5,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// Display name of the authenticated creator, empty if anonymous.
+	Owner string `protobuf:"bytes,7,opt,name=owner,proto3" json:"owner,omitempty"`
+	// Whether the redirect asks for a password.
+	Protected bool  `protobuf:"varint,8,opt,name=protected,proto3" json:"protected,omitempty"`
+	Clicks    int64 `protobuf:"varint,9,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	// Redirect limit, unset if unlimited.
+	MaxClicks *int64 `protobuf:"varint,10,opt,name=max_clicks,json=maxClicks,proto3,oneof" json:"max_clicks,omitempty"`
+	// Redirects left before the link is gone, unset if unlimited.
+	ClicksLeft *int64           `protobuf:"varint,11,opt,name=clicks_left,json=clicksLeft,proto3,oneof" json:"clicks_left,omitempty"`
+	Rules      []*Rule          `protobuf:"bytes,12,rep,name=rules,proto3" json:"rules,omitempty"`
+	Variants   []*Variant       `protobuf:"bytes,13,rep,name=variants,proto3" json:"variants,omitempty"`
+	Options    *RedirectOptions `protobuf:"bytes,14,opt,name=options,proto3" json:"options,omitempty"`
+	// Whether visitors see a warning instead of the redirect.
+	Disabled       bool   `protobuf:"varint,15,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	DisabledReason string `protobuf:"bytes,16,opt,name=disabled_reason,json=disabledReason,proto3" json:"disabled_reason,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *URL) Reset() {
+	*x = URL{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *URL) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*URL) ProtoMessage() {}
+
+func (x *URL) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use URL.ProtoReflect.Descriptor instead.
+func (*URL) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *URL) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *URL) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *URL) GetOriginal() string {
+	if x != nil {
+		return x.Original
+	}
+	return ""
+}
+
+func (x *URL) GetShort() string {
+	if x != nil {
+		return x.Short
+	}
+	return ""
+}
+
+func (x *URL) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *URL) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *URL) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *URL) GetProtected() bool {
+	if x != nil {
+		return x.Protected
+	}
+	return false
+}
+
+func (x *URL) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+func (x *URL) GetMaxClicks() int64 {
+	if x != nil && x.MaxClicks != nil {
+		return *x.MaxClicks
+	}
+	return 0
+}
+
+func (x *URL) GetClicksLeft() int64 {
+	if x != nil && x.ClicksLeft != nil {
+		return *x.ClicksLeft
+	}
+	return 0
+}
+
+func (x *URL) GetRules() []*Rule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *URL) GetVariants() []*Variant {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+func (x *URL) GetOptions() *RedirectOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *URL) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *URL) GetDisabledReason() string {
+	if x != nil {
+		return x.DisabledReason
+	}
+	return ""
+}
+
+// Rule sends visitors that match all of its conditions to destination.
+type Rule struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Destination string                 `protobuf:"bytes,1,opt,name=destination,proto3" json:"destination,omitempty"`
+	// Device class: mobile, tablet, desktop or bot.
+	Device []string `protobuf:"bytes,2,rep,name=device,proto3" json:"device,omitempty"`
+	// Operating system: ios, android, windows, macos, chromeos or linux.
+	Os []string `protobuf:"bytes,3,rep,name=os,proto3" json:"os,omitempty"`
+	// Preferred language from Accept-Language, e.g. "de" or "pt-BR".
+	Language []string `protobuf:"bytes,4,rep,name=language,proto3" json:"language,omitempty"`
+	// ISO 3166-1 alpha-2 country of the client address.
+	Country []string    `protobuf:"bytes,5,rep,name=country,proto3" json:"country,omitempty"`
+	Time    *TimeWindow `protobuf:"bytes,6,opt,name=time,proto3" json:"time,omitempty"`
+	// Query parameters that must equal the value; "*" only requires presence.
+	Query         map[string]string `protobuf:"bytes,7,rep,name=query,proto3" json:"query,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Rule) Reset() {
+	*x = Rule{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Rule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rule) ProtoMessage() {}
+
+func (x *Rule) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rule.ProtoReflect.Descriptor instead.
+func (*Rule) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Rule) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *Rule) GetDevice() []string {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
+func (x *Rule) GetOs() []string {
+	if x != nil {
+		return x.Os
+	}
+	return nil
+}
+
+func (x *Rule) GetLanguage() []string {
+	if x != nil {
+		return x.Language
+	}
+	return nil
+}
+
+func (x *Rule) GetCountry() []string {
+	if x != nil {
+		return x.Country
+	}
+	return nil
+}
+
+func (x *Rule) GetTime() *TimeWindow {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Rule) GetQuery() map[string]string {
+	if x != nil {
+		return x.Query
+	}
+	return nil
+}
+
+// TimeWindow restricts a rule to days of the week and a daily time range.
+type TimeWindow struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Days of the week: mon, tue, wed, thu, fri, sat, sun.
+	Days []string `protobuf:"bytes,1,rep,name=days,proto3" json:"days,omitempty"`
+	// Start of the range as HH:MM, inclusive.
+	From string `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	// End of the range as HH:MM, exclusive.
+	To string `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	// IANA time zone, UTC if empty.
+	Timezone      string `protobuf:"bytes,4,opt,name=timezone,proto3" json:"timezone,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TimeWindow) Reset() {
+	*x = TimeWindow{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TimeWindow) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimeWindow) ProtoMessage() {}
+
+func (x *TimeWindow) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimeWindow.ProtoReflect.Descriptor instead.
+func (*TimeWindow) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TimeWindow) GetDays() []string {
+	if x != nil {
+		return x.Days
+	}
+	return nil
+}
+
+func (x *TimeWindow) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *TimeWindow) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *TimeWindow) GetTimezone() string {
+	if x != nil {
+		return x.Timezone
+	}
+	return ""
+}
+
+// Variant is one destination of an A/B split with its share of the traffic.
+type Variant struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Destination   string                 `protobuf:"bytes,2,opt,name=destination,proto3" json:"destination,omitempty"`
+	Weight        int32                  `protobuf:"varint,3,opt,name=weight,proto3" json:"weight,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Variant) Reset() {
+	*x = Variant{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Variant) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Variant) ProtoMessage() {}
+
+func (x *Variant) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Variant.ProtoReflect.Descriptor instead.
+func (*Variant) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Variant) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Variant) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *Variant) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+// RedirectOptions control how the destination URL is built on redirect.
+type RedirectOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Forward the incoming query string: merge or override; off if empty.
+	ForwardQuery string `protobuf:"bytes,1,opt,name=forward_query,json=forwardQuery,proto3" json:"forward_query,omitempty"`
+	// Append the path after the short code to the destination.
+	PassPath bool `protobuf:"varint,2,opt,name=pass_path,json=passPath,proto3" json:"pass_path,omitempty"`
+	Utm      *UTM `protobuf:"bytes,3,opt,name=utm,proto3" json:"utm,omitempty"`
+	// Seconds of a countdown page before redirecting.
+	Interstitial  int32 `protobuf:"varint,4,opt,name=interstitial,proto3" json:"interstitial,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RedirectOptions) Reset() {
+	*x = RedirectOptions{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RedirectOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RedirectOptions) ProtoMessage() {}
+
+func (x *RedirectOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RedirectOptions.ProtoReflect.Descriptor instead.
+func (*RedirectOptions) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RedirectOptions) GetForwardQuery() string {
+	if x != nil {
+		return x.ForwardQuery
+	}
+	return ""
+}
+
+func (x *RedirectOptions) GetPassPath() bool {
+	if x != nil {
+		return x.PassPath
+	}
+	return false
+}
+
+func (x *RedirectOptions) GetUtm() *UTM {
+	if x != nil {
+		return x.Utm
+	}
+	return nil
+}
+
+func (x *RedirectOptions) GetInterstitial() int32 {
+	if x != nil {
+		return x.Interstitial
+	}
+	return 0
+}
+
+// UTM holds campaign parameters set on the destination.
+type UTM struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Medium        string                 `protobuf:"bytes,2,opt,name=medium,proto3" json:"medium,omitempty"`
+	Campaign      string                 `protobuf:"bytes,3,opt,name=campaign,proto3" json:"campaign,omitempty"`
+	Term          string                 `protobuf:"bytes,4,opt,name=term,proto3" json:"term,omitempty"`
+	Content       string                 `protobuf:"bytes,5,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UTM) Reset() {
+	*x = UTM{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UTM) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UTM) ProtoMessage() {}
+
+func (x *UTM) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UTM.ProtoReflect.Descriptor instead.
+func (*UTM) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UTM) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *UTM) GetMedium() string {
+	if x != nil {
+		return x.Medium
+	}
+	return ""
+}
+
+func (x *UTM) GetCampaign() string {
+	if x != nil {
+		return x.Campaign
+	}
+	return ""
+}
+
+func (x *UTM) GetTerm() string {
+	if x != nil {
+		return x.Term
+	}
+	return ""
+}
+
+func (x *UTM) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+type CreateURLRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Original string                 `protobuf:"bytes,1,opt,name=original,proto3" json:"original,omitempty"`
+	// Branded domain of the link; the default domain if empty.
+	Domain        string           `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	Password      string           `protobuf:"bytes,3,opt,name=password,proto3" json:"password,omitempty"`
+	MaxClicks     *int64           `protobuf:"varint,4,opt,name=max_clicks,json=maxClicks,proto3,oneof" json:"max_clicks,omitempty"`
+	Rules         []*Rule          `protobuf:"bytes,5,rep,name=rules,proto3" json:"rules,omitempty"`
+	Variants      []*Variant       `protobuf:"bytes,6,rep,name=variants,proto3" json:"variants,omitempty"`
+	Options       *RedirectOptions `protobuf:"bytes,7,opt,name=options,proto3" json:"options,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateURLRequest) Reset() {
+	*x = CreateURLRequest{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateURLRequest) ProtoMessage() {}
+
+func (x *CreateURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateURLRequest.ProtoReflect.Descriptor instead.
+func (*CreateURLRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateURLRequest) GetOriginal() string {
+	if x != nil {
+		return x.Original
+	}
+	return ""
+}
+
+func (x *CreateURLRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *CreateURLRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *CreateURLRequest) GetMaxClicks() int64 {
+	if x != nil && x.MaxClicks != nil {
+		return *x.MaxClicks
+	}
+	return 0
+}
+
+func (x *CreateURLRequest) GetRules() []*Rule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *CreateURLRequest) GetVariants() []*Variant {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+func (x *CreateURLRequest) GetOptions() *RedirectOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+type GetURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Short         string                 `protobuf:"bytes,2,opt,name=short,proto3" json:"short,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetURLRequest) Reset() {
+	*x = GetURLRequest{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetURLRequest) ProtoMessage() {}
+
+func (x *GetURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetURLRequest.ProtoReflect.Descriptor instead.
+func (*GetURLRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetURLRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *GetURLRequest) GetShort() string {
+	if x != nil {
+		return x.Short
+	}
+	return ""
+}
+
+type DeleteURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Short         string                 `protobuf:"bytes,2,opt,name=short,proto3" json:"short,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteURLRequest) Reset() {
+	*x = DeleteURLRequest{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteURLRequest) ProtoMessage() {}
+
+func (x *DeleteURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteURLRequest.ProtoReflect.Descriptor instead.
+func (*DeleteURLRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DeleteURLRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *DeleteURLRequest) GetShort() string {
+	if x != nil {
+		return x.Short
+	}
+	return ""
+}
+
+type DeleteURLResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteURLResponse) Reset() {
+	*x = DeleteURLResponse{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteURLResponse) ProtoMessage() {}
+
+func (x *DeleteURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteURLResponse.ProtoReflect.Descriptor instead.
+func (*DeleteURLResponse) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{9}
+}
+
+type ListURLsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Text to search for in the original URL or short code; all links if empty.
+	Query string `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// Maximum number of links, 20 if unset, at most 100.
+	Limit         int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset        int32 `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListURLsRequest) Reset() {
+	*x = ListURLsRequest{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListURLsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListURLsRequest) ProtoMessage() {}
+
+func (x *ListURLsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListURLsRequest.ProtoReflect.Descriptor instead.
+func (*ListURLsRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListURLsRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ListURLsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListURLsRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type ListURLsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Urls  []*URL                 `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"`
+	// Total number of matches, for pagination.
+	Total         int64 `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListURLsResponse) Reset() {
+	*x = ListURLsResponse{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListURLsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListURLsResponse) ProtoMessage() {}
+
+func (x *ListURLsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListURLsResponse.ProtoReflect.Descriptor instead.
+func (*ListURLsResponse) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListURLsResponse) GetUrls() []*URL {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+func (x *ListURLsResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+type GetStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Short         string                 `protobuf:"bytes,2,opt,name=short,proto3" json:"short,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetStatsRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *GetStatsRequest) GetShort() string {
+	if x != nil {
+		return x.Short
+	}
+	return ""
+}
+
+// Stats summarizes the traffic of a short link.
+type Stats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Short         string                 `protobuf:"bytes,1,opt,name=short,proto3" json:"short,omitempty"`
+	Clicks        int64                  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	MaxClicks     *int64                 `protobuf:"varint,3,opt,name=max_clicks,json=maxClicks,proto3,oneof" json:"max_clicks,omitempty"`
+	ClicksLeft    *int64                 `protobuf:"varint,4,opt,name=clicks_left,json=clicksLeft,proto3,oneof" json:"clicks_left,omitempty"`
+	Variants      []*VariantStats        `protobuf:"bytes,5,rep,name=variants,proto3" json:"variants,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Stats) GetShort() string {
+	if x != nil {
+		return x.Short
+	}
+	return ""
+}
+
+func (x *Stats) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+func (x *Stats) GetMaxClicks() int64 {
+	if x != nil && x.MaxClicks != nil {
+		return *x.MaxClicks
+	}
+	return 0
+}
+
+func (x *Stats) GetClicksLeft() int64 {
+	if x != nil && x.ClicksLeft != nil {
+		return *x.ClicksLeft
+	}
+	return 0
+}
+
+func (x *Stats) GetVariants() []*VariantStats {
+	if x != nil {
+		return x.Variants
+	}
+	return nil
+}
+
+// VariantStats is the click count of one A/B variant.
+type VariantStats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Variant       *Variant               `protobuf:"bytes,1,opt,name=variant,proto3" json:"variant,omitempty"`
+	Clicks        int64                  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VariantStats) Reset() {
+	*x = VariantStats{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VariantStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VariantStats) ProtoMessage() {}
+
+func (x *VariantStats) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VariantStats.ProtoReflect.Descriptor instead.
+func (*VariantStats) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *VariantStats) GetVariant() *Variant {
+	if x != nil {
+		return x.Variant
+	}
+	return nil
+}
+
+func (x *VariantStats) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+type WatchClicksRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Domain        string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Short         string                 `protobuf:"bytes,2,opt,name=short,proto3" json:"short,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchClicksRequest) Reset() {
+	*x = WatchClicksRequest{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchClicksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchClicksRequest) ProtoMessage() {}
+
+func (x *WatchClicksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchClicksRequest.ProtoReflect.Descriptor instead.
+func (*WatchClicksRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *WatchClicksRequest) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *WatchClicksRequest) GetShort() string {
+	if x != nil {
+		return x.Short
+	}
+	return ""
+}
+
+// Click is a redirect served for a short link.
+type Click struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Domain string                 `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Short  string                 `protobuf:"bytes,2,opt,name=short,proto3" json:"short,omitempty"`
+	// Index of the A/B variant the visitor got, unset without a split.
+	Variant       *int32                 `protobuf:"varint,3,opt,name=variant,proto3,oneof" json:"variant,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Click) Reset() {
+	*x = Click{}
+	mi := &file_shortener_v1_shortener_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Click) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Click) ProtoMessage() {}
+
+func (x *Click) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_v1_shortener_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Click.ProtoReflect.Descriptor instead.
+func (*Click) Descriptor() ([]byte, []int) {
+	return file_shortener_v1_shortener_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Click) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *Click) GetShort() string {
+	if x != nil {
+		return x.Short
+	}
+	return ""
+}
+
+func (x *Click) GetVariant() int32 {
+	if x != nil && x.Variant != nil {
+		return *x.Variant
+	}
+	return 0
+}
+
+func (x *Click) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+var File_shortener_v1_shortener_proto protoreflect.FileDescriptor
+
+const file_shortener_v1_shortener_proto_rawDesc = "" +
+	"\n" +
+	"\x1cshortener/v1/shortener.proto\x12\fshortener.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xc7\x04\n" +
+	"\x03URL\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x16\n" +
+	"\x06domain\x18\x02 \x01(\tR\x06domain\x12\x1a\n" +
+	"\boriginal\x18\x03 \x01(\tR\boriginal\x12\x14\n" +
+	"\x05short\x18\x04 \x01(\tR\x05short\x12\x1b\n" +
+	"\tshort_url\x18\x05 \x01(\tR\bshortUrl\x129\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12\x14\n" +
+	"\x05owner\x18\a \x01(\tR\x05owner\x12\x1c\n" +
+	"\tprotected\x18\b \x01(\bR\tprotected\x12\x16\n" +
+	"\x06clicks\x18\t \x01(\x03R\x06clicks\x12\"\n" +
+	"\n" +
+	"max_clicks\x18\n" +
+	" \x01(\x03H\x00R\tmaxClicks\x88\x01\x01\x12$\n" +
+	"\vclicks_left\x18\v \x01(\x03H\x01R\n" +
+	"clicksLeft\x88\x01\x01\x12(\n" +
+	"\x05rules\x18\f \x03(\v2\x12.shortener.v1.RuleR\x05rules\x121\n" +
+	"\bvariants\x18\r \x03(\v2\x15.shortener.v1.VariantR\bvariants\x127\n" +
+	"\aoptions\x18\x0e \x01(\v2\x1d.shortener.v1.RedirectOptionsR\aoptions\x12\x1a\n" +
+	"\bdisabled\x18\x0f \x01(\bR\bdisabled\x12'\n" +
+	"\x0fdisabled_reason\x18\x10 \x01(\tR\x0edisabledReasonB\r\n" +
+	"\v_max_clicksB\x0e\n" +
+	"\f_clicks_left\"\xa3\x02\n" +
+	"\x04Rule\x12 \n" +
+	"\vdestination\x18\x01 \x01(\tR\vdestination\x12\x16\n" +
+	"\x06device\x18\x02 \x03(\tR\x06device\x12\x0e\n" +
+	"\x02os\x18\x03 \x03(\tR\x02os\x12\x1a\n" +
+	"\blanguage\x18\x04 \x03(\tR\blanguage\x12\x18\n" +
+	"\acountry\x18\x05 \x03(\tR\acountry\x12,\n" +
+	"\x04time\x18\x06 \x01(\v2\x18.shortener.v1.TimeWindowR\x04time\x123\n" +
+	"\x05query\x18\a \x03(\v2\x1d.shortener.v1.Rule.QueryEntryR\x05query\x1a8\n" +
+	"\n" +
+	"QueryEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"`\n" +
+	"\n" +
+	"TimeWindow\x12\x12\n" +
+	"\x04days\x18\x01 \x03(\tR\x04days\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x03 \x01(\tR\x02to\x12\x1a\n" +
+	"\btimezone\x18\x04 \x01(\tR\btimezone\"W\n" +
+	"\aVariant\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12 \n" +
+	"\vdestination\x18\x02 \x01(\tR\vdestination\x12\x16\n" +
+	"\x06weight\x18\x03 \x01(\x05R\x06weight\"\x9c\x01\n" +
+	"\x0fRedirectOptions\x12#\n" +
+	"\rforward_query\x18\x01 \x01(\tR\fforwardQuery\x12\x1b\n" +
+	"\tpass_path\x18\x02 \x01(\bR\bpassPath\x12#\n" +
+	"\x03utm\x18\x03 \x01(\v2\x11.shortener.v1.UTMR\x03utm\x12\"\n" +
+	"\finterstitial\x18\x04 \x01(\x05R\finterstitial\"\x7f\n" +
+	"\x03UTM\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x16\n" +
+	"\x06medium\x18\x02 \x01(\tR\x06medium\x12\x1a\n" +
+	"\bcampaign\x18\x03 \x01(\tR\bcampaign\x12\x12\n" +
+	"\x04term\x18\x04 \x01(\tR\x04term\x12\x18\n" +
+	"\acontent\x18\x05 \x01(\tR\acontent\"\xab\x02\n" +
+	"\x10CreateURLRequest\x12\x1a\n" +
+	"\boriginal\x18\x01 \x01(\tR\boriginal\x12\x16\n" +
+	"\x06domain\x18\x02 \x01(\tR\x06domain\x12\x1a\n" +
+	"\bpassword\x18\x03 \x01(\tR\bpassword\x12\"\n" +
+	"\n" +
+	"max_clicks\x18\x04 \x01(\x03H\x00R\tmaxClicks\x88\x01\x01\x12(\n" +
+	"\x05rules\x18\x05 \x03(\v2\x12.shortener.v1.RuleR\x05rules\x121\n" +
+	"\bvariants\x18\x06 \x03(\v2\x15.shortener.v1.VariantR\bvariants\x127\n" +
+	"\aoptions\x18\a \x01(\v2\x1d.shortener.v1.RedirectOptionsR\aoptionsB\r\n" +
+	"\v_max_clicks\"=\n" +
+	"\rGetURLRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x14\n" +
+	"\x05short\x18\x02 \x01(\tR\x05short\"@\n" +
+	"\x10DeleteURLRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x14\n" +
+	"\x05short\x18\x02 \x01(\tR\x05short\"\x13\n" +
+	"\x11DeleteURLResponse\"U\n" +
+	"\x0fListURLsRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x05R\x06offset\"O\n" +
+	"\x10ListURLsResponse\x12%\n" +
+	"\x04urls\x18\x01 \x03(\v2\x11.shortener.v1.URLR\x04urls\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x03R\x05total\"?\n" +
+	"\x0fGetStatsRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x14\n" +
+	"\x05short\x18\x02 \x01(\tR\x05short\"\xd6\x01\n" +
+	"\x05Stats\x12\x14\n" +
+	"\x05short\x18\x01 \x01(\tR\x05short\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks\x12\"\n" +
+	"\n" +
+	"max_clicks\x18\x03 \x01(\x03H\x00R\tmaxClicks\x88\x01\x01\x12$\n" +
+	"\vclicks_left\x18\x04 \x01(\x03H\x01R\n" +
+	"clicksLeft\x88\x01\x01\x126\n" +
+	"\bvariants\x18\x05 \x03(\v2\x1a.shortener.v1.VariantStatsR\bvariantsB\r\n" +
+	"\v_max_clicksB\x0e\n" +
+	"\f_clicks_left\"W\n" +
+	"\fVariantStats\x12/\n" +
+	"\avariant\x18\x01 \x01(\v2\x15.shortener.v1.VariantR\avariant\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks\"B\n" +
+	"\x12WatchClicksRequest\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x14\n" +
+	"\x05short\x18\x02 \x01(\tR\x05short\"\x90\x01\n" +
+	"\x05Click\x12\x16\n" +
+	"\x06domain\x18\x01 \x01(\tR\x06domain\x12\x14\n" +
+	"\x05short\x18\x02 \x01(\tR\x05short\x12\x1d\n" +
+	"\avariant\x18\x03 \x01(\x05H\x00R\avariant\x88\x01\x01\x12.\n" +
+	"\x04time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04timeB\n" +
+	"\n" +
+	"\b_variant2\xa7\x03\n" +
+	"\n" +
+	"URLService\x12>\n" +
+	"\tCreateURL\x12\x1e.shortener.v1.CreateURLRequest\x1a\x11.shortener.v1.URL\x128\n" +
+	"\x06GetURL\x12\x1b.shortener.v1.GetURLRequest\x1a\x11.shortener.v1.URL\x12L\n" +
+	"\tDeleteURL\x12\x1e.shortener.v1.DeleteURLRequest\x1a\x1f.shortener.v1.DeleteURLResponse\x12I\n" +
+	"\bListURLs\x12\x1d.shortener.v1.ListURLsRequest\x1a\x1e.shortener.v1.ListURLsResponse\x12>\n" +
+	"\bGetStats\x12\x1d.shortener.v1.GetStatsRequest\x1a\x13.shortener.v1.Stats\x12F\n" +
+	"\vWatchClicks\x12 .shortener.v1.WatchClicksRequest\x1a\x13.shortener.v1.Click0\x01B?Z=github.com/zen-flo/url-shortener/api/shortener/v1;shortenerv1b\x06proto3"
+
+var (
+	file_shortener_v1_shortener_proto_rawDescOnce sync.Once
+	file_shortener_v1_shortener_proto_rawDescData []byte
+)
+
+func file_shortener_v1_shortener_proto_rawDescGZIP() []byte {
+	file_shortener_v1_shortener_proto_rawDescOnce.Do(func() {
+		file_shortener_v1_shortener_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_shortener_v1_shortener_proto_rawDesc), len(file_shortener_v1_shortener_proto_rawDesc)))
+	})
+	return file_shortener_v1_shortener_proto_rawDescData
+}
+
+var file_shortener_v1_shortener_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_shortener_v1_shortener_proto_goTypes = []any{
+	(*URL)(nil),                   // 0: shortener.v1.URL
+	(*Rule)(nil),                  // 1: shortener.v1.Rule
+	(*TimeWindow)(nil),            // 2: shortener.v1.TimeWindow
+	(*Variant)(nil),               // 3: shortener.v1.Variant
+	(*RedirectOptions)(nil),       // 4: shortener.v1.RedirectOptions
+	(*UTM)(nil),                   // 5: shortener.v1.UTM
+	(*CreateURLRequest)(nil),      // 6: shortener.v1.CreateURLRequest
+	(*GetURLRequest)(nil),         // 7: shortener.v1.GetURLRequest
+	(*DeleteURLRequest)(nil),      // 8: shortener.v1.DeleteURLRequest
+	(*DeleteURLResponse)(nil),     // 9: shortener.v1.DeleteURLResponse
+	(*ListURLsRequest)(nil),       // 10: shortener.v1.ListURLsRequest
+	(*ListURLsResponse)(nil),      // 11: shortener.v1.ListURLsResponse
+	(*GetStatsRequest)(nil),       // 12: shortener.v1.GetStatsRequest
+	(*Stats)(nil),                 // 13: shortener.v1.Stats
+	(*VariantStats)(nil),          // 14: shortener.v1.VariantStats
+	(*WatchClicksRequest)(nil),    // 15: shortener.v1.WatchClicksRequest
+	(*Click)(nil),                 // 16: shortener.v1.Click
+	nil,                           // 17: shortener.v1.Rule.QueryEntry
+	(*timestamppb.Timestamp)(nil), // 18: google.protobuf.Timestamp
+}
+var file_shortener_v1_shortener_proto_depIdxs = []int32{
+	18, // 0: shortener.v1.URL.created_at:type_name -> google.protobuf.Timestamp
+	1,  // 1: shortener.v1.URL.rules:type_name -> shortener.v1.Rule
+	3,  // 2: shortener.v1.URL.variants:type_name -> shortener.v1.Variant
+	4,  // 3: shortener.v1.URL.options:type_name -> shortener.v1.RedirectOptions
+	2,  // 4: shortener.v1.Rule.time:type_name -> shortener.v1.TimeWindow
+	17, // 5: shortener.v1.Rule.query:type_name -> shortener.v1.Rule.QueryEntry
+	5,  // 6: shortener.v1.RedirectOptions.utm:type_name -> shortener.v1.UTM
+	1,  // 7: shortener.v1.CreateURLRequest.rules:type_name -> shortener.v1.Rule
+	3,  // 8: shortener.v1.CreateURLRequest.variants:type_name -> shortener.v1.Variant
+	4,  // 9: shortener.v1.CreateURLRequest.options:type_name -> shortener.v1.RedirectOptions
+	0,  // 10: shortener.v1.ListURLsResponse.urls:type_name -> shortener.v1.URL
+	14, // 11: shortener.v1.Stats.variants:type_name -> shortener.v1.VariantStats
+	3,  // 12: shortener.v1.VariantStats.variant:type_name -> shortener.v1.Variant
+	18, // 13: shortener.v1.Click.time:type_name -> google.protobuf.Timestamp
+	6,  // 14: shortener.v1.URLService.CreateURL:input_type -> shortener.v1.CreateURLRequest
+	7,  // 15: shortener.v1.URLService.GetURL:input_type -> shortener.v1.GetURLRequest
+	8,  // 16: shortener.v1.URLService.DeleteURL:input_type -> shortener.v1.DeleteURLRequest
+	10, // 17: shortener.v1.URLService.ListURLs:input_type -> shortener.v1.ListURLsRequest
+	12, // 18: shortener.v1.URLService.GetStats:input_type -> shortener.v1.GetStatsRequest
+	15, // 19: shortener.v1.URLService.WatchClicks:input_type -> shortener.v1.WatchClicksRequest
+	0,  // 20: shortener.v1.URLService.CreateURL:output_type -> shortener.v1.URL
+	0,  // 21: shortener.v1.URLService.GetURL:output_type -> shortener.v1.URL
+	9,  // 22: shortener.v1.URLService.DeleteURL:output_type -> shortener.v1.DeleteURLResponse
+	11, // 23: shortener.v1.URLService.ListURLs:output_type -> shortener.v1.ListURLsResponse
+	13, // 24: shortener.v1.URLService.GetStats:output_type -> shortener.v1.Stats
+	16, // 25: shortener.v1.URLService.WatchClicks:output_type -> shortener.v1.Click
+	20, // [20:26] is the sub-list for method output_type
+	14, // [14:20] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
+}
+
+func init() { file_shortener_v1_shortener_proto_init() }
+func file_shortener_v1_shortener_proto_init() {
+	if File_shortener_v1_shortener_proto != nil {
+		return
+	}
+	file_shortener_v1_shortener_proto_msgTypes[0].OneofWrappers = []any{}
+	file_shortener_v1_shortener_proto_msgTypes[6].OneofWrappers = []any{}
+	file_shortener_v1_shortener_proto_msgTypes[13].OneofWrappers = []any{}
+	file_shortener_v1_shortener_proto_msgTypes[16].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_shortener_v1_shortener_proto_rawDesc), len(file_shortener_v1_shortener_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   18,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_shortener_v1_shortener_proto_goTypes,
+		DependencyIndexes: file_shortener_v1_shortener_proto_depIdxs,
+		MessageInfos:      file_shortener_v1_shortener_proto_msgTypes,
+	}.Build()
+	File_shortener_v1_shortener_proto = out.File
+	file_shortener_v1_shortener_proto_goTypes = nil
+	file_shortener_v1_shortener_proto_depIdxs = nil
+}
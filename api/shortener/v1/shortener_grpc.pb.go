@@ -0,0 +1,339 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: shortener/v1/shortener.proto
+
+package shortenerv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	URLService_CreateURL_FullMethodName   = "/shortener.v1.URLService/CreateURL"
+	URLService_GetURL_FullMethodName      = "/shortener.v1.URLService/GetURL"
+	URLService_DeleteURL_FullMethodName   = "/shortener.v1.URLService/DeleteURL"
+	URLService_ListURLs_FullMethodName    = "/shortener.v1.URLService/ListURLs"
+	URLService_GetStats_FullMethodName    = "/shortener.v1.URLService/GetStats"
+	URLService_WatchClicks_FullMethodName = "/shortener.v1.URLService/WatchClicks"
+)
+
+// URLServiceClient is the client API for URLService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// URLService manages short links. It mirrors the REST endpoints under /urls.
+// Links are addressed by domain and short code; the empty domain is the default one.
+type URLServiceClient interface {
+	// CreateURL shortens a URL.
+	CreateURL(ctx context.Context, in *CreateURLRequest, opts ...grpc.CallOption) (*URL, error)
+	// GetURL returns a link.
+	GetURL(ctx context.Context, in *GetURLRequest, opts ...grpc.CallOption) (*URL, error)
+	// DeleteURL removes a link. It requires the token of the owner of the link or an admin.
+	DeleteURL(ctx context.Context, in *DeleteURLRequest, opts ...grpc.CallOption) (*DeleteURLResponse, error)
+	// ListURLs returns a page of links of all domains, newest first: all links for admins,
+	// the caller's own links for other tokens.
+	ListURLs(ctx context.Context, in *ListURLsRequest, opts ...grpc.CallOption) (*ListURLsResponse, error)
+	// GetStats returns the click counts of a link.
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*Stats, error)
+	// WatchClicks streams the redirects of a link as they happen, until the client cancels.
+	// It requires the token of the owner of the link or an admin.
+	// A client that falls behind is disconnected with RESOURCE_EXHAUSTED.
+	WatchClicks(ctx context.Context, in *WatchClicksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Click], error)
+}
+
+type uRLServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewURLServiceClient(cc grpc.ClientConnInterface) URLServiceClient {
+	return &uRLServiceClient{cc}
+}
+
+func (c *uRLServiceClient) CreateURL(ctx context.Context, in *CreateURLRequest, opts ...grpc.CallOption) (*URL, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(URL)
+	err := c.cc.Invoke(ctx, URLService_CreateURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLServiceClient) GetURL(ctx context.Context, in *GetURLRequest, opts ...grpc.CallOption) (*URL, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(URL)
+	err := c.cc.Invoke(ctx, URLService_GetURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLServiceClient) DeleteURL(ctx context.Context, in *DeleteURLRequest, opts ...grpc.CallOption) (*DeleteURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteURLResponse)
+	err := c.cc.Invoke(ctx, URLService_DeleteURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLServiceClient) ListURLs(ctx context.Context, in *ListURLsRequest, opts ...grpc.CallOption) (*ListURLsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListURLsResponse)
+	err := c.cc.Invoke(ctx, URLService_ListURLs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLServiceClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*Stats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Stats)
+	err := c.cc.Invoke(ctx, URLService_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLServiceClient) WatchClicks(ctx context.Context, in *WatchClicksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Click], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &URLService_ServiceDesc.Streams[0], URLService_WatchClicks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchClicksRequest, Click]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type URLService_WatchClicksClient = grpc.ServerStreamingClient[Click]
+
+// URLServiceServer is the server API for URLService service.
+// All implementations must embed UnimplementedURLServiceServer
+// for forward compatibility.
+//
+// URLService manages short links. It mirrors the REST endpoints under /urls.
+// Links are addressed by domain and short code; the empty domain is the default one.
+type URLServiceServer interface {
+	// CreateURL shortens a URL.
+	CreateURL(context.Context, *CreateURLRequest) (*URL, error)
+	// GetURL returns a link.
+	GetURL(context.Context, *GetURLRequest) (*URL, error)
+	// DeleteURL removes a link. It requires the token of the owner of the link or an admin.
+	DeleteURL(context.Context, *DeleteURLRequest) (*DeleteURLResponse, error)
+	// ListURLs returns a page of links of all domains, newest first: all links for admins,
+	// the caller's own links for other tokens.
+	ListURLs(context.Context, *ListURLsRequest) (*ListURLsResponse, error)
+	// GetStats returns the click counts of a link.
+	GetStats(context.Context, *GetStatsRequest) (*Stats, error)
+	// WatchClicks streams the redirects of a link as they happen, until the client cancels.
+	// It requires the token of the owner of the link or an admin.
+	// A client that falls behind is disconnected with RESOURCE_EXHAUSTED.
+	WatchClicks(*WatchClicksRequest, grpc.ServerStreamingServer[Click]) error
+	mustEmbedUnimplementedURLServiceServer()
+}
+
+// UnimplementedURLServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedURLServiceServer struct{}
+
+func (UnimplementedURLServiceServer) CreateURL(context.Context, *CreateURLRequest) (*URL, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateURL not implemented")
+}
+func (UnimplementedURLServiceServer) GetURL(context.Context, *GetURLRequest) (*URL, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetURL not implemented")
+}
+func (UnimplementedURLServiceServer) DeleteURL(context.Context, *DeleteURLRequest) (*DeleteURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteURL not implemented")
+}
+func (UnimplementedURLServiceServer) ListURLs(context.Context, *ListURLsRequest) (*ListURLsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListURLs not implemented")
+}
+func (UnimplementedURLServiceServer) GetStats(context.Context, *GetStatsRequest) (*Stats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedURLServiceServer) WatchClicks(*WatchClicksRequest, grpc.ServerStreamingServer[Click]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchClicks not implemented")
+}
+func (UnimplementedURLServiceServer) mustEmbedUnimplementedURLServiceServer() {}
+func (UnimplementedURLServiceServer) testEmbeddedByValue()                    {}
+
+// UnsafeURLServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to URLServiceServer will
+// result in compilation errors.
+type UnsafeURLServiceServer interface {
+	mustEmbedUnimplementedURLServiceServer()
+}
+
+func RegisterURLServiceServer(s grpc.ServiceRegistrar, srv URLServiceServer) {
+	// If the following call pancis, it indicates UnimplementedURLServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&URLService_ServiceDesc, srv)
+}
+
+func _URLService_CreateURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLServiceServer).CreateURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLService_CreateURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLServiceServer).CreateURL(ctx, req.(*CreateURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLService_GetURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLServiceServer).GetURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLService_GetURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLServiceServer).GetURL(ctx, req.(*GetURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLService_DeleteURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLServiceServer).DeleteURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLService_DeleteURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLServiceServer).DeleteURL(ctx, req.(*DeleteURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLService_ListURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListURLsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLServiceServer).ListURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLService_ListURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLServiceServer).ListURLs(ctx, req.(*ListURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLService_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLServiceServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLService_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLServiceServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLService_WatchClicks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchClicksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(URLServiceServer).WatchClicks(m, &grpc.GenericServerStream[WatchClicksRequest, Click]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type URLService_WatchClicksServer = grpc.ServerStreamingServer[Click]
+
+// URLService_ServiceDesc is the grpc.ServiceDesc for URLService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var URLService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "shortener.v1.URLService",
+	HandlerType: (*URLServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateURL",
+			Handler:    _URLService_CreateURL_Handler,
+		},
+		{
+			MethodName: "GetURL",
+			Handler:    _URLService_GetURL_Handler,
+		},
+		{
+			MethodName: "DeleteURL",
+			Handler:    _URLService_DeleteURL_Handler,
+		},
+		{
+			MethodName: "ListURLs",
+			Handler:    _URLService_ListURLs_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _URLService_GetStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchClicks",
+			Handler:       _URLService_WatchClicks_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "shortener/v1/shortener.proto",
+}
@@ -0,0 +1,280 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// The methods below call the /admin API and need a token with the admin scope.
+
+/*
+DisableURL shows visitors of a link a warning with the reason instead of redirecting them.
+*/
+func (c *Client) DisableURL(ctx context.Context, domain, short, reason string) (*URL, error) {
+	var u URL
+	body := map[string]string{"reason": reason}
+	if err := c.do(ctx, http.MethodPut, "/admin"+linkPath(short, "/disabled"), domainQuery(domain), body, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+/*
+EnableURL lets a disabled link redirect again.
+*/
+func (c *Client) EnableURL(ctx context.Context, domain, short string) (*URL, error) {
+	var u URL
+	if err := c.do(ctx, http.MethodDelete, "/admin"+linkPath(short, "/disabled"), domainQuery(domain), nil, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+/*
+Rescan checks all links against the blocklists now and disables those that match.
+*/
+func (c *Client) Rescan(ctx context.Context) (*ScanResult, error) {
+	var r ScanResult
+	if err := c.do(ctx, http.MethodPost, "/admin/screening/scan", nil, nil, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+/*
+RunLinkCheck checks the destinations of all links now.
+*/
+func (c *Client) RunLinkCheck(ctx context.Context) (*HealthResult, error) {
+	var r HealthResult
+	if err := c.do(ctx, http.MethodPost, "/admin/linkcheck/run", nil, nil, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+/*
+CreateDomain adds a branded domain with its defaults for new links.
+Returns ErrConflict if the domain exists.
+*/
+func (c *Client) CreateDomain(ctx context.Context, d Domain) (*Domain, error) {
+	var created Domain
+	if err := c.do(ctx, http.MethodPost, "/admin/domains", nil, d, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+/*
+ListDomains returns all branded domains.
+*/
+func (c *Client) ListDomains(ctx context.Context) ([]Domain, error) {
+	var domains []Domain
+	if err := c.do(ctx, http.MethodGet, "/admin/domains", nil, nil, &domains); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+/*
+GetDomain returns a branded domain.
+*/
+func (c *Client) GetDomain(ctx context.Context, name string) (*Domain, error) {
+	var d Domain
+	if err := c.do(ctx, http.MethodGet, domainPath(name, ""), nil, nil, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+/*
+UpdateDomain replaces the defaults of a domain for links created afterwards.
+*/
+func (c *Client) UpdateDomain(ctx context.Context, name string, defaults DomainDefaults) (*Domain, error) {
+	var d Domain
+	if err := c.do(ctx, http.MethodPut, domainPath(name, ""), nil, defaults, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+/*
+SetInterstitial forces a countdown of the given seconds on every link of a domain; 0 removes it.
+*/
+func (c *Client) SetInterstitial(ctx context.Context, name string, seconds int) (*Domain, error) {
+	var d Domain
+	body := map[string]int{"seconds": seconds}
+	if err := c.do(ctx, http.MethodPut, domainPath(name, "/interstitial"), nil, body, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+/*
+DeleteDomain removes a branded domain. Returns ErrConflict while links use it.
+*/
+func (c *Client) DeleteDomain(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, domainPath(name, ""), nil, nil, nil)
+}
+
+/*
+CreateAPIKey issues an API key with the given scopes.
+*/
+func (c *Client) CreateAPIKey(ctx context.Context, name string, scopes []string) (*CreatedAPIKey, error) {
+	var key CreatedAPIKey
+	body := map[string]any{"name": name, "scopes": scopes}
+	if err := c.do(ctx, http.MethodPost, "/admin/keys", nil, body, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+/*
+ListAPIKeys returns all API keys, including revoked ones, without their secrets.
+*/
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var keys []APIKey
+	if err := c.do(ctx, http.MethodGet, "/admin/keys", nil, nil, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+/*
+RevokeAPIKey revokes an API key; requests using it are rejected immediately.
+*/
+func (c *Client) RevokeAPIKey(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/admin/keys/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+/*
+CreateBackup takes a snapshot of the database.
+*/
+func (c *Client) CreateBackup(ctx context.Context) (*Snapshot, error) {
+	var s Snapshot
+	if err := c.do(ctx, http.MethodPost, "/admin/backups", nil, nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+/*
+ListBackups returns the kept snapshots, newest first.
+*/
+func (c *Client) ListBackups(ctx context.Context) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	if err := c.do(ctx, http.MethodGet, "/admin/backups", nil, nil, &snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+/*
+CreateWebhook subscribes url to link events, e.g. "link.created", or "*" for all.
+*/
+func (c *Client) CreateWebhook(ctx context.Context, url string, events []string) (*CreatedWebhook, error) {
+	var hook CreatedWebhook
+	body := map[string]any{"url": url, "events": events}
+	if err := c.do(ctx, http.MethodPost, "/admin/webhooks", nil, body, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+/*
+ListWebhooks returns all webhook subscriptions.
+*/
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var hooks []Webhook
+	if err := c.do(ctx, http.MethodGet, "/admin/webhooks", nil, nil, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+/*
+GetWebhook returns a webhook subscription.
+*/
+func (c *Client) GetWebhook(ctx context.Context, id int) (*Webhook, error) {
+	var hook Webhook
+	if err := c.do(ctx, http.MethodGet, webhookPath(id, ""), nil, nil, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+/*
+UpdateWebhook changes the fields of a webhook that are set in update.
+*/
+func (c *Client) UpdateWebhook(ctx context.Context, id int, update WebhookUpdate) (*Webhook, error) {
+	var hook Webhook
+	if err := c.do(ctx, http.MethodPut, webhookPath(id, ""), nil, update, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+/*
+DeleteWebhook removes a webhook subscription with its pending deliveries.
+*/
+func (c *Client) DeleteWebhook(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, webhookPath(id, ""), nil, nil, nil)
+}
+
+/*
+ListWebhookDeliveries returns the latest deliveries of a webhook, optionally only those with
+the given status: pending, delivered or dead. A limit of 0 uses the server default.
+*/
+func (c *Client) ListWebhookDeliveries(ctx context.Context, id int, status string, limit int) ([]WebhookDelivery, error) {
+	q := url.Values{}
+	if status != "" {
+		q.Set("status", status)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var deliveries []WebhookDelivery
+	if err := c.do(ctx, http.MethodGet, webhookPath(id, "/deliveries"), q, nil, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+/*
+RedeliverDeadWebhooks queues all dead deliveries of a webhook again and returns how many there were.
+*/
+func (c *Client) RedeliverDeadWebhooks(ctx context.Context, id int) (int, error) {
+	var resp struct {
+		Queued int `json:"queued"`
+	}
+	if err := c.do(ctx, http.MethodPost, webhookPath(id, "/redeliver"), nil, nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Queued, nil
+}
+
+/*
+RedeliverWebhook queues one delivery again.
+*/
+func (c *Client) RedeliverWebhook(ctx context.Context, deliveryID int) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	if err := c.do(ctx, http.MethodPost, "/admin/webhooks/deliveries/"+strconv.Itoa(deliveryID)+"/redeliver", nil, nil, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+/*
+domainPath returns the path of a domain under /admin/domains, followed by suffix.
+*/
+func domainPath(name, suffix string) string {
+	return "/admin/domains/" + url.PathEscape(name) + suffix
+}
+
+/*
+webhookPath returns the path of a webhook under /admin/webhooks, followed by suffix.
+*/
+func webhookPath(id int, suffix string) string {
+	return "/admin/webhooks/" + strconv.Itoa(id) + suffix
+}
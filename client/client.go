@@ -0,0 +1,314 @@
+/*
+Package client is a Go client for the URL shortener REST API.
+
+	c, err := client.New("https://sho.rt", client.WithToken(os.Getenv("SHORTENER_TOKEN")))
+	if err != nil {
+		return err
+	}
+	link, err := c.CreateURL(ctx, client.CreateRequest{Original: "https://example.com"})
+	if errors.Is(err, client.ErrBlocked) {
+		// the destination is on a blocklist
+	}
+
+Failed requests are retried with exponential backoff, honoring Retry-After. Requests that
+are not idempotent, such as creating a link, are only retried when the server rejected them
+without processing them (429 Too Many Requests).
+*/
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of a Client created by New.
+const (
+	DefaultTimeout    = 30 * time.Second
+	DefaultMaxRetries = 3
+	DefaultRetryBase  = 200 * time.Millisecond
+	DefaultRetryMax   = 10 * time.Second
+)
+
+// maxErrorBody limits how much of an error response is read into Error.Message.
+const maxErrorBody = 4 << 10
+
+/*
+Client calls the API of one server. It is safe for concurrent use.
+*/
+type Client struct {
+	baseURL    *url.URL
+	token      string
+	httpClient *http.Client
+	userAgent  string
+
+	maxRetries int
+	retryBase  time.Duration
+	retryMax   time.Duration
+}
+
+/*
+Option configures a Client.
+*/
+type Option func(*Client)
+
+/*
+WithToken authenticates requests with an API key or the admin token. Links created with
+it are owned by the key; the /admin methods need a key with the admin scope.
+*/
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+/*
+WithHTTPClient sends requests with hc instead of a client with DefaultTimeout.
+*/
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+/*
+WithTimeout limits every attempt of a request. The context of a call bounds all attempts together.
+*/
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		hc := *c.httpClient
+		hc.Timeout = d
+		c.httpClient = &hc
+	}
+}
+
+/*
+WithRetries sets how often a failed request is retried and the backoff between attempts:
+base before the first retry, doubled for every further one up to max. 0 retries disables them.
+*/
+func WithRetries(retries int, base, max time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = retries
+		c.retryBase = base
+		c.retryMax = max
+	}
+}
+
+/*
+WithUserAgent sets the User-Agent header of requests.
+*/
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+/*
+New creates a client for the server at baseURL, e.g. "https://sho.rt".
+*/
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q, expected e.g. https://sho.rt", baseURL)
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		userAgent:  "url-shortener-client",
+		maxRetries: DefaultMaxRetries,
+		retryBase:  DefaultRetryBase,
+		retryMax:   DefaultRetryMax,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+/*
+BaseURL returns the server the client talks to.
+*/
+func (c *Client) BaseURL() string {
+	return c.baseURL.String()
+}
+
+/*
+do sends a request with a JSON body, if in is not nil, and decodes the JSON response into out,
+if it is not nil. Responses other than 2xx are returned as *Error.
+*/
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	return c.doHost(ctx, method, "", path, query, in, out)
+}
+
+/*
+doHost is do with the Host header set to host, if it is not empty.
+*/
+func (c *Client) doHost(ctx context.Context, method, host, path string, query url.Values, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+	u := *c.baseURL
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, host, u.String(), body)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			defer resp.Body.Close()
+			if out == nil || resp.StatusCode == http.StatusNoContent {
+				return nil
+			}
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return fmt.Errorf("decode response: %w", err)
+			}
+			return nil
+		}
+
+		var wait time.Duration
+		if err != nil {
+			if ctx.Err() != nil || !idempotent(method) {
+				return err
+			}
+		} else {
+			apiErr := readError(resp)
+			if !retryable(method, apiErr.StatusCode) {
+				return apiErr
+			}
+			err, wait = apiErr, apiErr.RetryAfter
+		}
+		if attempt >= c.maxRetries {
+			return err
+		}
+		if wait <= 0 {
+			wait = c.backoff(attempt)
+		}
+		// Give up early rather than sleep past the deadline of the call
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+/*
+send makes one attempt of a request.
+*/
+func (c *Client) send(ctx context.Context, method, host, target string, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, r)
+	if err != nil {
+		return nil, err
+	}
+	if host != "" {
+		req.Host = host
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.httpClient.Do(req)
+}
+
+/*
+backoff returns the delay before retry attempt+1: retryBase doubled per attempt, capped at
+retryMax, with up to 20% jitter so that clients do not retry in lockstep.
+*/
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.retryBase << min(attempt, 30)
+	if d <= 0 || d > c.retryMax {
+		d = c.retryMax
+	}
+	return d - time.Duration(rand.Int64N(int64(d)/5+1))
+}
+
+/*
+idempotent reports whether a request with the given method may be repeated without side effects.
+*/
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+retryable reports whether a response with the given status is worth retrying. Other requests
+than idempotent ones are only retried when the server rejected them before doing anything.
+*/
+func retryable(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent(method)
+	default:
+		return false
+	}
+}
+
+/*
+parseRetryAfter reads a Retry-After header in seconds or as an HTTP date, 0 if absent or invalid.
+*/
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
+
+/*
+readError builds the *Error of a failed response and closes its body.
+*/
+func readError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(b)),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	// Error responses end with the trace ID of the request
+	if msg, traceID, ok := strings.Cut(e.Message, " (trace_id: "); ok && strings.HasSuffix(traceID, ")") {
+		e.Message, e.TraceID = msg, strings.TrimSuffix(traceID, ")")
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
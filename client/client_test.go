@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c, err := New(srv.URL, WithToken("secret"), WithRetries(2, time.Millisecond, 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+func TestNewInvalidBaseURL(t *testing.T) {
+	for _, base := range []string{"", "sho.rt", "ftp://sho.rt", "http://"} {
+		if _, err := New(base); err == nil {
+			t.Errorf("expected an error for %q", base)
+		}
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	var first atomic.Int64
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("expected the token, got %q", r.Header.Get("Authorization"))
+		}
+		if calls.Add(1) == 1 {
+			first.Store(time.Now().UnixNano())
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		if waited := time.Since(time.Unix(0, first.Load())); waited < time.Second {
+			t.Errorf("expected the retry after Retry-After, got it after %s", waited)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"short":"abc123","original":"https://example.com"}`))
+	})
+
+	u, err := c.CreateURL(context.Background(), CreateRequest{Original: "https://example.com"})
+	if err != nil || u.Short != "abc123" {
+		t.Fatalf("expected the link after a retry, got %v, %v", u, err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 attempts, got %d", calls.Load())
+	}
+}
+
+func TestRetryOnlyIdempotentRequests(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	if _, err := c.CreateURL(context.Background(), CreateRequest{Original: "https://example.com"}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected a POST not to be retried, got %d attempts", calls.Load())
+	}
+
+	calls.Store(0)
+	if _, err := c.GetURL(context.Background(), "", "abc123"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected a GET to be tried 3 times, got %d", calls.Load())
+	}
+}
+
+func TestRetryGivesUpBeforeDeadline(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := c.ListURLs(ctx, ListOptions{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Minute {
+		t.Fatalf("expected the 429 with its Retry-After, got %v", err)
+	}
+	if calls.Load() != 1 || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("expected to give up at once, got %d attempts in %s", calls.Load(), time.Since(start))
+	}
+}
+
+func TestError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/urls/abc123/stats" || r.URL.Query().Get("domain") != "brand.link" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		http.Error(w, "URL not found (trace_id: 4bf92f3577b34da6a3ce929d0e0e4736)", http.StatusNotFound)
+	})
+
+	_, err := c.Stats(context.Background(), "brand.link", "abc123")
+	if !errors.Is(err, ErrNotFound) || errors.Is(err, ErrGone) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Message != "URL not found" || apiErr.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the message and trace ID, got %+v", apiErr)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 10, 30, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"-1", 0},
+		{"Thu, 30 Oct 2025 12:00:30 GMT", 30 * time.Second},
+		{"Thu, 30 Oct 2025 11:00:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
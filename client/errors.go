@@ -0,0 +1,71 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Errors matched by errors.Is against an *Error, by status code.
+var (
+	ErrBadRequest   = errors.New("bad request")         // 400, e.g. an empty URL or invalid rules
+	ErrUnauthorized = errors.New("unauthorized")        // 401, a missing or unknown token
+	ErrForbidden    = errors.New("forbidden")           // 403, a token without the needed scope or of another owner
+	ErrNotFound     = errors.New("not found")           // 404, e.g. an unknown short code
+	ErrConflict     = errors.New("conflict")            // 409, e.g. a domain that already exists
+	ErrGone         = errors.New("gone")                // 410, a link that used up its clicks
+	ErrBlocked      = errors.New("destination blocked") // 422, a destination on a blocklist
+	ErrRateLimited  = errors.New("rate limited")        // 429
+	ErrUnavailable  = errors.New("unavailable")         // 502 and 503
+	ErrTimeout      = errors.New("timeout")             // 504, the server gave up on the request
+)
+
+/*
+Error is a response of the API with a status code other than 2xx.
+*/
+type Error struct {
+	StatusCode int           // HTTP status code
+	Message    string        // Error message of the server, e.g. "URL not found"
+	TraceID    string        // Trace of the request on the server, for reporting the error
+	RetryAfter time.Duration // Delay asked for by the server with Retry-After, 0 if none
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	if e.TraceID != "" {
+		return fmt.Sprintf("%d %s: %s (trace_id: %s)", e.StatusCode, http.StatusText(e.StatusCode), e.Message, e.TraceID)
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+/*
+Is reports whether target is the sentinel error of the status code, so that
+errors.Is(err, client.ErrNotFound) works on any *Error.
+*/
+func (e *Error) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusGone:
+		return target == ErrGone
+	case http.StatusUnprocessableEntity:
+		return target == ErrBlocked
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return target == ErrUnavailable
+	case http.StatusGatewayTimeout:
+		return target == ErrTimeout
+	default:
+		return false
+	}
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+// Types of the API, shared with the server so that the JSON always matches.
+type (
+	URL               = model.URL
+	Rule              = model.Rule
+	Rules             = model.Rules
+	TimeWindow        = model.TimeWindow
+	Variant           = model.Variant
+	Variants          = model.Variants
+	RedirectOptions   = model.RedirectOptions
+	UTM               = model.UTM
+	Stats             = model.Stats
+	VariantStats      = model.VariantStats
+	LinkHealth        = model.LinkHealth
+	DestinationHealth = model.DestinationHealth
+	Metadata          = model.Metadata
+	Domain            = model.Domain
+	DomainDefaults    = model.DomainDefaults
+	APIKey            = model.APIKey
+	Webhook           = model.Webhook
+	WebhookDelivery   = model.WebhookDelivery
+	WebhookEvent      = model.WebhookEvent
+)
+
+/*
+CreateRequest is the body of CreateURL. Only Original is required.
+*/
+type CreateRequest struct {
+	Original  string          `json:"original"`
+	Domain    string          `json:"domain,omitempty"`    // Branded domain; the default domain if empty
+	Password  string          `json:"password,omitempty"`  // Visitors must enter it before being redirected
+	MaxClicks *int            `json:"maxClicks,omitempty"` // Redirect limit, nil if unlimited
+	Rules     Rules           `json:"rules,omitempty"`
+	Variants  Variants        `json:"variants,omitempty"`
+	Options   RedirectOptions `json:"options,omitzero"`
+}
+
+/*
+ListOptions select a page of ListURLs.
+*/
+type ListOptions struct {
+	Query  string // Text to search for in the original URL or short code; all links if empty
+	Limit  int    // Maximum number of links, the server default if 0
+	Offset int
+}
+
+/*
+URLList is a page of links with the total number of matches.
+*/
+type URLList struct {
+	URLs  []URL `json:"urls"`
+	Total int   `json:"total"`
+}
+
+/*
+LinkPreview is what visitors see of a link on its preview page.
+*/
+type LinkPreview struct {
+	Short        string    `json:"short"`
+	ShortURL     string    `json:"shortUrl,omitempty"`
+	Destinations []string  `json:"destinations,omitempty"` // Hidden while the link is locked with a password
+	CreatedAt    time.Time `json:"createdAt"`
+	Owner        string    `json:"owner,omitempty"`
+	Clicks       int       `json:"clicks"`
+	Protected    bool      `json:"protected,omitempty"`
+	Interstitial int       `json:"interstitial,omitempty"` // Seconds of the countdown shown before redirecting
+}
+
+/*
+CreatedAPIKey is a new API key with its secret, which is returned only once.
+*/
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+/*
+CreatedWebhook is a new webhook with its signing secret, which is returned only once.
+*/
+type CreatedWebhook struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+/*
+WebhookUpdate lists the fields of a webhook to change; nil fields are kept.
+*/
+type WebhookUpdate struct {
+	URL    *string  `json:"url,omitempty"`
+	Events []string `json:"events,omitempty"`
+	Active *bool    `json:"active,omitempty"`
+}
+
+/*
+Snapshot is a backup of the database.
+*/
+type Snapshot struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"sha256"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+/*
+ScanResult summarizes a rescan of all links against the blocklists.
+*/
+type ScanResult struct {
+	Scanned  int `json:"scanned"`
+	Disabled int `json:"disabled"`
+}
+
+/*
+HealthResult summarizes a round of link health checks.
+*/
+type HealthResult struct {
+	Checked   int `json:"checked"`
+	Broken    int `json:"broken"`
+	Recovered int `json:"recovered"`
+}
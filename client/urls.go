@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+/*
+CreateURL shortens a URL. Returns ErrBadRequest for invalid parameters and ErrBlocked
+for a destination on a blocklist.
+*/
+func (c *Client) CreateURL(ctx context.Context, req CreateRequest) (*URL, error) {
+	var u URL
+	if err := c.do(ctx, http.MethodPost, "/urls", nil, req, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+/*
+GetURL returns a link. An empty domain is the default domain.
+*/
+func (c *Client) GetURL(ctx context.Context, domain, short string) (*URL, error) {
+	var u URL
+	if err := c.do(ctx, http.MethodGet, linkPath(short, ""), domainQuery(domain), nil, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+/*
+ListURLs returns a page of links of all domains, newest first. It requires a token:
+admins get all links, other API keys only their own.
+*/
+func (c *Client) ListURLs(ctx context.Context, opts ListOptions) (*URLList, error) {
+	q := url.Values{}
+	if opts.Query != "" {
+		q.Set("q", opts.Query)
+	}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		q.Set("offset", strconv.Itoa(opts.Offset))
+	}
+	var list URLList
+	if err := c.do(ctx, http.MethodGet, "/urls", q, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+/*
+DeleteURL removes a link. Like every change of a link, it requires the token of its owner
+or an admin and returns ErrUnauthorized without a token and ErrForbidden with another one.
+*/
+func (c *Client) DeleteURL(ctx context.Context, domain, short string) error {
+	return c.do(ctx, http.MethodDelete, linkPath(short, ""), domainQuery(domain), nil, nil)
+}
+
+/*
+GetRules returns the redirect rules of a link in evaluation order.
+*/
+func (c *Client) GetRules(ctx context.Context, domain, short string) (Rules, error) {
+	var rules Rules
+	if err := c.do(ctx, http.MethodGet, linkPath(short, "/rules"), domainQuery(domain), nil, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+/*
+UpdateRules replaces the redirect rules of a link; no rules removes them all.
+*/
+func (c *Client) UpdateRules(ctx context.Context, domain, short string, rules Rules) (*URL, error) {
+	if rules == nil {
+		rules = Rules{}
+	}
+	return c.updateLink(ctx, domain, short, "/rules", rules)
+}
+
+/*
+UpdateVariants replaces the A/B variants of a link; no variants removes the split.
+*/
+func (c *Client) UpdateVariants(ctx context.Context, domain, short string, variants Variants) (*URL, error) {
+	if variants == nil {
+		variants = Variants{}
+	}
+	return c.updateLink(ctx, domain, short, "/variants", variants)
+}
+
+/*
+UpdateOptions replaces the redirect options of a link; the zero value turns them all off.
+*/
+func (c *Client) UpdateOptions(ctx context.Context, domain, short string, opts RedirectOptions) (*URL, error) {
+	return c.updateLink(ctx, domain, short, "/options", opts)
+}
+
+/*
+Stats returns the click counts of a link, including every A/B variant.
+*/
+func (c *Client) Stats(ctx context.Context, domain, short string) (*Stats, error) {
+	var s Stats
+	if err := c.do(ctx, http.MethodGet, linkPath(short, "/stats"), domainQuery(domain), nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+/*
+Health returns the result of the latest check of the destinations of a link.
+*/
+func (c *Client) Health(ctx context.Context, domain, short string) (*LinkHealth, error) {
+	var h LinkHealth
+	if err := c.do(ctx, http.MethodGet, linkPath(short, "/health"), domainQuery(domain), nil, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+/*
+Preview describes a link without following it or counting a click. Links of a branded
+domain are looked up by sending the domain as the Host header.
+*/
+func (c *Client) Preview(ctx context.Context, domain, short string) (*LinkPreview, error) {
+	var p LinkPreview
+	q := url.Values{"format": {"json"}}
+	if err := c.doHost(ctx, http.MethodGet, domain, "/"+url.PathEscape(short)+"+", q, nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+/*
+updateLink replaces one setting of a link with a PUT to /urls/{short}{suffix}.
+*/
+func (c *Client) updateLink(ctx context.Context, domain, short, suffix string, body any) (*URL, error) {
+	var u URL
+	if err := c.do(ctx, http.MethodPut, linkPath(short, suffix), domainQuery(domain), body, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+/*
+linkPath returns the path of a link under /urls, followed by suffix.
+*/
+func linkPath(short, suffix string) string {
+	return "/urls/" + url.PathEscape(short) + suffix
+}
+
+/*
+domainQuery returns the query selecting the domain of a link, empty for the default domain.
+*/
+func domainQuery(domain string) url.Values {
+	if domain == "" {
+		return nil
+	}
+	return url.Values{"domain": {domain}}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/backup"
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+/*
+runCommand executes a maintenance subcommand instead of starting the server:
+
+	url-shortener backup  [-db urls.db] [-dir backups] [-retain 7]
+	url-shortener verify  <snapshot>
+	url-shortener restore [-db urls.db] <snapshot>
+	url-shortener apikey  [-db urls.db] [-scopes admin] <name>
+*/
+func runCommand(cfg Config, name string, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	dbPath := fs.String("db", cfg.DBPath, "path to the SQLite database")
+
+	switch name {
+	case "backup":
+		dir := fs.String("dir", cfg.BackupDir, "directory to store snapshots in")
+		retain := fs.Int("retain", cfg.BackupRetain, "number of snapshots to keep (0 keeps all)")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		database, err := db.InitDB(*dbPath)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		snapshot, err := backup.NewManager(database, *dir, *retain).Create(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s  %s (%d bytes)\n", snapshot.Checksum, snapshot.Path, snapshot.Size)
+		return nil
+
+	case "verify":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: %s verify <snapshot>", os.Args[0])
+		}
+		if err := backup.Verify(ctx, fs.Arg(0)); err != nil {
+			return err
+		}
+		fmt.Printf("%s: OK\n", fs.Arg(0))
+		return nil
+
+	case "restore":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: %s restore [-db path] <snapshot>", os.Args[0])
+		}
+		if err := backup.Restore(ctx, fs.Arg(0), *dbPath); err != nil {
+			return err
+		}
+		fmt.Printf("restored %s from %s\n", *dbPath, fs.Arg(0))
+		return nil
+
+	case "apikey":
+		scopes := fs.String("scopes", auth.ScopeAdmin, "comma-separated scopes of the key")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: %s apikey [-db path] [-scopes admin] <name>", os.Args[0])
+		}
+
+		database, err := db.InitDB(*dbPath)
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		keys := service.NewAPIKeyService(database)
+		defer keys.Close()
+		key, plaintext, err := keys.CreateAPIKey(ctx, fs.Arg(0), strings.Split(*scopes, ","))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created API key %d (%s, scopes: %s); it will not be shown again:\n%s\n", key.ID, key.Name, key.Scopes, plaintext)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
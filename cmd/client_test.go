@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zen-flo/url-shortener/client"
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/handler"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+// Проверяем клиентский SDK на настоящем роутере: те же маршруты, JSON и коды ошибок, что и в продакшене
+func TestClient(t *testing.T) {
+	database, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to initialize the database: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	svc := service.NewURLService(database)
+	keys := service.NewAPIKeyService(database)
+	authn := auth.Chain(auth.StaticToken("secret"), keys)
+	srv := httptest.NewServer(NewRouter(handler.NewURLHandler(svc),
+		WithAdmin(authn, handler.NewAPIKeyHandler(keys), handler.NewDomainHandler(svc), handler.NewWebhookHandler(svc)),
+		WithRedirect(handler.NewRedirectHandler(svc, auth.NewSigner(nil))),
+	))
+	t.Cleanup(srv.Close)
+
+	ctx := context.Background()
+	c, err := client.New(srv.URL, client.WithToken("secret"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	created, err := c.CreateURL(ctx, client.CreateRequest{Original: "https://example.com"})
+	if err != nil || created.Short == "" || created.Owner != "admin-token" {
+		t.Fatalf("CreateURL: %+v, %v", created, err)
+	}
+	if _, err := c.CreateURL(ctx, client.CreateRequest{}); !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("expected ErrBadRequest for an empty URL, got %v", err)
+	}
+
+	got, err := c.GetURL(ctx, "", created.Short)
+	if err != nil || got.Original != "https://example.com" {
+		t.Fatalf("GetURL: %+v, %v", got, err)
+	}
+
+	list, err := c.ListURLs(ctx, client.ListOptions{Query: "example.com", Limit: 10})
+	if err != nil || list.Total != 1 || len(list.URLs) != 1 {
+		t.Fatalf("ListURLs: %+v, %v", list, err)
+	}
+
+	rules := client.Rules{{Destination: "https://example.com/ios", OS: []string{"ios"}}}
+	if _, err := c.UpdateRules(ctx, "", created.Short, rules); err != nil {
+		t.Fatalf("UpdateRules: %v", err)
+	}
+	if got, err := c.GetRules(ctx, "", created.Short); err != nil || len(got) != 1 || got[0].Destination != "https://example.com/ios" {
+		t.Errorf("GetRules: %+v, %v", got, err)
+	}
+
+	updated, err := c.UpdateOptions(ctx, "", created.Short, client.RedirectOptions{PassPath: true})
+	if err != nil || !updated.Options.PassPath {
+		t.Errorf("UpdateOptions: %+v, %v", updated, err)
+	}
+
+	if stats, err := c.Stats(ctx, "", created.Short); err != nil || stats.Short != created.Short {
+		t.Errorf("Stats: %+v, %v", stats, err)
+	}
+	if preview, err := c.Preview(ctx, "", created.Short); err != nil || len(preview.Destinations) != 2 {
+		t.Errorf("Preview: %+v, %v", preview, err)
+	}
+
+	// API администратора
+	if _, err := c.CreateDomain(ctx, client.Domain{Name: "brand.link"}); err != nil {
+		t.Fatalf("CreateDomain: %v", err)
+	}
+	if _, err := c.CreateDomain(ctx, client.Domain{Name: "brand.link"}); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("expected ErrConflict for an existing domain, got %v", err)
+	}
+	branded, err := c.CreateURL(ctx, client.CreateRequest{Original: "https://example.com/brand", Domain: "brand.link"})
+	if err != nil {
+		t.Fatalf("CreateURL on a branded domain: %v", err)
+	}
+	if got, err := c.GetURL(ctx, "brand.link", branded.Short); err != nil || got.Domain != "brand.link" {
+		t.Errorf("GetURL on a branded domain: %+v, %v", got, err)
+	}
+
+	key, err := c.CreateAPIKey(ctx, "marketing", []string{"links"})
+	if err != nil || key.Key == "" {
+		t.Fatalf("CreateAPIKey: %+v, %v", key, err)
+	}
+	hook, err := c.CreateWebhook(ctx, "https://crm.example.com/hooks", []string{"link.deleted"})
+	if err != nil || hook.Secret == "" {
+		t.Fatalf("CreateWebhook: %+v, %v", hook, err)
+	}
+
+	// Ключ без scope admin может создавать ссылки, но не пользоваться API администратора
+	marketing, _ := client.New(srv.URL, client.WithToken(key.Key))
+	if own, err := marketing.CreateURL(ctx, client.CreateRequest{Original: "https://example.com/promo"}); err != nil || own.Owner != "marketing" {
+		t.Errorf("expected the key name as the owner, got %+v, %v", own, err)
+	}
+	if _, err := marketing.ListWebhooks(ctx); !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("expected ErrForbidden without the admin scope, got %v", err)
+	}
+	anonymous, _ := client.New(srv.URL)
+	if _, err := anonymous.ListDomains(ctx); !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized without a token, got %v", err)
+	}
+
+	if err := c.DeleteURL(ctx, "", created.Short); err != nil {
+		t.Fatalf("DeleteURL: %v", err)
+	}
+	if _, err := c.GetURL(ctx, "", created.Short); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after deletion, got %v", err)
+	}
+	deliveries, err := c.ListWebhookDeliveries(ctx, hook.ID, model.DeliveryPending, 0)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Event != "link.deleted" {
+		t.Errorf("expected the deletion to be queued for the webhook, got %+v, %v", deliveries, err)
+	}
+}
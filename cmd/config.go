@@ -0,0 +1,379 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Config holds the server settings read from environment variables.
+*/
+type Config struct {
+	Port           int           // PORT
+	BaseURL        string        // BASE_URL, scheme and host of short links on the default domain
+	DBPath         string        // DB_PATH
+	DBReadConns    int           // DB_READ_CONNS, size of the read-only connection pool
+	RequestTimeout time.Duration // REQUEST_TIMEOUT, e.g. "5s"
+	TraceExporter  string        // TRACES_EXPORTER: none, stdout or otlp
+	ServiceName    string        // OTEL_SERVICE_NAME
+	LogLevel       string        // LOG_LEVEL: debug, info, warn or error
+	LogFormat      string        // LOG_FORMAT: json or text
+	GeoIPDB        string        // GEOIP_DB, .mmdb or .csv country database for redirect rules
+
+	CountReconcileInterval time.Duration // COUNT_RECONCILE_INTERVAL, full recount of the urls table
+	HealthCheckTimeout     time.Duration // HEALTH_CHECK_TIMEOUT, per readiness check
+	ShutdownDrainDelay     time.Duration // SHUTDOWN_DRAIN_DELAY, readiness fails this long before listeners close
+	ShutdownTimeout        time.Duration // SHUTDOWN_TIMEOUT, limit for in-flight requests to finish
+
+	AdminToken     string        // ADMIN_TOKEN, bootstrap token with the admin scope; empty disables it
+	SessionSecret  string        // SESSION_SECRET, signs dashboard and unlocked-link cookies; random per process if empty
+	SessionTTL     time.Duration // SESSION_TTL, lifetime of a dashboard session
+	BackupDir      string        // BACKUP_DIR
+	BackupInterval time.Duration // BACKUP_INTERVAL, 0 disables scheduled backups
+	BackupRetain   int           // BACKUP_RETAIN, number of snapshots to keep
+
+	HTTPSPort         int           // HTTPS_PORT, used when TLS is enabled; PORT then only redirects to HTTPS
+	TLSCertFile       string        // TLS_CERT_FILE, PEM certificate chain
+	TLSKeyFile        string        // TLS_KEY_FILE, PEM private key
+	TLSReloadInterval time.Duration // TLS_RELOAD_INTERVAL, how often the files are checked for changes
+	ACME              bool          // TLS_ACME, obtain certificates automatically instead of using files
+	ACMEDomains       []string      // ACME_DOMAINS, comma-separated hosts besides the registered branded domains
+	ACMEDirectoryURL  string        // ACME_DIRECTORY_URL, empty means Let's Encrypt
+	ACMECARoots       string        // ACME_CA_ROOTS, PEM bundle trusted for the directory, e.g. of a local Pebble
+	ACMEEmail         string        // ACME_EMAIL, contact for expiry notices
+	ACMECacheDir      string        // ACME_CACHE_DIR, account key and issued certificates
+	HSTSMaxAge        time.Duration // HSTS_MAX_AGE, 0 disables the Strict-Transport-Security header
+
+	GRPCPort int // GRPC_PORT, gRPC API with the TLS settings of the HTTPS server; 0 disables it
+
+	ClickFeedBuffer int // CLICK_FEED_BUFFER, clicks queued for a live feed client before it is dropped as too slow
+
+	ScreenBlocklists     []string          // SCREEN_BLOCKLISTS, comma-separated blocklist files
+	ScreenStub           map[string]string // SCREEN_STUB, "host=threat" pairs reported by the stub reputation provider
+	ScreenInterval       time.Duration     // SCREEN_INTERVAL, rescan of existing links, 0 disables it
+	ScreenReloadInterval time.Duration     // SCREEN_RELOAD_INTERVAL, how often blocklist files are checked for changes
+
+	MetadataWorkers int           // METADATA_WORKERS, concurrent fetches of destination pages, 0 disables them
+	MetadataTimeout time.Duration // METADATA_TIMEOUT, limit for fetching one page
+
+	LinkCheckInterval      time.Duration     // LINKCHECK_INTERVAL, health checks of link destinations, 0 disables them
+	LinkCheckConcurrency   int               // LINKCHECK_CONCURRENCY, destinations checked at a time
+	LinkCheckHostDelay     time.Duration     // LINKCHECK_HOST_DELAY, pause between requests to one host
+	LinkCheckTimeout       time.Duration     // LINKCHECK_TIMEOUT, limit for one request
+	LinkCheckFailures      int               // LINKCHECK_FAILURES, consecutive failed checks before a link is broken
+	LinkCheckWebhook       string            // LINKCHECK_WEBHOOK, receives every broken and recovered notification
+	LinkCheckOwnerWebhooks map[string]string // LINKCHECK_OWNER_WEBHOOKS, "owner=url" pairs for notifications about an owner's links
+
+	WebhookPollInterval time.Duration // WEBHOOK_POLL_INTERVAL, how often due webhook deliveries are looked for
+	WebhookWorkers      int           // WEBHOOK_WORKERS, webhook requests sent at a time
+	WebhookTimeout      time.Duration // WEBHOOK_TIMEOUT, limit for one webhook request
+	WebhookAllowPrivate bool          // WEBHOOK_ALLOW_PRIVATE, deliver to loopback and private addresses, e.g. in local setups
+	WebhookMaxAttempts  int           // WEBHOOK_MAX_ATTEMPTS, attempts before a delivery is dead
+	WebhookRetryBase    time.Duration // WEBHOOK_RETRY_BASE, delay before the first retry, doubled for every further one
+	WebhookRetryMax     time.Duration // WEBHOOK_RETRY_MAX, longest delay between retries
+	WebhookRetention    time.Duration // WEBHOOK_RETENTION, how long delivered deliveries are kept
+	WebhookBacklogMax   int           // WEBHOOK_BACKLOG_MAX, pending deliveries above which the instance is not ready
+	WebhookBacklogDelay time.Duration // WEBHOOK_BACKLOG_DELAY, how long a due delivery may wait before the instance is not ready
+}
+
+/*
+TLSEnabled reports whether the server serves HTTPS.
+*/
+func (c Config) TLSEnabled() bool {
+	return c.ACME || c.TLSCertFile != ""
+}
+
+/*
+loadConfig reads the configuration from the environment, falling back to defaults.
+*/
+func loadConfig() (Config, error) {
+	cfg := Config{
+		Port:           8080,
+		DBPath:         "urls.db",
+		DBReadConns:    4,
+		RequestTimeout: 5 * time.Second,
+		TraceExporter:  "none",
+		ServiceName:    "url-shortener",
+		LogLevel:       "info",
+		LogFormat:      "json",
+
+		CountReconcileInterval: time.Hour,
+		HealthCheckTimeout:     2 * time.Second,
+		ShutdownDrainDelay:     5 * time.Second,
+		ShutdownTimeout:        15 * time.Second,
+
+		SessionTTL:   12 * time.Hour,
+		BackupDir:    "backups",
+		BackupRetain: 7,
+
+		HTTPSPort:         8443,
+		TLSReloadInterval: time.Minute,
+		ACMECacheDir:      "acme-cache",
+		HSTSMaxAge:        365 * 24 * time.Hour,
+
+		GRPCPort: 9090,
+
+		ClickFeedBuffer: 64,
+
+		ScreenInterval:       24 * time.Hour,
+		ScreenReloadInterval: time.Minute,
+
+		MetadataWorkers: 2,
+		MetadataTimeout: 5 * time.Second,
+
+		LinkCheckInterval:    24 * time.Hour,
+		LinkCheckConcurrency: 8,
+		LinkCheckHostDelay:   time.Second,
+		LinkCheckTimeout:     10 * time.Second,
+		LinkCheckFailures:    2,
+
+		WebhookPollInterval: 5 * time.Second,
+		WebhookWorkers:      4,
+		WebhookTimeout:      10 * time.Second,
+		WebhookMaxAttempts:  10,
+		WebhookRetryBase:    30 * time.Second,
+		WebhookRetryMax:     6 * time.Hour,
+		WebhookRetention:    7 * 24 * time.Hour,
+		WebhookBacklogMax:   10000,
+		WebhookBacklogDelay: 5 * time.Minute,
+	}
+
+	if v := os.Getenv("PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid PORT %q: %w", v, err)
+		}
+		cfg.Port = port
+	}
+
+	if v := os.Getenv("DB_PATH"); v != "" {
+		cfg.DBPath = v
+	}
+
+	if v := os.Getenv("DB_READ_CONNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("invalid DB_READ_CONNS %q", v)
+		}
+		cfg.DBReadConns = n
+	}
+
+	if err := parseDurationEnv("REQUEST_TIMEOUT", &cfg.RequestTimeout); err != nil {
+		return cfg, err
+	}
+
+	if v := os.Getenv("TRACES_EXPORTER"); v != "" {
+		cfg.TraceExporter = v
+	}
+
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		cfg.ServiceName = v
+	}
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		cfg.LogLevel = v
+	}
+
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		cfg.LogFormat = v
+	}
+
+	cfg.GeoIPDB = os.Getenv("GEOIP_DB")
+
+	cfg.AdminToken = os.Getenv("ADMIN_TOKEN")
+	cfg.SessionSecret = os.Getenv("SESSION_SECRET")
+
+	if v := os.Getenv("BACKUP_DIR"); v != "" {
+		cfg.BackupDir = v
+	}
+
+	if v := os.Getenv("BACKUP_RETAIN"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid BACKUP_RETAIN %q", v)
+		}
+		cfg.BackupRetain = n
+	}
+
+	if v := os.Getenv("HTTPS_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid HTTPS_PORT %q: %w", v, err)
+		}
+		cfg.HTTPSPort = port
+	}
+
+	cfg.TLSCertFile = os.Getenv("TLS_CERT_FILE")
+	cfg.TLSKeyFile = os.Getenv("TLS_KEY_FILE")
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return cfg, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	if v := os.Getenv("WEBHOOK_ALLOW_PRIVATE"); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid WEBHOOK_ALLOW_PRIVATE %q", v)
+		}
+		cfg.WebhookAllowPrivate = allow
+	}
+
+	if v := os.Getenv("TLS_ACME"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid TLS_ACME %q", v)
+		}
+		cfg.ACME = enabled
+	}
+	if cfg.ACME && cfg.TLSCertFile != "" {
+		return cfg, fmt.Errorf("TLS_ACME cannot be combined with TLS_CERT_FILE")
+	}
+	for _, host := range strings.Split(os.Getenv("ACME_DOMAINS"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			cfg.ACMEDomains = append(cfg.ACMEDomains, strings.ToLower(host))
+		}
+	}
+	cfg.ACMEDirectoryURL = os.Getenv("ACME_DIRECTORY_URL")
+	cfg.ACMECARoots = os.Getenv("ACME_CA_ROOTS")
+	cfg.ACMEEmail = os.Getenv("ACME_EMAIL")
+	if v := os.Getenv("ACME_CACHE_DIR"); v != "" {
+		cfg.ACMECacheDir = v
+	}
+
+	if v := os.Getenv("GRPC_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port < 0 {
+			return cfg, fmt.Errorf("invalid GRPC_PORT %q", v)
+		}
+		cfg.GRPCPort = port
+	}
+
+	cfg.BaseURL = fmt.Sprintf("http://localhost:%d", cfg.Port)
+	if cfg.TLSEnabled() {
+		cfg.BaseURL = fmt.Sprintf("https://localhost:%d", cfg.HTTPSPort)
+	}
+	if v := os.Getenv("BASE_URL"); v != "" {
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return cfg, fmt.Errorf("invalid BASE_URL %q, expected e.g. https://sho.rt", v)
+		}
+		cfg.BaseURL = v
+	}
+
+	for _, path := range strings.Split(os.Getenv("SCREEN_BLOCKLISTS"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			cfg.ScreenBlocklists = append(cfg.ScreenBlocklists, path)
+		}
+	}
+	for _, pair := range strings.Split(os.Getenv("SCREEN_STUB"), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		host, threat, ok := strings.Cut(pair, "=")
+		if !ok || host == "" || threat == "" {
+			return cfg, fmt.Errorf("invalid SCREEN_STUB entry %q, expected host=threat", pair)
+		}
+		if cfg.ScreenStub == nil {
+			cfg.ScreenStub = make(map[string]string)
+		}
+		cfg.ScreenStub[strings.ToLower(host)] = threat
+	}
+
+	if v := os.Getenv("METADATA_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid METADATA_WORKERS %q", v)
+		}
+		cfg.MetadataWorkers = n
+	}
+
+	counts := []struct {
+		env  string
+		dest *int
+	}{
+		{"CLICK_FEED_BUFFER", &cfg.ClickFeedBuffer},
+		{"LINKCHECK_CONCURRENCY", &cfg.LinkCheckConcurrency},
+		{"LINKCHECK_FAILURES", &cfg.LinkCheckFailures},
+		{"WEBHOOK_WORKERS", &cfg.WebhookWorkers},
+		{"WEBHOOK_MAX_ATTEMPTS", &cfg.WebhookMaxAttempts},
+		{"WEBHOOK_BACKLOG_MAX", &cfg.WebhookBacklogMax},
+	}
+	for _, c := range counts {
+		if v := os.Getenv(c.env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return cfg, fmt.Errorf("invalid %s %q", c.env, v)
+			}
+			*c.dest = n
+		}
+	}
+	cfg.LinkCheckWebhook = os.Getenv("LINKCHECK_WEBHOOK")
+	for _, pair := range strings.Split(os.Getenv("LINKCHECK_OWNER_WEBHOOKS"), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		owner, webhook, ok := strings.Cut(pair, "=")
+		if !ok || owner == "" || webhook == "" {
+			return cfg, fmt.Errorf("invalid LINKCHECK_OWNER_WEBHOOKS entry %q, expected owner=url", pair)
+		}
+		if cfg.LinkCheckOwnerWebhooks == nil {
+			cfg.LinkCheckOwnerWebhooks = make(map[string]string)
+		}
+		cfg.LinkCheckOwnerWebhooks[owner] = webhook
+	}
+
+	durations := []struct {
+		env  string
+		dest *time.Duration
+	}{
+		{"COUNT_RECONCILE_INTERVAL", &cfg.CountReconcileInterval},
+		{"HEALTH_CHECK_TIMEOUT", &cfg.HealthCheckTimeout},
+		{"SHUTDOWN_DRAIN_DELAY", &cfg.ShutdownDrainDelay},
+		{"SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout},
+		{"BACKUP_INTERVAL", &cfg.BackupInterval},
+		{"SESSION_TTL", &cfg.SessionTTL},
+		{"TLS_RELOAD_INTERVAL", &cfg.TLSReloadInterval},
+		{"HSTS_MAX_AGE", &cfg.HSTSMaxAge},
+		{"SCREEN_INTERVAL", &cfg.ScreenInterval},
+		{"SCREEN_RELOAD_INTERVAL", &cfg.ScreenReloadInterval},
+		{"METADATA_TIMEOUT", &cfg.MetadataTimeout},
+		{"LINKCHECK_INTERVAL", &cfg.LinkCheckInterval},
+		{"LINKCHECK_HOST_DELAY", &cfg.LinkCheckHostDelay},
+		{"LINKCHECK_TIMEOUT", &cfg.LinkCheckTimeout},
+		{"WEBHOOK_POLL_INTERVAL", &cfg.WebhookPollInterval},
+		{"WEBHOOK_TIMEOUT", &cfg.WebhookTimeout},
+		{"WEBHOOK_RETRY_BASE", &cfg.WebhookRetryBase},
+		{"WEBHOOK_RETRY_MAX", &cfg.WebhookRetryMax},
+		{"WEBHOOK_RETENTION", &cfg.WebhookRetention},
+		{"WEBHOOK_BACKLOG_DELAY", &cfg.WebhookBacklogDelay},
+	}
+	for _, d := range durations {
+		if err := parseDurationEnv(d.env, d.dest); err != nil {
+			return cfg, err
+		}
+	}
+	if cfg.WebhookPollInterval == 0 {
+		return cfg, fmt.Errorf("invalid WEBHOOK_POLL_INTERVAL, must be positive")
+	}
+
+	return cfg, nil
+}
+
+/*
+parseDurationEnv overrides dest with the duration in the named variable, if set.
+*/
+func parseDurationEnv(env string, dest *time.Duration) error {
+	v := os.Getenv(env)
+	if v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid %s %q", env, v)
+	}
+	*dest = d
+	return nil
+}
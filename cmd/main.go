@@ -1,12 +1,36 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	_ "github.com/zen-flo/url-shortener/docs" // docs are generated by Swag CLI
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/backup"
+	"github.com/zen-flo/url-shortener/internal/certs"
+	"github.com/zen-flo/url-shortener/internal/dashboard"
 	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/geoip"
+	"github.com/zen-flo/url-shortener/internal/grpcapi"
 	"github.com/zen-flo/url-shortener/internal/handler"
+	"github.com/zen-flo/url-shortener/internal/health"
+	"github.com/zen-flo/url-shortener/internal/linkcheck"
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/metadata"
+	"github.com/zen-flo/url-shortener/internal/rules"
+	"github.com/zen-flo/url-shortener/internal/screening"
 	"github.com/zen-flo/url-shortener/internal/service"
+	"github.com/zen-flo/url-shortener/internal/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -17,39 +41,440 @@ Main function starts the HTTP server for the URL Shortener API.
 // @title URL Shortener API
 // @version 1.0
 // @description Simple REST API for shortening URLs.
-// @host localhost:8080
 // @BasePath /
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
+// @description Admin token or API key with the admin scope, in the form "Bearer <token>"
 func main() {
+	if len(os.Args) > 1 {
+		cfg, err := loadConfig()
+		if err == nil {
+			err = runCommand(cfg, os.Args[1], os.Args[2:])
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := run(); err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+/*
+run wires the dependencies together and serves HTTP until the server fails
+or a termination signal triggers a graceful shutdown.
+*/
+func run() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	// Initialize logging
+	log, err := logger.New(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		return fmt.Errorf("init logger: %w", err)
+	}
+	slog.SetDefault(log)
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.TraceExporter,
+		ServiceName: cfg.ServiceName,
+	})
+	if err != nil {
+		return fmt.Errorf("init tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("failed to flush traces", "error", err)
+		}
+	}()
+
 	// Initialize database
-	database := db.InitDB("urls.db")
+	database, err := db.InitDB(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("init database: %w", err)
+	}
 	defer func() {
 		if err := database.Close(); err != nil {
-			fmt.Printf("Error closing database: %v\n", err)
+			log.Error("failed to close database", "error", err)
 		}
 	}()
+	log.Info("database initialized", "path", cfg.DBPath)
+
+	serviceOpts := []service.Option{service.WithBaseURL(cfg.BaseURL)}
+	if cfg.DBPath != ":memory:" {
+		reader, err := db.OpenReader(cfg.DBPath, cfg.DBReadConns)
+		if err != nil {
+			return fmt.Errorf("open read pool: %w", err)
+		}
+		defer func() {
+			if err := reader.Close(); err != nil {
+				log.Error("failed to close read pool", "error", err)
+			}
+		}()
+		serviceOpts = append(serviceOpts, service.WithReader(reader))
+	}
+
+	// Destination screening
+	var providers []screening.Provider
+	var blocklist *screening.Blocklist
+	if len(cfg.ScreenBlocklists) > 0 {
+		blocklist, err = screening.LoadBlocklist(cfg.ScreenBlocklists...)
+		if err != nil {
+			return err
+		}
+		providers = append(providers, blocklist)
+		log.Info("blocklists loaded", "files", cfg.ScreenBlocklists)
+	}
+	if len(cfg.ScreenStub) > 0 {
+		providers = append(providers, &screening.Stub{Threats: cfg.ScreenStub})
+		log.Warn("stub reputation provider enabled", "hosts", len(cfg.ScreenStub))
+	}
+	if len(providers) > 0 {
+		serviceOpts = append(serviceOpts, service.WithScreener(screening.New(providers...)))
+	}
+
+	// Title, description and image of destination pages
+	if cfg.MetadataWorkers > 0 {
+		serviceOpts = append(serviceOpts, service.WithMetadata(metadata.New(metadata.Config{Timeout: cfg.MetadataTimeout})))
+	}
+
+	// Health checks of link destinations
+	checker := linkcheck.New(linkcheck.Config{
+		Timeout:     cfg.LinkCheckTimeout,
+		Concurrency: cfg.LinkCheckConcurrency,
+		HostDelay:   cfg.LinkCheckHostDelay,
+	})
+	serviceOpts = append(serviceOpts, service.WithLinkChecker(checker, cfg.LinkCheckFailures))
+	if cfg.LinkCheckWebhook != "" || len(cfg.LinkCheckOwnerWebhooks) > 0 {
+		notifier := linkcheck.NewWebhook(cfg.LinkCheckWebhook, cfg.LinkCheckOwnerWebhooks, cfg.LinkCheckTimeout)
+		serviceOpts = append(serviceOpts, service.WithHealthNotifier(notifier))
+	}
+
+	// Delivery of link events to webhook subscriptions
+	serviceOpts = append(serviceOpts, service.WithWebhooks(service.WebhookConfig{
+		Timeout:      cfg.WebhookTimeout,
+		AllowPrivate: cfg.WebhookAllowPrivate,
+		Workers:      cfg.WebhookWorkers,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		RetryBase:    cfg.WebhookRetryBase,
+		RetryMax:     cfg.WebhookRetryMax,
+		Retention:    cfg.WebhookRetention,
+	}))
+
+	// Live feeds of clicks over SSE, WebSocket and gRPC
+	serviceOpts = append(serviceOpts, service.WithClickFeed(cfg.ClickFeedBuffer))
 
 	// Initialize service and handler
-	urlService := service.NewURLService(database)
+	urlService := service.NewURLService(database, serviceOpts...)
+	defer func() {
+		if err := urlService.Close(); err != nil {
+			log.Error("failed to close prepared statements", "error", err)
+		}
+	}()
+
+	// Background loops stop on a termination signal and are joined before the service and the database are closed
+	var background sync.WaitGroup
+	defer background.Wait()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Blocklists are reloaded when their files change
+	if blocklist != nil && cfg.ScreenReloadInterval > 0 {
+		background.Go(func() { blocklist.Watch(ctx, cfg.ScreenReloadInterval) })
+	}
+
+	// Metadata of new destinations is fetched by a small worker pool
+	background.Go(func() { urlService.RunMetadataFetcher(ctx, cfg.MetadataWorkers) })
+
+	// Pending webhook deliveries are sent and retried in the background
+	background.Go(func() { urlService.RunWebhookDispatcher(ctx, cfg.WebhookPollInterval) })
+
 	urlHandler := handler.NewURLHandler(urlService)
+	urlHandler.Timeout = cfg.RequestTimeout
+	redirectHandler := handler.NewRedirectHandler(urlService, auth.NewSigner([]byte(cfg.SessionSecret)))
+	redirectHandler.Timeout = cfg.RequestTimeout
+	if cfg.GeoIPDB != "" {
+		countries, err := geoip.Open(cfg.GeoIPDB)
+		if err != nil {
+			return err
+		}
+		defer countries.Close()
+		redirectHandler.Rules = rules.NewEngine(countries)
+		log.Info("GeoIP database loaded", "path", cfg.GeoIPDB)
+	}
+
+	// Scheduled backups
+	backups := backup.NewManager(database, cfg.BackupDir, cfg.BackupRetain)
+	if cfg.BackupInterval > 0 {
+		background.Go(func() {
+			ticker := time.NewTicker(cfg.BackupInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				ctx, cancel := context.WithTimeout(ctx, cfg.BackupInterval)
+				snapshot, err := backups.Create(ctx)
+				cancel()
+				if err != nil {
+					log.Error("scheduled backup failed", "error", err)
+					continue
+				}
+				log.Info("scheduled backup created", "name", snapshot.Name, "size", snapshot.Size)
+			}
+		})
+	}
+
+	// Periodic rescan of existing links, e.g. against updated blocklists
+	if len(providers) > 0 && cfg.ScreenInterval > 0 {
+		background.Go(func() {
+			ticker := time.NewTicker(cfg.ScreenInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				ctx, cancel := context.WithTimeout(ctx, cfg.ScreenInterval)
+				result, err := urlService.Rescan(ctx)
+				cancel()
+				if err != nil {
+					log.Error("destination rescan failed", "error", err)
+					continue
+				}
+				log.Info("destination rescan finished", "scanned", result.Scanned, "disabled", result.Disabled)
+			}
+		})
+	}
+
+	// Periodic health checks of link destinations
+	if cfg.LinkCheckInterval > 0 {
+		background.Go(func() {
+			ticker := time.NewTicker(cfg.LinkCheckInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				ctx, cancel := context.WithTimeout(ctx, cfg.LinkCheckInterval)
+				result, err := urlService.CheckLinks(ctx)
+				cancel()
+				if err != nil {
+					log.Error("link health check failed", "error", err)
+					continue
+				}
+				log.Info("link health check finished", "checked", result.Checked, "broken", result.Broken, "recovered", result.Recovered)
+			}
+		})
+	}
+
+	// API keys, the admin token and dashboard sessions
+	apiKeys := service.NewAPIKeyService(database)
+	defer func() {
+		if err := apiKeys.Close(); err != nil {
+			log.Error("failed to close prepared statements", "error", err)
+		}
+	}()
+	authn := auth.Chain(auth.StaticToken(cfg.AdminToken), apiKeys)
+	if cfg.SessionSecret == "" {
+		log.Warn("SESSION_SECRET is not set, dashboard sessions and unlocked links will not survive a restart")
+	}
+	sessions := auth.NewSessions([]byte(cfg.SessionSecret), "admin_session", "/admin/ui", cfg.SessionTTL)
+
+	// Register readiness checks
+	healthRegistry := health.NewRegistry(cfg.HealthCheckTimeout)
+	healthRegistry.Register("database", health.DBCheck(database))
+	healthRegistry.Register("migrations", health.MigrationCheck(database))
+	healthRegistry.Register("webhooks", health.WebhookBacklogCheck(urlService, cfg.WebhookBacklogMax, cfg.WebhookBacklogDelay))
 
 	// Create router
-	r := NewRouter(urlHandler)
+	r := NewRouter(urlHandler,
+		WithHealth(healthRegistry),
+		WithRedirect(redirectHandler),
+		WithAdmin(authn, handler.NewBackupHandler(backups), handler.NewAPIKeyHandler(apiKeys), handler.NewDomainHandler(urlService), handler.NewScreeningHandler(urlService), handler.NewLinkCheckHandler(urlService), handler.NewWebhookHandler(urlService), handler.NewEventsHandler(urlService)),
+		WithDashboard(dashboard.New(urlService, apiKeys, authn, sessions)),
+		WithHSTS(cfg.HSTSMaxAge),
+	)
 
-	// Start background metrics updater
-	go func() {
+	// Start background metrics updater.
+	// Gauges are refreshed from the incrementally maintained counters every minute,
+	// while the full recount runs on the much longer reconcile interval.
+	background.Go(func() {
 		ticker := time.NewTicker(time.Minute)
 		defer ticker.Stop()
-		for range ticker.C {
-			urlService.UpdateURLCount()
+		reconcile := time.NewTicker(cfg.CountReconcileInterval)
+		defer reconcile.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(ctx, cfg.RequestTimeout)
+				urlService.UpdateURLCount(ctx)
+				cancel()
+			case <-reconcile.C:
+				ctx, cancel := context.WithTimeout(ctx, time.Minute)
+				if err := urlService.ReconcileURLCount(ctx); err != nil {
+					log.Error("failed to reconcile URL count", "error", err)
+				}
+				cancel()
+			}
 		}
-	}()
+	})
+
+	servers, err := newServers(ctx, cfg, r, urlService)
+	if err != nil {
+		return err
+	}
+
+	// Start HTTP servers
+	errCh := make(chan error, len(servers)+1)
+	for _, srv := range servers {
+		go func() {
+			var err error
+			if srv.TLSConfig != nil {
+				log.Info("starting HTTPS server", "addr", srv.Addr)
+				err = srv.ListenAndServeTLS("", "")
+			} else {
+				log.Info("starting server", "addr", srv.Addr)
+				err = srv.ListenAndServe()
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- fmt.Errorf("serve %s: %w", srv.Addr, err)
+			}
+		}()
+	}
+
+	// Start the gRPC API on its own port, with the same service and credentials as the REST API
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort > 0 {
+		var opts []grpc.ServerOption
+		if tlsConfig := servers[0].TLSConfig; tlsConfig != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig.Clone())))
+		}
+		grpcAPI := grpcapi.New(urlService)
+		grpcAPI.Timeout = cfg.RequestTimeout
+		grpcServer = grpcapi.NewGRPCServer(grpcAPI, authn, opts...)
+
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+		if err != nil {
+			return fmt.Errorf("listen on gRPC port: %w", err)
+		}
+		go func() {
+			log.Info("starting gRPC server", "addr", lis.Addr().String())
+			if err := grpcServer.Serve(lis); err != nil {
+				errCh <- fmt.Errorf("serve gRPC: %w", err)
+			}
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
 
-	// Server port
-	port := 8080
-	fmt.Printf("Starting server on port %d...\n", port)
+	// Fail readiness first and give load balancers time to notice before closing listeners
+	log.Info("shutting down", "drain_delay", cfg.ShutdownDrainDelay)
+	healthRegistry.SetDraining(true)
+	time.Sleep(cfg.ShutdownDrainDelay)
 
-	// Start HTTP server
-	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), r); err != nil {
-		fmt.Printf("Error starting server: %v\n", err)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	// Click feeds never end on their own, so their clients are told to reconnect elsewhere
+	urlService.Clicks.Close()
+	grpcStopped := make(chan struct{})
+	if grpcServer != nil {
+		go func() {
+			grpcServer.GracefulStop()
+			close(grpcStopped)
+		}()
+	} else {
+		close(grpcStopped)
+	}
+	// Streams that are still sending are cut off at the shutdown timeout
+	stopGRPC := func() {
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
 	}
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			stopGRPC()
+			return fmt.Errorf("shutdown server %s: %w", srv.Addr, err)
+		}
+	}
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		stopGRPC()
+	}
+	log.Info("server stopped")
+	return nil
+}
+
+/*
+newServers returns the servers to run. Without TLS a single plain HTTP server serves
+the router. With TLS the router is served over HTTPS, while the plain HTTP port
+redirects to it and answers ACME http-01 challenges.
+*/
+func newServers(ctx context.Context, cfg Config, r http.Handler, urlService *service.URLService) ([]*http.Server, error) {
+	plain := &http.Server{Addr: fmt.Sprintf(":%d", cfg.Port), Handler: r}
+	if !cfg.TLSEnabled() {
+		return []*http.Server{plain}, nil
+	}
+
+	var tlsConfig *tls.Config
+	plain.Handler = certs.RedirectHTTPS(cfg.HTTPSPort)
+	if cfg.ACME {
+		acmeCfg := certs.ACMEConfig{
+			DirectoryURL: cfg.ACMEDirectoryURL,
+			Email:        cfg.ACMEEmail,
+			CacheDir:     cfg.ACMECacheDir,
+			Hosts:        cfg.ACMEDomains,
+			// Branded domains get certificates as soon as they are registered
+			Allow: func(ctx context.Context, host string) bool {
+				domain, err := urlService.ResolveDomain(ctx, host)
+				return err == nil && domain == host
+			},
+		}
+		if cfg.ACMECARoots != "" {
+			roots, err := certs.LoadRoots(cfg.ACMECARoots)
+			if err != nil {
+				return nil, err
+			}
+			acmeCfg.RootCAs = roots
+		}
+		manager := certs.NewACMEManager(acmeCfg)
+		tlsConfig = manager.TLSConfig()
+		plain.Handler = manager.HTTPHandler(plain.Handler)
+	} else {
+		cert, err := certs.NewFileCertificate(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.TLSReloadInterval > 0 {
+			go cert.Watch(ctx, cfg.TLSReloadInterval)
+		}
+		tlsConfig = &tls.Config{GetCertificate: cert.GetCertificate}
+	}
+	tlsConfig.MinVersion = tls.VersionTLS12
+
+	secure := &http.Server{Addr: fmt.Sprintf(":%d", cfg.HTTPSPort), Handler: r, TLSConfig: tlsConfig}
+	return []*http.Server{secure, plain}, nil
 }
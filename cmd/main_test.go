@@ -5,35 +5,33 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/jmoiron/sqlx"
 	_ "modernc.org/sqlite"
 
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/dashboard"
+	"github.com/zen-flo/url-shortener/internal/db"
 	"github.com/zen-flo/url-shortener/internal/handler"
+	"github.com/zen-flo/url-shortener/internal/health"
 	"github.com/zen-flo/url-shortener/internal/service"
 )
 
 func setupTestRouter(t *testing.T) http.Handler {
-	db, err := sqlx.Connect("sqlite", ":memory:")
+	database, err := db.InitDB(":memory:")
 	if err != nil {
 		t.Fatalf("failed to initialize the database: %v", err)
 	}
+	t.Cleanup(func() { _ = database.Close() })
 
-	schema := `
-	CREATE TABLE urls (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		original TEXT NOT NULL,
-		short TEXT NOT NULL UNIQUE,
-		created_at DATETIME NOT NULL
-	);`
-	if _, err := db.Exec(schema); err != nil {
-		t.Fatalf("couldn't create a table: %v", err)
-	}
-
-	svc := service.NewURLService(db)
+	svc := service.NewURLService(database)
 	urlHandler := handler.NewURLHandler(svc)
 
-	return NewRouter(urlHandler)
+	reg := health.NewRegistry(health.DefaultTimeout)
+	reg.Register("database", health.DBCheck(database))
+	reg.Register("migrations", health.MigrationCheck(database))
+
+	return NewRouter(urlHandler, WithHealth(reg))
 }
 
 func TestServerRoutes(t *testing.T) {
@@ -45,7 +43,8 @@ func TestServerRoutes(t *testing.T) {
 		wantBody   string
 	}{
 		{"/", http.StatusOK, "URL Shortener API is running"},
-		{"/health", http.StatusOK, "OK"},
+		{"/livez", http.StatusOK, `"status":"ok"`},
+		{"/readyz", http.StatusOK, `"status":"ok"`},
 		{"/metrics", http.StatusOK, ""},                              // проверим только код ответа
 		{"/swagger/doc.json", http.StatusOK, "\"swagger\": \"2.0\""}, // Swagger HTML
 	}
@@ -74,7 +73,7 @@ func TestMetricsContent(t *testing.T) {
 	rec := httptest.NewRecorder()
 	r.ServeHTTP(rec, req)
 
-	req = httptest.NewRequest("GET", "/health", nil)
+	req = httptest.NewRequest("GET", "/livez", nil)
 	rec = httptest.NewRecorder()
 	r.ServeHTTP(rec, req)
 
@@ -88,3 +87,103 @@ func TestMetricsContent(t *testing.T) {
 		t.Errorf("Prometheus metrics not found in /metrics")
 	}
 }
+
+// Проверяем, что API администратора и веб-интерфейс смонтированы рядом и защищены каждый своим способом
+func TestAdminRoutes(t *testing.T) {
+	database, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to initialize the database: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	svc := service.NewURLService(database)
+	keys := service.NewAPIKeyService(database)
+	authn := auth.Chain(auth.StaticToken("secret"), keys)
+	sessions := auth.NewSessions(nil, "admin_session", "/admin/ui", time.Hour)
+
+	r := NewRouter(handler.NewURLHandler(svc),
+		WithAdmin(authn, handler.NewAPIKeyHandler(keys)),
+		WithDashboard(dashboard.New(svc, keys, authn, sessions)),
+	)
+
+	tests := []struct {
+		method     string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"GET", "/admin/keys", "", http.StatusUnauthorized},
+		{"GET", "/admin/keys", "secret", http.StatusOK},
+		{"POST", "/admin/keys", "secret", http.StatusCreated},
+		{"GET", "/admin/ui/", "", http.StatusSeeOther},
+		{"GET", "/admin/ui/login", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"name":"ci","scopes":["admin"]}`))
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("expected %d for %s %s, got %d", tt.wantStatus, tt.method, tt.path, rec.Code)
+		}
+	}
+}
+
+// Проверяем, что потоки кликов требуют токен, а браузеры могут передать его в параметре access_token
+func TestClickStreams(t *testing.T) {
+	database, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to initialize the database: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	svc := service.NewURLService(database)
+	link, err := svc.CreateShortURL(t.Context(), "https://example.com")
+	if err != nil {
+		t.Fatalf("CreateShortURL: %v", err)
+	}
+	r := NewRouter(handler.NewURLHandler(svc), WithAdmin(auth.StaticToken("secret"), handler.NewEventsHandler(svc)))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	// Потоки не заканчиваются сами, поэтому закрываем их при остановке сервера
+	defer svc.Clicks.Close()
+
+	tests := []struct {
+		path       string
+		stream     bool
+		token      string
+		wantStatus int
+	}{
+		{"/admin/events", true, "", http.StatusUnauthorized},
+		{"/admin/events?access_token=wrong", true, "", http.StatusUnauthorized},
+		{"/admin/events?access_token=secret", true, "", http.StatusOK},
+		{"/admin/events", true, "secret", http.StatusOK},
+		// Обычные запросы не принимают токен из URL
+		{"/admin/events?access_token=secret", false, "", http.StatusUnauthorized},
+		{"/urls/" + link.Short + "/events", true, "", http.StatusUnauthorized},
+		{"/urls/" + link.Short + "/events?access_token=secret", true, "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequestWithContext(t.Context(), "GET", srv.URL+tt.path, nil)
+		if tt.stream {
+			req.Header.Set("Accept", "text/event-stream")
+		}
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("expected %d for %s (stream %v), got %d", tt.wantStatus, tt.path, tt.stream, resp.StatusCode)
+		}
+	}
+}
@@ -1,20 +1,116 @@
 package main
 
 import (
-	"fmt"
 	"github.com/go-chi/chi/v5"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"net/http"
+	"time"
 
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/dashboard"
 	"github.com/zen-flo/url-shortener/internal/handler"
+	"github.com/zen-flo/url-shortener/internal/health"
+	"github.com/zen-flo/url-shortener/internal/logger"
 	"github.com/zen-flo/url-shortener/internal/middleware"
 )
 
+/*
+routerOptions holds the optional dependencies of the router.
+*/
+type routerOptions struct {
+	health    *health.Registry
+	adminAuth auth.Authenticator
+	admin     []routeRegistrar
+	dashboard *dashboard.Dashboard
+	redirect  *handler.RedirectHandler
+	hsts      time.Duration
+}
+
+/*
+routeRegistrar is implemented by handlers that register their own routes.
+*/
+type routeRegistrar interface {
+	RegisterRoutes(r chi.Router)
+}
+
+/*
+RouterOption configures NewRouter.
+*/
+type RouterOption func(*routerOptions)
+
+/*
+WithHealth sets the registry used by the liveness and readiness probes.
+Without it readiness has no checks and only reflects the draining state.
+*/
+func WithHealth(reg *health.Registry) RouterOption {
+	return func(o *routerOptions) {
+		o.health = reg
+	}
+}
+
+/*
+WithAdmin mounts the routes of the given handlers under /admin.
+Requests must present a token or API key that authn resolves to a principal with the admin scope.
+*/
+func WithAdmin(authn auth.Authenticator, handlers ...routeRegistrar) RouterOption {
+	return func(o *routerOptions) {
+		o.adminAuth = authn
+		o.admin = append(o.admin, handlers...)
+	}
+}
+
+/*
+WithDashboard mounts the admin web UI at the path of its session cookie, e.g. /admin/ui.
+*/
+func WithDashboard(d *dashboard.Dashboard) RouterOption {
+	return func(o *routerOptions) {
+		o.dashboard = d
+	}
+}
+
+/*
+WithRedirect serves short links at /{short}. The route is registered last,
+so that it does not shadow any fixed top-level path.
+*/
+func WithRedirect(h *handler.RedirectHandler) RouterOption {
+	return func(o *routerOptions) {
+		o.redirect = h
+	}
+}
+
+/*
+WithHSTS sends Strict-Transport-Security with the given max-age on HTTPS responses.
+*/
+func WithHSTS(maxAge time.Duration) RouterOption {
+	return func(o *routerOptions) {
+		o.hsts = maxAge
+	}
+}
+
 // NewRouter Router creates and configures an HTTP router.
 // Accepts a UrlService — this is important for tests.
-func NewRouter(urlHandler *handler.URLHandler) http.Handler {
+func NewRouter(urlHandler *handler.URLHandler, opts ...RouterOption) http.Handler {
+	o := routerOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.health == nil {
+		o.health = health.NewRegistry(health.DefaultTimeout)
+	}
+
 	r := chi.NewRouter()
 
+	// Request IDs, tracing and access logs
+	r.Use(middleware.RequestID)
+	r.Use(middleware.TracingMiddleware)
+	r.Use(middleware.AccessLog)
+	if o.hsts > 0 {
+		r.Use(middleware.HSTS(o.hsts))
+	}
+
+	// Tokens of browser click streams, which cannot send headers
+	r.Use(middleware.StreamToken)
+
 	// Metrics
 	r.Use(middleware.MetricsMiddleware)
 	r.Handle("/metrics", middleware.MetricsHandler())
@@ -23,25 +119,48 @@ func NewRouter(urlHandler *handler.URLHandler) http.Handler {
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		if _, err := w.Write([]byte("URL Shortener API is running")); err != nil {
-			fmt.Printf("Error writing response: %v\n", err)
+			logger.FromContext(r.Context()).ErrorContext(r.Context(), "failed to write response", "error", err)
 		}
 	})
 
-	// Health check endpoint
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("OK")); err != nil {
-			fmt.Printf("Error writing response: %v\n", err)
-		}
-	})
+	// Liveness and readiness probes
+	r.Method(http.MethodGet, "/livez", o.health.LivenessHandler())
+	r.Method(http.MethodGet, "/readyz", o.health.ReadinessHandler())
 
-	// Swagger
+	// Swagger, served relative to whichever host the request came to
 	r.Get("/swagger/*", httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8080/swagger/doc.json"),
+		httpSwagger.URL("/swagger/doc.json"),
 	))
 
-	// Routes for URL Shortener
-	urlHandler.RegisterRoutes(r)
+	// Admin web UI, authenticated with session cookies instead of bearer tokens
+	if o.dashboard != nil {
+		r.Mount(o.dashboard.Sessions.Path, o.dashboard.Routes())
+	}
+
+	// Administrative routes
+	if o.adminAuth != nil && len(o.admin) > 0 {
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(middleware.RequireScope(o.adminAuth, auth.ScopeAdmin))
+			for _, h := range o.admin {
+				h.RegisterRoutes(r)
+			}
+		})
+	}
+
+	// Routes for URL Shortener; a token is optional, but names the owner of the links it creates
+	if o.adminAuth != nil {
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Authenticate(o.adminAuth))
+			urlHandler.RegisterRoutes(r)
+		})
+	} else {
+		urlHandler.RegisterRoutes(r)
+	}
+
+	// Short links
+	if o.redirect != nil {
+		o.redirect.RegisterRoutes(r)
+	}
 
 	return r
 }
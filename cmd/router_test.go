@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"github.com/zen-flo/url-shortener/internal/clickfeed"
 	"github.com/zen-flo/url-shortener/internal/handler"
 	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/service"
 	"net/http/httptest"
 	"testing"
 )
@@ -10,19 +13,55 @@ import (
 // mockService заглушка для URLService
 type mockService struct{}
 
-func (m *mockService) CreateShortURL(original string) (*model.URL, error) {
+func (m *mockService) CreateShortURL(_ context.Context, original string, _ ...service.CreateOption) (*model.URL, error) {
 	return &model.URL{ID: 1, Original: original, Short: "abc123"}, nil
 }
 
-func (m *mockService) GetOriginalURL(short string) (*model.URL, error) {
-	return &model.URL{ID: 1, Original: "https://example.com", Short: short}, nil
+func (m *mockService) GetOriginalURL(_ context.Context, domain, short string) (*model.URL, error) {
+	return &model.URL{ID: 1, Original: "https://example.com", Short: short, Domain: domain}, nil
 }
 
-func (m *mockService) DeleteURL(_ string) error {
+func (m *mockService) DeleteURL(_ context.Context, _, _ string) error {
 	return nil
 }
 
-func (m *mockService) UpdateURLCount() {}
+func (m *mockService) UpdateRules(_ context.Context, domain, short string, rs model.Rules) (*model.URL, error) {
+	return &model.URL{ID: 1, Original: "https://example.com", Short: short, Domain: domain, Rules: rs}, nil
+}
+
+func (m *mockService) UpdateVariants(_ context.Context, domain, short string, vs model.Variants) (*model.URL, error) {
+	return &model.URL{ID: 1, Original: "https://example.com", Short: short, Domain: domain, Variants: vs}, nil
+}
+
+func (m *mockService) UpdateOptions(_ context.Context, domain, short string, o model.RedirectOptions) (*model.URL, error) {
+	return &model.URL{ID: 1, Original: "https://example.com", Short: short, Domain: domain, Options: o}, nil
+}
+
+func (m *mockService) Stats(_ context.Context, domain, short string) (*model.Stats, error) {
+	return &model.Stats{Short: short}, nil
+}
+
+func (m *mockService) LinkHealth(_ context.Context, _, _ string) (*model.LinkHealth, error) {
+	return &model.LinkHealth{}, nil
+}
+
+func (m *mockService) ListURLs(_ context.Context, _, _ string, _, _ int) ([]model.URL, int, error) {
+	return nil, 0, nil
+}
+
+func (m *mockService) SubscribeClicks(_ context.Context, _, _ string) (*clickfeed.Subscription, error) {
+	return clickfeed.New(1).Subscribe(nil), nil
+}
+
+func (m *mockService) RegisterClick(_ context.Context, _, _ string, _ int) error {
+	return nil
+}
+
+func (m *mockService) ResolveDomain(_ context.Context, _ string) (string, error) {
+	return "", nil
+}
+
+func (m *mockService) UpdateURLCount(_ context.Context) {}
 
 func TestRouterRoutes(t *testing.T) {
 	// Создаём мок-сервис и handler
@@ -39,7 +78,8 @@ func TestRouterRoutes(t *testing.T) {
 	}{
 		{"GET", "/", 200},
 		{"GET", "/metrics", 200},
-		{"GET", "/health", 200},
+		{"GET", "/livez", 200},
+		{"GET", "/readyz", 200},
 	}
 
 	for _, tt := range tests {
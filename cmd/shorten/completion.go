@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// profilesCommand prints the profile names for completing -profile.
+const profilesCommand = "shorten profile list -o csv 2>/dev/null | tail -n +2 | cut -d, -f1"
+
+// Values offered for flags, files for those in fileFlags.
+var (
+	flagValues = map[string]string{"o": "table json csv", "output": "table json csv", "forward-query": "merge override"}
+	fileFlags  = map[string]bool{"config": true, "rules": true, "variants": true}
+)
+
+func completionCommand() command {
+	return command{
+		name:    "completion",
+		args:    "bash | zsh | fish",
+		summary: "Print the shell completion script",
+		words:   []string{"bash", "zsh", "fish"},
+		setup: func(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+			return func(ctx context.Context, a *app, args []string) error {
+				if err := wantArgs(args, 1); err != nil {
+					return err
+				}
+				switch args[0] {
+				case "bash":
+					return bashCompletion(a.stdout)
+				case "zsh":
+					fmt.Fprint(a.stdout, "#compdef shorten\n\nautoload -U +X bashcompinit && bashcompinit\n\n")
+					return bashCompletion(a.stdout)
+				case "fish":
+					return fishCompletion(a.stdout)
+				}
+				return usageError{fmt.Sprintf("unknown shell %q, expected bash, zsh or fish", args[0])}
+			}
+		},
+	}
+}
+
+/*
+completionFlag is a flag as seen by shell completion.
+*/
+type completionFlag struct {
+	name  string
+	usage string
+	value bool // Whether the flag takes a value
+}
+
+/*
+flagsOf returns the flags defined by register, in alphabetical order.
+*/
+func flagsOf(register func(fs *flag.FlagSet)) []completionFlag {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	register(fs)
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{name: f.Name, usage: f.Usage, value: !ok || !b.IsBoolFlag()})
+	})
+	return flags
+}
+
+func globalFlags() []completionFlag {
+	return flagsOf(new(app).register)
+}
+
+func commandFlags(c command) []completionFlag {
+	return flagsOf(func(fs *flag.FlagSet) { c.setup(fs) })
+}
+
+func flagNames(flags []completionFlag) string {
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = "-" + f.name
+	}
+	return strings.Join(names, " ")
+}
+
+/*
+bashCompletion writes the bash completion script, which zsh loads through bashcompinit.
+*/
+func bashCompletion(w io.Writer) error {
+	cmds := commands()
+	globals := globalFlags()
+
+	// Flags taking a value, whose next word is not the command.
+	valueFlags := map[string]bool{}
+	for _, f := range globals {
+		valueFlags[f.name] = f.value
+	}
+	var names []string
+	for _, c := range cmds {
+		names = append(names, c.name)
+		for _, f := range commandFlags(c) {
+			valueFlags[f.name] = valueFlags[f.name] || f.value
+		}
+	}
+	var values []string
+	for _, name := range sortedKeys(valueFlags) {
+		if valueFlags[name] {
+			values = append(values, name)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# bash completion for shorten, load it with: source <(shorten completion bash)\n")
+	b.WriteString("_shorten() {\n")
+	b.WriteString("    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}\n")
+	b.WriteString("    if [[ $prev == -* ]]; then\n")
+	b.WriteString("        prev=${prev#-}; prev=${prev#-}\n")
+	b.WriteString("        case $prev in\n")
+	for _, name := range sortedKeys(flagValues) {
+		fmt.Fprintf(&b, "            %s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", name, flagValues[name])
+	}
+	fmt.Fprintf(&b, "            profile) COMPREPLY=($(compgen -W \"$(%s)\" -- \"$cur\")); return ;;\n", profilesCommand)
+	fmt.Fprintf(&b, "            %s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(sortedKeys(fileFlags), "|"))
+	fmt.Fprintf(&b, "            %s) return ;;\n", strings.Join(values, "|"))
+	b.WriteString("        esac\n")
+	b.WriteString("    fi\n\n")
+	b.WriteString("    local cmd= word name i\n")
+	b.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("        word=${COMP_WORDS[i]}\n")
+	b.WriteString("        if [[ $word == -* ]]; then\n")
+	b.WriteString("            name=${word#-}; name=${name#-}\n")
+	fmt.Fprintf(&b, "            case $name in %s) ((i++)) ;; esac\n", strings.Join(values, "|"))
+	b.WriteString("            continue\n")
+	b.WriteString("        fi\n")
+	b.WriteString("        cmd=$word\n")
+	b.WriteString("        break\n")
+	b.WriteString("    done\n\n")
+	b.WriteString("    local flags words\n")
+	b.WriteString("    case $cmd in\n")
+	fmt.Fprintf(&b, "        \"\") flags=%q; words=%q ;;\n", flagNames(globals), strings.Join(names, " "))
+	for _, c := range cmds {
+		fmt.Fprintf(&b, "        %s) flags=%q; words=%q ;;\n", c.name, flagNames(append(commandFlags(c), globals...)), strings.Join(c.words, " "))
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("    if [[ $cur == -* ]]; then\n")
+	b.WriteString("        COMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	b.WriteString("    else\n")
+	b.WriteString("        COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	b.WriteString("    fi\n")
+	b.WriteString("}\n")
+	b.WriteString("complete -F _shorten shorten\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+/*
+fishCompletion writes the fish completion script.
+*/
+func fishCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# fish completion for shorten, load it with: shorten completion fish | source\n")
+	b.WriteString("complete -c shorten -f\n")
+
+	cmds := commands()
+	for _, c := range cmds {
+		fmt.Fprintf(&b, "complete -c shorten -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.summary))
+	}
+	for _, f := range globalFlags() {
+		b.WriteString(fishFlag("", f))
+	}
+	for _, c := range cmds {
+		cond := "'__fish_seen_subcommand_from " + c.name + "'"
+		if len(c.words) > 0 {
+			fmt.Fprintf(&b, "complete -c shorten -n %s -a %s\n", cond, fishQuote(strings.Join(c.words, " ")))
+		}
+		for _, f := range commandFlags(c) {
+			b.WriteString(fishFlag(cond, f))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+/*
+fishFlag returns the complete line of a flag, only after a command if cond is set.
+*/
+func fishFlag(cond string, f completionFlag) string {
+	line := "complete -c shorten"
+	if cond != "" {
+		line += " -n " + cond
+	}
+	line += " -o " + f.name
+	switch {
+	case f.name == "profile":
+		line += " -x -a " + fishQuote("("+profilesCommand+")")
+	case flagValues[f.name] != "":
+		line += " -x -a " + fishQuote(flagValues[f.name])
+	case fileFlags[f.name]:
+		line += " -r -F"
+	case f.value:
+		line += " -x"
+	}
+	return line + " -d " + fishQuote(f.usage) + "\n"
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DefaultServer is used when neither a flag, the environment nor a profile names a server.
+const DefaultServer = "http://localhost:8080"
+
+/*
+Profile holds how to reach one server.
+*/
+type Profile struct {
+	Server string `json:"server"`
+	Token  string `json:"token,omitempty"`
+	Output string `json:"output,omitempty"` // Default output format: table, json or csv
+}
+
+/*
+Config is the profiles file, by default ~/.config/shorten/config.json:
+
+	{
+	  "default": "prod",
+	  "profiles": {
+	    "prod":  {"server": "https://sho.rt", "token": "..."},
+	    "local": {"server": "http://localhost:8080", "output": "json"}
+	  }
+	}
+*/
+type Config struct {
+	Default  string             `json:"default,omitempty"`
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+/*
+configPath returns the profiles file: SHORTEN_CONFIG if set, otherwise shorten/config.json
+in the user configuration directory.
+*/
+func configPath() (string, error) {
+	if path := os.Getenv("SHORTEN_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "shorten", "config.json"), nil
+}
+
+/*
+loadConfig reads the profiles file. A missing file is an empty configuration.
+*/
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{Profiles: map[string]Profile{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]Profile{}
+	}
+	return cfg, nil
+}
+
+/*
+save writes the profiles file. It is readable only by the user since it holds tokens.
+*/
+func (c *Config) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+/*
+profile returns the named profile, or the default one if name is empty.
+Without a name and a default it returns an empty profile.
+*/
+func (c *Config) profile(name string) (Profile, error) {
+	if name == "" {
+		name = c.Default
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %q not found", name)
+	}
+	return p, nil
+}
+
+/*
+names returns the profile names in alphabetical order.
+*/
+func (c *Config) names() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
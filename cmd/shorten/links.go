@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/zen-flo/url-shortener/client"
+)
+
+// exportPageSize is the page size used by export, the maximum the server returns at once.
+const exportPageSize = 100
+
+/*
+optionFlags are the flags setting the redirect options of a link.
+*/
+type optionFlags struct {
+	fs   *flag.FlagSet
+	opts client.RedirectOptions
+}
+
+func addOptionFlags(fs *flag.FlagSet) *optionFlags {
+	o := &optionFlags{fs: fs}
+	fs.StringVar(&o.opts.ForwardQuery, "forward-query", "", "forward the query string of visitors: merge or override; empty turns it off")
+	fs.BoolVar(&o.opts.PassPath, "pass-path", false, "append the path after the short code to the destination")
+	fs.IntVar(&o.opts.Interstitial, "interstitial", 0, "seconds of a countdown page shown before redirecting")
+	fs.StringVar(&o.opts.UTM.Source, "utm-source", "", "utm_source set on the destination")
+	fs.StringVar(&o.opts.UTM.Medium, "utm-medium", "", "utm_medium set on the destination")
+	fs.StringVar(&o.opts.UTM.Campaign, "utm-campaign", "", "utm_campaign set on the destination")
+	fs.StringVar(&o.opts.UTM.Term, "utm-term", "", "utm_term set on the destination")
+	fs.StringVar(&o.opts.UTM.Content, "utm-content", "", "utm_content set on the destination")
+	return o
+}
+
+/*
+apply copies the option flags given on the command line into opts, keeping the other options,
+and reports whether there were any.
+*/
+func (o *optionFlags) apply(opts *client.RedirectOptions) bool {
+	set := false
+	o.fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "forward-query":
+			opts.ForwardQuery = o.opts.ForwardQuery
+		case "pass-path":
+			opts.PassPath = o.opts.PassPath
+		case "interstitial":
+			opts.Interstitial = o.opts.Interstitial
+		case "utm-source":
+			opts.UTM.Source = o.opts.UTM.Source
+		case "utm-medium":
+			opts.UTM.Medium = o.opts.UTM.Medium
+		case "utm-campaign":
+			opts.UTM.Campaign = o.opts.UTM.Campaign
+		case "utm-term":
+			opts.UTM.Term = o.opts.UTM.Term
+		case "utm-content":
+			opts.UTM.Content = o.opts.UTM.Content
+		default:
+			return
+		}
+		set = true
+	})
+	return set
+}
+
+func createCommand() command {
+	return command{
+		name:    "create",
+		args:    "<url>",
+		summary: "Shorten a URL",
+		setup: func(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+			domain := fs.String("domain", "", "branded domain of the link")
+			password := fs.String("password", "", "password visitors must enter before being redirected")
+			maxClicks := fs.Int("max-clicks", 0, "redirect limit (0 is unlimited)")
+			rulesFile := fs.String("rules", "", "JSON file with the redirect rules, - for standard input")
+			variantsFile := fs.String("variants", "", "JSON file with the A/B variants, - for standard input")
+			quiet := fs.Bool("q", false, "print only the short URL")
+			options := addOptionFlags(fs)
+
+			return func(ctx context.Context, a *app, args []string) error {
+				if err := wantArgs(args, 1); err != nil {
+					return err
+				}
+				req := client.CreateRequest{Original: args[0], Domain: *domain, Password: *password}
+				if *maxClicks > 0 {
+					req.MaxClicks = maxClicks
+				}
+				if *rulesFile != "" {
+					if err := a.readJSON(*rulesFile, &req.Rules); err != nil {
+						return err
+					}
+				}
+				if *variantsFile != "" {
+					if err := a.readJSON(*variantsFile, &req.Variants); err != nil {
+						return err
+					}
+				}
+				options.apply(&req.Options)
+
+				out, c, err := a.connect()
+				if err != nil {
+					return err
+				}
+				u, err := c.CreateURL(ctx, req)
+				if err != nil {
+					return err
+				}
+				if *quiet {
+					_, err := fmt.Fprintln(a.stdout, firstNonEmpty(u.ShortURL, u.Short))
+					return err
+				}
+				return out.printURL(u)
+			}
+		},
+	}
+}
+
+func getCommand() command {
+	return command{
+		name:    "get",
+		args:    "<short>",
+		summary: "Show a link",
+		setup: func(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+			domain := fs.String("domain", "", "branded domain of the link")
+
+			return func(ctx context.Context, a *app, args []string) error {
+				if err := wantArgs(args, 1); err != nil {
+					return err
+				}
+				out, c, err := a.connect()
+				if err != nil {
+					return err
+				}
+				u, err := c.GetURL(ctx, *domain, args[0])
+				if err != nil {
+					return err
+				}
+				return out.printURL(u)
+			}
+		},
+	}
+}
+
+func listCommand() command {
+	return command{
+		name:    "list",
+		summary: "List links, newest first",
+		setup: func(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+			var opts client.ListOptions
+			fs.StringVar(&opts.Query, "query", "", "text to search for in the original URL or short code")
+			fs.IntVar(&opts.Limit, "limit", 20, "number of links to show (at most 100)")
+			fs.IntVar(&opts.Offset, "offset", 0, "number of links to skip")
+
+			return func(ctx context.Context, a *app, args []string) error {
+				if err := wantArgs(args, 0); err != nil {
+					return err
+				}
+				out, c, err := a.connect()
+				if err != nil {
+					return err
+				}
+				list, err := c.ListURLs(ctx, opts)
+				if err != nil {
+					return err
+				}
+				if err := out.printURLs(list, list.URLs); err != nil {
+					return err
+				}
+				if out.format == formatTable {
+					fmt.Fprintf(a.stdout, "\n%d-%d of %d\n", min(opts.Offset+1, list.Total), opts.Offset+len(list.URLs), list.Total)
+				}
+				return nil
+			}
+		},
+	}
+}
+
+func updateCommand() command {
+	return command{
+		name:    "update",
+		args:    "<short>",
+		summary: "Change the rules, A/B variants or redirect options of a link",
+		setup: func(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+			domain := fs.String("domain", "", "branded domain of the link")
+			rulesFile := fs.String("rules", "", "JSON file replacing the redirect rules ([] removes them), - for standard input")
+			variantsFile := fs.String("variants", "", "JSON file replacing the A/B variants ([] removes them), - for standard input")
+			options := addOptionFlags(fs)
+
+			return func(ctx context.Context, a *app, args []string) error {
+				if err := wantArgs(args, 1); err != nil {
+					return err
+				}
+				short := args[0]
+				changed := options.apply(&client.RedirectOptions{})
+				if !changed && *rulesFile == "" && *variantsFile == "" {
+					return usageError{"nothing to update: set -rules, -variants or a redirect option"}
+				}
+				var rules client.Rules
+				if *rulesFile != "" {
+					if err := a.readJSON(*rulesFile, &rules); err != nil {
+						return err
+					}
+				}
+				var variants client.Variants
+				if *variantsFile != "" {
+					if err := a.readJSON(*variantsFile, &variants); err != nil {
+						return err
+					}
+				}
+				out, c, err := a.connect()
+				if err != nil {
+					return err
+				}
+
+				var u *client.URL
+				if *rulesFile != "" {
+					if u, err = c.UpdateRules(ctx, *domain, short, rules); err != nil {
+						return err
+					}
+				}
+				if *variantsFile != "" {
+					if u, err = c.UpdateVariants(ctx, *domain, short, variants); err != nil {
+						return err
+					}
+				}
+				if changed {
+					// Redirect options are replaced as a whole, so the flags are applied to the current ones.
+					current, err := c.GetURL(ctx, *domain, short)
+					if err != nil {
+						return err
+					}
+					opts := current.Options
+					options.apply(&opts)
+					if u, err = c.UpdateOptions(ctx, *domain, short, opts); err != nil {
+						return err
+					}
+				}
+				return out.printURL(u)
+			}
+		},
+	}
+}
+
+func deleteCommand() command {
+	return command{
+		name:    "delete",
+		args:    "<short>...",
+		summary: "Delete links",
+		setup: func(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+			domain := fs.String("domain", "", "branded domain of the links")
+
+			return func(ctx context.Context, a *app, args []string) error {
+				if len(args) == 0 {
+					return usageError{"expected at least one short code"}
+				}
+				c, err := a.client()
+				if err != nil {
+					return err
+				}
+				for _, short := range args {
+					if err := c.DeleteURL(ctx, *domain, short); err != nil {
+						return fmt.Errorf("%s: %w", short, err)
+					}
+				}
+				return nil
+			}
+		},
+	}
+}
+
+func exportCommand() command {
+	return command{
+		name:    "export",
+		summary: "Export all links as JSON, or CSV with -o csv",
+		setup: func(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+			query := fs.String("query", "", "export only links matching this text")
+
+			return func(ctx context.Context, a *app, args []string) error {
+				if err := wantArgs(args, 0); err != nil {
+					return err
+				}
+				out, c, err := a.connect()
+				if err != nil {
+					return err
+				}
+				if out.format == formatTable {
+					out.format = formatJSON
+				}
+
+				// Links created while paging shift the pages, so those already seen are skipped.
+				urls := []client.URL{}
+				seen := map[int]bool{}
+				for offset := 0; ; offset += exportPageSize {
+					page, err := c.ListURLs(ctx, client.ListOptions{Query: *query, Limit: exportPageSize, Offset: offset})
+					if err != nil {
+						return err
+					}
+					for _, u := range page.URLs {
+						if !seen[u.ID] {
+							seen[u.ID] = true
+							urls = append(urls, u)
+						}
+					}
+					if len(page.URLs) < exportPageSize || offset+len(page.URLs) >= page.Total {
+						break
+					}
+				}
+				return out.printURLs(urls, urls)
+			}
+		},
+	}
+}
+
+func statsCommand() command {
+	return command{
+		name:    "stats",
+		args:    "<short>",
+		summary: "Show the clicks of a link and of its A/B variants",
+		setup: func(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+			domain := fs.String("domain", "", "branded domain of the link")
+
+			return func(ctx context.Context, a *app, args []string) error {
+				if err := wantArgs(args, 1); err != nil {
+					return err
+				}
+				out, c, err := a.connect()
+				if err != nil {
+					return err
+				}
+				stats, err := c.Stats(ctx, *domain, args[0])
+				if err != nil {
+					return err
+				}
+				return out.printStats(stats)
+			}
+		},
+	}
+}
@@ -0,0 +1,336 @@
+/*
+Command shorten manages short links from the terminal through the REST API:
+
+	shorten create -domain brand.link https://example.com/spring-sale
+	shorten list -query example.com -o csv
+	shorten -profile prod stats abc123
+
+Servers and tokens are kept as profiles in ~/.config/shorten/config.json,
+see `shorten profile -h`.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/zen-flo/url-shortener/client"
+)
+
+/*
+command is a subcommand. setup defines its flags on fs and returns the function that runs it
+with the positional arguments, so that the flags are known to shell completion as well.
+*/
+type command struct {
+	name    string
+	args    string   // Positional arguments shown in the usage, e.g. "<short>"
+	summary string   // One line shown in the command list
+	words   []string // Fixed values of the first argument offered by shell completion
+	setup   func(fs *flag.FlagSet) func(ctx context.Context, a *app, args []string) error
+}
+
+/*
+commands lists the subcommands in the order shown in the usage.
+*/
+func commands() []command {
+	return []command{
+		createCommand(),
+		getCommand(),
+		listCommand(),
+		updateCommand(),
+		deleteCommand(),
+		exportCommand(),
+		statsCommand(),
+		profileCommand(),
+		completionCommand(),
+	}
+}
+
+/*
+usageError is returned for invalid arguments; it exits with status 2 instead of 1.
+*/
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string { return e.msg }
+
+/*
+app holds the global flags and the streams of one run.
+*/
+type app struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+
+	configPath string
+	profile    string
+	server     string
+	token      string
+	output     string
+	timeout    time.Duration
+
+	cfg *Config
+}
+
+/*
+register defines the global flags on fs. They are accepted both before and after the
+command name, so the defaults are the values already parsed.
+*/
+func (a *app) register(fs *flag.FlagSet) {
+	fs.StringVar(&a.configPath, "config", a.configPath, "profiles file (env SHORTEN_CONFIG)")
+	fs.StringVar(&a.profile, "profile", a.profile, "profile to use instead of the default one (env SHORTEN_PROFILE)")
+	fs.StringVar(&a.server, "server", a.server, "server URL, overrides the profile (env SHORTEN_SERVER)")
+	fs.StringVar(&a.token, "token", a.token, "API token, overrides the profile (env SHORTEN_TOKEN)")
+	fs.StringVar(&a.output, "o", a.output, "output format: table, json or csv")
+	fs.DurationVar(&a.timeout, "timeout", a.timeout, "timeout of one request")
+}
+
+/*
+config loads the profiles file once.
+*/
+func (a *app) config() (*Config, error) {
+	if a.cfg != nil {
+		return a.cfg, nil
+	}
+	path := a.configPath
+	if path == "" {
+		var err error
+		if path, err = configPath(); err != nil {
+			return nil, err
+		}
+	}
+	a.configPath = path
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	a.cfg = cfg
+	return cfg, nil
+}
+
+/*
+currentProfile returns the profile selected with -profile or SHORTEN_PROFILE, or the default one.
+*/
+func (a *app) currentProfile() (Profile, error) {
+	cfg, err := a.config()
+	if err != nil {
+		return Profile{}, err
+	}
+	return cfg.profile(firstNonEmpty(a.profile, os.Getenv("SHORTEN_PROFILE")))
+}
+
+/*
+client connects to the server of the flags, the environment or the profile, in that order.
+*/
+func (a *app) client() (*client.Client, error) {
+	p, err := a.currentProfile()
+	if err != nil {
+		return nil, err
+	}
+	opts := []client.Option{client.WithUserAgent("shorten")}
+	if token := firstNonEmpty(a.token, os.Getenv("SHORTEN_TOKEN"), p.Token); token != "" {
+		opts = append(opts, client.WithToken(token))
+	}
+	if a.timeout > 0 {
+		opts = append(opts, client.WithTimeout(a.timeout))
+	}
+	return client.New(firstNonEmpty(a.server, os.Getenv("SHORTEN_SERVER"), p.Server, DefaultServer), opts...)
+}
+
+/*
+out returns the printer of the output format of the flags or the profile, table by default.
+*/
+func (a *app) out() (output, error) {
+	format := a.output
+	if format == "" {
+		if p, err := a.currentProfile(); err == nil {
+			format = p.Output
+		}
+	}
+	return newOutput(a.stdout, firstNonEmpty(format, formatTable))
+}
+
+/*
+connect returns the printer and the client of a command that calls the server.
+*/
+func (a *app) connect() (output, *client.Client, error) {
+	out, err := a.out()
+	if err != nil {
+		return output{}, nil, err
+	}
+	c, err := a.client()
+	if err != nil {
+		return output{}, nil, err
+	}
+	return out, c, nil
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+/*
+run executes the command line args and returns the exit status:
+0 on success, 1 if the command failed and 2 for invalid arguments.
+*/
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	a := &app{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("shorten", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	a.register(fs)
+	fs.Usage = func() { printUsage(stderr, fs) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	name := fs.Arg(0)
+	if name == "help" {
+		fs.Usage()
+		return 0
+	}
+	var cmd *command
+	for _, c := range commands() {
+		if c.name == name {
+			cmd = &c
+			break
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "shorten: unknown command %q\n", name)
+		fs.Usage()
+		return 2
+	}
+
+	sub := flag.NewFlagSet("shorten "+name, flag.ContinueOnError)
+	sub.SetOutput(stderr)
+	a.register(sub)
+	runCmd := cmd.setup(sub)
+	sub.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s\n\n%s\n\nFlags:\n", strings.TrimSpace("shorten "+name+" [flags] "+cmd.args), cmd.summary)
+		sub.PrintDefaults()
+	}
+	positional, err := parseInterspersed(sub, fs.Args()[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if a.output != "" {
+		if _, err := newOutput(stdout, a.output); err != nil {
+			fmt.Fprintf(stderr, "shorten: %v\n", err)
+			return 2
+		}
+	}
+
+	if err := runCmd(ctx, a, positional); err != nil {
+		fmt.Fprintf(stderr, "shorten %s: %v\n", name, err)
+		var usage usageError
+		if errors.As(err, &usage) {
+			sub.Usage()
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+/*
+parseInterspersed parses flags placed anywhere among the positional arguments,
+e.g. `get abc123 -domain brand.link`, and returns the positional ones. Everything after
+"--" is positional.
+*/
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+/*
+printUsage writes the command list and the global flags.
+*/
+func printUsage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprint(w, "Usage: shorten [global flags] <command> [flags] [args]\n\nCommands:\n")
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-11s %s\n", c.name, c.summary)
+	}
+	fmt.Fprint(w, "\nGlobal flags, also accepted after the command:\n")
+	fs.PrintDefaults()
+	fmt.Fprint(w, "\nRun `shorten <command> -h` for the flags of a command.\n")
+}
+
+/*
+firstNonEmpty returns the first non-empty value.
+*/
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+/*
+readJSON decodes the JSON file at path into v; "-" reads standard input.
+*/
+func (a *app) readJSON(path string, v any) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(a.stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON in %s: %w", path, err)
+	}
+	return nil
+}
+
+/*
+wantArgs returns a usage error unless there are exactly n positional arguments.
+*/
+func wantArgs(args []string, n int) error {
+	if len(args) != n {
+		return usageError{fmt.Sprintf("expected %d argument(s), got %d", n, len(args))}
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zen-flo/url-shortener/client"
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/handler"
+	"github.com/zen-flo/url-shortener/internal/middleware"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+/*
+setupCLI направляет профили во временный файл и убирает переменные окружения, влияющие на CLI.
+*/
+func setupCLI(t *testing.T) string {
+	configFile := filepath.Join(t.TempDir(), "shorten", "config.json")
+	t.Setenv("SHORTEN_CONFIG", configFile)
+	t.Setenv("SHORTEN_PROFILE", "")
+	t.Setenv("SHORTEN_SERVER", "")
+	t.Setenv("SHORTEN_TOKEN", "")
+	return configFile
+}
+
+/*
+setupServer поднимает настоящие маршруты /urls на in-memory SQLite.
+CLI обращается к ним с токеном администратора, так как список ссылок требует токен.
+*/
+func setupServer(t *testing.T) (*service.URLService, string) {
+	database, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to initialize the database: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	svc := service.NewURLService(database)
+	r := chi.NewRouter()
+	r.Use(middleware.Authenticate(auth.StaticToken("secret")))
+	handler.NewURLHandler(svc).RegisterRoutes(r)
+	t.Setenv("SHORTEN_TOKEN", "secret")
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return svc, srv.URL
+}
+
+func runCLI(t *testing.T, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestProfiles(t *testing.T) {
+	configFile := setupCLI(t)
+
+	// Токен читается из stdin, чтобы не оставлять его в истории shell
+	if _, stderr, code := runCLI(t, "s3cret\n", "profile", "set", "prod", "-server", "https://sho.rt", "-token", "-"); code != 0 {
+		t.Fatalf("profile set prod: exit %d: %s", code, stderr)
+	}
+	if _, stderr, code := runCLI(t, "", "profile", "set", "local", "-server", "http://localhost:8080", "-output", "json"); code != 0 {
+		t.Fatalf("profile set local: exit %d: %s", code, stderr)
+	}
+	if _, _, code := runCLI(t, "", "profile", "set", "staging"); code != 2 {
+		t.Errorf("expected exit 2 for a new profile without a server, got %d", code)
+	}
+	if _, _, code := runCLI(t, "", "profile", "set", "local", "-output", "xml"); code != 1 {
+		t.Errorf("expected exit 1 for an unknown output format, got %d", code)
+	}
+
+	info, err := os.Stat(configFile)
+	if err != nil {
+		t.Fatalf("config not saved: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the config to be readable only by the user, got %v", info.Mode().Perm())
+	}
+	cfg, err := loadConfig(configFile)
+	if err != nil || cfg.Default != "prod" || cfg.Profiles["prod"].Token != "s3cret" || cfg.Profiles["local"].Output != "json" {
+		t.Fatalf("unexpected config %+v, %v", cfg, err)
+	}
+
+	// Первый профиль становится профилем по умолчанию, токены в списке не показываются
+	stdout, _, code := runCLI(t, "", "profile", "list", "-o", "csv")
+	want := "name,server,output,has_token,default\nlocal,http://localhost:8080,json,false,false\nprod,https://sho.rt,,true,true\n"
+	if code != 0 || stdout != want {
+		t.Errorf("profile list: exit %d, got\n%s\nwant\n%s", code, stdout, want)
+	}
+
+	// Флаги и переменные окружения важнее профиля
+	tests := []struct {
+		name   string
+		a      *app
+		env    string
+		server string
+	}{
+		{"default profile", &app{}, "", "https://sho.rt"},
+		{"selected profile", &app{profile: "local"}, "", "http://localhost:8080"},
+		{"environment", &app{}, "https://env.sho.rt", "https://env.sho.rt"},
+		{"flag", &app{server: "https://flag.sho.rt"}, "https://env.sho.rt", "https://flag.sho.rt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SHORTEN_SERVER", tt.env)
+			c, err := tt.a.client()
+			if err != nil || c.BaseURL() != tt.server {
+				t.Errorf("expected %s, got %v, %v", tt.server, c, err)
+			}
+		})
+	}
+	if _, err := (&app{profile: "missing"}).client(); err == nil {
+		t.Error("expected an error for a missing profile")
+	}
+	if out, err := (&app{profile: "local"}).out(); err != nil || out.format != formatJSON {
+		t.Errorf("expected the output format of the profile, got %+v, %v", out, err)
+	}
+
+	if _, _, code := runCLI(t, "", "profile", "use", "local"); code != 0 {
+		t.Errorf("profile use: exit %d", code)
+	}
+	if _, _, code := runCLI(t, "", "profile", "remove", "local"); code != 0 {
+		t.Errorf("profile remove: exit %d", code)
+	}
+	if cfg, _ := loadConfig(configFile); cfg.Default != "" || len(cfg.Profiles) != 1 {
+		t.Errorf("expected only prod without a default, got %+v", cfg)
+	}
+	if _, _, code := runCLI(t, "", "profile", "use", "local"); code != 1 {
+		t.Errorf("expected exit 1 for a removed profile, got %d", code)
+	}
+}
+
+func TestLinkCommands(t *testing.T) {
+	setupCLI(t)
+	_, server := setupServer(t)
+	t.Setenv("SHORTEN_SERVER", server)
+
+	shortURL, stderr, code := runCLI(t, "", "create", "-q", "https://example.com/a", "-max-clicks", "3")
+	if code != 0 {
+		t.Fatalf("create: exit %d: %s", code, stderr)
+	}
+	short := path.Base(strings.TrimSpace(shortURL))
+
+	stdout, stderr, code := runCLI(t, "", "-o", "json", "create", "https://example.org", "-utm-source", "news")
+	var created client.URL
+	if code != 0 || json.Unmarshal([]byte(stdout), &created) != nil || created.Options.UTM.Source != "news" {
+		t.Fatalf("create -o json: exit %d: %s%s", code, stdout, stderr)
+	}
+
+	stdout, _, code = runCLI(t, "", "list", "-o", "csv")
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if code != 0 || err != nil || len(records) != 3 || !reflect.DeepEqual(records[0], urlColumns) || records[2][2] != short {
+		t.Errorf("list -o csv: exit %d, %v: %q", code, err, records)
+	}
+	if stdout, _, _ := runCLI(t, "", "list", "-query", "example.org"); !strings.Contains(stdout, created.Short) || strings.Contains(stdout, short) || !strings.Contains(stdout, "1-1 of 1") {
+		t.Errorf("list -query: got\n%s", stdout)
+	}
+
+	// Опции меняются по одной, остальные сохраняются
+	variants := `[{"name":"A","destination":"https://example.org/a","weight":70},{"name":"B","destination":"https://example.org/b","weight":30}]`
+	if _, stderr, code := runCLI(t, variants, "update", created.Short, "-variants", "-", "-pass-path"); code != 0 {
+		t.Fatalf("update: exit %d: %s", code, stderr)
+	}
+	stdout, _, _ = runCLI(t, "", "get", created.Short, "-o", "json")
+	var updated client.URL
+	if err := json.Unmarshal([]byte(stdout), &updated); err != nil || len(updated.Variants) != 2 || !updated.Options.PassPath || updated.Options.UTM.Source != "news" {
+		t.Errorf("expected the variants and both options, got %+v, %v", updated, err)
+	}
+	if _, _, code := runCLI(t, "", "update", created.Short); code != 2 {
+		t.Errorf("expected exit 2 for update without changes, got %d", code)
+	}
+	if _, stderr, code := runCLI(t, `[{"destination":"https://example.org","weigth":1}]`, "update", created.Short, "-variants", "-"); code != 1 || !strings.Contains(stderr, "weigth") {
+		t.Errorf("expected unknown fields to be rejected, got exit %d: %s", code, stderr)
+	}
+
+	stdout, _, code = runCLI(t, "", "stats", created.Short, "-o", "csv")
+	want := fmt.Sprintf("short,variant,destination,weight,clicks,max_clicks,clicks_left\n%[1]s,,,,0,,\n%[1]s,A,https://example.org/a,70,0,,\n%[1]s,B,https://example.org/b,30,0,,\n", created.Short)
+	if code != 0 || stdout != want {
+		t.Errorf("stats -o csv: exit %d, got\n%s\nwant\n%s", code, stdout, want)
+	}
+	if stdout, _, _ := runCLI(t, "", "get", short); !strings.Contains(stdout, "Clicks left:  3 of 3") {
+		t.Errorf("get: got\n%s", stdout)
+	}
+
+	if _, stderr, code := runCLI(t, "", "delete", short); code != 0 {
+		t.Fatalf("delete: exit %d: %s", code, stderr)
+	}
+	if _, stderr, code := runCLI(t, "", "get", short); code != 1 || !strings.Contains(stderr, "404") {
+		t.Errorf("expected a 404 after deletion, got exit %d: %s", code, stderr)
+	}
+}
+
+func TestExport(t *testing.T) {
+	setupCLI(t)
+	svc, server := setupServer(t)
+	t.Setenv("SHORTEN_SERVER", server)
+
+	// Больше одной страницы
+	const n = exportPageSize + 5
+	for i := range n {
+		if _, err := svc.CreateShortURL(context.Background(), fmt.Sprintf("https://example.com/%d", i)); err != nil {
+			t.Fatalf("CreateShortURL: %v", err)
+		}
+	}
+
+	stdout, stderr, code := runCLI(t, "", "export")
+	var urls []client.URL
+	if code != 0 || json.Unmarshal([]byte(stdout), &urls) != nil || len(urls) != n {
+		t.Fatalf("export: exit %d, %d links: %s", code, len(urls), stderr)
+	}
+	seen := map[string]bool{}
+	for _, u := range urls {
+		seen[u.Short] = true
+	}
+	if len(seen) != n {
+		t.Errorf("expected %d distinct links, got %d", n, len(seen))
+	}
+
+	stdout, _, code = runCLI(t, "", "export", "-o", "csv", "-query", "example.com/10")
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	// example.com/10, /100 ... /104
+	if code != 0 || err != nil || len(records) != 7 {
+		t.Errorf("export -o csv -query: exit %d, %v: %q", code, err, records)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	setupCLI(t)
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{nil, 2},
+		{[]string{"help"}, 0},
+		{[]string{"-h"}, 0},
+		{[]string{"create", "-h"}, 0},
+		{[]string{"shrink"}, 2},
+		{[]string{"get"}, 2},
+		{[]string{"get", "a", "b"}, 2},
+		{[]string{"list", "-limit", "many"}, 2},
+		{[]string{"-o", "xml", "list"}, 2},
+		{[]string{"list", "-o", "yaml"}, 2},
+		{[]string{"completion", "powershell"}, 2},
+	}
+	for _, tt := range tests {
+		if _, _, code := runCLI(t, "", tt.args...); code != tt.code {
+			t.Errorf("shorten %s: expected exit %d, got %d", strings.Join(tt.args, " "), tt.code, code)
+		}
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	domain := fs.String("domain", "", "")
+	quiet := fs.Bool("q", false, "")
+
+	args, err := parseInterspersed(fs, []string{"abc", "-domain", "brand.link", "def", "-q", "--", "-ghi"})
+	if err != nil || !reflect.DeepEqual(args, []string{"abc", "def", "-ghi"}) || *domain != "brand.link" || !*quiet {
+		t.Errorf("got %q, domain %q, quiet %v, %v", args, *domain, *quiet, err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	setupCLI(t)
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		stdout, _, code := runCLI(t, "", "completion", shell)
+		if code != 0 {
+			t.Fatalf("completion %s: exit %d", shell, code)
+		}
+		// Все команды и их флаги попадают в скрипт
+		for _, c := range commands() {
+			if !strings.Contains(stdout, c.name) {
+				t.Errorf("completion %s: missing command %s", shell, c.name)
+			}
+			for _, f := range commandFlags(c) {
+				flag := "-" + f.name
+				if shell == "fish" {
+					flag = "-o " + f.name
+				}
+				if !strings.Contains(stdout, flag) {
+					t.Errorf("completion %s: missing flag -%s of %s", shell, f.name, c.name)
+				}
+			}
+		}
+	}
+	if stdout, _, _ := runCLI(t, "", "completion", "zsh"); !strings.HasPrefix(stdout, "#compdef shorten\n") {
+		t.Errorf("expected a zsh completion file, got %.40q", stdout)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zen-flo/url-shortener/client"
+)
+
+// Output formats.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+/*
+output prints results in one of the output formats.
+*/
+type output struct {
+	w      io.Writer
+	format string
+}
+
+func newOutput(w io.Writer, format string) (output, error) {
+	switch format {
+	case formatTable, formatJSON, formatCSV:
+		return output{w: w, format: format}, nil
+	}
+	return output{}, fmt.Errorf("unknown output format %q, expected table, json or csv", format)
+}
+
+/*
+rows is a header with its rows.
+*/
+type rows struct {
+	header []string
+	rows   [][]string
+}
+
+/*
+print writes v as indented JSON, or tbl or csv for the other formats.
+*/
+func (o output) print(v any, tbl, csv rows) error {
+	switch o.format {
+	case formatJSON:
+		return o.json(v)
+	case formatCSV:
+		return o.csv(csv)
+	}
+	return o.table(tbl)
+}
+
+func (o output) json(v any) error {
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (o output) csv(r rows) error {
+	w := csv.NewWriter(o.w)
+	_ = w.Write(r.header)
+	_ = w.WriteAll(r.rows)
+	return w.Error()
+}
+
+/*
+table aligns the rows in columns under an upper-case header; no header prints only the rows.
+*/
+func (o output) table(r rows) error {
+	w := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	if r.header != nil {
+		fmt.Fprintln(w, strings.ToUpper(strings.Join(r.header, "\t")))
+	}
+	for _, row := range r.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// Columns of links in CSV; the table shows fewer to fit the terminal.
+var (
+	urlColumns      = []string{"id", "domain", "short", "short_url", "original", "clicks", "max_clicks", "clicks_left", "owner", "created_at", "disabled"}
+	urlTableColumns = []string{"short", "domain", "original", "clicks", "owner", "created"}
+)
+
+func urlRow(u client.URL) []string {
+	return []string{
+		strconv.Itoa(u.ID), u.Domain, u.Short, u.ShortURL, u.Original, strconv.Itoa(u.Clicks),
+		optionalInt(u.MaxClicks), optionalInt(u.ClicksLeft), u.Owner, u.CreatedAt.Format(time.RFC3339),
+		strconv.FormatBool(u.Disabled),
+	}
+}
+
+func urlTableRow(u client.URL) []string {
+	clicks := strconv.Itoa(u.Clicks)
+	if u.MaxClicks != nil {
+		clicks += "/" + strconv.Itoa(*u.MaxClicks)
+	}
+	return []string{u.Short, orDash(u.Domain), truncate(u.Original, 60), clicks, orDash(u.Owner), u.CreatedAt.Local().Format("2006-01-02 15:04")}
+}
+
+/*
+printURLs prints a list of links; v is what is printed as JSON.
+*/
+func (o output) printURLs(v any, urls []client.URL) error {
+	tbl := rows{header: urlTableColumns}
+	csv := rows{header: urlColumns}
+	for _, u := range urls {
+		tbl.rows = append(tbl.rows, urlTableRow(u))
+		csv.rows = append(csv.rows, urlRow(u))
+	}
+	return o.print(v, tbl, csv)
+}
+
+/*
+printURL prints one link, as a list of fields in the table format.
+*/
+func (o output) printURL(u *client.URL) error {
+	fields := [][]string{
+		{"Short URL:", firstNonEmpty(u.ShortURL, u.Short)},
+		{"Original:", u.Original},
+		{"Domain:", orDash(u.Domain)},
+		{"Owner:", orDash(u.Owner)},
+		{"Created:", u.CreatedAt.Local().Format(time.DateTime)},
+		{"Clicks:", strconv.Itoa(u.Clicks)},
+	}
+	if u.MaxClicks != nil {
+		fields = append(fields, []string{"Clicks left:", optionalInt(u.ClicksLeft) + " of " + strconv.Itoa(*u.MaxClicks)})
+	}
+	if u.Protected {
+		fields = append(fields, []string{"Password:", "yes"})
+	}
+	for i, r := range u.Rules {
+		fields = append(fields, []string{fmt.Sprintf("Rule %d:", i+1), r.Destination})
+	}
+	for _, v := range u.Variants {
+		fields = append(fields, []string{fmt.Sprintf("Variant %s:", firstNonEmpty(v.Name, "-")), fmt.Sprintf("%s (weight %d)", v.Destination, v.Weight)})
+	}
+	if opts := describeOptions(u.Options); opts != "" {
+		fields = append(fields, []string{"Options:", opts})
+	}
+	if u.Disabled {
+		fields = append(fields, []string{"Disabled:", firstNonEmpty(u.DisabledReason, "yes")})
+	}
+	if u.Metadata.Title != "" {
+		fields = append(fields, []string{"Title:", u.Metadata.Title})
+	}
+
+	if o.format == formatTable {
+		return o.table(rows{rows: fields})
+	}
+	return o.printURLs(u, []client.URL{*u})
+}
+
+/*
+printStats prints the clicks of a link, with a row per A/B variant.
+*/
+func (o output) printStats(s *client.Stats) error {
+	csv := rows{
+		header: []string{"short", "variant", "destination", "weight", "clicks", "max_clicks", "clicks_left"},
+		rows:   [][]string{{s.Short, "", "", "", strconv.Itoa(s.Clicks), optionalInt(s.MaxClicks), optionalInt(s.ClicksLeft)}},
+	}
+	for _, v := range s.Variants {
+		csv.rows = append(csv.rows, []string{s.Short, v.Name, v.Destination, strconv.Itoa(v.Weight), strconv.Itoa(v.Clicks), "", ""})
+	}
+	if o.format != formatTable {
+		return o.print(s, rows{}, csv)
+	}
+
+	fields := [][]string{{"Clicks:", strconv.Itoa(s.Clicks)}}
+	if s.MaxClicks != nil {
+		fields = append(fields, []string{"Clicks left:", optionalInt(s.ClicksLeft) + " of " + strconv.Itoa(*s.MaxClicks)})
+	}
+	if err := o.table(rows{rows: fields}); err != nil {
+		return err
+	}
+	if len(s.Variants) == 0 {
+		return nil
+	}
+	variants := rows{header: []string{"variant", "destination", "weight", "clicks"}}
+	for _, v := range s.Variants {
+		variants.rows = append(variants.rows, []string{orDash(v.Name), truncate(v.Destination, 60), strconv.Itoa(v.Weight), strconv.Itoa(v.Clicks)})
+	}
+	fmt.Fprintln(o.w)
+	return o.table(variants)
+}
+
+/*
+describeOptions summarizes the redirect options of a link on one line.
+*/
+func describeOptions(opts client.RedirectOptions) string {
+	var parts []string
+	if opts.ForwardQuery != "" {
+		parts = append(parts, "forward query ("+opts.ForwardQuery+")")
+	}
+	if opts.PassPath {
+		parts = append(parts, "pass path")
+	}
+	utm := opts.UTM.Params()
+	for _, name := range []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"} {
+		if v := utm[name]; v != "" {
+			parts = append(parts, name+"="+v)
+		}
+	}
+	if opts.Interstitial > 0 {
+		parts = append(parts, fmt.Sprintf("%ds countdown", opts.Interstitial))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func optionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+func orDash(s string) string {
+	return firstNonEmpty(s, "-")
+}
+
+/*
+truncate shortens s to at most n runes, ending it with an ellipsis.
+*/
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
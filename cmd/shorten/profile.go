@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/zen-flo/url-shortener/client"
+)
+
+func profileCommand() command {
+	return command{
+		name:    "profile",
+		args:    "list | set <name> | use <name> | remove <name>",
+		summary: "Manage the profiles of servers",
+		words:   []string{"list", "set", "use", "remove"},
+		setup: func(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+			format := fs.String("output", "", "default output format of the profile (set)")
+			makeDefault := fs.Bool("default", false, "make the profile the default one (set)")
+
+			return func(ctx context.Context, a *app, args []string) error {
+				if len(args) == 0 {
+					return usageError{"expected list, set, use or remove"}
+				}
+				cfg, err := a.config()
+				if err != nil {
+					return err
+				}
+				action, args := args[0], args[1:]
+				if action == "list" {
+					if err := wantArgs(args, 0); err != nil {
+						return err
+					}
+					return a.listProfiles(cfg)
+				}
+
+				if err := wantArgs(args, 1); err != nil {
+					return err
+				}
+				name := args[0]
+				switch action {
+				case "set":
+					p := cfg.Profiles[name]
+					if a.server != "" {
+						if _, err := client.New(a.server); err != nil {
+							return err
+						}
+						p.Server = a.server
+					}
+					if p.Server == "" {
+						return usageError{"a new profile needs -server"}
+					}
+					if a.token == "-" {
+						line, err := bufio.NewReader(a.stdin).ReadString('\n')
+						if line = strings.TrimSpace(line); line == "" {
+							return fmt.Errorf("no token on standard input: %w", err)
+						}
+						a.token = line
+					}
+					if a.token != "" {
+						p.Token = a.token
+					}
+					if *format != "" {
+						if _, err := newOutput(nil, *format); err != nil {
+							return err
+						}
+						p.Output = *format
+					}
+					cfg.Profiles[name] = p
+					if *makeDefault || cfg.Default == "" {
+						cfg.Default = name
+					}
+
+				case "use":
+					if _, ok := cfg.Profiles[name]; !ok {
+						return fmt.Errorf("profile %q not found", name)
+					}
+					cfg.Default = name
+
+				case "remove":
+					if _, ok := cfg.Profiles[name]; !ok {
+						return fmt.Errorf("profile %q not found", name)
+					}
+					delete(cfg.Profiles, name)
+					if cfg.Default == name {
+						cfg.Default = ""
+					}
+
+				default:
+					return usageError{fmt.Sprintf("unknown action %q, expected list, set, use or remove", action)}
+				}
+				return cfg.save(a.configPath)
+			}
+		},
+	}
+}
+
+/*
+listProfiles prints the profiles without their tokens.
+*/
+func (a *app) listProfiles(cfg *Config) error {
+	type profileInfo struct {
+		Name     string `json:"name"`
+		Server   string `json:"server"`
+		Output   string `json:"output,omitempty"`
+		HasToken bool   `json:"hasToken"`
+		Default  bool   `json:"default"`
+	}
+
+	out, err := a.out()
+	if err != nil {
+		return err
+	}
+	profiles := []profileInfo{}
+	tbl := rows{header: []string{"", "name", "server", "output", "token"}}
+	csv := rows{header: []string{"name", "server", "output", "has_token", "default"}}
+	for _, name := range cfg.names() {
+		p := cfg.Profiles[name]
+		info := profileInfo{Name: name, Server: p.Server, Output: p.Output, HasToken: p.Token != "", Default: name == cfg.Default}
+		profiles = append(profiles, info)
+
+		marker, token := "", "-"
+		if info.Default {
+			marker = "*"
+		}
+		if info.HasToken {
+			token = "yes"
+		}
+		tbl.rows = append(tbl.rows, []string{marker, name, p.Server, orDash(p.Output), token})
+		csv.rows = append(csv.rows, []string{name, p.Server, p.Output, fmt.Sprint(info.HasToken), fmt.Sprint(info.Default)})
+	}
+	return out.print(profiles, tbl, csv)
+}
@@ -15,9 +15,132 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/urls": {
+        "/admin/backups": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "List stored snapshots, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List database backups",
+                "responses": {
+                    "200": {
+                        "description": "Snapshots",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_backup.Snapshot"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "failed to list backups",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Generate a short link from the original URL",
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Take a consistent online snapshot of the database and prune old snapshots",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Create a database backup",
+                "responses": {
+                    "201": {
+                        "description": "Snapshot created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_backup.Snapshot"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "snapshot already exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "backup failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/domains": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List branded domains",
+                "responses": {
+                    "200": {
+                        "description": "Domains",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Domain"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Allow short links on a domain. Point its DNS at the service; redirects are resolved by the Host header.",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,38 +148,47 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "URLs"
+                    "Admin"
                 ],
-                "summary": "Create a shortened URL",
+                "summary": "Add a branded domain",
                 "parameters": [
                     {
-                        "description": "Original URL",
-                        "name": "url",
+                        "description": "Domain name and defaults for new links",
+                        "name": "domain",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Domain"
                         }
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "Successfully created",
+                        "description": "Domain added",
                         "schema": {
-                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.URL"
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Domain"
                         }
                     },
                     "400": {
-                        "description": "invalid JSON",
+                        "description": "invalid domain",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "internal server error",
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "domain already exists",
                         "schema": {
                             "type": "string"
                         }
@@ -64,35 +196,120 @@ const docTemplate = `{
                 }
             }
         },
-        "/urls/{short}": {
+        "/admin/domains/{name}": {
             "get": {
-                "description": "Retrieve the original URL by short code",
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "URLs"
+                    "Admin"
                 ],
-                "summary": "Get original URL",
+                "summary": "Get a branded domain",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "\"abc123\"",
-                        "description": "Short code",
-                        "name": "short",
+                        "example": "\"brand.link\"",
+                        "description": "Domain name",
+                        "name": "name",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Original URL retrieved successfully",
+                        "description": "Domain",
                         "schema": {
-                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.URL"
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Domain"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
                         }
                     },
                     "404": {
-                        "description": "URL not found",
+                        "description": "domain not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "The defaults apply to links created afterwards; existing links keep their settings.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Replace the defaults of a domain",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Domain name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Defaults for new links",
+                        "name": "defaults",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.DomainDefaults"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated domain",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Domain"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid domain",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "domain not found",
                         "schema": {
                             "type": "string"
                         }
@@ -100,17 +317,22 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Remove a short URL by its code",
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Only domains without links can be removed.",
                 "tags": [
-                    "URLs"
+                    "Admin"
                 ],
-                "summary": "Delete a shortened URL",
+                "summary": "Remove a branded domain",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "\"abc123\"",
-                        "description": "Short code",
-                        "name": "short",
+                        "example": "\"brand.link\"",
+                        "description": "Domain name",
+                        "name": "name",
                         "in": "path",
                         "required": true
                     }
@@ -122,37 +344,2551 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "failed to delete URL",
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "domain not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "domain still has links",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "github_com_zen-flo_url-shortener_internal_model.URL": {
-            "type": "object",
-            "properties": {
-                "createdAt": {
-                    "description": "Timestamp when URL was created",
-                    "type": "string"
-                },
-                "id": {
-                    "description": "Unique identifier",
-                    "type": "integer"
-                },
-                "original": {
-                    "description": "Original URL",
-                    "type": "string"
-                },
-                "short": {
-                    "description": "Shortened URL",
-                    "type": "string"
-                }
-            }
+        },
+        "/admin/domains/{name}/interstitial": {
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Every link of the domain, including existing ones, shows a countdown page with its destination before redirecting. Links with a longer countdown of their own keep it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Force an interstitial on a domain",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Domain name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Countdown in seconds",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handler.interstitialRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated domain",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Domain"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid domain",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "domain not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/events": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Live feed of the redirects of all links, as server-sent events (\"click\" events with a Click as data) or, with an Upgrade: websocket request, as WebSocket text messages with a Click each. Browsers may pass the token in the access_token query parameter, since EventSource and WebSocket cannot set headers. A client that falls behind gets a \"dropped\" event or close status 1008 and is disconnected; on shutdown it gets a \"closed\" event or close status 1001.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Stream the clicks of all links",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token for clients that cannot set headers",
+                        "name": "access_token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of clicks",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/keys": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "List all API keys, including revoked ones. Secrets are never returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List API keys",
+                "responses": {
+                    "200": {
+                        "description": "Keys",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.APIKey"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Issue a new API key with the given scopes. The key is returned only once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Create an API key",
+                "parameters": [
+                    {
+                        "description": "Key name and scopes",
+                        "name": "key",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Key created",
+                        "schema": {
+                            "$ref": "#/definitions/internal_handler.CreatedAPIKey"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Revoke an API key; requests using it are rejected immediately",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Revoke an API key",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "API key not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/linkcheck/run": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Check the destinations of all enabled links now and record the results, see GET /urls/{short}/health. Runs periodically in the background as well. With many links on few hosts this takes a while, since requests to one host are spaced out.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Check all link destinations",
+                "responses": {
+                    "200": {
+                        "description": "Check summary",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_service.HealthResult"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/screening/scan": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Screen the destinations of all enabled links again and disable those now blocked. Runs periodically in the background as well.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Rescan all link destinations",
+                "responses": {
+                    "200": {
+                        "description": "Scan summary",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_service.ScanResult"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/urls/{short}/disabled": {
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Visitors of a disabled link see a warning page with the reason instead of being redirected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Disable a link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Branded domain of the link",
+                        "name": "domain",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Reason",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handler.disableRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Disabled link",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.URL"
+                        }
+                    },
+                    "400": {
+                        "description": "reason is required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Resume redirects, e.g. after a false positive. A link still matching a blocklist is disabled again by the next rescan.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Enable a disabled link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Branded domain of the link",
+                        "name": "domain",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Enabled link",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.URL"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "List all webhook subscriptions. Secrets are never returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List webhooks",
+                "responses": {
+                    "200": {
+                        "description": "Webhooks",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Webhook"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Deliver link events to a URL as signed POST requests: link.created, link.updated, link.deleted, link.expired, link.clicked, link.broken and link.recovered, or \"*\" for all. The secret for verifying the X-Webhook-Signature header is returned only once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Subscribe to link events",
+                "parameters": [
+                    {
+                        "description": "Endpoint and event types",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Webhook created",
+                        "schema": {
+                            "$ref": "#/definitions/internal_handler.CreatedWebhook"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid webhook",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/deliveries/{id}/redeliver": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Queue a delivery again with a fresh set of retries, whatever its status. The payload is unchanged, so the event ID stays the same.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Redeliver a webhook delivery",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Delivery ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Delivery queued",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.WebhookDelivery"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "webhook delivery not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Webhook",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Webhook"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "webhook not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Change the URL, event types or active flag of a subscription; fields left out keep their values. Events are not queued for an inactive subscription, and deliveries queued before wait until it is active again.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Update a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated webhook",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid webhook",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "webhook not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Delete a subscription together with its queued, delivered and dead deliveries",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "webhook not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/{id}/deliveries": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "List the latest deliveries of a webhook, newest first. status=dead lists the dead letters: deliveries given up after the last retry.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List webhook deliveries",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "delivered",
+                            "dead"
+                        ],
+                        "type": "string",
+                        "description": "Only deliveries with this status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of deliveries, up to 500",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deliveries",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid webhook: unknown status",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "webhook not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/webhooks/{id}/redeliver": {
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Queue all dead deliveries of a webhook again with a fresh set of retries, e.g. after the endpoint recovered from an outage",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Redeliver dead webhook deliveries",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Number of deliveries queued\" example({\"queued\": 12})",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "webhook not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/urls": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "List links of all domains, newest first, optionally only those whose original URL or short code contains q. Requires a token; admins see the links of all owners, everyone else only their own. Where password-protected links lead is hidden from callers other than their owner and admins.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "List shortened URLs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"example.com\"",
+                        "description": "Text to search for in the original URL or short code",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Maximum number of links, up to 100",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of links to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Links and the total number of matches",
+                        "schema": {
+                            "$ref": "#/definitions/internal_handler.URLList"
+                        }
+                    },
+                    "400": {
+                        "description": "limit must be a positive number",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "request canceled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "request timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Generate a short link from the original URL",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Create a shortened URL",
+                "parameters": [
+                    {
+                        "description": "Original URL with optional branded domain, password, click limit, redirect rules, A/B variants and redirect options",
+                        "name": "url",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Successfully created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.URL"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid JSON",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "destination is blocked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "request canceled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "request timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/urls/{short}": {
+            "get": {
+                "description": "Retrieve the original URL by short code. The destinations of a password-protected link are left out unless the caller owns the link or is an admin.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Get original URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Branded domain of the link; the default domain if empty",
+                        "name": "domain",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Original URL retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.URL"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "request canceled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "request timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Remove a short URL by its code. Only the owner of the link and admins may delete it.",
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Delete a shortened URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Branded domain of the link; the default domain if empty",
+                        "name": "domain",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "only the owner of a link may change it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "failed to delete URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "request canceled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "request timed out",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/urls/{short}/events": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Live feed of the redirects of a short link, starting now, as server-sent events (\"click\" events with a Click as data) or, with an Upgrade: websocket request, as WebSocket text messages with a Click each. Requires the token of the owner of the link or an admin; browsers may pass it in the access_token query parameter, since EventSource and WebSocket cannot set headers. A client that falls behind gets a \"dropped\" event or close status 1008 and is disconnected; on shutdown it gets a \"closed\" event or close status 1001.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Stream the clicks of a link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Branded domain of the link; the default domain if empty",
+                        "name": "domain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Token for clients that cannot set headers",
+                        "name": "access_token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of clicks",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "clicks of a link are only streamed to its owner",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/urls/{short}/health": {
+            "get": {
+                "description": "Result of the latest periodic check of the link destinations: status, latency and redirect chain of every destination. A link is broken after several consecutive failed checks. checkedAt is empty until the first check. The destinations of a password-protected link are only shown to its owner and admins.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Get link health",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Branded domain of the link; the default domain if empty",
+                        "name": "domain",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Health",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.LinkHealth"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/urls/{short}/options": {
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Configure query string forwarding (merge or override), UTM parameters with placeholders and path suffix pass-through. An empty object turns them all off.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Replace redirect options",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Branded domain of the link; the default domain if empty",
+                        "name": "domain",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Redirect options",
+                        "name": "options",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.RedirectOptions"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated URL",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.URL"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid redirect options",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "only the owner of a link may change it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/urls/{short}/rules": {
+            "get": {
+                "description": "List the conditional redirect rules of a short link in evaluation order",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Get redirect rules",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Branded domain of the link; the default domain if empty",
+                        "name": "domain",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rules, empty if the link always redirects to the original URL",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Rule"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "rules of a password-protected link are only shown to its owner",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Replace the ordered redirect rules of a short link. The first matching rule wins; the original URL is the fallback. An empty array removes all rules.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Replace redirect rules",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Branded domain of the link; the default domain if empty",
+                        "name": "domain",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Rules in evaluation order",
+                        "name": "rules",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Rule"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated URL",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.URL"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid redirect rules",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "only the owner of a link may change it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "destination is blocked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/urls/{short}/stats": {
+            "get": {
+                "description": "Click counts of a short link, including every A/B variant. The variant destinations of a password-protected link are left out unless the caller owns the link or is an admin.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Get link statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Branded domain of the link; the default domain if empty",
+                        "name": "domain",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Statistics",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Stats"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/urls/{short}/variants": {
+            "put": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Split the traffic of a short link across weighted destinations. Visitors stick to their variant. Replacing the variants resets their click counts; an empty array removes the split.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URLs"
+                ],
+                "summary": "Replace A/B variants",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"brand.link\"",
+                        "description": "Branded domain of the link; the default domain if empty",
+                        "name": "domain",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Variants with weights",
+                        "name": "variants",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Variant"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated URL",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.URL"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid A/B variants",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "only the owner of a link may change it",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "destination is blocked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/{short}": {
+            "get": {
+                "description": "Redirect to the original URL of the link on the domain named by the Host header. Password-protected links show an HTML password prompt instead. Depending on the link options the query string is forwarded, UTM parameters are added and a path after the short code (/{short}/extra/path) is appended to the destination. Links with an interstitial, set on the link or its domain, show a countdown page before redirecting.\n\nA \"+\" after the short code (/{short}+) or preview=1 shows a preview of the link instead of following it, without counting a click: HTML, or JSON if requested with Accept: application/json or format=json.",
+                "produces": [
+                    "text/html",
+                    "application/json"
+                ],
+                "tags": [
+                    "Redirect"
+                ],
+                "summary": "Follow a short link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            1
+                        ],
+                        "type": "integer",
+                        "description": "Show the preview instead of redirecting",
+                        "name": "preview",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json"
+                        ],
+                        "type": "string",
+                        "description": "Preview format",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Password prompt, interstitial countdown or preview",
+                        "schema": {
+                            "$ref": "#/definitions/internal_handler.LinkPreview"
+                        }
+                    },
+                    "302": {
+                        "description": "Redirect to the original URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Warning page of a link disabled by screening",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found or path pass-through disabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "URL has reached its click limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Check the password from the prompt form and redirect to the original URL",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "Redirect"
+                ],
+                "summary": "Unlock a password-protected link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"abc123\"",
+                        "description": "Short code",
+                        "name": "short",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Link password",
+                        "name": "password",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirect to the original URL",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Wrong password",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Warning page of a link disabled by screening",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "URL not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "URL has reached its click limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many attempts",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "github_com_zen-flo_url-shortener_internal_backup.Snapshot": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.APIKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "Timestamp when the key was created",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Unique identifier",
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "Human-readable label",
+                    "type": "string"
+                },
+                "prefix": {
+                    "description": "First characters of the key, for identification",
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "description": "Timestamp when the key was revoked",
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "Comma-separated scopes, e.g. \"admin\"",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.DestinationHealth": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Why the request failed, e.g. a DNS or TLS error",
+                    "type": "string"
+                },
+                "latencyMs": {
+                    "description": "Time spent on the requests, including redirects",
+                    "type": "integer",
+                    "example": 120
+                },
+                "ok": {
+                    "description": "Whether the destination answered with a success, a redirect or 429",
+                    "type": "boolean"
+                },
+                "redirects": {
+                    "description": "Locations followed, in order",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "https://example.com/docs/"
+                    ]
+                },
+                "status": {
+                    "description": "Status code of the last response, 0 if there was none",
+                    "type": "integer",
+                    "example": 200
+                },
+                "url": {
+                    "description": "Destination as configured on the link",
+                    "type": "string",
+                    "example": "https://example.com/docs"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.Domain": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "Timestamp when the domain was added",
+                    "type": "string"
+                },
+                "defaults": {
+                    "description": "Settings applied to new links on the domain",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.DomainDefaults"
+                        }
+                    ]
+                },
+                "interstitial": {
+                    "description": "Countdown in seconds forced on every link of the domain, 0 if none",
+                    "type": "integer",
+                    "example": 5
+                },
+                "name": {
+                    "description": "Host name without port",
+                    "type": "string",
+                    "example": "brand.link"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.DomainDefaults": {
+            "type": "object",
+            "properties": {
+                "maxClicks": {
+                    "description": "Redirect limit of new links",
+                    "type": "integer"
+                },
+                "options": {
+                    "description": "Redirect options of new links",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.RedirectOptions"
+                        }
+                    ]
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.LinkHealth": {
+            "type": "object",
+            "properties": {
+                "broken": {
+                    "description": "Whether a destination has been failing long enough to count as dead",
+                    "type": "boolean"
+                },
+                "brokenSince": {
+                    "description": "When the link was first found broken",
+                    "type": "string"
+                },
+                "checkedAt": {
+                    "description": "Time of the latest check, empty if the link was not checked yet",
+                    "type": "string"
+                },
+                "destinations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.DestinationHealth"
+                    }
+                },
+                "failures": {
+                    "description": "Consecutive checks with a failing destination",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.Metadata": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "description": "Meta description, or og:description",
+                    "type": "string"
+                },
+                "fetchedAt": {
+                    "description": "When the page was fetched",
+                    "type": "string"
+                },
+                "image": {
+                    "description": "Absolute og:image URL",
+                    "type": "string",
+                    "example": "https://example.com/og.png"
+                },
+                "siteName": {
+                    "description": "og:site_name",
+                    "type": "string"
+                },
+                "title": {
+                    "description": "\u003ctitle\u003e, or og:title if the page has none",
+                    "type": "string",
+                    "example": "Example Domain"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.RedirectOptions": {
+            "type": "object",
+            "properties": {
+                "forwardQuery": {
+                    "description": "Forward the incoming query string: merge or override; off if empty",
+                    "type": "string",
+                    "enum": [
+                        "merge",
+                        "override"
+                    ]
+                },
+                "interstitial": {
+                    "description": "Seconds of a countdown page showing the destination before redirecting",
+                    "type": "integer",
+                    "example": 5
+                },
+                "passPath": {
+                    "description": "Append the path after the short code: /abc/x → destination/x",
+                    "type": "boolean"
+                },
+                "utm": {
+                    "description": "UTM parameters set on the destination",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.UTM"
+                        }
+                    ]
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.Rule": {
+            "type": "object",
+            "properties": {
+                "country": {
+                    "description": "ISO 3166-1 alpha-2 country of the client address",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "destination": {
+                    "description": "URL to redirect to when the rule matches",
+                    "type": "string"
+                },
+                "device": {
+                    "description": "Device class: mobile, tablet, desktop or bot",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "language": {
+                    "description": "Preferred language from Accept-Language, e.g. \"de\" or \"pt-BR\"",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "os": {
+                    "description": "Operating system: ios, android, windows, macos, chromeos or linux",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "query": {
+                    "description": "Query parameters that must equal the value; \"*\" only requires presence",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "time": {
+                    "description": "Time window in which the rule applies",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.TimeWindow"
+                        }
+                    ]
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.Stats": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "description": "Redirects served",
+                    "type": "integer"
+                },
+                "clicksLeft": {
+                    "description": "Redirects left before the link is gone",
+                    "type": "integer"
+                },
+                "maxClicks": {
+                    "description": "Redirect limit, nil if unlimited",
+                    "type": "integer"
+                },
+                "short": {
+                    "description": "Short code",
+                    "type": "string"
+                },
+                "variants": {
+                    "description": "Clicks per A/B variant",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.VariantStats"
+                    }
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.TimeWindow": {
+            "type": "object",
+            "properties": {
+                "days": {
+                    "description": "Days of the week: mon, tue, wed, thu, fri, sat, sun",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "from": {
+                    "description": "Start of the range as HH:MM, inclusive",
+                    "type": "string"
+                },
+                "timezone": {
+                    "description": "IANA time zone, UTC if empty",
+                    "type": "string"
+                },
+                "to": {
+                    "description": "End of the range as HH:MM, exclusive; earlier than From wraps past midnight",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.URL": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "description": "Number of redirects served",
+                    "type": "integer"
+                },
+                "clicksLeft": {
+                    "description": "Redirects left before the link is gone",
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "description": "Timestamp when URL was created",
+                    "type": "string"
+                },
+                "disabled": {
+                    "description": "Whether visitors see a warning instead of the redirect",
+                    "type": "boolean"
+                },
+                "disabledReason": {
+                    "description": "Why the link was disabled, e.g. the blocklist entry it matched",
+                    "type": "string"
+                },
+                "domain": {
+                    "description": "Branded domain of the link, empty for the default domain",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Unique identifier",
+                    "type": "integer"
+                },
+                "maxClicks": {
+                    "description": "Redirect limit, nil if unlimited",
+                    "type": "integer"
+                },
+                "metadata": {
+                    "description": "Title, description and image of the destination page",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Metadata"
+                        }
+                    ]
+                },
+                "options": {
+                    "description": "Query forwarding, UTM parameters, path pass-through and interstitial",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.RedirectOptions"
+                        }
+                    ]
+                },
+                "original": {
+                    "description": "Original URL",
+                    "type": "string"
+                },
+                "owner": {
+                    "description": "Display name of the authenticated creator, empty if anonymous",
+                    "type": "string"
+                },
+                "protected": {
+                    "description": "Whether the redirect asks for a password",
+                    "type": "boolean"
+                },
+                "rules": {
+                    "description": "Conditional redirects evaluated before falling back to Original",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Rule"
+                    }
+                },
+                "short": {
+                    "description": "Short code, unique per domain",
+                    "type": "string"
+                },
+                "shortUrl": {
+                    "description": "Full short link, e.g. https://brand.link/abc123",
+                    "type": "string"
+                },
+                "variants": {
+                    "description": "Weighted A/B split used instead of Original",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.Variant"
+                    }
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.UTM": {
+            "type": "object",
+            "properties": {
+                "campaign": {
+                    "description": "utm_campaign",
+                    "type": "string",
+                    "example": "spring"
+                },
+                "content": {
+                    "description": "utm_content",
+                    "type": "string",
+                    "example": "{variant}"
+                },
+                "medium": {
+                    "description": "utm_medium",
+                    "type": "string",
+                    "example": "email"
+                },
+                "source": {
+                    "description": "utm_source",
+                    "type": "string",
+                    "example": "newsletter"
+                },
+                "term": {
+                    "description": "utm_term",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.Variant": {
+            "type": "object",
+            "properties": {
+                "destination": {
+                    "description": "URL to redirect to",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Label shown in stats, e.g. \"A\"",
+                    "type": "string"
+                },
+                "weight": {
+                    "description": "Relative share of visitors, e.g. 70 and 30",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.VariantStats": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "description": "Redirects to this variant since the variants were last changed",
+                    "type": "integer"
+                },
+                "destination": {
+                    "description": "URL to redirect to",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Label shown in stats, e.g. \"A\"",
+                    "type": "string"
+                },
+                "weight": {
+                    "description": "Relative share of visitors, e.g. 70 and 30",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.Webhook": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Whether new events are delivered",
+                    "type": "boolean"
+                },
+                "createdAt": {
+                    "description": "Timestamp when the subscription was created",
+                    "type": "string"
+                },
+                "events": {
+                    "description": "Event types delivered, \"*\" for all",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "link.created",
+                        "link.clicked"
+                    ]
+                },
+                "id": {
+                    "description": "Unique identifier",
+                    "type": "integer"
+                },
+                "url": {
+                    "description": "Endpoint receiving the events",
+                    "type": "string",
+                    "example": "https://crm.example.com/hooks/links"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_model.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "Requests made so far",
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "description": "Timestamp when the event was queued",
+                    "type": "string"
+                },
+                "deliveredAt": {
+                    "description": "Timestamp of the successful attempt",
+                    "type": "string"
+                },
+                "event": {
+                    "description": "Event type",
+                    "type": "string",
+                    "example": "link.clicked"
+                },
+                "id": {
+                    "description": "Unique identifier",
+                    "type": "integer"
+                },
+                "lastError": {
+                    "description": "Why the last attempt failed",
+                    "type": "string"
+                },
+                "lastStatus": {
+                    "description": "Status code of the last response, 0 if there was none",
+                    "type": "integer",
+                    "example": 503
+                },
+                "nextAttemptAt": {
+                    "description": "When a pending delivery is tried next",
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "Request body, a WebhookEvent",
+                    "type": "object"
+                },
+                "status": {
+                    "description": "Delivery state",
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "delivered",
+                        "dead"
+                    ]
+                },
+                "webhookId": {
+                    "description": "Subscription the event is delivered to",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_service.HealthResult": {
+            "type": "object",
+            "properties": {
+                "broken": {
+                    "description": "Links found broken by this round",
+                    "type": "integer"
+                },
+                "checked": {
+                    "description": "Links checked",
+                    "type": "integer"
+                },
+                "recovered": {
+                    "description": "Broken links that work again",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_zen-flo_url-shortener_internal_service.ScanResult": {
+            "type": "object",
+            "properties": {
+                "disabled": {
+                    "description": "Links disabled by this scan",
+                    "type": "integer"
+                },
+                "scanned": {
+                    "description": "Links checked",
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_handler.CreatedAPIKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "description": "Timestamp when the key was created",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Unique identifier",
+                    "type": "integer"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "usk_5mD0mX2rJ4..."
+                },
+                "name": {
+                    "description": "Human-readable label",
+                    "type": "string"
+                },
+                "prefix": {
+                    "description": "First characters of the key, for identification",
+                    "type": "string"
+                },
+                "revokedAt": {
+                    "description": "Timestamp when the key was revoked",
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "Comma-separated scopes, e.g. \"admin\"",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_handler.CreatedWebhook": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Whether new events are delivered",
+                    "type": "boolean"
+                },
+                "createdAt": {
+                    "description": "Timestamp when the subscription was created",
+                    "type": "string"
+                },
+                "events": {
+                    "description": "Event types delivered, \"*\" for all",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "link.created",
+                        "link.clicked"
+                    ]
+                },
+                "id": {
+                    "description": "Unique identifier",
+                    "type": "integer"
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "whsec_Jx9pQ2..."
+                },
+                "url": {
+                    "description": "Endpoint receiving the events",
+                    "type": "string",
+                    "example": "https://crm.example.com/hooks/links"
+                }
+            }
+        },
+        "internal_handler.LinkPreview": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "destinations": {
+                    "description": "Original URL, then those of rules and variants; hidden while the link is locked",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "interstitial": {
+                    "description": "Seconds of the countdown shown before redirecting",
+                    "type": "integer"
+                },
+                "owner": {
+                    "description": "Display name of the creator",
+                    "type": "string"
+                },
+                "protected": {
+                    "type": "boolean"
+                },
+                "short": {
+                    "type": "string",
+                    "example": "abc123"
+                },
+                "shortUrl": {
+                    "type": "string",
+                    "example": "https://brand.link/abc123"
+                }
+            }
+        },
+        "internal_handler.URLList": {
+            "type": "object",
+            "properties": {
+                "total": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "urls": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_zen-flo_url-shortener_internal_model.URL"
+                    }
+                }
+            }
+        },
+        "internal_handler.disableRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "description": "Shown to visitors on the warning page",
+                    "type": "string",
+                    "example": "reported as phishing"
+                }
+            }
+        },
+        "internal_handler.interstitialRequest": {
+            "type": "object",
+            "properties": {
+                "seconds": {
+                    "description": "Countdown before redirecting, 0 removes it",
+                    "type": "integer",
+                    "example": 5
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "AdminToken": {
+            "description": "Admin token or API key with the admin scope, in the form \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -160,7 +2896,7 @@ const docTemplate = `{
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
 	Version:          "1.0",
-	Host:             "localhost:8080",
+	Host:             "",
 	BasePath:         "/",
 	Schemes:          []string{},
 	Title:            "URL Shortener API",
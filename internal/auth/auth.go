@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Scopes granted to principals.
+const (
+	// ScopeAdmin allows access to the /admin API and the dashboard.
+	ScopeAdmin = "admin"
+)
+
+// ErrInvalidCredentials is returned when a token does not identify any principal.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+/*
+Principal is the authenticated caller of a request.
+*/
+type Principal struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// KeyID is the ID of the API key the principal signed in with; zero for the admin token.
+	KeyID int `json:"key_id,omitempty"`
+}
+
+/*
+HasScope reports whether the principal was granted scope.
+*/
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+DisplayName returns the name shown to others, e.g. as the owner of a link:
+API keys appear by their name, without the "key:" prefix of the principal name.
+*/
+func (p *Principal) DisplayName() string {
+	return strings.TrimPrefix(p.Name, "key:")
+}
+
+/*
+Owns reports whether the principal may see and manage the links of owner: admins may
+manage all links, others only their own. A nil principal owns nothing.
+*/
+func (p *Principal) Owns(owner string) bool {
+	if p == nil {
+		return false
+	}
+	return p.HasScope(ScopeAdmin) || (owner != "" && owner == p.DisplayName())
+}
+
+/*
+ListOwner returns the owner whose links the principal may list: an empty string,
+meaning all owners, for admins and the principal's own name for everyone else.
+*/
+func (p *Principal) ListOwner() string {
+	if p.HasScope(ScopeAdmin) {
+		return ""
+	}
+	return p.DisplayName()
+}
+
+/*
+Authenticator resolves a bearer token or API key to a principal.
+*/
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Principal, error)
+}
+
+/*
+AuthenticatorFunc adapts a function to the Authenticator interface.
+*/
+type AuthenticatorFunc func(ctx context.Context, token string) (*Principal, error)
+
+// Authenticate calls f(ctx, token).
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	return f(ctx, token)
+}
+
+/*
+StaticToken authenticates a single bootstrap token with the admin scope.
+It is used to create the first API keys; an empty token never matches.
+*/
+func StaticToken(token string) Authenticator {
+	return AuthenticatorFunc(func(_ context.Context, candidate string) (*Principal, error) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) != 1 {
+			return nil, ErrInvalidCredentials
+		}
+		return TokenPrincipal(), nil
+	})
+}
+
+/*
+TokenPrincipal returns the principal of the bootstrap admin token.
+*/
+func TokenPrincipal() *Principal {
+	return &Principal{Name: "admin-token", Scopes: []string{ScopeAdmin}}
+}
+
+/*
+Chain tries each authenticator in order and returns the first principal found.
+*/
+func Chain(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, token string) (*Principal, error) {
+		for _, a := range authenticators {
+			p, err := a.Authenticate(ctx, token)
+			if err == nil {
+				return p, nil
+			}
+			if !errors.Is(err, ErrInvalidCredentials) {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidCredentials
+	})
+}
+
+/*
+TokenFromRequest extracts the credential from "Authorization: Bearer <token>" or the X-API-Key header.
+*/
+func TokenFromRequest(r *http.Request) string {
+	const prefix = "Bearer "
+	if h := r.Header.Get("Authorization"); len(h) > len(prefix) && strings.EqualFold(h[:len(prefix)], prefix) {
+		return strings.TrimSpace(h[len(prefix):])
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+type principalKey struct{}
+
+/*
+WithPrincipal returns a copy of ctx carrying the authenticated principal.
+*/
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+/*
+PrincipalFromContext returns the principal stored in ctx, or nil for anonymous requests.
+*/
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	failing := AuthenticatorFunc(func(context.Context, string) (*Principal, error) {
+		return nil, errors.New("database is down")
+	})
+	ctx := context.Background()
+
+	if p, err := Chain(StaticToken("secret"), failing).Authenticate(ctx, "secret"); err != nil || p.Name != "admin-token" {
+		t.Errorf("expected the static token to match first, got %v, %v", p, err)
+	}
+	if _, err := Chain(StaticToken("secret"), failing).Authenticate(ctx, "other"); err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected backend errors to be propagated, got %v", err)
+	}
+	if _, err := Chain(StaticToken("")).Authenticate(ctx, ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected an empty static token to never match, got %v", err)
+	}
+}
+
+func TestOwns(t *testing.T) {
+	key := &Principal{Name: "key:marketing"}
+	admin := &Principal{Name: "admin-token", Scopes: []string{ScopeAdmin}}
+	var anonymous *Principal
+
+	if !key.Owns("marketing") || key.Owns("sales") || key.Owns("") {
+		t.Error("expected a key to own only the links created with it")
+	}
+	if !admin.Owns("sales") || !admin.Owns("") {
+		t.Error("expected admins to own all links")
+	}
+	if anonymous.Owns("") {
+		t.Error("expected anonymous callers to own nothing")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	s := NewSessions([]byte("secret"), "session", "/admin/ui", time.Hour)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	rec := httptest.NewRecorder()
+	issued, err := s.Issue(rec, httptest.NewRequest(http.MethodPost, "/admin/ui/login", nil), &Principal{Name: "key:alice", Scopes: []string{ScopeAdmin}, KeyID: 7})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	cookie := rec.Result().Cookies()[0]
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode || cookie.Path != "/admin/ui" {
+		t.Errorf("unexpected cookie attributes %+v", cookie)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/ui/", nil)
+	req.AddCookie(cookie)
+	session, err := s.Verify(req)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if session.ID != issued.ID || session.KeyID != 7 {
+		t.Errorf("unexpected session %+v", session)
+	}
+
+	token := s.CSRFToken(session)
+	if !s.ValidCSRF(session, token) || s.ValidCSRF(session, "") || s.ValidCSRF(&Session{ID: "other"}, token) {
+		t.Error("CSRF token must be valid only for its own session")
+	}
+
+	// Tampered cookie
+	tampered := httptest.NewRequest(http.MethodGet, "/admin/ui/", nil)
+	tampered.AddCookie(&http.Cookie{Name: "session", Value: "x" + cookie.Value})
+	if _, err := s.Verify(tampered); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("expected ErrInvalidSession for a tampered cookie, got %v", err)
+	}
+
+	// Expired session
+	s.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if _, err := s.Verify(req); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("expected ErrInvalidSession for an expired cookie, got %v", err)
+	}
+}
+
+func TestSigner(t *testing.T) {
+	s := NewSigner([]byte("secret"))
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	token := s.Sign("unlock:abc123", time.Minute)
+	if !s.Verify(token, "unlock:abc123") {
+		t.Fatal("expected the token to be valid")
+	}
+	if s.Verify(token, "unlock:other") || s.Verify("x"+token, "unlock:abc123") {
+		t.Error("expected the token to be bound to its value and signature")
+	}
+	if NewSigner([]byte("other")).Verify(token, "unlock:abc123") {
+		t.Error("expected tokens of another secret to be rejected")
+	}
+
+	s.now = func() time.Time { return now.Add(time.Minute) }
+	if s.Verify(token, "unlock:abc123") {
+		t.Error("expected the token to expire")
+	}
+}
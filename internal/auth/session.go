@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSession is returned for missing, tampered or expired session cookies.
+var ErrInvalidSession = errors.New("invalid session")
+
+/*
+Session is the signed content of a browser session cookie.
+It names the API key the user signed in with rather than its scopes,
+so that the key is looked up, and can be revoked, on every request.
+*/
+type Session struct {
+	KeyID     int    `json:"k"`
+	ID        string `json:"id"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+/*
+Sessions issues and verifies HMAC-signed session cookies and derives CSRF tokens from them,
+so no server-side session storage is needed.
+*/
+type Sessions struct {
+	CookieName string
+	Path       string
+	TTL        time.Duration
+
+	secret []byte
+	now    func() time.Time
+}
+
+/*
+NewSessions creates a session manager signing cookies with secret.
+An empty secret is replaced by a random one, which invalidates sessions on restart.
+*/
+func NewSessions(secret []byte, cookieName, path string, ttl time.Duration) *Sessions {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	}
+	return &Sessions{CookieName: cookieName, Path: path, TTL: ttl, secret: secret, now: time.Now}
+}
+
+/*
+Issue creates a session for the principal and sets it as a cookie on the response.
+*/
+func (s *Sessions) Issue(w http.ResponseWriter, r *http.Request, p *Principal) (*Session, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	session := &Session{
+		KeyID:     p.KeyID,
+		ID:        base64.RawURLEncoding.EncodeToString(id),
+		ExpiresAt: s.now().Add(s.TTL).Unix(),
+	}
+
+	payload, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.CookieName,
+		Value:    encoded + "." + s.sign(encoded),
+		Path:     s.Path,
+		Expires:  time.Unix(session.ExpiresAt, 0),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return session, nil
+}
+
+/*
+Verify returns the session carried by the request cookie.
+*/
+func (s *Sessions) Verify(r *http.Request) (*Session, error) {
+	c, err := r.Cookie(s.CookieName)
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+	encoded, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(encoded))) {
+		return nil, ErrInvalidSession
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+	var session Session
+	if err := json.Unmarshal(payload, &session); err != nil {
+		return nil, ErrInvalidSession
+	}
+	if s.now().Unix() >= session.ExpiresAt {
+		return nil, ErrInvalidSession
+	}
+	return &session, nil
+}
+
+/*
+Clear removes the session cookie.
+*/
+func (s *Sessions) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.CookieName,
+		Value:    "",
+		Path:     s.Path,
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+/*
+CSRFToken returns the anti-forgery token bound to the session.
+*/
+func (s *Sessions) CSRFToken(session *Session) string {
+	return s.sign("csrf:" + session.ID)
+}
+
+/*
+ValidCSRF reports whether token matches the session's anti-forgery token.
+*/
+func (s *Sessions) ValidCSRF(session *Session, token string) bool {
+	return hmac.Equal([]byte(token), []byte(s.CSRFToken(session)))
+}
+
+func (s *Sessions) sign(value string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
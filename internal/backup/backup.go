@@ -0,0 +1,293 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// filePrefix and fileExt frame the timestamp in snapshot file names, e.g. urls-20251030T120000.000Z.db.
+	filePrefix = "urls-"
+	fileExt    = ".db"
+	// checksumExt is appended to the snapshot name for its sha256sum-compatible checksum file.
+	checksumExt = ".sha256"
+	// timeLayout sorts lexicographically in chronological order.
+	timeLayout = "20060102T150405.000Z"
+)
+
+var (
+	// ErrChecksumMismatch is returned when a snapshot does not match its recorded checksum.
+	ErrChecksumMismatch = errors.New("snapshot checksum mismatch")
+	// ErrSnapshotExists is returned when a snapshot with the same timestamp was already taken.
+	ErrSnapshotExists = errors.New("snapshot already exists")
+)
+
+/*
+Snapshot describes a backup file.
+*/
+type Snapshot struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"-"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"sha256"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+/*
+Manager takes consistent online snapshots of a SQLite database into a directory
+and keeps the most recent Retain of them.
+*/
+type Manager struct {
+	DB     *sqlx.DB
+	Dir    string
+	Retain int
+
+	now func() time.Time
+}
+
+/*
+NewManager creates a backup manager. A retain value below 1 keeps every snapshot.
+*/
+func NewManager(database *sqlx.DB, dir string, retain int) *Manager {
+	return &Manager{DB: database, Dir: dir, Retain: retain, now: time.Now}
+}
+
+/*
+Create writes a new snapshot with VACUUM INTO, which produces a consistent, compacted copy
+while the database stays online, records its checksum and prunes old snapshots.
+*/
+func (m *Manager) Create(ctx context.Context) (Snapshot, error) {
+	if err := os.MkdirAll(m.Dir, 0o750); err != nil {
+		return Snapshot{}, fmt.Errorf("create backup dir: %w", err)
+	}
+
+	createdAt := m.now().UTC().Truncate(time.Millisecond)
+	name := filePrefix + createdAt.Format(timeLayout) + fileExt
+	path := filepath.Join(m.Dir, name)
+
+	// Write into a directory of this call alone, so that concurrent snapshots never share a file
+	tmpDir, err := os.MkdirTemp(m.Dir, ".snapshot-")
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("create temporary dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	tmp := filepath.Join(tmpDir, name)
+	if _, err := m.DB.ExecContext(ctx, "VACUUM INTO ?", tmp); err != nil {
+		return Snapshot{}, fmt.Errorf("vacuum into %s: %w", tmp, err)
+	}
+
+	sum, size, err := checksumFile(tmp)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	// Linking fails instead of replacing a snapshot taken at the same instant
+	if err := os.Link(tmp, path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return Snapshot{}, fmt.Errorf("%w: %s", ErrSnapshotExists, name)
+		}
+		return Snapshot{}, fmt.Errorf("finalize snapshot: %w", err)
+	}
+	if err := writeChecksum(path, sum); err != nil {
+		return Snapshot{}, err
+	}
+
+	if err := m.prune(); err != nil {
+		return Snapshot{}, err
+	}
+
+	return Snapshot{Name: name, Path: path, Size: size, Checksum: sum, CreatedAt: createdAt}, nil
+}
+
+/*
+List returns the snapshots in the backup directory, newest first.
+*/
+func (m *Manager) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(m.Dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read backup dir: %w", err)
+	}
+
+	var snapshots []Snapshot
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileExt) {
+			continue
+		}
+		createdAt, err := time.Parse(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileExt))
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(m.Dir, name)
+		sum, _ := readChecksum(path)
+		snapshots = append(snapshots, Snapshot{
+			Name:      name,
+			Path:      path,
+			Size:      info.Size(),
+			Checksum:  sum,
+			CreatedAt: createdAt,
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+/*
+prune removes the oldest snapshots beyond the retention limit.
+*/
+func (m *Manager) prune() error {
+	if m.Retain < 1 {
+		return nil
+	}
+	snapshots, err := m.List()
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots[min(m.Retain, len(snapshots)):] {
+		if err := os.Remove(s.Path); err != nil {
+			return fmt.Errorf("remove old snapshot: %w", err)
+		}
+		_ = os.Remove(s.Path + checksumExt)
+	}
+	return nil
+}
+
+/*
+Verify checks a snapshot against its checksum file and runs SQLite's integrity check on it.
+*/
+func Verify(ctx context.Context, path string) error {
+	want, err := readChecksum(path)
+	if err != nil {
+		return err
+	}
+	got, _, err := checksumFile(path)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, path)
+	}
+
+	snapshot, err := sqlx.Open("sqlite", path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("open snapshot: %w", err)
+	}
+	defer snapshot.Close()
+
+	var result string
+	if err := snapshot.GetContext(ctx, &result, "PRAGMA integrity_check"); err != nil {
+		return fmt.Errorf("integrity check: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	return nil
+}
+
+/*
+Restore verifies a snapshot and atomically replaces the database file at dbPath with it.
+The server must be stopped: stale WAL and shared-memory files are removed.
+*/
+func Restore(ctx context.Context, snapshotPath, dbPath string) error {
+	if err := Verify(ctx, snapshotPath); err != nil {
+		return err
+	}
+
+	tmp := dbPath + ".restore"
+	if err := copyFile(snapshotPath, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("remove %s: %w", dbPath+suffix, err)
+		}
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("replace database: %w", err)
+	}
+	return nil
+}
+
+func checksumFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, fmt.Errorf("checksum snapshot: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+/*
+writeChecksum stores the checksum in sha256sum format, so `sha256sum -c` can verify it too.
+*/
+func writeChecksum(path, sum string) error {
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+	if err := os.WriteFile(path+checksumExt, []byte(line), 0o640); err != nil {
+		return fmt.Errorf("write checksum: %w", err)
+	}
+	return nil
+}
+
+func readChecksum(path string) (string, error) {
+	data, err := os.ReadFile(path + checksumExt)
+	if err != nil {
+		return "", fmt.Errorf("read checksum: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file for %s", path)
+	}
+	return fields[0], nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open snapshot: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("copy snapshot: %w", err)
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("sync %s: %w", dst, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,117 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/db"
+)
+
+func TestCreateVerifyRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "urls.db")
+
+	database, err := db.InitDB(dbPath)
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	if _, err := database.Exec("INSERT INTO urls (original, short, created_at) VALUES ('https://example.com', 'abc123', CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	ctx := context.Background()
+	m := NewManager(database, filepath.Join(dir, "backups"), 2)
+
+	// Take three snapshots one second apart; only the newest two are retained
+	base := time.Date(2025, 10, 30, 12, 0, 0, 0, time.UTC)
+	var last Snapshot
+	for i := 0; i < 3; i++ {
+		m.now = func() time.Time { return base.Add(time.Duration(i) * time.Second) }
+		last, err = m.Create(ctx)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	snapshots, err := m.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 retained snapshots, got %d", len(snapshots))
+	}
+	if snapshots[0].Name != last.Name || snapshots[0].Checksum != last.Checksum {
+		t.Errorf("expected newest snapshot %s first, got %s", last.Name, snapshots[0].Name)
+	}
+
+	if err := Verify(ctx, last.Path); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	// A second snapshot at the same instant fails instead of replacing the first
+	if _, err := m.Create(ctx); !errors.Is(err, ErrSnapshotExists) {
+		t.Fatalf("expected ErrSnapshotExists, got %v", err)
+	}
+	if err := Verify(ctx, last.Path); err != nil {
+		t.Fatalf("expected the first snapshot to stay intact, got %v", err)
+	}
+	if entries, _ := os.ReadDir(m.Dir); len(entries) != 4 {
+		t.Errorf("expected only the snapshots and their checksums to be left, got %d entries", len(entries))
+	}
+
+	// Changes after the snapshot are rolled back by the restore
+	if _, err := database.Exec("DELETE FROM urls"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	database.Close()
+
+	if err := Restore(ctx, last.Path, dbPath); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	restored, err := db.InitDB(dbPath)
+	if err != nil {
+		t.Fatalf("InitDB after restore failed: %v", err)
+	}
+	defer restored.Close()
+
+	var count int
+	if err := restored.Get(&count, "SELECT COUNT(*) FROM urls"); err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 url after restore, got %d", count)
+	}
+}
+
+func TestVerifyDetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	database, err := db.InitDB(filepath.Join(dir, "urls.db"))
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer database.Close()
+
+	snapshot, err := NewManager(database, dir, 0).Create(context.Background())
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	f, err := os.OpenFile(snapshot.Path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open snapshot failed: %v", err)
+	}
+	_, _ = f.Write([]byte("garbage"))
+	f.Close()
+
+	if err := Verify(context.Background(), snapshot.Path); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if err := Restore(context.Background(), snapshot.Path, filepath.Join(dir, "other.db")); err == nil {
+		t.Errorf("expected restore of corrupted snapshot to fail")
+	}
+}
@@ -0,0 +1,76 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+/*
+ACMEConfig configures certificates obtained automatically from an ACME CA.
+*/
+type ACMEConfig struct {
+	// DirectoryURL of the CA; empty means Let's Encrypt
+	DirectoryURL string
+	// RootCAs trusted when talking to the CA, e.g. that of a local Pebble; nil means the system pool
+	RootCAs *x509.CertPool
+	Email   string
+	// CacheDir stores the account key and issued certificates between restarts
+	CacheDir string
+	// Hosts are always allowed to obtain a certificate
+	Hosts []string
+	// Allow is consulted for other hosts, e.g. to accept registered branded domains
+	Allow func(ctx context.Context, host string) bool
+}
+
+/*
+NewACMEManager returns an autocert manager that issues certificates on the first
+TLS handshake for an allowed host and renews them before they expire.
+*/
+func NewACMEManager(cfg ACMEConfig) *autocert.Manager {
+	m := &autocert.Manager{
+		Prompt: autocert.AcceptTOS,
+		Email:  cfg.Email,
+		HostPolicy: func(ctx context.Context, host string) error {
+			if slices.Contains(cfg.Hosts, host) || (cfg.Allow != nil && cfg.Allow(ctx, host)) {
+				return nil
+			}
+			return fmt.Errorf("acme: host %q is not configured", host)
+		},
+	}
+	if cfg.CacheDir != "" {
+		m.Cache = autocert.DirCache(cfg.CacheDir)
+	}
+	if cfg.DirectoryURL != "" || cfg.RootCAs != nil {
+		client := &acme.Client{DirectoryURL: cfg.DirectoryURL}
+		if cfg.RootCAs != nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = &tls.Config{RootCAs: cfg.RootCAs}
+			client.HTTPClient = &http.Client{Transport: transport}
+		}
+		m.Client = client
+	}
+	return m
+}
+
+/*
+LoadRoots reads a PEM bundle of CA certificates.
+*/
+func LoadRoots(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CA roots: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
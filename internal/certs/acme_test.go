@@ -0,0 +1,407 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+/*
+testCA is a minimal stand-in for an ACME server such as Pebble. It implements the
+RFC 8555 order flow with http-01 validation against validateAt, but does not verify
+JWS signatures. Every order holds a single identifier and a single authorization.
+*/
+type testCA struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+
+	// validateAt is the address of the HTTP listener answering http-01 challenges
+	validateAt string
+
+	mu         sync.Mutex
+	thumbprint string
+	orders     []*testOrder
+	nonce      int
+}
+
+type testOrder struct {
+	domain string
+	status string // of the order
+	authz  string // of its authorization
+	cert   []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ACME CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	ca := &testCA{t: t, key: key, cert: cert}
+	ca.srv = httptest.NewTLSServer(http.HandlerFunc(ca.serveHTTP))
+	t.Cleanup(ca.srv.Close)
+	return ca
+}
+
+/*
+roots returns a pool trusting the TLS certificate of the directory.
+*/
+func (ca *testCA) roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.srv.Certificate())
+	return pool
+}
+
+func (ca *testCA) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	ca.nonce++
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", ca.nonce))
+	base := ca.srv.URL
+
+	if r.URL.Path == "/dir" {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"newNonce":   base + "/nonce",
+			"newAccount": base + "/account",
+			"newOrder":   base + "/order",
+			"revokeCert": base + "/revoke",
+			"keyChange":  base + "/key-change",
+		})
+		return
+	}
+	if r.URL.Path == "/nonce" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	protected, payload, err := decodeJWS(r.Body)
+	if err != nil {
+		ca.t.Errorf("ACME stand-in: %s %s: %v", r.Method, r.URL.Path, err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"type": "urn:ietf:params:acme:error:malformed"})
+		return
+	}
+
+	var id int
+	switch {
+	case r.URL.Path == "/account":
+		thumbprint, err := jwkThumbprint(protected.JWK)
+		if err != nil {
+			ca.t.Errorf("ACME stand-in: account key: %v", err)
+			return
+		}
+		ca.thumbprint = thumbprint
+		w.Header().Set("Location", base+"/account/1")
+		writeJSON(w, http.StatusCreated, map[string]string{"status": "valid"})
+
+	case r.URL.Path == "/order":
+		var req struct {
+			Identifiers []acme.AuthzID `json:"identifiers"`
+		}
+		if err := json.Unmarshal(payload, &req); err != nil || len(req.Identifiers) != 1 {
+			ca.t.Errorf("ACME stand-in: unexpected order %s", payload)
+			return
+		}
+		ca.orders = append(ca.orders, &testOrder{domain: req.Identifiers[0].Value, status: "pending", authz: "pending"})
+		id = len(ca.orders) - 1
+		w.Header().Set("Location", fmt.Sprintf("%s/order/%d", base, id))
+		writeJSON(w, http.StatusCreated, ca.orderJSON(id))
+
+	case sscanf(r.URL.Path, "/order/%d", &id) && ca.exists(id):
+		w.Header().Set("Location", fmt.Sprintf("%s/order/%d", base, id))
+		writeJSON(w, http.StatusOK, ca.orderJSON(id))
+
+	case sscanf(r.URL.Path, "/authz/%d", &id) && ca.exists(id):
+		writeJSON(w, http.StatusOK, ca.authzJSON(id))
+
+	case sscanf(r.URL.Path, "/chal/%d", &id) && ca.exists(id):
+		if err := ca.validate(id); err != nil {
+			ca.orders[id].authz, ca.orders[id].status = "invalid", "invalid"
+			ca.t.Logf("ACME stand-in: validation failed: %v", err)
+		} else {
+			ca.orders[id].authz, ca.orders[id].status = "valid", "ready"
+		}
+		writeJSON(w, http.StatusOK, ca.challengeJSON(id))
+
+	case sscanf(r.URL.Path, "/finalize/%d", &id) && ca.exists(id):
+		var req struct {
+			CSR string `json:"csr"`
+		}
+		if err := json.Unmarshal(payload, &req); err != nil || ca.orders[id].status != "ready" {
+			writeJSON(w, http.StatusForbidden, map[string]string{"type": "urn:ietf:params:acme:error:orderNotReady"})
+			return
+		}
+		cert, err := ca.issue(req.CSR, ca.orders[id].domain)
+		if err != nil {
+			ca.t.Errorf("ACME stand-in: issue: %v", err)
+			writeJSON(w, http.StatusBadRequest, map[string]string{"type": "urn:ietf:params:acme:error:badCSR"})
+			return
+		}
+		ca.orders[id].cert, ca.orders[id].status = cert, "valid"
+		w.Header().Set("Location", fmt.Sprintf("%s/order/%d", base, id))
+		writeJSON(w, http.StatusOK, ca.orderJSON(id))
+
+	case sscanf(r.URL.Path, "/cert/%d", &id) && ca.exists(id) && ca.orders[id].cert != nil:
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		_, _ = w.Write(ca.orders[id].cert)
+
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"type": "urn:ietf:params:acme:error:malformed"})
+	}
+}
+
+func (ca *testCA) exists(id int) bool {
+	return id >= 0 && id < len(ca.orders)
+}
+
+func (ca *testCA) orderJSON(id int) map[string]any {
+	o := ca.orders[id]
+	res := map[string]any{
+		"status":         o.status,
+		"identifiers":    []acme.AuthzID{{Type: "dns", Value: o.domain}},
+		"authorizations": []string{fmt.Sprintf("%s/authz/%d", ca.srv.URL, id)},
+		"finalize":       fmt.Sprintf("%s/finalize/%d", ca.srv.URL, id),
+	}
+	if o.cert != nil {
+		res["certificate"] = fmt.Sprintf("%s/cert/%d", ca.srv.URL, id)
+	}
+	return res
+}
+
+func (ca *testCA) authzJSON(id int) map[string]any {
+	return map[string]any{
+		"status":     ca.orders[id].authz,
+		"identifier": acme.AuthzID{Type: "dns", Value: ca.orders[id].domain},
+		"challenges": []map[string]any{ca.challengeJSON(id)},
+	}
+}
+
+/*
+challengeJSON offers http-01 only, so that the test exercises the HTTP listener.
+*/
+func (ca *testCA) challengeJSON(id int) map[string]any {
+	return map[string]any{
+		"type":   "http-01",
+		"url":    fmt.Sprintf("%s/chal/%d", ca.srv.URL, id),
+		"token":  fmt.Sprintf("token-%d", id),
+		"status": ca.orders[id].authz,
+	}
+}
+
+/*
+validate fetches the http-01 key authorization for the order's domain.
+*/
+func (ca *testCA) validate(id int) error {
+	token := fmt.Sprintf("token-%d", id)
+	req, err := http.NewRequest(http.MethodGet, "http://"+ca.validateAt+"/.well-known/acme-challenge/"+token, nil)
+	if err != nil {
+		return err
+	}
+	req.Host = ca.orders[id].domain
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if want := token + "." + ca.thumbprint; strings.TrimSpace(string(body)) != want {
+		return fmt.Errorf("got key authorization %q (status %d), want %q", body, resp.StatusCode, want)
+	}
+	return nil
+}
+
+func (ca *testCA) issue(csrB64, domain string) ([]byte, error) {
+	der, err := base64.RawURLEncoding.DecodeString(csrB64)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, err
+	}
+	if len(csr.DNSNames) != 1 || csr.DNSNames[0] != domain {
+		return nil, fmt.Errorf("CSR names %v do not match the order for %s", csr.DNSNames, domain)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leaf, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, csr.PublicKey, ca.key)
+	if err != nil {
+		return nil, err
+	}
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf})
+	return append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...), nil
+}
+
+type jwsHeader struct {
+	JWK json.RawMessage `json:"jwk"`
+	KID string          `json:"kid"`
+	URL string          `json:"url"`
+}
+
+/*
+decodeJWS returns the protected header and payload of a flattened JWS,
+without checking the signature.
+*/
+func decodeJWS(body io.Reader) (jwsHeader, []byte, error) {
+	var header jwsHeader
+	var jws struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+	}
+	if err := json.NewDecoder(body).Decode(&jws); err != nil {
+		return header, nil, err
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(jws.Protected)
+	if err != nil {
+		return header, nil, err
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return header, nil, err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	return header, payload, err
+}
+
+/*
+jwkThumbprint computes the RFC 7638 thumbprint of an EC account key.
+*/
+func jwkThumbprint(raw json.RawMessage) (string, error) {
+	var jwk struct {
+		Kty, Crv, X, Y string
+	}
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return "", err
+	}
+	if jwk.Kty != "EC" || jwk.Crv != "P-256" {
+		return "", fmt.Errorf("unsupported account key %s %s", jwk.Kty, jwk.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return "", err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return "", err
+	}
+	pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	return acme.JWKThumbprint(pub)
+}
+
+func sscanf(path, format string, id *int) bool {
+	_, err := fmt.Sscanf(path, format, id)
+	return err == nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestACMEManager(t *testing.T) {
+	ca := newTestCA(t)
+	m := NewACMEManager(ACMEConfig{
+		DirectoryURL: ca.srv.URL + "/dir",
+		RootCAs:      ca.roots(),
+		CacheDir:     t.TempDir(),
+		Hosts:        []string{"sho.rt"},
+		Allow: func(_ context.Context, host string) bool {
+			return host == "brand.link"
+		},
+	})
+
+	// The plain HTTP listener answers http-01 challenges and redirects everything else
+	web := httptest.NewServer(m.HTTPHandler(RedirectHTTPS(443)))
+	t.Cleanup(web.Close)
+	ca.validateAt = web.Listener.Addr().String()
+
+	hello := func(name string) *tls.ClientHelloInfo {
+		return &tls.ClientHelloInfo{
+			ServerName:        name,
+			CipherSuites:      []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+			SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+			SupportedCurves:   []tls.CurveID{tls.CurveP256},
+			SupportedVersions: []uint16{tls.VersionTLS13},
+		}
+	}
+
+	for _, host := range []string{"sho.rt", "brand.link"} {
+		cert, err := m.GetCertificate(hello(host))
+		if err != nil {
+			t.Fatalf("GetCertificate(%s) failed: %v", host, err)
+		}
+		if err := cert.Leaf.CheckSignatureFrom(ca.cert); err != nil {
+			t.Errorf("expected the certificate for %s to be issued by the test CA: %v", host, err)
+		}
+		if err := cert.Leaf.VerifyHostname(host); err != nil {
+			t.Errorf("expected the certificate to be valid for %s: %v", host, err)
+		}
+	}
+
+	if _, err := m.GetCertificate(hello("other.link")); err == nil {
+		t.Error("expected a host outside the policy to be refused")
+	}
+	ca.mu.Lock()
+	issued := len(ca.orders)
+	ca.mu.Unlock()
+	if issued != 2 {
+		t.Errorf("expected 2 orders, got %d", issued)
+	}
+
+	// Issued certificates are served from memory and the cache without a new order
+	if _, err := m.GetCertificate(hello("brand.link")); err != nil {
+		t.Fatalf("GetCertificate from cache failed: %v", err)
+	}
+	ca.mu.Lock()
+	issued = len(ca.orders)
+	ca.mu.Unlock()
+	if issued != 2 {
+		t.Errorf("expected the cached certificate to be reused, got %d orders", issued)
+	}
+}
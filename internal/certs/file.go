@@ -0,0 +1,109 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+FileCertificate serves a certificate and key loaded from PEM files.
+The files are re-read when their modification time changes, so renewed
+certificates are picked up without restarting the server.
+*/
+type FileCertificate struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+/*
+NewFileCertificate loads the certificate pair, failing if it cannot be used.
+*/
+func NewFileCertificate(certFile, keyFile string) (*FileCertificate, error) {
+	c := &FileCertificate{certFile: certFile, keyFile: keyFile}
+	if _, err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+/*
+GetCertificate returns the current certificate. It is meant for tls.Config.GetCertificate.
+*/
+func (c *FileCertificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+/*
+Reload re-reads the files if either changed since the last load and reports whether
+the certificate was replaced. A pair that fails to load keeps the previous certificate.
+*/
+func (c *FileCertificate) Reload() (bool, error) {
+	certMod, err := modTime(c.certFile)
+	if err != nil {
+		return false, err
+	}
+	keyMod, err := modTime(c.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.RLock()
+	unchanged := c.cert != nil && certMod.Equal(c.certMod) && keyMod.Equal(c.keyMod)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("load TLS certificate: %w", err)
+	}
+
+	c.mu.Lock()
+	c.cert, c.certMod, c.keyMod = &cert, certMod, keyMod
+	c.mu.Unlock()
+	return true, nil
+}
+
+/*
+Watch checks the files for changes every interval until ctx is done.
+*/
+func (c *FileCertificate) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := c.Reload()
+			if err != nil {
+				// Certificate and key are often replaced one after the other; retry on the next tick
+				slog.Warn("failed to reload TLS certificate, keeping the previous one", "error", err)
+				continue
+			}
+			if reloaded {
+				slog.Info("TLS certificate reloaded", "path", c.certFile)
+			}
+		}
+	}
+}
+
+func modTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("stat TLS file: %w", err)
+	}
+	return info.ModTime(), nil
+}
@@ -0,0 +1,108 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/*
+writeSelfSigned writes a self-signed certificate for name and its key, with the given
+modification time so that consecutive writes are told apart on coarse file systems.
+*/
+func writeSelfSigned(t *testing.T, certFile, keyFile, name string, mod time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), mod)
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), mod)
+}
+
+func writeFile(t *testing.T, path string, data []byte, mod time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedName(t *testing.T, c *FileCertificate) string {
+	t.Helper()
+	cert, err := c.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("GetCertificate failed: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestFileCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Hour)
+	writeSelfSigned(t, certFile, keyFile, "old.example", start)
+
+	c, err := NewFileCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewFileCertificate failed: %v", err)
+	}
+	if got := servedName(t, c); got != "old.example" {
+		t.Fatalf("expected old.example, got %s", got)
+	}
+
+	if reloaded, err := c.Reload(); err != nil || reloaded {
+		t.Fatalf("expected unchanged files to be skipped, got %v %v", reloaded, err)
+	}
+
+	writeSelfSigned(t, certFile, keyFile, "new.example", start.Add(time.Minute))
+	if reloaded, err := c.Reload(); err != nil || !reloaded {
+		t.Fatalf("expected the certificate to be reloaded, got %v %v", reloaded, err)
+	}
+	if got := servedName(t, c); got != "new.example" {
+		t.Errorf("expected new.example after reload, got %s", got)
+	}
+
+	// A certificate that no longer matches its key is rejected and the previous one kept
+	writeFile(t, keyFile, []byte("not a key"), start.Add(2*time.Minute))
+	if _, err := c.Reload(); err == nil {
+		t.Error("expected a broken key to fail the reload")
+	}
+	if got := servedName(t, c); got != "new.example" {
+		t.Errorf("expected the previous certificate to be kept, got %s", got)
+	}
+
+	if _, err := NewFileCertificate(filepath.Join(dir, "missing.crt"), keyFile); err == nil {
+		t.Error("expected missing files to fail")
+	}
+}
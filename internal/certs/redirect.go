@@ -0,0 +1,28 @@
+package certs
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/*
+RedirectHTTPS returns a handler that permanently redirects every request to the same
+URL over HTTPS on httpsPort. The default port 443 is left out of the Location.
+*/
+func RedirectHTTPS(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]")
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		// 308 keeps the method and body of API calls, unlike 301
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,35 @@
+package certs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHTTPS(t *testing.T) {
+	tests := []struct {
+		port   int
+		host   string
+		target string
+		want   string
+	}{
+		{443, "sho.rt", "/abc?x=1", "https://sho.rt/abc?x=1"},
+		{443, "sho.rt:80", "/abc", "https://sho.rt/abc"},
+		{8443, "localhost:8080", "/urls", "https://localhost:8443/urls"},
+		{443, "[::1]:8080", "/", "https://[::1]/"},
+		{8443, "[::1]", "/", "https://[::1]:8443/"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.target, nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		RedirectHTTPS(tt.port).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s: expected 308, got %d", tt.host, rec.Code)
+		}
+		if got := rec.Header().Get("Location"); got != tt.want {
+			t.Errorf("%s: expected Location %s, got %s", tt.host, tt.want, got)
+		}
+	}
+}
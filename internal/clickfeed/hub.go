@@ -0,0 +1,159 @@
+package clickfeed
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+// DefaultBuffer is the number of clicks a subscriber may fall behind before it is disconnected.
+const DefaultBuffer = 64
+
+var (
+	subscribers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "click_feed_subscribers",
+			Help: "Current number of click feed subscribers.",
+		},
+	)
+
+	dropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "click_feed_dropped_total",
+			Help: "Total number of click feed subscribers disconnected for falling behind.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(subscribers)
+	prometheus.MustRegister(dropped)
+}
+
+/*
+Hub fans out clicks to subscribers. Publishing never blocks: a subscriber whose buffer
+is full is disconnected, so that one slow reader cannot hold up redirects.
+*/
+type Hub struct {
+	buffer int
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+/*
+Subscription receives the clicks matching its filter on C until it is closed.
+C is closed when the subscription is closed or dropped for falling behind.
+*/
+type Subscription struct {
+	C <-chan model.Click
+
+	ch      chan model.Click
+	filter  func(model.Click) bool
+	hub     *Hub
+	dropped bool // guarded by hub.mu
+}
+
+/*
+New creates a hub with the given per-subscriber buffer; values below 1 use DefaultBuffer.
+*/
+func New(buffer int) *Hub {
+	if buffer < 1 {
+		buffer = DefaultBuffer
+	}
+	return &Hub{buffer: buffer, subs: make(map[*Subscription]struct{})}
+}
+
+/*
+Subscribe returns a subscription to the clicks for which filter returns true; a nil filter receives all clicks.
+The caller must close the subscription when done. After Close the subscription is already closed.
+*/
+func (h *Hub) Subscribe(filter func(model.Click) bool) *Subscription {
+	ch := make(chan model.Click, h.buffer)
+	s := &Subscription{C: ch, ch: ch, filter: filter, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return s
+	}
+	h.subs[s] = struct{}{}
+	subscribers.Inc()
+	return s
+}
+
+/*
+Publish sends the click to all matching subscribers without waiting for them.
+*/
+func (h *Hub) Publish(c model.Click) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if s.filter != nil && !s.filter(c) {
+			continue
+		}
+		select {
+		case s.ch <- c:
+		default:
+			s.dropped = true
+			h.remove(s)
+			dropped.Inc()
+		}
+	}
+}
+
+/*
+Len returns the number of subscribers.
+*/
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+/*
+Close ends all subscriptions, e.g. when the server shuts down, so that their streams finish
+instead of holding up the shutdown. Unlike dropped subscriptions they are not reported as Dropped.
+*/
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		h.remove(s)
+	}
+}
+
+/*
+remove closes the channel of a subscription. The caller holds h.mu.
+*/
+func (h *Hub) remove(s *Subscription) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	close(s.ch)
+	subscribers.Dec()
+}
+
+/*
+Close ends the subscription. It is safe to call more than once.
+*/
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	s.hub.remove(s)
+	s.hub.mu.Unlock()
+}
+
+/*
+Dropped reports whether the subscription was closed because it fell behind.
+*/
+func (s *Subscription) Dropped() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.dropped
+}
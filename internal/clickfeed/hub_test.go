@@ -0,0 +1,86 @@
+package clickfeed
+
+import (
+	"testing"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+func TestHub(t *testing.T) {
+	hub := New(2)
+	all := hub.Subscribe(nil)
+	defer all.Close()
+	one := hub.Subscribe(func(c model.Click) bool { return c.Short == "abc" })
+	defer one.Close()
+
+	hub.Publish(model.Click{Short: "abc"})
+	hub.Publish(model.Click{Short: "xyz"})
+
+	if c := <-all.C; c.Short != "abc" {
+		t.Errorf("expected abc first, got %s", c.Short)
+	}
+	if c := <-all.C; c.Short != "xyz" {
+		t.Errorf("expected xyz second, got %s", c.Short)
+	}
+	if c := <-one.C; c.Short != "abc" {
+		t.Errorf("expected the filtered subscriber to get abc, got %s", c.Short)
+	}
+	select {
+	case c := <-one.C:
+		t.Errorf("expected the filter to skip %s", c.Short)
+	default:
+	}
+	if hub.Len() != 2 {
+		t.Errorf("expected 2 subscribers, got %d", hub.Len())
+	}
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	hub := New(1)
+	slow := hub.Subscribe(nil)
+	fast := hub.Subscribe(nil)
+	defer fast.Close()
+
+	hub.Publish(model.Click{Short: "a"})
+	<-fast.C
+	hub.Publish(model.Click{Short: "b"})
+
+	if !slow.Dropped() || hub.Len() != 1 {
+		t.Fatalf("expected the slow subscriber to be dropped, %d left", hub.Len())
+	}
+	// The buffered click is still delivered before the channel reports the end
+	if c, ok := <-slow.C; !ok || c.Short != "a" {
+		t.Errorf("expected the buffered click, got %v %v", c, ok)
+	}
+	if _, ok := <-slow.C; ok {
+		t.Error("expected the channel of a dropped subscriber to be closed")
+	}
+	if c := <-fast.C; c.Short != "b" {
+		t.Errorf("expected the fast subscriber to keep receiving, got %s", c.Short)
+	}
+
+	slow.Close()
+	if fast.Dropped() {
+		t.Error("expected the fast subscriber not to be dropped")
+	}
+}
+
+func TestHubClose(t *testing.T) {
+	hub := New(1)
+	sub := hub.Subscribe(nil)
+	hub.Close()
+
+	if _, ok := <-sub.C; ok {
+		t.Error("expected the subscription to be closed")
+	}
+	if sub.Dropped() || hub.Len() != 0 {
+		t.Errorf("expected a closed, not dropped subscription, %d left", hub.Len())
+	}
+	late := hub.Subscribe(nil)
+	if _, ok := <-late.C; ok {
+		t.Error("expected subscriptions after Close to be closed")
+	}
+	hub.Publish(model.Click{Short: "a"})
+	sub.Close()
+	late.Close()
+}
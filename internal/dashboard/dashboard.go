@@ -0,0 +1,424 @@
+package dashboard
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+//go:embed static
+var staticFS embed.FS
+
+// PageSize is the number of links shown per page.
+const PageSize = 25
+
+// csrfField is the name of the hidden form field carrying the CSRF token.
+const csrfField = "csrf_token"
+
+/*
+LinkStore is the part of the URL service used by the dashboard.
+*/
+type LinkStore interface {
+	CreateShortURL(ctx context.Context, original string, opts ...service.CreateOption) (*model.URL, error)
+	GetOriginalURL(ctx context.Context, domain, short string) (*model.URL, error)
+	UpdateURL(ctx context.Context, domain, short, original string) (*model.URL, error)
+	DeleteURL(ctx context.Context, domain, short string) error
+	ListURLs(ctx context.Context, owner, query string, limit, offset int) ([]model.URL, int, error)
+	Stats(ctx context.Context, domain, short string) (*model.Stats, error)
+	ListDomains(ctx context.Context) ([]model.Domain, error)
+}
+
+/*
+KeyStore is the part of the API key service used by the dashboard.
+*/
+type KeyStore interface {
+	CreateAPIKey(ctx context.Context, name string, scopes []string) (*model.APIKey, string, error)
+	ListAPIKeys(ctx context.Context) ([]model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int) error
+	KeyPrincipal(ctx context.Context, id int) (*auth.Principal, error)
+}
+
+/*
+Dashboard is the server-rendered admin UI.
+Users sign in with the admin token or an API key with the admin scope and receive a session cookie;
+every form submission must carry the CSRF token bound to that session.
+*/
+type Dashboard struct {
+	Links    LinkStore
+	Keys     KeyStore
+	Auth     auth.Authenticator
+	Sessions *auth.Sessions
+
+	pages map[string]*template.Template
+}
+
+/*
+New creates a dashboard. Templates are parsed once from the embedded files.
+*/
+func New(links LinkStore, keys KeyStore, authn auth.Authenticator, sessions *auth.Sessions) *Dashboard {
+	d := &Dashboard{Links: links, Keys: keys, Auth: authn, Sessions: sessions, pages: map[string]*template.Template{}}
+	for _, page := range []string{"login.html", "links.html", "link.html", "keys.html"} {
+		d.pages[page] = template.Must(template.New("").Funcs(funcs).ParseFS(templateFS, "templates/layout.html", "templates/"+page))
+	}
+	return d
+}
+
+var funcs = template.FuncMap{
+	"datetime": func(v interface{}) string {
+		switch t := v.(type) {
+		case time.Time:
+			return t.Format("2006-01-02 15:04")
+		case *time.Time:
+			if t != nil {
+				return t.Format("2006-01-02 15:04")
+			}
+		}
+		return ""
+	},
+	"linkPath": linkPath,
+	"percent": func(part, total int) int {
+		if total <= 0 {
+			return 0
+		}
+		return part * 100 / total
+	},
+}
+
+/*
+Routes returns the dashboard handler. It is meant to be mounted under a prefix, e.g. /admin/ui.
+*/
+func (d *Dashboard) Routes() http.Handler {
+	r := chi.NewRouter()
+
+	static, _ := fs.Sub(staticFS, "static")
+	r.Handle("/static/*", http.StripPrefix(d.prefix("/static/"), http.FileServer(http.FS(static))))
+
+	r.Get("/login", d.loginForm)
+	r.Post("/login", d.login)
+
+	r.Group(func(r chi.Router) {
+		r.Use(d.requireSession)
+		r.Post("/logout", d.logout)
+		r.Get("/", d.listLinks)
+		r.Post("/links", d.createLink)
+		r.Get("/links/{short}", d.editLink)
+		r.Post("/links/{short}", d.updateLink)
+		r.Post("/links/{short}/delete", d.deleteLink)
+		r.Get("/keys", d.listKeys)
+		r.Post("/keys", d.createKey)
+		r.Post("/keys/{id}/revoke", d.revokeKey)
+	})
+	return r
+}
+
+type sessionKey struct{}
+
+/*
+requireSession redirects anonymous users and users whose API key was revoked to the login page
+and rejects state-changing requests without a valid CSRF token.
+*/
+func (d *Dashboard) requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := d.Sessions.Verify(r)
+		if err != nil {
+			http.Redirect(w, r, d.prefix("/login"), http.StatusSeeOther)
+			return
+		}
+		p, err := d.principal(r.Context(), session)
+		if errors.Is(err, service.ErrAPIKeyNotFound) || (err == nil && !p.HasScope(auth.ScopeAdmin)) {
+			d.Sessions.Clear(w)
+			http.Redirect(w, r, d.prefix("/login"), http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			d.serverError(w, r, err)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead &&
+			!d.Sessions.ValidCSRF(session, r.PostFormValue(csrfField)) {
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+
+		ctx := auth.WithPrincipal(r.Context(), p)
+		ctx = context.WithValue(ctx, sessionKey{}, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/*
+principal looks up the principal of a session: the admin token or the API key it was opened with.
+*/
+func (d *Dashboard) principal(ctx context.Context, session *auth.Session) (*auth.Principal, error) {
+	if session.KeyID == 0 {
+		return auth.TokenPrincipal(), nil
+	}
+	return d.Keys.KeyPrincipal(ctx, session.KeyID)
+}
+
+func (d *Dashboard) loginForm(w http.ResponseWriter, r *http.Request) {
+	d.render(w, r, "login.html", map[string]interface{}{})
+}
+
+func (d *Dashboard) login(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSpace(r.PostFormValue("token"))
+	p, err := d.Auth.Authenticate(r.Context(), token)
+	if err != nil || !p.HasScope(auth.ScopeAdmin) {
+		if err != nil && !errors.Is(err, auth.ErrInvalidCredentials) {
+			logger.FromContext(r.Context()).ErrorContext(r.Context(), "dashboard login failed", "error", err)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		d.render(w, r, "login.html", map[string]interface{}{"Error": "Invalid token or missing admin scope"})
+		return
+	}
+
+	if _, err := d.Sessions.Issue(w, r, p); err != nil {
+		d.serverError(w, r, err)
+		return
+	}
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "dashboard login", "principal", p.Name)
+	http.Redirect(w, r, d.prefix("/"), http.StatusSeeOther)
+}
+
+func (d *Dashboard) logout(w http.ResponseWriter, r *http.Request) {
+	d.Sessions.Clear(w)
+	http.Redirect(w, r, d.prefix("/login"), http.StatusSeeOther)
+}
+
+func (d *Dashboard) listLinks(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	// Only admins sign in, so the links of all owners are listed
+	links, total, err := d.Links.ListURLs(r.Context(), "", query, PageSize, (page-1)*PageSize)
+	if err != nil {
+		d.serverError(w, r, err)
+		return
+	}
+	domains, err := d.Links.ListDomains(r.Context())
+	if err != nil {
+		d.serverError(w, r, err)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Query":   query,
+		"Links":   links,
+		"Domains": domains,
+		"Total":   total,
+		"Page":    page,
+		"Error":   r.URL.Query().Get("error"),
+	}
+	if page > 1 {
+		data["PrevPage"] = page - 1
+	}
+	if page*PageSize < total {
+		data["NextPage"] = page + 1
+	}
+	d.render(w, r, "links.html", data)
+}
+
+func (d *Dashboard) createLink(w http.ResponseWriter, r *http.Request) {
+	var opts []service.CreateOption
+	if domain := r.PostFormValue("domain"); domain != "" {
+		opts = append(opts, service.WithDomain(domain))
+	}
+	if password := r.PostFormValue("password"); password != "" {
+		opts = append(opts, service.WithPassword(password))
+	}
+	if v := r.PostFormValue("max_clicks"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			n = 0 // rejected by the service as not positive
+		}
+		opts = append(opts, service.WithMaxClicks(n))
+	}
+	if p := auth.PrincipalFromContext(r.Context()); p != nil {
+		opts = append(opts, service.WithOwner(p.DisplayName()))
+	}
+	link, err := d.Links.CreateShortURL(r.Context(), strings.TrimSpace(r.PostFormValue("original")), opts...)
+	if errors.Is(err, service.ErrEmptyURL) || errors.Is(err, service.ErrInvalidURL) || errors.Is(err, service.ErrPasswordLong) || errors.Is(err, service.ErrMaxClicks) ||
+		errors.Is(err, service.ErrDomainNotFound) || errors.Is(err, service.ErrBlocked) {
+		http.Redirect(w, r, d.prefix("/?error="+url.QueryEscape(err.Error())), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		d.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, d.prefix(linkPath(*link, "")), http.StatusSeeOther)
+}
+
+func (d *Dashboard) editLink(w http.ResponseWriter, r *http.Request) {
+	domain, short := linkKey(r)
+	link, err := d.Links.GetOriginalURL(r.Context(), domain, short)
+	if errors.Is(err, service.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		d.serverError(w, r, err)
+		return
+	}
+	d.renderLink(w, r, http.StatusOK, map[string]interface{}{"Link": link})
+}
+
+/*
+renderLink renders the link page together with its click statistics.
+*/
+func (d *Dashboard) renderLink(w http.ResponseWriter, r *http.Request, code int, data map[string]interface{}) {
+	if link, ok := data["Link"].(*model.URL); ok && link != nil {
+		stats, err := d.Links.Stats(r.Context(), link.Domain, link.Short)
+		if err != nil {
+			d.serverError(w, r, err)
+			return
+		}
+		data["Stats"] = stats
+	}
+	if code != http.StatusOK {
+		w.WriteHeader(code)
+	}
+	d.render(w, r, "link.html", data)
+}
+
+func (d *Dashboard) updateLink(w http.ResponseWriter, r *http.Request) {
+	domain, short := linkKey(r)
+	link, err := d.Links.UpdateURL(r.Context(), domain, short, strings.TrimSpace(r.PostFormValue("original")))
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, service.ErrEmptyURL), errors.Is(err, service.ErrInvalidURL), errors.Is(err, service.ErrBlocked):
+		link, _ = d.Links.GetOriginalURL(r.Context(), domain, short)
+		d.renderLink(w, r, http.StatusBadRequest, map[string]interface{}{"Link": link, "Error": err.Error()})
+	case err != nil:
+		d.serverError(w, r, err)
+	default:
+		d.renderLink(w, r, http.StatusOK, map[string]interface{}{"Link": link, "Saved": true})
+	}
+}
+
+func (d *Dashboard) deleteLink(w http.ResponseWriter, r *http.Request) {
+	domain, short := linkKey(r)
+	err := d.Links.DeleteURL(r.Context(), domain, short)
+	if err != nil && !errors.Is(err, service.ErrNotFound) {
+		d.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, d.prefix("/"), http.StatusSeeOther)
+}
+
+func (d *Dashboard) listKeys(w http.ResponseWriter, r *http.Request) {
+	d.renderKeys(w, r, http.StatusOK, map[string]interface{}{})
+}
+
+func (d *Dashboard) createKey(w http.ResponseWriter, r *http.Request) {
+	scopes := strings.Split(r.PostFormValue("scopes"), ",")
+	key, plaintext, err := d.Keys.CreateAPIKey(r.Context(), r.PostFormValue("name"), scopes)
+	if errors.Is(err, service.ErrInvalidAPIKey) {
+		d.renderKeys(w, r, http.StatusBadRequest, map[string]interface{}{"Error": err.Error()})
+		return
+	}
+	if err != nil {
+		d.serverError(w, r, err)
+		return
+	}
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "API key created", "id", key.ID, "name", key.Name, "scopes", key.Scopes)
+	d.renderKeys(w, r, http.StatusCreated, map[string]interface{}{"NewKey": key, "Plaintext": plaintext})
+}
+
+func (d *Dashboard) revokeKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := d.Keys.RevokeAPIKey(r.Context(), id); err != nil && !errors.Is(err, service.ErrAPIKeyNotFound) {
+		d.serverError(w, r, err)
+		return
+	}
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "API key revoked", "id", id)
+	http.Redirect(w, r, d.prefix("/keys"), http.StatusSeeOther)
+}
+
+func (d *Dashboard) renderKeys(w http.ResponseWriter, r *http.Request, code int, data map[string]interface{}) {
+	keys, err := d.Keys.ListAPIKeys(r.Context())
+	if err != nil {
+		d.serverError(w, r, err)
+		return
+	}
+	data["Keys"] = keys
+	if code != http.StatusOK {
+		w.WriteHeader(code)
+	}
+	d.render(w, r, "keys.html", data)
+}
+
+/*
+render executes a page template. Common values — the mount prefix, the signed-in principal
+and the CSRF token — are added to data.
+*/
+func (d *Dashboard) render(w http.ResponseWriter, r *http.Request, page string, data map[string]interface{}) {
+	data["Base"] = d.prefix("")
+	if session, ok := r.Context().Value(sessionKey{}).(*auth.Session); ok {
+		data["Principal"] = auth.PrincipalFromContext(r.Context()).Name
+		data["CSRF"] = d.Sessions.CSRFToken(session)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	if err := d.pages[page].ExecuteTemplate(w, "layout", data); err != nil {
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "failed to render dashboard page", "page", page, "error", err)
+	}
+}
+
+func (d *Dashboard) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	logger.FromContext(r.Context()).ErrorContext(r.Context(), "dashboard request failed", "error", err)
+	http.Error(w, "internal server error", http.StatusInternalServerError)
+}
+
+/*
+linkPath returns the dashboard path of a link followed by suffix.
+Links on branded domains carry the domain as a query parameter.
+*/
+func linkPath(link model.URL, suffix string) string {
+	path := "/links/" + url.PathEscape(link.Short) + suffix
+	if link.Domain != "" {
+		path += "?domain=" + url.QueryEscape(link.Domain)
+	}
+	return path
+}
+
+/*
+linkKey returns the domain and short code of the link addressed by the request.
+*/
+func linkKey(r *http.Request) (domain, short string) {
+	return r.URL.Query().Get("domain"), chi.URLParam(r, "short")
+}
+
+/*
+prefix returns path under the dashboard mount point, which is the session cookie path.
+*/
+func (d *Dashboard) prefix(path string) string {
+	return strings.TrimSuffix(d.Sessions.Path, "/") + path
+}
@@ -0,0 +1,234 @@
+package dashboard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	_ "modernc.org/sqlite" // SQLite driver
+
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+type testClient struct {
+	t       *testing.T
+	handler http.Handler
+	cookies []*http.Cookie
+}
+
+func (c *testClient) do(method, path string, form url.Values) *httptest.ResponseRecorder {
+	var req *http.Request
+	if form != nil {
+		req = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest(method, path, nil)
+	}
+	for _, cookie := range c.cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	c.handler.ServeHTTP(rec, req)
+	if cookies := rec.Result().Cookies(); len(cookies) > 0 {
+		c.cookies = cookies
+	}
+	return rec
+}
+
+var csrfPattern = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+func setupDashboard(t *testing.T) (*testClient, *service.URLService) {
+	database, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to connect to in-memory DB: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	links := service.NewURLService(database)
+	keys := service.NewAPIKeyService(database)
+	authn := auth.Chain(auth.StaticToken("secret"), keys)
+	d := New(links, keys, authn, auth.NewSessions([]byte("key"), "admin_session", "/admin/ui", time.Hour))
+
+	r := chi.NewRouter()
+	r.Mount("/admin/ui", d.Routes())
+	return &testClient{t: t, handler: r}, links
+}
+
+func TestDashboardRequiresLogin(t *testing.T) {
+	c, _ := setupDashboard(t)
+
+	rec := c.do(http.MethodGet, "/admin/ui/", nil)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/admin/ui/login" {
+		t.Fatalf("expected redirect to login, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = c.do(http.MethodPost, "/admin/ui/login", url.Values{"token": {"wrong"}})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong token, got %d", rec.Code)
+	}
+
+	rec = c.do(http.MethodGet, "/admin/ui/static/style.css", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Type"), "text/css") {
+		t.Fatalf("expected embedded stylesheet, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestDashboardLinksAndKeys(t *testing.T) {
+	c, links := setupDashboard(t)
+	ctx := context.Background()
+
+	rec := c.do(http.MethodPost, "/admin/ui/login", url.Values{"token": {"secret"}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect after login, got %d", rec.Code)
+	}
+
+	rec = c.do(http.MethodGet, "/admin/ui/", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected links page, got %d", rec.Code)
+	}
+	m := csrfPattern.FindStringSubmatch(rec.Body.String())
+	if m == nil {
+		t.Fatal("CSRF token not found in the page")
+	}
+	csrf := m[1]
+
+	// Forms without the CSRF token are rejected
+	rec = c.do(http.MethodPost, "/admin/ui/links", url.Values{"original": {"https://example.com"}})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without CSRF token, got %d", rec.Code)
+	}
+
+	rec = c.do(http.MethodPost, "/admin/ui/links", url.Values{"original": {"https://example.com"}, "csrf_token": {csrf}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect after create, got %d", rec.Code)
+	}
+	short := strings.TrimPrefix(rec.Header().Get("Location"), "/admin/ui/links/")
+
+	rec = c.do(http.MethodPost, "/admin/ui/links/"+short, url.Values{"original": {"https://example.org"}, "csrf_token": {csrf}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected edit page after update, got %d", rec.Code)
+	}
+	if got, _ := links.GetOriginalURL(ctx, "", short); got == nil || got.Original != "https://example.org" {
+		t.Errorf("expected the link to be updated, got %+v", got)
+	}
+
+	rec = c.do(http.MethodGet, "/admin/ui/?q=example.org", nil)
+	if !strings.Contains(rec.Body.String(), short) {
+		t.Errorf("expected search results to contain %s", short)
+	}
+
+	rec = c.do(http.MethodPost, "/admin/ui/keys", url.Values{"name": {"ci"}, "scopes": {"admin"}, "csrf_token": {csrf}})
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), "usk_") {
+		t.Fatalf("expected the new key to be shown once, got %d", rec.Code)
+	}
+
+	rec = c.do(http.MethodPost, "/admin/ui/links/"+short+"/delete", url.Values{"csrf_token": {csrf}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect after delete, got %d", rec.Code)
+	}
+	if _, err := links.GetOriginalURL(ctx, "", short); err == nil {
+		t.Error("expected the link to be deleted")
+	}
+
+	rec = c.do(http.MethodPost, "/admin/ui/logout", url.Values{"csrf_token": {csrf}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect after logout, got %d", rec.Code)
+	}
+	if rec = c.do(http.MethodGet, "/admin/ui/", nil); rec.Code != http.StatusSeeOther {
+		t.Errorf("expected the session to be cleared, got %d", rec.Code)
+	}
+}
+
+func TestDashboardRevokedKeyEndsSession(t *testing.T) {
+	admin, _ := setupDashboard(t)
+	admin.do(http.MethodPost, "/admin/ui/login", url.Values{"token": {"secret"}})
+	m := csrfPattern.FindStringSubmatch(admin.do(http.MethodGet, "/admin/ui/keys", nil).Body.String())
+	if m == nil {
+		t.Fatal("CSRF token not found in the page")
+	}
+	csrf := m[1]
+
+	rec := admin.do(http.MethodPost, "/admin/ui/keys", url.Values{"name": {"ci"}, "scopes": {"admin"}, "csrf_token": {csrf}})
+	key := regexp.MustCompile(`usk_[A-Za-z0-9_-]+`).FindString(rec.Body.String())
+	if key == "" {
+		t.Fatal("new key not found in the page")
+	}
+
+	user := &testClient{t: t, handler: admin.handler}
+	if rec = user.do(http.MethodPost, "/admin/ui/login", url.Values{"token": {key}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect after login with the key, got %d", rec.Code)
+	}
+	if rec = user.do(http.MethodGet, "/admin/ui/", nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected links page, got %d", rec.Code)
+	}
+
+	if rec = admin.do(http.MethodPost, "/admin/ui/keys/1/revoke", url.Values{"csrf_token": {csrf}}); rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected redirect after revoke, got %d", rec.Code)
+	}
+	rec = user.do(http.MethodGet, "/admin/ui/", nil)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/admin/ui/login" {
+		t.Errorf("expected the session of a revoked key to end, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestDashboardVariantChart(t *testing.T) {
+	c, links := setupDashboard(t)
+	ctx := context.Background()
+
+	link, err := links.CreateShortURL(ctx, "https://example.com", service.WithVariants(model.Variants{
+		{Destination: "https://example.com/a", Weight: 1},
+		{Destination: "https://example.com/b", Weight: 1},
+	}))
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	for _, variant := range []int{0, 0, 0, 1} {
+		_ = links.RegisterClick(ctx, "", link.Short, variant)
+	}
+
+	c.do(http.MethodPost, "/admin/ui/login", url.Values{"token": {"secret"}})
+	rec := c.do(http.MethodGet, "/admin/ui/links/"+link.Short, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected link page, got %d", rec.Code)
+	}
+	for _, want := range []string{`style="width: 75%"`, `style="width: 25%"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("expected chart bar %s in the page", want)
+		}
+	}
+}
+
+func TestDashboardBrandedLink(t *testing.T) {
+	c, links := setupDashboard(t)
+	ctx := context.Background()
+	if _, err := links.CreateDomain(ctx, model.Domain{Name: "brand.link"}); err != nil {
+		t.Fatalf("CreateDomain failed: %v", err)
+	}
+
+	c.do(http.MethodPost, "/admin/ui/login", url.Values{"token": {"secret"}})
+	rec := c.do(http.MethodGet, "/admin/ui/", nil)
+	if !strings.Contains(rec.Body.String(), "<option>brand.link</option>") {
+		t.Fatal("expected the domain to be offered when creating a link")
+	}
+	csrf := csrfPattern.FindStringSubmatch(rec.Body.String())[1]
+
+	rec = c.do(http.MethodPost, "/admin/ui/links", url.Values{"original": {"https://example.com"}, "domain": {"brand.link"}, "csrf_token": {csrf}})
+	location := rec.Header().Get("Location")
+	if rec.Code != http.StatusSeeOther || !strings.HasSuffix(location, "?domain=brand.link") {
+		t.Fatalf("expected redirect to the branded link, got %d %s", rec.Code, location)
+	}
+
+	rec = c.do(http.MethodGet, location, nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "http://brand.link/") {
+		t.Fatalf("expected the link page with the full short URL, got %d", rec.Code)
+	}
+}
@@ -1,17 +1,24 @@
 package db
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
 	"testing"
 
+	"github.com/jmoiron/sqlx"
 	_ "modernc.org/sqlite"
 )
 
 func TestInitDB(t *testing.T) {
-	db := InitDB(":memory:")
+	db, err := InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
 	defer db.Close()
 
 	var count int
-	err := db.Get(&count, "SELECT COUNT(*) FROM urls")
+	err = db.Get(&count, "SELECT COUNT(*) FROM urls")
 	if err != nil {
 		t.Fatalf("failed to query urls table: %v", err)
 	}
@@ -21,7 +28,10 @@ func TestInitDB(t *testing.T) {
 }
 
 func TestCRUD(t *testing.T) {
-	db := InitDB(":memory:")
+	db, err := InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
 	defer db.Close()
 
 	schema := `
@@ -31,7 +41,7 @@ func TestCRUD(t *testing.T) {
 	);`
 	db.Exec(schema)
 
-	_, err := db.Exec("INSERT INTO test_table(name) VALUES (?)", "test")
+	_, err = db.Exec("INSERT INTO test_table(name) VALUES (?)", "test")
 	if err != nil {
 		t.Fatalf("failed to insert row: %v", err)
 	}
@@ -45,3 +55,185 @@ func TestCRUD(t *testing.T) {
 		t.Errorf("expected name 'test', got '%s'", name)
 	}
 }
+
+func TestInitDBInvalidPath(t *testing.T) {
+	if _, err := InitDB("/nonexistent-dir/urls.db"); err == nil {
+		t.Errorf("expected error for unwritable database path")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db, err := InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	// Running migrations again is a no-op
+	if err := Migrate(ctx, db); err != nil {
+		t.Fatalf("second Migrate failed: %v", err)
+	}
+
+	version, err := SchemaVersion(ctx, db)
+	if err != nil {
+		t.Fatalf("SchemaVersion failed: %v", err)
+	}
+	if version != LatestVersion() {
+		t.Errorf("expected schema version %d, got %d", LatestVersion(), version)
+	}
+
+	// Triggers keep the counters in sync with the urls table
+	if _, err := db.Exec("INSERT INTO urls (original, short, created_at) VALUES ('https://example.com', 'abc123', CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatalf("failed to insert url: %v", err)
+	}
+	if _, err := db.Exec("DELETE FROM urls WHERE short = 'abc123'"); err != nil {
+		t.Fatalf("failed to delete url: %v", err)
+	}
+
+	var active, deleted int
+	if err := db.Get(&active, "SELECT value FROM url_counters WHERE name = 'active'"); err != nil {
+		t.Fatalf("failed to read counter: %v", err)
+	}
+	if err := db.Get(&deleted, "SELECT value FROM url_counters WHERE name = 'deleted'"); err != nil {
+		t.Fatalf("failed to read counter: %v", err)
+	}
+	if active != 0 || deleted != 1 {
+		t.Errorf("expected active=0 deleted=1, got active=%d deleted=%d", active, deleted)
+	}
+
+	// A link that used up its clicks counts as expired until it is deleted
+	db.MustExec("INSERT INTO urls (original, short, created_at, owner, max_clicks) VALUES ('https://example.com', 'once', CURRENT_TIMESTAMP, 'ci', 1)")
+	db.MustExec("UPDATE urls SET clicks = clicks + 1 WHERE short = 'once'")
+	var expired, owned int
+	if err := db.Get(&expired, "SELECT value FROM url_counters WHERE name = 'expired'"); err != nil || expired != 1 {
+		t.Errorf("expected expired=1, got %d (err %v)", expired, err)
+	}
+	if err := db.Get(&owned, "SELECT value FROM url_owner_counters WHERE owner = 'ci'"); err != nil || owned != 1 {
+		t.Errorf("expected one link of owner ci, got %d (err %v)", owned, err)
+	}
+	db.MustExec("DELETE FROM urls WHERE short = 'once'")
+	_ = db.Get(&expired, "SELECT value FROM url_counters WHERE name = 'expired'")
+	_ = db.Get(&owned, "SELECT value FROM url_owner_counters WHERE owner = 'ci'")
+	if expired != 0 || owned != 0 {
+		t.Errorf("expected the counters to drop on delete, got expired=%d owned=%d", expired, owned)
+	}
+}
+
+// Links and variant clicks created before the domain migration survive the rebuild of the urls table
+func TestMigrateDomains(t *testing.T) {
+	db, err := sqlx.Open("sqlite", dsn(":memory:"))
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	if _, err := db.Exec("CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatalf("failed to create schema_migrations: %v", err)
+	}
+	for _, m := range migrations[:8] {
+		if err := applyMigration(ctx, db, m); err != nil {
+			t.Fatalf("apply migration %d: %v", m.version, err)
+		}
+	}
+	db.MustExec("INSERT INTO urls (original, short, created_at, variants) VALUES ('https://example.com', 'abc123', CURRENT_TIMESTAMP, '[]')")
+	db.MustExec("INSERT INTO urls (original, short, created_at) VALUES ('https://example.org', 'gone', CURRENT_TIMESTAMP)")
+	db.MustExec("DELETE FROM urls WHERE short = 'gone'")
+	db.MustExec("INSERT INTO variant_clicks (url_id, variant, clicks) VALUES (1, 0, 7)")
+
+	if err := Migrate(ctx, db); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	var clicks int
+	if err := db.Get(&clicks, "SELECT clicks FROM variant_clicks WHERE url_id = 1 AND variant = 0"); err != nil || clicks != 7 {
+		t.Fatalf("expected variant clicks to survive, got %d (err %v)", clicks, err)
+	}
+
+	// The same code is free on another domain, and ids of deleted links are not reused
+	db.MustExec("INSERT INTO urls (domain, original, short, created_at) VALUES ('brand.link', 'https://example.net', 'abc123', CURRENT_TIMESTAMP)")
+	if _, err := db.Exec("INSERT INTO urls (original, short, created_at) VALUES ('https://example.net', 'abc123', CURRENT_TIMESTAMP)"); err == nil {
+		t.Error("expected a duplicate code on the same domain to fail")
+	}
+	var maxID int
+	if err := db.Get(&maxID, "SELECT MAX(id) FROM urls"); err != nil || maxID != 3 {
+		t.Errorf("expected the new link to get id 3, got %d (err %v)", maxID, err)
+	}
+
+	var active int
+	if err := db.Get(&active, "SELECT value FROM url_counters WHERE name = 'active'"); err != nil || active != 2 {
+		t.Errorf("expected the counter triggers to be restored, got active=%d (err %v)", active, err)
+	}
+}
+
+func TestFileDatabasePools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.db")
+
+	writer, err := InitDB(path)
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer writer.Close()
+
+	var mode string
+	if err := writer.Get(&mode, "PRAGMA journal_mode"); err != nil {
+		t.Fatalf("failed to read journal mode: %v", err)
+	}
+	if mode != "wal" {
+		t.Errorf("expected WAL journal mode, got %q", mode)
+	}
+
+	var timeout int
+	if err := writer.Get(&timeout, "PRAGMA busy_timeout"); err != nil {
+		t.Fatalf("failed to read busy timeout: %v", err)
+	}
+	if timeout != BusyTimeoutMillis {
+		t.Errorf("expected busy_timeout %d, got %d", BusyTimeoutMillis, timeout)
+	}
+
+	reader, err := OpenReader(path, 2)
+	if err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	defer reader.Close()
+
+	if _, err := reader.Exec("INSERT INTO urls (original, short, created_at) VALUES ('x', 'y', CURRENT_TIMESTAMP)"); err == nil {
+		t.Errorf("expected the read pool to reject writes")
+	}
+
+	if _, err := OpenReader(":memory:", 1); err == nil {
+		t.Errorf("expected error when opening a read pool for an in-memory database")
+	}
+}
+
+func TestStmtCache(t *testing.T) {
+	db, err := InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer db.Close()
+
+	cache := NewStmtCache(db)
+	defer cache.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := ExecContext(ctx, cache, "INSERT INTO urls (original, short, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)", "https://example.com", fmt.Sprintf("code%d", i)); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	var count int
+	if err := GetContext(ctx, cache, &count, "SELECT COUNT(*) FROM urls"); err != nil {
+		t.Fatalf("count failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 rows, got %d", count)
+	}
+	if len(cache.stmts) != 2 {
+		t.Errorf("expected 2 prepared statements to be reused, got %d", len(cache.stmts))
+	}
+}
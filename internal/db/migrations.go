@@ -0,0 +1,333 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+/*
+migration is a versioned schema change applied exactly once, inside a transaction.
+*/
+type migration struct {
+	version int
+	name    string
+	stmts   []string
+}
+
+/*
+migrations lists all schema changes in the order they are applied.
+New migrations are appended with the next version number; existing ones are never edited.
+*/
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create urls table",
+		stmts: []string{`
+		CREATE TABLE IF NOT EXISTS urls (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			original TEXT NOT NULL,
+			short TEXT NOT NULL UNIQUE,
+			created_at DATETIME NOT NULL
+		)`},
+	},
+	{
+		version: 2,
+		name:    "maintain url counters with triggers",
+		stmts: []string{
+			`CREATE TABLE url_counters (
+				name TEXT PRIMARY KEY,
+				value INTEGER NOT NULL
+			)`,
+			`INSERT INTO url_counters (name, value) VALUES ('active', (SELECT COUNT(*) FROM urls)), ('deleted', 0)`,
+			`CREATE TRIGGER urls_count_insert AFTER INSERT ON urls
+			BEGIN
+				UPDATE url_counters SET value = value + 1 WHERE name = 'active';
+			END`,
+			`CREATE TRIGGER urls_count_delete AFTER DELETE ON urls
+			BEGIN
+				UPDATE url_counters SET value = value - 1 WHERE name = 'active';
+				UPDATE url_counters SET value = value + 1 WHERE name = 'deleted';
+			END`,
+		},
+	},
+	{
+		version: 3,
+		name:    "create api_keys table",
+		stmts: []string{`
+		CREATE TABLE api_keys (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			prefix TEXT NOT NULL,
+			key_hash TEXT NOT NULL UNIQUE,
+			scopes TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			revoked_at DATETIME
+		)`},
+	},
+	{
+		version: 4,
+		name:    "add password hash to urls",
+		stmts: []string{
+			`ALTER TABLE urls ADD COLUMN password_hash TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 5,
+		name:    "add click counter and limit to urls",
+		stmts: []string{
+			`ALTER TABLE urls ADD COLUMN clicks INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE urls ADD COLUMN max_clicks INTEGER`,
+			// Expired links are those that used up their click limit; they stay in the table and among the active ones
+			`INSERT INTO url_counters (name, value) VALUES ('expired', 0)`,
+			`CREATE TRIGGER urls_count_expire AFTER UPDATE OF clicks, max_clicks ON urls
+			WHEN (NEW.max_clicks IS NOT NULL AND NEW.clicks >= NEW.max_clicks) != (OLD.max_clicks IS NOT NULL AND OLD.clicks >= OLD.max_clicks)
+			BEGIN
+				UPDATE url_counters SET value = value + CASE WHEN NEW.clicks >= NEW.max_clicks THEN 1 ELSE -1 END WHERE name = 'expired';
+			END`,
+			`CREATE TRIGGER urls_count_expired_delete AFTER DELETE ON urls
+			WHEN OLD.max_clicks IS NOT NULL AND OLD.clicks >= OLD.max_clicks
+			BEGIN
+				UPDATE url_counters SET value = value - 1 WHERE name = 'expired';
+			END`,
+		},
+	},
+	{
+		version: 6,
+		name:    "add redirect rules to urls",
+		stmts: []string{
+			`ALTER TABLE urls ADD COLUMN rules TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 7,
+		name:    "add A/B variants to urls",
+		stmts: []string{
+			`ALTER TABLE urls ADD COLUMN variants TEXT NOT NULL DEFAULT ''`,
+			`CREATE TABLE variant_clicks (
+				url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+				variant INTEGER NOT NULL,
+				clicks INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (url_id, variant)
+			)`,
+		},
+	},
+	{
+		version: 8,
+		name:    "add redirect options to urls",
+		stmts: []string{
+			`ALTER TABLE urls ADD COLUMN options TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		// SQLite cannot drop the UNIQUE constraint on short, so the urls table is rebuilt.
+		// Dropping the old table cascades to variant_clicks, which is saved and restored around it.
+		version: 9,
+		name:    "scope short codes by domain",
+		stmts: []string{
+			`CREATE TABLE domains (
+				name TEXT PRIMARY KEY,
+				defaults TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL
+			)`,
+			`CREATE TABLE urls_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				domain TEXT NOT NULL DEFAULT '',
+				original TEXT NOT NULL,
+				short TEXT NOT NULL,
+				created_at DATETIME NOT NULL,
+				password_hash TEXT NOT NULL DEFAULT '',
+				clicks INTEGER NOT NULL DEFAULT 0,
+				max_clicks INTEGER,
+				rules TEXT NOT NULL DEFAULT '',
+				variants TEXT NOT NULL DEFAULT '',
+				options TEXT NOT NULL DEFAULT '',
+				UNIQUE (domain, short)
+			)`,
+			`INSERT INTO urls_new (id, original, short, created_at, password_hash, clicks, max_clicks, rules, variants, options)
+			SELECT id, original, short, created_at, password_hash, clicks, max_clicks, rules, variants, options FROM urls`,
+			`DELETE FROM sqlite_sequence WHERE name = 'urls_new'`,
+			`INSERT INTO sqlite_sequence (name, seq) SELECT 'urls_new', seq FROM sqlite_sequence WHERE name = 'urls'`,
+			`CREATE TEMP TABLE variant_clicks_saved AS SELECT * FROM variant_clicks`,
+			`DROP TABLE urls`,
+			`ALTER TABLE urls_new RENAME TO urls`,
+			`INSERT INTO variant_clicks SELECT * FROM variant_clicks_saved`,
+			`DROP TABLE variant_clicks_saved`,
+			`CREATE TRIGGER urls_count_insert AFTER INSERT ON urls
+			BEGIN
+				UPDATE url_counters SET value = value + 1 WHERE name = 'active';
+			END`,
+			`CREATE TRIGGER urls_count_delete AFTER DELETE ON urls
+			BEGIN
+				UPDATE url_counters SET value = value - 1 WHERE name = 'active';
+				UPDATE url_counters SET value = value + 1 WHERE name = 'deleted';
+			END`,
+			`CREATE TRIGGER urls_count_expire AFTER UPDATE OF clicks, max_clicks ON urls
+			WHEN (NEW.max_clicks IS NOT NULL AND NEW.clicks >= NEW.max_clicks) != (OLD.max_clicks IS NOT NULL AND OLD.clicks >= OLD.max_clicks)
+			BEGIN
+				UPDATE url_counters SET value = value + CASE WHEN NEW.clicks >= NEW.max_clicks THEN 1 ELSE -1 END WHERE name = 'expired';
+			END`,
+			`CREATE TRIGGER urls_count_expired_delete AFTER DELETE ON urls
+			WHEN OLD.max_clicks IS NOT NULL AND OLD.clicks >= OLD.max_clicks
+			BEGIN
+				UPDATE url_counters SET value = value - 1 WHERE name = 'expired';
+			END`,
+		},
+	},
+	{
+		version: 10,
+		name:    "add disabled reason to urls",
+		stmts: []string{
+			`ALTER TABLE urls ADD COLUMN disabled_reason TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 11,
+		name:    "add link owners and domain interstitials",
+		stmts: []string{
+			`ALTER TABLE urls ADD COLUMN owner TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE domains ADD COLUMN interstitial INTEGER NOT NULL DEFAULT 0`,
+			`CREATE TABLE url_owner_counters (
+				owner TEXT PRIMARY KEY,
+				value INTEGER NOT NULL
+			)`,
+			`INSERT INTO url_owner_counters (owner, value) SELECT owner, COUNT(*) FROM urls GROUP BY owner`,
+			`CREATE TRIGGER urls_count_owner_insert AFTER INSERT ON urls
+			BEGIN
+				INSERT INTO url_owner_counters (owner, value) VALUES (NEW.owner, 1)
+				ON CONFLICT (owner) DO UPDATE SET value = value + 1;
+			END`,
+			`CREATE TRIGGER urls_count_owner_delete AFTER DELETE ON urls
+			BEGIN
+				UPDATE url_owner_counters SET value = value - 1 WHERE owner = OLD.owner;
+			END`,
+		},
+	},
+	{
+		version: 12,
+		name:    "add destination metadata to urls",
+		stmts: []string{
+			`ALTER TABLE urls ADD COLUMN metadata TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version: 13,
+		name:    "create link_health table",
+		stmts: []string{
+			`CREATE TABLE link_health (
+				url_id INTEGER PRIMARY KEY REFERENCES urls(id) ON DELETE CASCADE,
+				checked_at DATETIME NOT NULL,
+				broken INTEGER NOT NULL DEFAULT 0,
+				broken_since DATETIME,
+				failures INTEGER NOT NULL DEFAULT 0,
+				destinations TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE INDEX link_health_broken ON link_health (broken) WHERE broken = 1`,
+		},
+	},
+	{
+		version: 14,
+		name:    "create webhooks and webhook_deliveries tables",
+		stmts: []string{
+			`CREATE TABLE webhooks (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				url TEXT NOT NULL,
+				secret TEXT NOT NULL,
+				events TEXT NOT NULL,
+				active INTEGER NOT NULL DEFAULT 1,
+				created_at DATETIME NOT NULL
+			)`,
+			`CREATE TABLE webhook_deliveries (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+				event TEXT NOT NULL,
+				payload TEXT NOT NULL,
+				status TEXT NOT NULL DEFAULT 'pending',
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at DATETIME NOT NULL,
+				last_status INTEGER NOT NULL DEFAULT 0,
+				last_error TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL,
+				delivered_at DATETIME
+			)`,
+			`CREATE INDEX webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending'`,
+			`CREATE INDEX webhook_deliveries_webhook ON webhook_deliveries (webhook_id, status, id)`,
+		},
+	},
+	{
+		version: 15,
+		name:    "index links by owner",
+		stmts: []string{
+			`CREATE INDEX urls_owner ON urls (owner, id)`,
+		},
+	},
+}
+
+/*
+LatestVersion returns the schema version the code expects.
+*/
+func LatestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+/*
+SchemaVersion returns the highest migration version applied to the database.
+*/
+func SchemaVersion(ctx context.Context, db *sqlx.DB) (int, error) {
+	var version int
+	err := db.GetContext(ctx, &version, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations")
+	return version, err
+}
+
+/*
+Migrate applies all pending migrations.
+*/
+func Migrate(ctx context.Context, db *sqlx.DB) error {
+	_, err := db.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+
+	current, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, db, m); err != nil {
+			return fmt.Errorf("apply migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+/*
+applyMigration runs the statements of a migration and records it in a single transaction.
+*/
+func applyMigration(ctx context.Context, db *sqlx.DB, m migration) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, stmt := range m.stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,36 +1,81 @@
 package db
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"net/url"
 
 	"github.com/jmoiron/sqlx"
 	_ "modernc.org/sqlite"
 )
 
+// BusyTimeoutMillis is how long a connection waits for a lock before failing with SQLITE_BUSY.
+const BusyTimeoutMillis = 5000
+
+// DefaultReadConns is the default size of the read connection pool.
+const DefaultReadConns = 4
+
 /*
-InitDB opens a SQLite database connection and returns *sqlx.DB.
-It also creates the urls table if it does not exist.
+InitDB opens the single writer connection to a SQLite database and applies pending schema migrations.
+
+SQLite allows one writer at a time, so the pool is limited to one connection: concurrent writes
+queue in Go instead of failing with "database is locked". The connection uses WAL journaling,
+so readers opened with OpenReader are not blocked by the writer.
 */
-func InitDB(dbPath string) *sqlx.DB {
-	db, err := sqlx.Open("sqlite", dbPath)
+func InitDB(dbPath string) (*sqlx.DB, error) {
+	db, err := sqlx.Open("sqlite", dsn(dbPath,
+		"journal_mode(WAL)",
+		"synchronous(NORMAL)",
+	)+"&_txlock=immediate")
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := Migrate(context.Background(), db); err != nil {
+		_ = db.Close()
+		return nil, err
 	}
 
-	schema := `
-	CREATE TABLE IF NOT EXISTS urls (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		original TEXT NOT NULL,
-		short TEXT NOT NULL UNIQUE,
-		created_at DATETIME NOT NULL
-	);
-	`
-
-	if _, err := db.Exec(schema); err != nil {
-		log.Fatalf("Failed to create urls table: %v", err)
+	return db, nil
+}
+
+/*
+OpenReader opens a pool of read-only connections to a database initialized with InitDB.
+An in-memory database is private to its connection and cannot be shared, so it is rejected.
+*/
+func OpenReader(dbPath string, maxConns int) (*sqlx.DB, error) {
+	if dbPath == ":memory:" {
+		return nil, fmt.Errorf("in-memory database cannot be opened by a separate pool")
+	}
+	if maxConns <= 0 {
+		maxConns = DefaultReadConns
 	}
 
-	fmt.Println("Database initialized successfully")
-	return db
+	db, err := sqlx.Open("sqlite", dsn(dbPath, "query_only(1)"))
+	if err != nil {
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
+
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+	return db, nil
+}
+
+/*
+dsn builds a modernc.org/sqlite data source name applying the given pragmas on every new connection,
+in addition to the busy timeout and foreign key enforcement.
+*/
+func dsn(dbPath string, pragmas ...string) string {
+	q := url.Values{}
+	q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", BusyTimeoutMillis))
+	q.Add("_pragma", "foreign_keys(1)")
+	for _, p := range pragmas {
+		q.Add("_pragma", p)
+	}
+	return dbPath + "?" + q.Encode()
 }
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+/*
+StmtCache runs queries through prepared statements that are prepared once per query string
+and reused across calls, saving SQLite from parsing the same SQL on every request.
+It implements sqlx.QueryerContext and sqlx.ExecerContext, so it can be passed to GetContext,
+SelectContext and ExecContext in place of the database.
+*/
+type StmtCache struct {
+	db    *sqlx.DB
+	mu    sync.Mutex
+	stmts map[string]*sqlx.Stmt
+}
+
+/*
+NewStmtCache creates an empty statement cache for the given database.
+*/
+func NewStmtCache(db *sqlx.DB) *StmtCache {
+	return &StmtCache{db: db, stmts: make(map[string]*sqlx.Stmt)}
+}
+
+/*
+prepare returns the cached statement for query, preparing it on first use.
+*/
+func (c *StmtCache) prepare(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := c.db.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+// QueryContext implements sqlx.QueryerContext.
+func (c *StmtCache) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryContext(ctx, args...)
+}
+
+// QueryxContext implements sqlx.QueryerContext.
+func (c *StmtCache) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryxContext(ctx, args...)
+}
+
+// QueryRowxContext implements sqlx.QueryerContext.
+// If the statement cannot be prepared, the query runs unprepared so that the error surfaces on Scan.
+func (c *StmtCache) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return c.db.QueryRowxContext(ctx, query, args...)
+	}
+	return stmt.QueryRowxContext(ctx, args...)
+}
+
+// ExecContext implements sqlx.ExecerContext.
+func (c *StmtCache) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+/*
+Close closes all prepared statements. The database itself is left open.
+*/
+func (c *StmtCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var firstErr error
+	for query, stmt := range c.stmts {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(c.stmts, query)
+	}
+	return firstErr
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/zen-flo/url-shortener/internal/tracing"
+)
+
+/*
+GetContext runs a query expected to return a single row and records it as a client span.
+*/
+func GetContext(ctx context.Context, q sqlx.QueryerContext, dest interface{}, query string, args ...interface{}) error {
+	ctx, span := startSpan(ctx, query)
+	err := sqlx.GetContext(ctx, q, dest, query, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		// A missing row is an expected outcome, not a failed statement.
+		tracing.End(span, nil)
+		return err
+	}
+	tracing.End(span, err)
+	return err
+}
+
+/*
+SelectContext runs a query returning multiple rows and records it as a client span.
+*/
+func SelectContext(ctx context.Context, q sqlx.QueryerContext, dest interface{}, query string, args ...interface{}) error {
+	ctx, span := startSpan(ctx, query)
+	err := sqlx.SelectContext(ctx, q, dest, query, args...)
+	tracing.End(span, err)
+	return err
+}
+
+/*
+ExecContext executes a statement without returning rows and records it as a client span.
+*/
+func ExecContext(ctx context.Context, e sqlx.ExecerContext, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startSpan(ctx, query)
+	result, err := e.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return result, err
+}
+
+/*
+startSpan opens a span named after the SQL operation, e.g. "sqlite SELECT".
+*/
+func startSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation := "QUERY"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+	return tracing.Tracer().Start(ctx, "sqlite "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "sqlite"),
+			attribute.String("db.operation.name", operation),
+			attribute.String("db.query.text", query),
+		),
+	)
+}
@@ -0,0 +1,128 @@
+package geoip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+/*
+DB resolves client addresses to countries from a database file.
+It implements rules.CountryResolver.
+*/
+type DB interface {
+	Country(addr netip.Addr) string
+	io.Closer
+}
+
+/*
+Open loads a country database. The format is chosen by extension:
+
+	.mmdb  MaxMind DB, e.g. GeoLite2-Country or DB-IP Country Lite
+	.csv   "network,country" lines, e.g. "203.0.113.0/24,DE"; for small custom lists
+*/
+func Open(path string) (DB, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mmdb":
+		r, err := maxminddb.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open GeoIP database: %w", err)
+		}
+		return &mmdb{reader: r}, nil
+	case ".csv":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open GeoIP database: %w", err)
+		}
+		defer f.Close()
+		return ParseCSV(f)
+	default:
+		return nil, fmt.Errorf("unsupported GeoIP database format %q", filepath.Ext(path))
+	}
+}
+
+type mmdb struct {
+	reader *maxminddb.Reader
+}
+
+func (m *mmdb) Country(addr netip.Addr) string {
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := m.reader.Lookup(net.IP(addr.AsSlice()), &record); err != nil {
+		return ""
+	}
+	return record.Country.ISOCode
+}
+
+func (m *mmdb) Close() error {
+	return m.reader.Close()
+}
+
+/*
+CSV is an in-memory list of networks. More specific networks take precedence.
+*/
+type CSV struct {
+	networks []network
+}
+
+type network struct {
+	prefix  netip.Prefix
+	country string
+}
+
+/*
+ParseCSV reads "network,country" lines. Empty lines and lines starting with # are skipped.
+*/
+func ParseCSV(r io.Reader) (*CSV, error) {
+	db := &CSV{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		cidr, country, ok := strings.Cut(text, ",")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected network,country", line)
+		}
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		db.networks = append(db.networks, network{prefix.Masked(), strings.ToUpper(strings.TrimSpace(country))})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(db.networks, func(i, j int) bool {
+		return db.networks[i].prefix.Bits() > db.networks[j].prefix.Bits()
+	})
+	return db, nil
+}
+
+// Country returns the country of the most specific network containing addr.
+func (c *CSV) Country(addr netip.Addr) string {
+	for _, n := range c.networks {
+		if n.prefix.Contains(addr) {
+			return n.country
+		}
+	}
+	return ""
+}
+
+// Close implements io.Closer.
+func (c *CSV) Close() error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package geoip
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	db, err := ParseCSV(strings.NewReader(`
+# test networks
+203.0.113.0/24,de
+203.0.113.128/25,AT
+2001:db8::/32,FR
+`))
+	if err != nil {
+		t.Fatalf("ParseCSV failed: %v", err)
+	}
+
+	tests := map[string]string{
+		"203.0.113.1":   "DE",
+		"203.0.113.200": "AT",
+		"2001:db8::1":   "FR",
+		"198.51.100.1":  "",
+	}
+	for addr, want := range tests {
+		if got := db.Country(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Country(%s) = %q, want %q", addr, got, want)
+		}
+	}
+
+	if _, err := ParseCSV(strings.NewReader("not-a-network,DE")); err == nil {
+		t.Error("expected an error for an invalid network")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "countries.csv")
+	if err := os.WriteFile(path, []byte("192.0.2.0/24,NL\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	if got := db.Country(netip.MustParseAddr("192.0.2.10")); got != "NL" {
+		t.Errorf("expected NL, got %q", got)
+	}
+
+	if _, err := Open(filepath.Join(dir, "countries.txt")); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if _, err := Open(filepath.Join(dir, "missing.mmdb")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
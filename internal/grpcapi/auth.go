@@ -0,0 +1,93 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/logger"
+)
+
+/*
+UnaryAuthenticate stores the principal in the call context if the call carries a token.
+Anonymous calls pass through, but a token that does not authenticate is rejected
+with Unauthenticated, as the REST API rejects it with 401.
+*/
+func UnaryAuthenticate(a auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+/*
+StreamAuthenticate is UnaryAuthenticate for streaming calls.
+*/
+func StreamAuthenticate(a auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), a)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+/*
+authenticatedStream overrides the context of a stream with the one carrying the principal.
+*/
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context carrying the principal.
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+/*
+authenticate resolves the token of the call, if any, and returns the context carrying the principal.
+*/
+func authenticate(ctx context.Context, a auth.Authenticator) (context.Context, error) {
+	token := tokenFromMetadata(ctx)
+	if token == "" {
+		return ctx, nil
+	}
+	p, err := a.Authenticate(ctx, token)
+	if err != nil {
+		if !errors.Is(err, auth.ErrInvalidCredentials) {
+			logger.FromContext(ctx).ErrorContext(ctx, "authentication failed", "error", err)
+			return nil, status.Error(codes.Unavailable, "authentication unavailable")
+		}
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return auth.WithPrincipal(ctx, p), nil
+}
+
+/*
+tokenFromMetadata extracts the credential from the "authorization: Bearer <token>" or
+"x-api-key" metadata, the counterparts of the REST headers.
+*/
+func tokenFromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	const prefix = "Bearer "
+	for _, h := range md.Get("authorization") {
+		if len(h) > len(prefix) && strings.EqualFold(h[:len(prefix)], prefix) {
+			return strings.TrimSpace(h[len(prefix):])
+		}
+	}
+	if keys := md.Get("x-api-key"); len(keys) > 0 {
+		return strings.TrimSpace(keys[0])
+	}
+	return ""
+}
@@ -0,0 +1,175 @@
+package grpcapi
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	shortenerv1 "github.com/zen-flo/url-shortener/api/shortener/v1"
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+/*
+urlToProto converts a link to its protobuf message.
+*/
+func urlToProto(u *model.URL) *shortenerv1.URL {
+	pb := &shortenerv1.URL{
+		Id:             int64(u.ID),
+		Domain:         u.Domain,
+		Original:       u.Original,
+		Short:          u.Short,
+		ShortUrl:       u.ShortURL,
+		CreatedAt:      timestamppb.New(u.CreatedAt),
+		Owner:          u.Owner,
+		Protected:      u.Protected,
+		Clicks:         int64(u.Clicks),
+		MaxClicks:      optionalInt64(u.MaxClicks),
+		ClicksLeft:     optionalInt64(u.ClicksLeft),
+		Disabled:       u.Disabled,
+		DisabledReason: u.DisabledReason,
+	}
+	for _, r := range u.Rules {
+		pb.Rules = append(pb.Rules, ruleToProto(r))
+	}
+	for _, v := range u.Variants {
+		pb.Variants = append(pb.Variants, variantToProto(v))
+	}
+	if u.Options != (model.RedirectOptions{}) {
+		pb.Options = optionsToProto(u.Options)
+	}
+	return pb
+}
+
+/*
+ruleToProto converts a redirect rule to its protobuf message.
+*/
+func ruleToProto(r model.Rule) *shortenerv1.Rule {
+	pb := &shortenerv1.Rule{
+		Destination: r.Destination,
+		Device:      r.Device,
+		Os:          r.OS,
+		Language:    r.Language,
+		Country:     r.Country,
+		Query:       r.Query,
+	}
+	if r.Time != nil {
+		pb.Time = &shortenerv1.TimeWindow{Days: r.Time.Days, From: r.Time.From, To: r.Time.To, Timezone: r.Time.TimeZone}
+	}
+	return pb
+}
+
+/*
+rulesFromProto converts redirect rules from their protobuf messages.
+*/
+func rulesFromProto(pbs []*shortenerv1.Rule) model.Rules {
+	var rules model.Rules
+	for _, pb := range pbs {
+		r := model.Rule{
+			Destination: pb.GetDestination(),
+			Device:      pb.GetDevice(),
+			OS:          pb.GetOs(),
+			Language:    pb.GetLanguage(),
+			Country:     pb.GetCountry(),
+			Query:       pb.GetQuery(),
+		}
+		if t := pb.GetTime(); t != nil {
+			r.Time = &model.TimeWindow{Days: t.GetDays(), From: t.GetFrom(), To: t.GetTo(), TimeZone: t.GetTimezone()}
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+/*
+variantToProto converts an A/B variant to its protobuf message.
+*/
+func variantToProto(v model.Variant) *shortenerv1.Variant {
+	return &shortenerv1.Variant{Name: v.Name, Destination: v.Destination, Weight: int32(v.Weight)}
+}
+
+/*
+variantsFromProto converts A/B variants from their protobuf messages.
+*/
+func variantsFromProto(pbs []*shortenerv1.Variant) model.Variants {
+	var variants model.Variants
+	for _, pb := range pbs {
+		variants = append(variants, model.Variant{Name: pb.GetName(), Destination: pb.GetDestination(), Weight: int(pb.GetWeight())})
+	}
+	return variants
+}
+
+/*
+optionsToProto converts redirect options to their protobuf message.
+*/
+func optionsToProto(o model.RedirectOptions) *shortenerv1.RedirectOptions {
+	pb := &shortenerv1.RedirectOptions{
+		ForwardQuery: o.ForwardQuery,
+		PassPath:     o.PassPath,
+		Interstitial: int32(o.Interstitial),
+	}
+	if o.UTM != (model.UTM{}) {
+		pb.Utm = &shortenerv1.UTM{
+			Source:   o.UTM.Source,
+			Medium:   o.UTM.Medium,
+			Campaign: o.UTM.Campaign,
+			Term:     o.UTM.Term,
+			Content:  o.UTM.Content,
+		}
+	}
+	return pb
+}
+
+/*
+optionsFromProto converts redirect options from their protobuf message.
+*/
+func optionsFromProto(pb *shortenerv1.RedirectOptions) model.RedirectOptions {
+	utm := pb.GetUtm()
+	return model.RedirectOptions{
+		ForwardQuery: pb.GetForwardQuery(),
+		PassPath:     pb.GetPassPath(),
+		Interstitial: int(pb.GetInterstitial()),
+		UTM: model.UTM{
+			Source:   utm.GetSource(),
+			Medium:   utm.GetMedium(),
+			Campaign: utm.GetCampaign(),
+			Term:     utm.GetTerm(),
+			Content:  utm.GetContent(),
+		},
+	}
+}
+
+/*
+statsToProto converts link stats to their protobuf message.
+*/
+func statsToProto(s *model.Stats) *shortenerv1.Stats {
+	pb := &shortenerv1.Stats{
+		Short:      s.Short,
+		Clicks:     int64(s.Clicks),
+		MaxClicks:  optionalInt64(s.MaxClicks),
+		ClicksLeft: optionalInt64(s.ClicksLeft),
+	}
+	for _, v := range s.Variants {
+		pb.Variants = append(pb.Variants, &shortenerv1.VariantStats{Variant: variantToProto(v.Variant), Clicks: int64(v.Clicks)})
+	}
+	return pb
+}
+
+/*
+clickToProto converts a click feed event to its protobuf message.
+*/
+func clickToProto(c model.Click) *shortenerv1.Click {
+	pb := &shortenerv1.Click{Domain: c.Domain, Short: c.Short, Time: timestamppb.New(c.Time)}
+	if c.Variant != nil {
+		pb.Variant = proto.Int32(int32(*c.Variant))
+	}
+	return pb
+}
+
+/*
+optionalInt64 converts an optional count, keeping nil as unset.
+*/
+func optionalInt64(n *int) *int64 {
+	if n == nil {
+		return nil
+	}
+	return proto.Int64(int64(*n))
+}
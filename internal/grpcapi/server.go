@@ -0,0 +1,320 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	shortenerv1 "github.com/zen-flo/url-shortener/api/shortener/v1"
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/metrics"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+// DefaultTimeout is the per-call deadline applied to service calls when Server.Timeout is not set.
+const DefaultTimeout = 5 * time.Second
+
+// Page sizes of ListURLs, the same as those of GET /urls.
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+/*
+Server implements the shortener.v1.URLService gRPC service on top of the same
+service as the REST handlers.
+*/
+type Server struct {
+	shortenerv1.UnimplementedURLServiceServer
+
+	Service service.URLServiceInterface
+	// Timeout bounds every service call made while handling a call. Click feeds are
+	// only bounded by the client.
+	Timeout time.Duration
+}
+
+/*
+New creates a new instance of Server.
+*/
+func New(s service.URLServiceInterface) *Server {
+	return &Server{Service: s, Timeout: DefaultTimeout}
+}
+
+/*
+NewGRPCServer creates a gRPC server serving srv with reflection enabled.
+With an authenticator, a bearer token in the call metadata is optional but names the
+owner of the links it creates, as on the REST API.
+*/
+func NewGRPCServer(srv *Server, authn auth.Authenticator, opts ...grpc.ServerOption) *grpc.Server {
+	if authn != nil {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(UnaryAuthenticate(authn)),
+			grpc.ChainStreamInterceptor(StreamAuthenticate(authn)),
+		)
+	}
+	g := grpc.NewServer(opts...)
+	shortenerv1.RegisterURLServiceServer(g, srv)
+	reflection.Register(g)
+	return g
+}
+
+/*
+CreateURL shortens a URL.
+*/
+func (s *Server) CreateURL(ctx context.Context, req *shortenerv1.CreateURLRequest) (*shortenerv1.URL, error) {
+	var opts []service.CreateOption
+	if req.GetDomain() != "" {
+		opts = append(opts, service.WithDomain(req.GetDomain()))
+	}
+	if req.GetPassword() != "" {
+		opts = append(opts, service.WithPassword(req.GetPassword()))
+	}
+	if req.MaxClicks != nil {
+		opts = append(opts, service.WithMaxClicks(int(req.GetMaxClicks())))
+	}
+	if rules := rulesFromProto(req.GetRules()); len(rules) > 0 {
+		opts = append(opts, service.WithRules(rules))
+	}
+	if variants := variantsFromProto(req.GetVariants()); len(variants) > 0 {
+		opts = append(opts, service.WithVariants(variants))
+	}
+	if req.GetOptions() != nil {
+		opts = append(opts, service.WithRedirectOptions(optionsFromProto(req.GetOptions())))
+	}
+	if p := auth.PrincipalFromContext(ctx); p != nil {
+		opts = append(opts, service.WithOwner(p.DisplayName()))
+	}
+
+	ctx, cancel := s.callContext(ctx)
+	defer cancel()
+
+	url, err := s.Service.CreateShortURL(ctx, req.GetOriginal(), opts...)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	metrics.RecordCreated(ctx, metrics.SourceGRPC)
+	return urlToProto(url), nil
+}
+
+/*
+GetURL returns a link. Where a password-protected link leads is hidden from callers
+other than its owner and admins.
+*/
+func (s *Server) GetURL(ctx context.Context, req *shortenerv1.GetURLRequest) (*shortenerv1.URL, error) {
+	ctx, cancel := s.callContext(ctx)
+	defer cancel()
+
+	domain, short := linkKey(req)
+	url, err := s.Service.GetOriginalURL(ctx, domain, short)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			metrics.RecordNotFound(ctx)
+		}
+		return nil, statusError(err)
+	}
+	hideDestinations(ctx, url)
+	return urlToProto(url), nil
+}
+
+/*
+DeleteURL removes a link. Like on the REST API, only its owner and admins may delete it.
+*/
+func (s *Server) DeleteURL(ctx context.Context, req *shortenerv1.DeleteURLRequest) (*shortenerv1.DeleteURLResponse, error) {
+	ctx, cancel := s.callContext(ctx)
+	defer cancel()
+
+	domain, short := linkKey(req)
+	if _, err := s.ownedURL(ctx, domain, short); err != nil {
+		return nil, err
+	}
+	if err := s.Service.DeleteURL(ctx, domain, short); err != nil {
+		return nil, statusError(err)
+	}
+	return &shortenerv1.DeleteURLResponse{}, nil
+}
+
+/*
+ListURLs returns a page of links of all domains, newest first. It requires a token:
+admins see the links of all owners, everyone else only their own.
+*/
+func (s *Server) ListURLs(ctx context.Context, req *shortenerv1.ListURLsRequest) (*shortenerv1.ListURLsResponse, error) {
+	p := auth.PrincipalFromContext(ctx)
+	if p == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	limit := DefaultListLimit
+	if req.GetLimit() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+	if req.GetLimit() > 0 {
+		limit = min(int(req.GetLimit()), MaxListLimit)
+	}
+	if req.GetOffset() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "offset must not be negative")
+	}
+
+	ctx, cancel := s.callContext(ctx)
+	defer cancel()
+
+	urls, total, err := s.Service.ListURLs(ctx, p.ListOwner(), req.GetQuery(), limit, int(req.GetOffset()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &shortenerv1.ListURLsResponse{Total: int64(total)}
+	for i := range urls {
+		hideDestinations(ctx, &urls[i])
+		resp.Urls = append(resp.Urls, urlToProto(&urls[i]))
+	}
+	return resp, nil
+}
+
+/*
+GetStats returns the click counts of a link. The destinations of the variants of a
+password-protected link are hidden from callers other than its owner and admins.
+*/
+func (s *Server) GetStats(ctx context.Context, req *shortenerv1.GetStatsRequest) (*shortenerv1.Stats, error) {
+	ctx, cancel := s.callContext(ctx)
+	defer cancel()
+
+	domain, short := linkKey(req)
+	stats, err := s.Service.Stats(ctx, domain, short)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	url, err := s.Service.GetOriginalURL(ctx, domain, short)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if hideDestinations(ctx, url) {
+		stats.HideDestinations()
+	}
+	return statsToProto(stats), nil
+}
+
+/*
+WatchClicks streams the redirects of a link until the client cancels the call.
+It requires the token of the owner of the link or an admin.
+A client that falls behind is disconnected with ResourceExhausted, and all clients
+with Unavailable when the server shuts down.
+*/
+func (s *Server) WatchClicks(req *shortenerv1.WatchClicksRequest, stream grpc.ServerStreamingServer[shortenerv1.Click]) error {
+	if req.GetShort() == "" {
+		return status.Error(codes.InvalidArgument, "short must not be empty")
+	}
+
+	domain, short := linkKey(req)
+	ctx, cancel := s.callContext(stream.Context())
+	defer cancel()
+	if _, err := s.ownedURL(ctx, domain, short); err != nil {
+		return err
+	}
+	sub, err := s.Service.SubscribeClicks(ctx, domain, short)
+	cancel()
+	if err != nil {
+		return statusError(err)
+	}
+	defer sub.Close()
+
+	// Clients learn that the subscription is in place from the headers
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	for {
+		select {
+		case c, ok := <-sub.C:
+			if !ok {
+				if sub.Dropped() {
+					return status.Error(codes.ResourceExhausted, "click feed dropped: client fell behind")
+				}
+				return status.Error(codes.Unavailable, "click feed closed: server shutting down")
+			}
+			if err := stream.Send(clickToProto(c)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
+
+/*
+linkKey returns the domain and short code addressing a link in a request, with the domain
+normalized as on the REST API; the empty domain is the default one.
+*/
+func linkKey(req interface {
+	GetDomain() string
+	GetShort() string
+}) (domain, short string) {
+	return service.NormalizeHost(req.GetDomain()), req.GetShort()
+}
+
+/*
+ownedURL loads a link before it is changed or watched. It fails with Unauthenticated
+without a token and with PermissionDenied unless the caller owns the link or is an admin.
+*/
+func (s *Server) ownedURL(ctx context.Context, domain, short string) (*model.URL, error) {
+	p := auth.PrincipalFromContext(ctx)
+	if p == nil {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	url, err := s.Service.GetOriginalURL(ctx, domain, short)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if !p.Owns(url.Owner) {
+		return nil, status.Error(codes.PermissionDenied, "only the owner of a link may change it")
+	}
+	return url, nil
+}
+
+/*
+hideDestinations hides where a password-protected link leads from callers other than its owner
+and admins, like hideDestinations of the REST handlers. It reports whether it did.
+*/
+func hideDestinations(ctx context.Context, url *model.URL) bool {
+	if !url.Protected || auth.PrincipalFromContext(ctx).Owns(url.Owner) {
+		return false
+	}
+	url.HideDestinations()
+	return true
+}
+
+/*
+callContext derives a context bounded by the server timeout from the call context.
+*/
+func (s *Server) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.Timeout)
+}
+
+/*
+statusError maps a service error to a gRPC status, like writeServiceError does to HTTP status codes.
+*/
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrGone):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrEmptyURL), errors.Is(err, service.ErrInvalidURL), errors.Is(err, service.ErrPasswordLong), errors.Is(err, service.ErrMaxClicks),
+		errors.Is(err, service.ErrInvalidRules), errors.Is(err, service.ErrInvalidSplit),
+		errors.Is(err, service.ErrInvalidOpts), errors.Is(err, service.ErrInvalidDomain), errors.Is(err, service.ErrDomainNotFound):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrDomainExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrBlocked):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,292 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	shortenerv1 "github.com/zen-flo/url-shortener/api/shortener/v1"
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/service"
+	_ "modernc.org/sqlite" // SQLite driver
+)
+
+func setupServer(t *testing.T) (*grpc.ClientConn, *service.URLService) {
+	database, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to connect to in-memory DB: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+	svc := service.NewURLService(database)
+
+	lis := bufconn.Listen(1 << 20)
+	g := NewGRPCServer(New(svc), auth.StaticToken("secret"))
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, svc
+}
+
+func TestServer(t *testing.T) {
+	conn, _ := setupServer(t)
+	client := shortenerv1.NewURLServiceClient(conn)
+	ctx := context.Background()
+
+	created, err := client.CreateURL(ctx, &shortenerv1.CreateURLRequest{
+		Original: "https://example.com",
+		Variants: []*shortenerv1.Variant{
+			{Name: "A", Destination: "https://example.com/a", Weight: 1},
+			{Name: "B", Destination: "https://example.com/b", Weight: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateURL: %v", err)
+	}
+	if created.GetShort() == "" || created.GetShortUrl() == "" || len(created.GetVariants()) != 2 {
+		t.Fatalf("unexpected link %v", created)
+	}
+
+	got, err := client.GetURL(ctx, &shortenerv1.GetURLRequest{Short: created.GetShort()})
+	if err != nil || got.GetOriginal() != "https://example.com" {
+		t.Fatalf("GetURL: %v, %v", got, err)
+	}
+
+	admin := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	list, err := client.ListURLs(admin, &shortenerv1.ListURLsRequest{Query: "example.com"})
+	if err != nil || list.GetTotal() != 1 || len(list.GetUrls()) != 1 {
+		t.Fatalf("ListURLs: %v, %v", list, err)
+	}
+
+	stats, err := client.GetStats(ctx, &shortenerv1.GetStatsRequest{Short: created.GetShort()})
+	if err != nil || stats.GetClicks() != 0 || len(stats.GetVariants()) != 2 || stats.MaxClicks != nil {
+		t.Fatalf("GetStats: %v, %v", stats, err)
+	}
+
+	if _, err := client.DeleteURL(admin, &shortenerv1.DeleteURLRequest{Short: created.GetShort()}); err != nil {
+		t.Fatalf("DeleteURL: %v", err)
+	}
+	if _, err := client.GetURL(ctx, &shortenerv1.GetURLRequest{Short: created.GetShort()}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound after deletion, got %v", err)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	conn, _ := setupServer(t)
+	client := shortenerv1.NewURLServiceClient(conn)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"empty URL", func() error {
+			_, err := client.CreateURL(ctx, &shortenerv1.CreateURLRequest{})
+			return err
+		}, codes.InvalidArgument},
+		{"unknown domain", func() error {
+			_, err := client.CreateURL(ctx, &shortenerv1.CreateURLRequest{Original: "https://example.com", Domain: "brand.link"})
+			return err
+		}, codes.InvalidArgument},
+		{"missing link", func() error {
+			_, err := client.GetStats(ctx, &shortenerv1.GetStatsRequest{Short: "missing"})
+			return err
+		}, codes.NotFound},
+		{"negative limit", func() error {
+			ctx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+			_, err := client.ListURLs(ctx, &shortenerv1.ListURLsRequest{Limit: -1})
+			return err
+		}, codes.InvalidArgument},
+		{"anonymous list", func() error {
+			_, err := client.ListURLs(ctx, &shortenerv1.ListURLsRequest{})
+			return err
+		}, codes.Unauthenticated},
+		{"invalid token", func() error {
+			ctx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong")
+			_, err := client.ListURLs(ctx, &shortenerv1.ListURLsRequest{})
+			return err
+		}, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		if got := status.Code(tt.call()); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestOnlyOwnerDeletesLink(t *testing.T) {
+	conn, svc := setupServer(t)
+	client := shortenerv1.NewURLServiceClient(conn)
+	ctx := context.Background()
+
+	created, err := svc.CreateShortURL(ctx, "https://example.com", service.WithOwner("marketing"))
+	if err != nil {
+		t.Fatalf("CreateShortURL: %v", err)
+	}
+	req := &shortenerv1.DeleteURLRequest{Short: created.Short}
+
+	if _, err := client.DeleteURL(ctx, req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated without a token, got %v", err)
+	}
+	srv := New(svc)
+	if _, err := srv.DeleteURL(auth.WithPrincipal(ctx, &auth.Principal{Name: "key:sales"}), req); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied for another key, got %v", err)
+	}
+	if _, err := srv.DeleteURL(auth.WithPrincipal(ctx, &auth.Principal{Name: "key:marketing"}), req); err != nil {
+		t.Errorf("expected the owner to delete the link, got %v", err)
+	}
+}
+
+func TestProtectedLinkDestinationsHidden(t *testing.T) {
+	conn, svc := setupServer(t)
+	client := shortenerv1.NewURLServiceClient(conn)
+	ctx := context.Background()
+
+	created, err := svc.CreateShortURL(ctx, "https://secret.example/x", service.WithPassword("s3cret"),
+		service.WithVariants(model.Variants{{Name: "A", Destination: "https://secret.example/a", Weight: 1}}))
+	if err != nil {
+		t.Fatalf("CreateShortURL: %v", err)
+	}
+
+	got, err := client.GetURL(ctx, &shortenerv1.GetURLRequest{Short: created.Short})
+	if err != nil || !got.GetProtected() || got.GetOriginal() != "" || len(got.GetVariants()) != 0 {
+		t.Errorf("expected the destinations to be hidden, got %v (err %v)", got, err)
+	}
+	stats, err := client.GetStats(ctx, &shortenerv1.GetStatsRequest{Short: created.Short})
+	if err != nil || len(stats.GetVariants()) != 1 || stats.GetVariants()[0].GetVariant().GetDestination() != "" {
+		t.Errorf("expected the variant destinations to be hidden, got %v (err %v)", stats, err)
+	}
+
+	admin := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	if got, err := client.GetURL(admin, &shortenerv1.GetURLRequest{Short: created.Short}); err != nil || got.GetOriginal() != "https://secret.example/x" {
+		t.Errorf("expected admins to see the destination, got %v (err %v)", got, err)
+	}
+}
+
+func TestDomainNormalized(t *testing.T) {
+	conn, svc := setupServer(t)
+	client := shortenerv1.NewURLServiceClient(conn)
+	ctx := context.Background()
+
+	if _, err := svc.CreateDomain(ctx, model.Domain{Name: "brand.link"}); err != nil {
+		t.Fatalf("CreateDomain: %v", err)
+	}
+	created, err := svc.CreateShortURL(ctx, "https://example.com", service.WithDomain("brand.link"))
+	if err != nil {
+		t.Fatalf("CreateShortURL: %v", err)
+	}
+
+	// The domain is matched like a Host header: case, port and a trailing dot do not matter
+	if got, err := client.GetURL(ctx, &shortenerv1.GetURLRequest{Domain: "Brand.Link.", Short: created.Short}); err != nil || got.GetDomain() != "brand.link" {
+		t.Errorf("GetURL: expected the link of brand.link, got %v (err %v)", got, err)
+	}
+	if _, err := client.GetStats(ctx, &shortenerv1.GetStatsRequest{Domain: "BRAND.LINK:443", Short: created.Short}); err != nil {
+		t.Errorf("GetStats: expected the link of brand.link, got %v", err)
+	}
+}
+
+func TestCreateURLOwner(t *testing.T) {
+	conn, _ := setupServer(t)
+	client := shortenerv1.NewURLServiceClient(conn)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	created, err := client.CreateURL(ctx, &shortenerv1.CreateURLRequest{Original: "https://example.com"})
+	if err != nil || created.GetOwner() != "admin-token" {
+		t.Fatalf("expected the token name as the owner, got %v (err %v)", created, err)
+	}
+}
+
+func TestWatchClicks(t *testing.T) {
+	conn, svc := setupServer(t)
+	client := shortenerv1.NewURLServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	created, err := svc.CreateShortURL(ctx, "https://example.com")
+	if err != nil {
+		t.Fatalf("CreateShortURL: %v", err)
+	}
+
+	watch := func(ctx context.Context, domain, short string) error {
+		stream, err := client.WatchClicks(ctx, &shortenerv1.WatchClicksRequest{Domain: domain, Short: short})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		return err
+	}
+	if err := watch(ctx, "", created.Short); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated without a token, got %v", err)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	if err := watch(ctx, "", "missing"); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for an unknown link, got %v", err)
+	}
+
+	// The default domain may be given as an empty or blank name
+	stream, err := client.WatchClicks(ctx, &shortenerv1.WatchClicksRequest{Domain: " ", Short: created.Short})
+	if err != nil {
+		t.Fatalf("WatchClicks: %v", err)
+	}
+	// The subscription is in place once the headers arrive
+	if _, err := stream.Header(); err != nil {
+		t.Fatalf("Header: %v", err)
+	}
+	if err := svc.RegisterClick(ctx, "", created.Short, -1); err != nil {
+		t.Fatalf("RegisterClick: %v", err)
+	}
+	click, err := stream.Recv()
+	if err != nil || click.GetShort() != created.Short || click.Variant != nil || click.GetTime() == nil {
+		t.Fatalf("expected the click, got %v (err %v)", click, err)
+	}
+
+	// Shutting down ends the stream instead of waiting for the client
+	svc.Clicks.Close()
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable after the feed closed, got %v", err)
+	}
+}
+
+func TestReflection(t *testing.T) {
+	conn, _ := setupServer(t)
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatalf("ServerReflectionInfo: %v", err)
+	}
+	if err := stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	found := false
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		found = found || s.GetName() == shortenerv1.URLService_ServiceDesc.ServiceName
+	}
+	if !found {
+		t.Errorf("expected %s to be listed, got %v", shortenerv1.URLService_ServiceDesc.ServiceName, resp)
+	}
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+/*
+APIKeyHandler provides administrative endpoints for managing API keys.
+*/
+type APIKeyHandler struct {
+	Service *service.APIKeyService
+}
+
+/*
+NewAPIKeyHandler creates a new instance of APIKeyHandler.
+*/
+func NewAPIKeyHandler(s *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{Service: s}
+}
+
+/*
+RegisterRoutes registers the API key routes. The caller mounts them behind admin authentication.
+*/
+func (h *APIKeyHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/keys", h.CreateAPIKey)
+	r.Get("/keys", h.ListAPIKeys)
+	r.Delete("/keys/{id}", h.RevokeAPIKey)
+}
+
+/*
+CreatedAPIKey is the response to key creation. Key holds the plaintext, which is never shown again.
+*/
+type CreatedAPIKey struct {
+	model.APIKey
+	Key string `json:"key" example:"usk_5mD0mX2rJ4..."`
+}
+
+// CreateAPIKey handles POST /admin/keys requests.
+// @Summary Create an API key
+// @Description Issue a new API key with the given scopes. The key is returned only once.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param key body map[string]interface{} true "Key name and scopes" example({"name": "ci-bot", "scopes": ["admin"]})
+// @Success 201 {object} handler.CreatedAPIKey "Key created"
+// @Failure 400 {string} string "invalid request"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Router /admin/keys [post]
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	key, plaintext, err := h.Service.CreateAPIKey(r.Context(), req.Name, req.Scopes)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			writeError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeServiceError(w, r, err)
+		return
+	}
+
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "API key created", "id", key.ID, "name", key.Name, "scopes", key.Scopes)
+	writeJSON(w, http.StatusCreated, CreatedAPIKey{APIKey: *key, Key: plaintext})
+}
+
+// ListAPIKeys handles GET /admin/keys requests.
+// @Summary List API keys
+// @Description List all API keys, including revoked ones. Secrets are never returned.
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Success 200 {array} model.APIKey "Keys"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Router /admin/keys [get]
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.Service.ListAPIKeys(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if keys == nil {
+		keys = []model.APIKey{}
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// RevokeAPIKey handles DELETE /admin/keys/{id} requests.
+// @Summary Revoke an API key
+// @Description Revoke an API key; requests using it are rejected immediately
+// @Tags Admin
+// @Security AdminToken
+// @Param id path int true "Key ID"
+// @Success 204 {string} string "No Content"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "API key not found"
+// @Router /admin/keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, service.ErrAPIKeyNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err := h.Service.RevokeAPIKey(r.Context(), id); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			writeError(w, r, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeServiceError(w, r, err)
+		return
+	}
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "API key revoked", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
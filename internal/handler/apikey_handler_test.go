@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+func TestAPIKeyHandler(t *testing.T) {
+	database, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer database.Close()
+
+	r := chi.NewRouter()
+	NewAPIKeyHandler(service.NewAPIKeyService(database)).RegisterRoutes(r)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/keys", strings.NewReader(`{"name":"ci","scopes":[]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 without scopes, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/keys", strings.NewReader(`{"name":"ci","scopes":["admin"]}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created CreatedAPIKey
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode key: %v", err)
+	}
+	if !strings.HasPrefix(created.Key, "usk_") {
+		t.Errorf("expected plaintext key in the response, got %q", created.Key)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/keys", nil))
+	if strings.Contains(rec.Body.String(), created.Key) {
+		t.Error("the key list must not contain secrets")
+	}
+
+	path := "/keys/" + strconv.Itoa(created.ID)
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, path, nil))
+		if rec.Code != want {
+			t.Errorf("expected status %d revoking %s, got %d", want, path, rec.Code)
+		}
+	}
+}
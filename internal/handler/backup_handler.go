@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zen-flo/url-shortener/internal/backup"
+	"github.com/zen-flo/url-shortener/internal/logger"
+)
+
+/*
+BackupHandler provides administrative endpoints for database snapshots.
+*/
+type BackupHandler struct {
+	Manager *backup.Manager
+}
+
+/*
+NewBackupHandler creates a new instance of BackupHandler.
+*/
+func NewBackupHandler(m *backup.Manager) *BackupHandler {
+	return &BackupHandler{Manager: m}
+}
+
+/*
+RegisterRoutes registers the backup routes. The caller mounts them behind admin authentication.
+*/
+func (h *BackupHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/backups", h.CreateBackup)
+	r.Get("/backups", h.ListBackups)
+}
+
+// CreateBackup handles POST /admin/backups requests.
+// @Summary Create a database backup
+// @Description Take a consistent online snapshot of the database and prune old snapshots
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Success 201 {object} backup.Snapshot "Snapshot created"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 409 {string} string "snapshot already exists"
+// @Failure 500 {string} string "backup failed"
+// @Router /admin/backups [post]
+func (h *BackupHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := h.Manager.Create(r.Context())
+	if errors.Is(err, backup.ErrSnapshotExists) {
+		writeError(w, r, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "backup failed", "error", err)
+		writeError(w, r, "backup failed", http.StatusInternalServerError)
+		return
+	}
+
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "backup created", "name", snapshot.Name, "size", snapshot.Size)
+	writeJSON(w, http.StatusCreated, snapshot)
+}
+
+// ListBackups handles GET /admin/backups requests.
+// @Summary List database backups
+// @Description List stored snapshots, newest first
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Success 200 {array} backup.Snapshot "Snapshots"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 500 {string} string "failed to list backups"
+// @Router /admin/backups [get]
+func (h *BackupHandler) ListBackups(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := h.Manager.List()
+	if err != nil {
+		writeError(w, r, "failed to list backups", http.StatusInternalServerError)
+		return
+	}
+	if snapshots == nil {
+		snapshots = []backup.Snapshot{}
+	}
+	writeJSON(w, http.StatusOK, snapshots)
+}
+
+/*
+writeJSON encodes v as the JSON response body with the given status.
+*/
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zen-flo/url-shortener/internal/backup"
+	"github.com/zen-flo/url-shortener/internal/db"
+)
+
+func TestBackupHandler(t *testing.T) {
+	dir := t.TempDir()
+	database, err := db.InitDB(filepath.Join(dir, "urls.db"))
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer database.Close()
+
+	r := chi.NewRouter()
+	NewBackupHandler(backup.NewManager(database, filepath.Join(dir, "backups"), 3)).RegisterRoutes(r)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/backups", nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var created backup.Snapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode snapshot: %v", err)
+	}
+	if created.Checksum == "" || created.Size == 0 {
+		t.Errorf("expected checksum and size, got %+v", created)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/backups", nil))
+	var list []backup.Snapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode list: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != created.Name {
+		t.Errorf("expected the created snapshot in the list, got %+v", list)
+	}
+}
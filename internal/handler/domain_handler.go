@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+/*
+DomainHandler provides administrative endpoints for managing branded short domains.
+*/
+type DomainHandler struct {
+	Service *service.URLService
+}
+
+/*
+NewDomainHandler creates a new instance of DomainHandler.
+*/
+func NewDomainHandler(s *service.URLService) *DomainHandler {
+	return &DomainHandler{Service: s}
+}
+
+/*
+RegisterRoutes registers the domain routes. The caller mounts them behind admin authentication.
+*/
+func (h *DomainHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/domains", h.CreateDomain)
+	r.Get("/domains", h.ListDomains)
+	r.Get("/domains/{name}", h.GetDomain)
+	r.Put("/domains/{name}", h.UpdateDomain)
+	r.Put("/domains/{name}/interstitial", h.SetInterstitial)
+	r.Delete("/domains/{name}", h.DeleteDomain)
+}
+
+// CreateDomain handles POST /admin/domains requests.
+// @Summary Add a branded domain
+// @Description Allow short links on a domain. Point its DNS at the service; redirects are resolved by the Host header.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param domain body model.Domain true "Domain name and defaults for new links" example({"name": "brand.link", "defaults": {"options": {"utm": {"source": "brand"}}}, "interstitial": 5})
+// @Success 201 {object} model.Domain "Domain added"
+// @Failure 400 {string} string "invalid domain"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 409 {string} string "domain already exists"
+// @Router /admin/domains [post]
+func (h *DomainHandler) CreateDomain(w http.ResponseWriter, r *http.Request) {
+	var req model.Domain
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.Service.CreateDomain(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "domain added", "domain", d.Name)
+	writeJSON(w, http.StatusCreated, d)
+}
+
+// ListDomains handles GET /admin/domains requests.
+// @Summary List branded domains
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Success 200 {array} model.Domain "Domains"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Router /admin/domains [get]
+func (h *DomainHandler) ListDomains(w http.ResponseWriter, r *http.Request) {
+	domains, err := h.Service.ListDomains(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if domains == nil {
+		domains = []model.Domain{}
+	}
+	writeJSON(w, http.StatusOK, domains)
+}
+
+// GetDomain handles GET /admin/domains/{name} requests.
+// @Summary Get a branded domain
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Param name path string true "Domain name" example("brand.link")
+// @Success 200 {object} model.Domain "Domain"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "domain not found"
+// @Router /admin/domains/{name} [get]
+func (h *DomainHandler) GetDomain(w http.ResponseWriter, r *http.Request) {
+	d, err := h.Service.GetDomain(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// UpdateDomain handles PUT /admin/domains/{name} requests.
+// @Summary Replace the defaults of a domain
+// @Description The defaults apply to links created afterwards; existing links keep their settings.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param name path string true "Domain name" example("brand.link")
+// @Param defaults body model.DomainDefaults true "Defaults for new links"
+// @Success 200 {object} model.Domain "Updated domain"
+// @Failure 400 {string} string "invalid domain"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "domain not found"
+// @Router /admin/domains/{name} [put]
+func (h *DomainHandler) UpdateDomain(w http.ResponseWriter, r *http.Request) {
+	var defaults model.DomainDefaults
+	if err := json.NewDecoder(r.Body).Decode(&defaults); err != nil {
+		writeError(w, r, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.Service.UpdateDomain(r.Context(), chi.URLParam(r, "name"), defaults)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+/*
+interstitialRequest is the body of PUT /admin/domains/{name}/interstitial.
+*/
+type interstitialRequest struct {
+	Seconds int `json:"seconds" example:"5"` // Countdown before redirecting, 0 removes it
+}
+
+// SetInterstitial handles PUT /admin/domains/{name}/interstitial requests.
+// @Summary Force an interstitial on a domain
+// @Description Every link of the domain, including existing ones, shows a countdown page with its destination before redirecting. Links with a longer countdown of their own keep it.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param name path string true "Domain name" example("brand.link")
+// @Param request body interstitialRequest true "Countdown in seconds"
+// @Success 200 {object} model.Domain "Updated domain"
+// @Failure 400 {string} string "invalid domain"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "domain not found"
+// @Router /admin/domains/{name}/interstitial [put]
+func (h *DomainHandler) SetInterstitial(w http.ResponseWriter, r *http.Request) {
+	var req interstitialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.Service.SetDomainInterstitial(r.Context(), chi.URLParam(r, "name"), req.Seconds)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// DeleteDomain handles DELETE /admin/domains/{name} requests.
+// @Summary Remove a branded domain
+// @Description Only domains without links can be removed.
+// @Tags Admin
+// @Security AdminToken
+// @Param name path string true "Domain name" example("brand.link")
+// @Success 204 {string} string "No Content"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "domain not found"
+// @Failure 409 {string} string "domain still has links"
+// @Router /admin/domains/{name} [delete]
+func (h *DomainHandler) DeleteDomain(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if err := h.Service.DeleteDomain(r.Context(), name); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "domain removed", "domain", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+writeError answers 404 for a domain named in the path that does not exist.
+*/
+func (h *DomainHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, service.ErrDomainNotFound) {
+		writeError(w, r, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeServiceError(w, r, err)
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+func TestBrandedDomains(t *testing.T) {
+	r, svc := setupRedirect(t)
+	NewURLHandler(svc).RegisterRoutes(r)
+	NewDomainHandler(svc).RegisterRoutes(r)
+
+	tests := []struct {
+		method, path, body string
+		wantStatus         int
+	}{
+		{http.MethodPost, "/domains", `{"name":"brand.link","defaults":{"options":{"utm":{"source":"brand"}}}}`, http.StatusCreated},
+		{http.MethodPost, "/domains", `{"name":"brand.link"}`, http.StatusConflict},
+		{http.MethodPost, "/domains", `{"name":"bad_domain"}`, http.StatusBadRequest},
+		{http.MethodGet, "/domains", "", http.StatusOK},
+		{http.MethodGet, "/domains/missing.link", "", http.StatusNotFound},
+		{http.MethodPut, "/domains/brand.link", `{"maxClicks":0}`, http.StatusBadRequest},
+		{http.MethodPost, "/urls", `{"original":"https://example.com","domain":"missing.link"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("expected %d for %s %s, got %d: %s", tt.wantStatus, tt.method, tt.path, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/urls", strings.NewReader(`{"original":"https://example.com","domain":"brand.link"}`)))
+	var link model.URL
+	if err := json.Unmarshal(rec.Body.Bytes(), &link); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 with the link, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if link.ShortURL != "http://brand.link/"+link.Short {
+		t.Errorf("unexpected short URL %s", link.ShortURL)
+	}
+
+	// The link is resolved by the Host header and addressed by the domain parameter in the API
+	req := httptest.NewRequest(http.MethodGet, "/"+link.Short, nil)
+	req.Host = "Brand.Link:8080"
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com?utm_source=brand" {
+		t.Errorf("expected a redirect with the domain defaults, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+link.Short, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 on the default domain, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/urls/"+link.Short+"?domain=brand.link", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for the link on its domain, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/domains/brand.link", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a domain with links, got %d", rec.Code)
+	}
+}
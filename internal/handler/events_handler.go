@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/net/http/httpguts"
+
+	"github.com/zen-flo/url-shortener/internal/clickfeed"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+const (
+	// streamHeartbeat is how often an idle click stream sends a keep-alive, so that proxies do not close it.
+	streamHeartbeat = 25 * time.Second
+	// streamWriteTimeout bounds each write to a click stream; a client that stops reading is disconnected.
+	streamWriteTimeout = 10 * time.Second
+)
+
+/*
+EventsHandler provides the administrative firehose of the clicks of all links.
+*/
+type EventsHandler struct {
+	Service *service.URLService
+}
+
+/*
+NewEventsHandler creates a new instance of EventsHandler.
+*/
+func NewEventsHandler(s *service.URLService) *EventsHandler {
+	return &EventsHandler{Service: s}
+}
+
+/*
+RegisterRoutes registers the firehose route. The caller mounts it behind admin authentication.
+*/
+func (h *EventsHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/events", h.Firehose)
+}
+
+// Firehose handles GET /admin/events requests.
+// @Summary Stream the clicks of all links
+// @Description Live feed of the redirects of all links, as server-sent events ("click" events with a Click as data) or, with an Upgrade: websocket request, as WebSocket text messages with a Click each. Browsers may pass the token in the access_token query parameter, since EventSource and WebSocket cannot set headers. A client that falls behind gets a "dropped" event or close status 1008 and is disconnected; on shutdown it gets a "closed" event or close status 1001.
+// @Tags Admin
+// @Produce text/event-stream
+// @Security AdminToken
+// @Param access_token query string false "Token for clients that cannot set headers"
+// @Success 200 {string} string "Stream of clicks"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Router /admin/events [get]
+func (h *EventsHandler) Firehose(w http.ResponseWriter, r *http.Request) {
+	sub := h.Service.SubscribeAllClicks()
+	defer sub.Close()
+	streamClicks(w, r, sub)
+}
+
+/*
+streamClicks sends the clicks of sub until the client goes away or the subscription ends:
+over WebSocket if the request asks for an upgrade, as server-sent events otherwise.
+*/
+func streamClicks(w http.ResponseWriter, r *http.Request, sub *clickfeed.Subscription) {
+	if isWebSocket(r) {
+		streamWebSocket(w, r, sub)
+		return
+	}
+	streamEvents(w, r, sub)
+}
+
+/*
+isWebSocket reports whether r asks to switch to WebSocket.
+*/
+func isWebSocket(r *http.Request) bool {
+	return httpguts.HeaderValuesContainsToken(r.Header["Connection"], "upgrade") &&
+		httpguts.HeaderValuesContainsToken(r.Header["Upgrade"], "websocket")
+}
+
+/*
+streamEvents sends the clicks as server-sent events.
+*/
+func streamEvents(w http.ResponseWriter, r *http.Request, sub *clickfeed.Subscription) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keeps nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+
+	send := func(msg string) error {
+		_ = rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := io.WriteString(w, msg); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	// EventSource reconnects after this many milliseconds when the stream ends
+	if err := send("retry: 3000\n\n"); err != nil {
+		return
+	}
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case c, ok := <-sub.C:
+			if !ok {
+				event, reason := streamEnd(sub)
+				_ = send("event: " + event + "\ndata: " + reason + "\n\n")
+				return
+			}
+			data, err := json.Marshal(c)
+			if err != nil {
+				return
+			}
+			if err := send("event: click\ndata: " + string(data) + "\n\n"); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := send(": ping\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+/*
+streamWebSocket sends the clicks as WebSocket text messages.
+*/
+func streamWebSocket(w http.ResponseWriter, r *http.Request, sub *clickfeed.Subscription) {
+	// The stream is authorized by its token rather than by cookies, so pages of any origin may open it
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+
+	// The client sends nothing but control frames; ctx is done once it closes the connection
+	ctx := conn.CloseRead(r.Context())
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case c, ok := <-sub.C:
+			if !ok {
+				code := websocket.StatusGoingAway
+				if sub.Dropped() {
+					code = websocket.StatusPolicyViolation
+				}
+				_, reason := streamEnd(sub)
+				_ = conn.Close(code, reason)
+				return
+			}
+			data, err := json.Marshal(c)
+			if err != nil {
+				return
+			}
+			if err := writeWithTimeout(ctx, func(ctx context.Context) error {
+				return conn.Write(ctx, websocket.MessageText, data)
+			}); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := writeWithTimeout(ctx, conn.Ping); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+/*
+writeWithTimeout runs a write to a click stream bounded by streamWriteTimeout.
+*/
+func writeWithTimeout(ctx context.Context, write func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, streamWriteTimeout)
+	defer cancel()
+	return write(ctx)
+}
+
+/*
+streamEnd returns the event name and reason sent when a subscription ends:
+the client fell behind, or the server is shutting down.
+*/
+func streamEnd(sub *clickfeed.Subscription) (event, reason string) {
+	if sub.Dropped() {
+		return "dropped", "client fell behind"
+	}
+	return "closed", "server shutting down"
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/clickfeed"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+/*
+readEvent reads one server-sent event and returns its name and data.
+*/
+func readEvent(t *testing.T, br *bufio.Reader) (event, data string) {
+	t.Helper()
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if event != "" || data != "" {
+				return event, data
+			}
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+/*
+waitSubscribers waits until the click feed of svc has n subscribers.
+*/
+func waitSubscribers(t *testing.T, svc *service.URLService, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for svc.Clicks.Len() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d subscribers, got %d", n, svc.Clicks.Len())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClickEvents(t *testing.T) {
+	r, svc := setupRedirect(t)
+	NewURLHandler(svc).RegisterRoutes(r)
+	// Requests with a token are authenticated, as Authenticate does in the router:
+	// "secret" is the admin token, any other one the API key of that name
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch token := auth.TokenFromRequest(req); token {
+		case "":
+		case "secret":
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Name: "admin", Scopes: []string{auth.ScopeAdmin}}))
+		default:
+			req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Name: "key:" + token}))
+		}
+		r.ServeHTTP(w, req)
+	}))
+	defer srv.Close()
+
+	created, err := svc.CreateShortURL(t.Context(), "https://example.com", service.WithOwner("marketing"))
+	if err != nil {
+		t.Fatalf("CreateShortURL: %v", err)
+	}
+
+	get := func(path, token string) *http.Response {
+		req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL+path, nil)
+		req.Header.Set("Accept", "text/event-stream")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	if resp := get("/urls/"+created.Short+"/events", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", resp.StatusCode)
+	}
+	if resp := get("/urls/missing/events", "secret"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown link, got %d", resp.StatusCode)
+	}
+	if resp := get("/urls/"+created.Short+"/events", "sales"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for another key, got %d", resp.StatusCode)
+	}
+
+	resp := get("/urls/"+created.Short+"/events", "marketing")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	waitSubscribers(t, svc, 1)
+
+	if err := svc.RegisterClick(t.Context(), "", created.Short, -1); err != nil {
+		t.Fatalf("RegisterClick: %v", err)
+	}
+	br := bufio.NewReader(resp.Body)
+	event, data := readEvent(t, br)
+	var click model.Click
+	if err := json.Unmarshal([]byte(data), &click); err != nil || event != "click" || click.Short != created.Short {
+		t.Fatalf("expected the click, got %q %q (err %v)", event, data, err)
+	}
+
+	// Shutting down ends the stream instead of waiting for the client
+	svc.Clicks.Close()
+	if event, _ := readEvent(t, br); event != "closed" {
+		t.Errorf("expected a closed event, got %q", event)
+	}
+	if _, err := br.ReadString('\n'); err != io.EOF {
+		t.Errorf("expected the stream to end, got %v", err)
+	}
+}
+
+func TestClickEventsDropped(t *testing.T) {
+	hub := clickfeed.New(1)
+	sub := hub.Subscribe(nil)
+	hub.Publish(model.Click{Short: "abc"})
+	hub.Publish(model.Click{Short: "abc"})
+
+	rec := httptest.NewRecorder()
+	streamClicks(rec, httptest.NewRequest(http.MethodGet, "/admin/events", nil), sub)
+
+	br := bufio.NewReader(rec.Body)
+	if event, _ := readEvent(t, br); event != "click" {
+		t.Errorf("expected the buffered click first, got %q", event)
+	}
+	if event, data := readEvent(t, br); event != "dropped" || data != "client fell behind" {
+		t.Errorf("expected a dropped event, got %q %q", event, data)
+	}
+}
+
+func TestFirehoseWebSocket(t *testing.T) {
+	r, svc := setupRedirect(t)
+	NewEventsHandler(svc).RegisterRoutes(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	conn, resp, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/events", nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected the upgrade, got %v (err %v)", resp, err)
+	}
+	defer conn.CloseNow()
+
+	waitSubscribers(t, svc, 1)
+	created, err := svc.CreateShortURL(ctx, "https://example.com")
+	if err != nil {
+		t.Fatalf("CreateShortURL: %v", err)
+	}
+	if err := svc.RegisterClick(ctx, "", created.Short, -1); err != nil {
+		t.Fatalf("RegisterClick: %v", err)
+	}
+	typ, msg, err := conn.Read(ctx)
+	var click model.Click
+	if err != nil || typ != websocket.MessageText || json.Unmarshal(msg, &click) != nil || click.Short != created.Short {
+		t.Fatalf("expected the click as a text message, got %v %q (err %v)", typ, msg, err)
+	}
+
+	svc.Clicks.Close()
+	if _, _, err := conn.Read(ctx); websocket.CloseStatus(err) != websocket.StatusGoingAway {
+		t.Errorf("expected close status 1001 on shutdown, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+/*
+LinkCheckHandler provides the administrative endpoint for checking link destinations on demand.
+*/
+type LinkCheckHandler struct {
+	Service *service.URLService
+}
+
+/*
+NewLinkCheckHandler creates a new instance of LinkCheckHandler.
+*/
+func NewLinkCheckHandler(s *service.URLService) *LinkCheckHandler {
+	return &LinkCheckHandler{Service: s}
+}
+
+/*
+RegisterRoutes registers the link check routes. The caller mounts them behind admin authentication.
+*/
+func (h *LinkCheckHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/linkcheck/run", h.Run)
+}
+
+// Run handles POST /admin/linkcheck/run requests.
+// @Summary Check all link destinations
+// @Description Check the destinations of all enabled links now and record the results, see GET /urls/{short}/health. Runs periodically in the background as well. With many links on few hosts this takes a while, since requests to one host are spaced out.
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Success 200 {object} service.HealthResult "Check summary"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Router /admin/linkcheck/run [post]
+func (h *LinkCheckHandler) Run(w http.ResponseWriter, r *http.Request) {
+	result, err := h.Service.CheckLinks(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,392 @@
+package handler
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/metrics"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/ratelimit"
+	"github.com/zen-flo/url-shortener/internal/rules"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+//go:embed templates/password.html templates/warning.html templates/preview.html templates/interstitial.html
+var templateFS embed.FS
+
+var (
+	passwordPage     = template.Must(template.ParseFS(templateFS, "templates/password.html"))
+	warningPage      = template.Must(template.ParseFS(templateFS, "templates/warning.html"))
+	previewPage      = template.Must(template.ParseFS(templateFS, "templates/preview.html"))
+	interstitialPage = template.Must(template.ParseFS(templateFS, "templates/interstitial.html"))
+)
+
+// Defaults for password-protected links.
+const (
+	DefaultUnlockTTL      = 10 * time.Minute
+	DefaultUnlockAttempts = 5
+	DefaultUnlockWindow   = 15 * time.Minute
+)
+
+// VariantCookieTTL is how long a visitor keeps their A/B variant.
+const VariantCookieTTL = 30 * 24 * time.Hour
+
+/*
+RedirectHandler sends visitors of a short link to its destination.
+Password-protected links first show a prompt; a correct password sets a signed cookie
+scoped to the link, so the visitor is not asked again until it expires.
+*/
+type RedirectHandler struct {
+	Service service.URLServiceInterface
+	// Unlock signs the cookies proving that the password of a link was entered.
+	Unlock *auth.Signer
+	// UnlockTTL is the lifetime of those cookies.
+	UnlockTTL time.Duration
+	// Attempts limits password attempts per link and client address.
+	Attempts *ratelimit.Limiter
+	// Rules picks the destination from the redirect rules of a link.
+	Rules *rules.Engine
+	// Timeout bounds every service call made while handling a request.
+	Timeout time.Duration
+}
+
+/*
+LinkPreview describes a link without following it.
+*/
+// @name LinkPreview
+type LinkPreview struct {
+	Short        string    `json:"short" example:"abc123"`
+	ShortURL     string    `json:"shortUrl,omitempty" example:"https://brand.link/abc123"`
+	Destinations []string  `json:"destinations,omitempty"` // Original URL, then those of rules and variants; hidden while the link is locked
+	CreatedAt    time.Time `json:"createdAt"`
+	Owner        string    `json:"owner,omitempty"` // Display name of the creator
+	Clicks       int       `json:"clicks"`
+	Protected    bool      `json:"protected,omitempty"`
+	Interstitial int       `json:"interstitial,omitempty"` // Seconds of the countdown shown before redirecting
+}
+
+/*
+NewRedirectHandler creates a new instance of RedirectHandler with default limits.
+*/
+func NewRedirectHandler(s service.URLServiceInterface, unlock *auth.Signer) *RedirectHandler {
+	return &RedirectHandler{
+		Service:   s,
+		Unlock:    unlock,
+		UnlockTTL: DefaultUnlockTTL,
+		Attempts:  ratelimit.New(DefaultUnlockAttempts, DefaultUnlockWindow),
+		Rules:     rules.NewEngine(nil),
+		Timeout:   DefaultTimeout,
+	}
+}
+
+/*
+RegisterRoutes registers the redirect routes. They must be registered after all other
+top-level routes, which take precedence as static paths.
+A path after the short code is passed through to links that allow it.
+*/
+func (h *RedirectHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/{short}", h.Redirect)
+	r.Post("/{short}", h.SubmitPassword)
+	r.Get("/{short}/*", h.Redirect)
+	r.Post("/{short}/*", h.SubmitPassword)
+}
+
+// Redirect handles GET /{short} requests.
+// @Summary Follow a short link
+// @Description Redirect to the original URL of the link on the domain named by the Host header. Password-protected links show an HTML password prompt instead. Depending on the link options the query string is forwarded, UTM parameters are added and a path after the short code (/{short}/extra/path) is appended to the destination. Links with an interstitial, set on the link or its domain, show a countdown page before redirecting.
+// @Description
+// @Description A "+" after the short code (/{short}+) or preview=1 shows a preview of the link instead of following it, without counting a click: HTML, or JSON if requested with Accept: application/json or format=json.
+// @Tags Redirect
+// @Produce html
+// @Produce json
+// @Param short path string true "Short code" example("abc123")
+// @Param preview query int false "Show the preview instead of redirecting" Enums(1)
+// @Param format query string false "Preview format" Enums(json)
+// @Success 302 {string} string "Redirect to the original URL"
+// @Success 200 {object} LinkPreview "Password prompt, interstitial countdown or preview"
+// @Failure 403 {string} string "Warning page of a link disabled by screening"
+// @Failure 404 {string} string "URL not found or path pass-through disabled"
+// @Failure 410 {string} string "URL has reached its click limit"
+// @Router /{short} [get]
+func (h *RedirectHandler) Redirect(w http.ResponseWriter, r *http.Request) {
+	short, preview := strings.CutSuffix(chi.URLParam(r, "short"), "+")
+	url, ok := h.lookup(w, r, short)
+	if !ok {
+		return
+	}
+
+	if preview || r.URL.Query().Get("preview") == "1" {
+		h.renderPreview(w, r, url)
+		return
+	}
+	if url.Protected {
+		w.Header().Set("Cache-Control", "no-store")
+		if !h.unlocked(r, url) {
+			h.renderPrompt(w, r, url, http.StatusOK, "")
+			return
+		}
+	}
+	h.redirect(w, r, url, http.StatusFound)
+}
+
+// SubmitPassword handles POST /{short} requests.
+// @Summary Unlock a password-protected link
+// @Description Check the password from the prompt form and redirect to the original URL
+// @Tags Redirect
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param short path string true "Short code" example("abc123")
+// @Param password formData string true "Link password"
+// @Success 303 {string} string "Redirect to the original URL"
+// @Failure 401 {string} string "Wrong password"
+// @Failure 403 {string} string "Warning page of a link disabled by screening"
+// @Failure 404 {string} string "URL not found"
+// @Failure 410 {string} string "URL has reached its click limit"
+// @Failure 429 {string} string "Too many attempts"
+// @Router /{short} [post]
+func (h *RedirectHandler) SubmitPassword(w http.ResponseWriter, r *http.Request) {
+	url, ok := h.lookup(w, r, chi.URLParam(r, "short"))
+	if !ok {
+		return
+	}
+	if !url.Protected {
+		h.redirect(w, r, url, http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	key := url.Short + "|" + clientIP(r)
+	if allowed, retryAfter := h.Attempts.Allow(key); !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+0.5)))
+		h.renderPrompt(w, r, url, http.StatusTooManyRequests, "Too many attempts, try again later.")
+		return
+	}
+
+	if !service.CheckPassword(url, r.PostFormValue("password")) {
+		logger.FromContext(r.Context()).WarnContext(r.Context(), "wrong link password", "short", url.Short)
+		h.renderPrompt(w, r, url, http.StatusUnauthorized, "Wrong password.")
+		return
+	}
+	h.Attempts.Reset(key)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     unlockCookie(url.Short),
+		Value:    h.Unlock.Sign(unlockValue(url), h.UnlockTTL),
+		Path:     "/",
+		MaxAge:   int(h.UnlockTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	h.redirect(w, r, url, http.StatusSeeOther)
+}
+
+/*
+lookup loads the link with the short code on the domain of the Host header, writing the error response if that fails
+or the path has a suffix the link does not pass through. Disabled links get the warning page.
+*/
+func (h *RedirectHandler) lookup(w http.ResponseWriter, r *http.Request, short string) (*model.URL, bool) {
+	ctx, cancel := requestContext(r, h.Timeout)
+	defer cancel()
+
+	domain, err := h.Service.ResolveDomain(ctx, r.Host)
+	if err != nil {
+		h.writeError(w, r, err)
+		return nil, false
+	}
+	url, err := h.Service.GetOriginalURL(ctx, domain, short)
+	if err == nil && url.ClicksLeft != nil && *url.ClicksLeft <= 0 {
+		err = service.ErrGone
+	}
+	if err == nil && chi.URLParam(r, "*") != "" && !url.Options.PassPath {
+		// Without pass-through the path is not part of any link
+		err = service.ErrNotFound
+	}
+	if err != nil {
+		h.writeError(w, r, err)
+		return nil, false
+	}
+	if url.Disabled {
+		metrics.RecordBlocked(r.Context())
+		h.renderWarning(w, r, url)
+		return nil, false
+	}
+	return url, true
+}
+
+/*
+unlocked reports whether the visitor may follow the link: it has no password,
+or the visitor holds a valid unlock cookie for it.
+*/
+func (h *RedirectHandler) unlocked(r *http.Request, url *model.URL) bool {
+	if !url.Protected {
+		return true
+	}
+	c, err := r.Cookie(unlockCookie(url.Short))
+	return err == nil && h.Unlock.Verify(c.Value, unlockValue(url))
+}
+
+/*
+redirect consumes a click of the link and sends the visitor to the destination chosen by its rules,
+through the countdown page if the link or its domain has an interstitial.
+*/
+func (h *RedirectHandler) redirect(w http.ResponseWriter, r *http.Request, url *model.URL, code int) {
+	ctx, cancel := requestContext(r, h.Timeout)
+	defer cancel()
+
+	decision := h.Rules.Resolve(r, url)
+	target, err := h.Rules.Target(r, url, decision, chi.URLParam(r, "*"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if err := h.Service.RegisterClick(ctx, url.Domain, url.Short, decision.Variant); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	metrics.RecordRedirect(ctx)
+
+	if len(url.Rules) > 0 || len(url.Variants) > 0 || url.Options.UTM != (model.UTM{}) {
+		// The destination depends on the visitor, so shared caches must not reuse it
+		w.Header().Set("Cache-Control", "private, no-cache")
+		w.Header().Add("Vary", "User-Agent, Accept-Language, Cookie")
+	}
+	if decision.Variant >= 0 {
+		http.SetCookie(w, &http.Cookie{
+			Name:     rules.VariantCookie(url.Short),
+			Value:    strconv.Itoa(decision.Variant),
+			Path:     "/",
+			MaxAge:   int(VariantCookieTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	if seconds := url.Countdown(); seconds > 0 {
+		h.renderInterstitial(w, r, target, seconds)
+		return
+	}
+	http.Redirect(w, r, target, code)
+}
+
+func (h *RedirectHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		metrics.RecordNotFound(r.Context())
+	case errors.Is(err, service.ErrGone):
+		metrics.RecordExpired(r.Context())
+	}
+	writeServiceError(w, r, err)
+}
+
+func (h *RedirectHandler) renderPrompt(w http.ResponseWriter, r *http.Request, url *model.URL, code int, msg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(code)
+	// The form posts back to the same URI so that the path suffix and query survive the prompt
+	data := struct{ Action, Error string }{r.URL.RequestURI(), msg}
+	if err := passwordPage.Execute(w, data); err != nil {
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "failed to render password prompt", "error", err)
+	}
+}
+
+/*
+renderWarning shows why a disabled link does not redirect, without revealing the destination.
+*/
+func (h *RedirectHandler) renderWarning(w http.ResponseWriter, r *http.Request, url *model.URL) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(http.StatusForbidden)
+	if err := warningPage.Execute(w, struct{ Reason string }{url.DisabledReason}); err != nil {
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "failed to render warning page", "error", err)
+	}
+}
+
+/*
+renderPreview describes the link as JSON or an HTML page, without counting a click.
+The destinations of a password-protected link stay hidden until it is unlocked.
+*/
+func (h *RedirectHandler) renderPreview(w http.ResponseWriter, r *http.Request, url *model.URL) {
+	preview := LinkPreview{
+		Short:        url.Short,
+		ShortURL:     url.ShortURL,
+		CreatedAt:    url.CreatedAt,
+		Owner:        url.Owner,
+		Clicks:       url.Clicks,
+		Protected:    url.Protected,
+		Interstitial: url.Countdown(),
+	}
+	if h.unlocked(r, url) {
+		preview.Destinations = url.Destinations()
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Add("Vary", "Accept")
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		writeJSON(w, http.StatusOK, preview)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	data := struct {
+		LinkPreview
+		Follow string
+	}{preview, "/" + url.Short}
+	if err := previewPage.Execute(w, data); err != nil {
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "failed to render preview page", "error", err)
+	}
+}
+
+/*
+renderInterstitial shows the destination with a countdown, after which the browser follows it.
+*/
+func (h *RedirectHandler) renderInterstitial(w http.ResponseWriter, r *http.Request, target string, seconds int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(http.StatusOK)
+	// Only web destinations are followed automatically; links stored before they were enforced may hold others
+	lower := strings.ToLower(target)
+	data := struct {
+		Target   string
+		Seconds  int
+		Redirect bool
+	}{target, seconds, strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")}
+	if err := interstitialPage.Execute(w, data); err != nil {
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "failed to render interstitial page", "error", err)
+	}
+}
+
+func unlockCookie(short string) string {
+	return "unlock_" + short
+}
+
+/*
+unlockValue is the value signed in the unlock cookie. It names the domain as well,
+so that a cookie copied to another domain does not unlock a link with the same code.
+*/
+func unlockValue(url *model.URL) string {
+	return "unlock:" + url.Domain + "/" + url.Short
+}
+
+/*
+clientIP returns the address of the client connection without the port.
+*/
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,363 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/ratelimit"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+func setupRedirect(t *testing.T) (*chi.Mux, *service.URLService) {
+	database, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to connect to in-memory DB: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	svc := service.NewURLService(database)
+	h := NewRedirectHandler(svc, auth.NewSigner([]byte("secret")))
+	h.Attempts = ratelimit.New(2, time.Minute)
+
+	r := chi.NewRouter()
+	h.RegisterRoutes(r)
+	return r, svc
+}
+
+func postPassword(r http.Handler, short, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/"+short, strings.NewReader(url.Values{"password": {password}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRedirect(t *testing.T) {
+	r, svc := setupRedirect(t)
+	link, err := svc.CreateShortURL(context.Background(), "https://example.com")
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+link.Short, nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com" {
+		t.Fatalf("expected 302 to the original URL, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown link, got %d", rec.Code)
+	}
+}
+
+func TestPasswordProtectedRedirect(t *testing.T) {
+	r, svc := setupRedirect(t)
+	link, err := svc.CreateShortURL(context.Background(), "https://example.com/internal", service.WithPassword("s3cret"))
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	// The prompt is shown instead of the redirect
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+link.Short, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `name="password"`) {
+		t.Fatalf("expected the password prompt, got %d", rec.Code)
+	}
+
+	if rec := postPassword(r, link.Short, "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong password, got %d", rec.Code)
+	}
+
+	rec = postPassword(r, link.Short, "s3cret")
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "https://example.com/internal" {
+		t.Fatalf("expected 303 to the original URL, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != unlockCookie(link.Short) || !cookies[0].HttpOnly {
+		t.Fatalf("expected an unlock cookie named after the link, got %+v", cookies)
+	}
+
+	// The browser sends the cookie to the preview of the link as well, which then shows the destination
+	jar, _ := cookiejar.New(nil)
+	jar.SetCookies(&url.URL{Scheme: "http", Host: "example.com", Path: "/" + link.Short}, cookies)
+	req := httptest.NewRequest(http.MethodGet, "/"+link.Short+"+", nil)
+	for _, c := range jar.Cookies(&url.URL{Scheme: "http", Host: "example.com", Path: "/" + link.Short + "+"}) {
+		req.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "https://example.com/internal") {
+		t.Fatalf("expected the preview of the unlocked link, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// The cookie skips the prompt
+	req = httptest.NewRequest(http.MethodGet, "/"+link.Short, nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected 302 with the unlock cookie, got %d", rec.Code)
+	}
+
+	// A cookie for another link does not unlock this one
+	other, _ := svc.CreateShortURL(context.Background(), "https://example.com/other", service.WithPassword("other"))
+	req = httptest.NewRequest(http.MethodGet, "/"+other.Short, nil)
+	req.AddCookie(&http.Cookie{Name: unlockCookie(other.Short), Value: cookies[0].Value})
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the prompt for another link, got %d", rec.Code)
+	}
+}
+
+func TestPasswordAttemptsLimited(t *testing.T) {
+	r, svc := setupRedirect(t)
+	link, _ := svc.CreateShortURL(context.Background(), "https://example.com", service.WithPassword("s3cret"))
+
+	for i := 0; i < 2; i++ {
+		if rec := postPassword(r, link.Short, "wrong"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i+1, rec.Code)
+		}
+	}
+
+	// Even the correct password is rejected once the limit is reached
+	rec := postPassword(r, link.Short, "s3cret")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("expected 429 with Retry-After, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestOneTimeLink(t *testing.T) {
+	r, svc := setupRedirect(t)
+	link, err := svc.CreateShortURL(context.Background(), "https://example.com/welcome", service.WithMaxClicks(1))
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	for _, want := range []int{http.StatusFound, http.StatusGone, http.StatusGone} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+link.Short, nil))
+		if rec.Code != want {
+			t.Fatalf("expected %d, got %d", want, rec.Code)
+		}
+	}
+}
+
+func TestRedirectRules(t *testing.T) {
+	r, svc := setupRedirect(t)
+	urlHandler := NewURLHandler(svc)
+	urlHandler.RegisterRoutes(r)
+
+	link, err := svc.CreateShortURL(context.Background(), "https://example.com")
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	body := `[{"destination":"https://apps.apple.com/app","os":["ios"]},{"destination":"https://play.google.com/app","os":["android"]}]`
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, asAdmin(httptest.NewRequest(http.MethodPut, "/urls/"+link.Short+"/rules", strings.NewReader(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for PUT rules, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, asAdmin(httptest.NewRequest(http.MethodPut, "/urls/"+link.Short+"/rules", strings.NewReader(`[{"destination":""}]`))))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid rule, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/urls/"+link.Short+"/rules", nil))
+	if !strings.Contains(rec.Body.String(), "play.google.com") {
+		t.Fatalf("expected the saved rules, got %s", rec.Body.String())
+	}
+
+	tests := map[string]string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148": "https://apps.apple.com/app",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile Safari/537.36":        "https://play.google.com/app",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0":               "https://example.com",
+	}
+	for ua, want := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/"+link.Short, nil)
+		req.Header.Set("User-Agent", ua)
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != want {
+			t.Errorf("%s: expected redirect to %s, got %d %s", ua, want, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+}
+
+func TestSplitRedirect(t *testing.T) {
+	r, svc := setupRedirect(t)
+	NewURLHandler(svc).RegisterRoutes(r)
+
+	link, _ := svc.CreateShortURL(context.Background(), "https://example.com")
+	body := `[{"destination":"https://example.com/a","weight":1},{"destination":"https://example.com/b","weight":1}]`
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, asAdmin(httptest.NewRequest(http.MethodPut, "/urls/"+link.Short+"/variants", strings.NewReader(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for PUT variants, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+link.Short, nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusFound || len(cookies) != 1 {
+		t.Fatalf("expected a redirect with the variant cookie, got %d %+v", rec.Code, cookies)
+	}
+	first := rec.Header().Get("Location")
+
+	// The cookie keeps the visitor on the same variant even from another address
+	req := httptest.NewRequest(http.MethodGet, "/"+link.Short, nil)
+	req.RemoteAddr = "198.51.100.7:4321"
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Header().Get("Location") != first {
+		t.Errorf("expected the sticky destination %s, got %s", first, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/urls/"+link.Short+"/stats", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"clicks":2`) {
+		t.Errorf("expected 2 clicks for one variant in stats, got %s", rec.Body.String())
+	}
+}
+
+func TestRedirectOptions(t *testing.T) {
+	r, svc := setupRedirect(t)
+	NewURLHandler(svc).RegisterRoutes(r)
+
+	link, _ := svc.CreateShortURL(context.Background(), "https://example.com/docs?lang=en", service.WithPassword("s3cret"))
+
+	// Without pass-through a path after the short code is unknown
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+link.Short+"/guide", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a path suffix, got %d", rec.Code)
+	}
+
+	body := `{"forwardQuery":"merge","passPath":true,"utm":{"source":"qr"}}`
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, asAdmin(httptest.NewRequest(http.MethodPut, "/urls/"+link.Short+"/options", strings.NewReader(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for PUT options, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The password prompt posts back to the full URI
+	target := "/" + link.Short + "/guide?ref=x&lang=de"
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if !strings.Contains(rec.Body.String(), `action="/`+link.Short+`/guide?ref=x&amp;lang=de"`) {
+		t.Fatalf("expected the prompt to post back to %s, got %s", target, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(url.Values{"password": {"s3cret"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	want := "https://example.com/docs/guide?lang=en&ref=x&utm_source=qr"
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != want {
+		t.Fatalf("expected 303 to %s, got %d %q", want, rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, asAdmin(httptest.NewRequest(http.MethodPut, "/urls/"+link.Short+"/options", strings.NewReader(`{"forwardQuery":"append"}`))))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown forwarding policy, got %d", rec.Code)
+	}
+}
+
+func TestLinkPreview(t *testing.T) {
+	r, svc := setupRedirect(t)
+	ctx := context.Background()
+	link, _ := svc.CreateShortURL(ctx, "https://example.com/docs", service.WithOwner("marketing"))
+
+	// The "+" suffix and preview=1 both show the preview without redirecting
+	for _, target := range []string{"/" + link.Short + "+", "/" + link.Short + "?preview=1"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK || !strings.Contains(body, "https://example.com/docs") || !strings.Contains(body, "by marketing") {
+			t.Fatalf("%s: expected the HTML preview, got %d %s", target, rec.Code, body)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/"+link.Short+"+", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	var preview LinkPreview
+	if err := json.NewDecoder(rec.Body).Decode(&preview); err != nil {
+		t.Fatalf("expected a JSON preview, got %v", err)
+	}
+	if len(preview.Destinations) != 1 || preview.Destinations[0] != "https://example.com/docs" || preview.Owner != "marketing" {
+		t.Errorf("unexpected preview %+v", preview)
+	}
+
+	// Previews are not clicks
+	got, _ := svc.GetOriginalURL(ctx, "", link.Short)
+	if got.Clicks != 0 {
+		t.Errorf("expected no clicks after previews, got %d", got.Clicks)
+	}
+
+	// The destination of a protected link is not revealed
+	protected, _ := svc.CreateShortURL(ctx, "https://example.com/internal", service.WithPassword("s3cret"))
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+protected.Short+"?preview=1&format=json", nil))
+	preview = LinkPreview{}
+	if err := json.NewDecoder(rec.Body).Decode(&preview); err != nil || !preview.Protected || len(preview.Destinations) != 0 {
+		t.Errorf("expected the destination to be hidden, got %+v (err %v)", preview, err)
+	}
+}
+
+func TestInterstitial(t *testing.T) {
+	r, svc := setupRedirect(t)
+	ctx := context.Background()
+	link, _ := svc.CreateShortURL(ctx, "https://example.com/a?b=1", service.WithRedirectOptions(model.RedirectOptions{Interstitial: 5}))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+link.Short, nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, `content="5;url=https://example.com/a?b=1"`) {
+		t.Fatalf("expected the countdown page, got %d %s", rec.Code, body)
+	}
+	if got, _ := svc.GetOriginalURL(ctx, "", link.Short); got.Clicks != 1 {
+		t.Errorf("expected the click to be counted, got %d", got.Clicks)
+	}
+
+	// A domain interstitial applies to its links as well
+	if _, err := svc.CreateDomain(ctx, model.Domain{Name: "brand.link", Interstitial: 3}); err != nil {
+		t.Fatalf("CreateDomain failed: %v", err)
+	}
+	branded, _ := svc.CreateShortURL(ctx, "https://example.com", service.WithDomain("brand.link"))
+	req := httptest.NewRequest(http.MethodGet, "/"+branded.Short, nil)
+	req.Host = "brand.link"
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `content="3;url=https://example.com"`) {
+		t.Fatalf("expected the domain countdown page, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Destinations other than http and https are never followed from the page
+	rec = httptest.NewRecorder()
+	NewRedirectHandler(svc, nil).renderInterstitial(rec, httptest.NewRequest(http.MethodGet, "/x", nil), "javascript:alert(1)", 3)
+	if body := rec.Body.String(); strings.Contains(body, "location.replace(") || strings.Contains(body, "http-equiv") {
+		t.Errorf("expected no automatic redirect to a javascript: URL, got %s", body)
+	}
+}
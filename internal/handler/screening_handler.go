@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+/*
+ScreeningHandler provides administrative endpoints for disabling links and rescanning destinations.
+*/
+type ScreeningHandler struct {
+	Service *service.URLService
+}
+
+/*
+NewScreeningHandler creates a new instance of ScreeningHandler.
+*/
+func NewScreeningHandler(s *service.URLService) *ScreeningHandler {
+	return &ScreeningHandler{Service: s}
+}
+
+/*
+RegisterRoutes registers the screening routes. The caller mounts them behind admin authentication.
+*/
+func (h *ScreeningHandler) RegisterRoutes(r chi.Router) {
+	r.Put("/urls/{short}/disabled", h.DisableURL)
+	r.Delete("/urls/{short}/disabled", h.EnableURL)
+	r.Post("/screening/scan", h.Rescan)
+}
+
+/*
+disableRequest is the body of PUT /admin/urls/{short}/disabled.
+*/
+type disableRequest struct {
+	Reason string `json:"reason" example:"reported as phishing"` // Shown to visitors on the warning page
+}
+
+// DisableURL handles PUT /admin/urls/{short}/disabled requests.
+// @Summary Disable a link
+// @Description Visitors of a disabled link see a warning page with the reason instead of being redirected.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param short path string true "Short code" example("abc123")
+// @Param domain query string false "Branded domain of the link" example("brand.link")
+// @Param request body disableRequest true "Reason"
+// @Success 200 {object} model.URL "Disabled link"
+// @Failure 400 {string} string "reason is required"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "URL not found"
+// @Router /admin/urls/{short}/disabled [put]
+func (h *ScreeningHandler) DisableURL(w http.ResponseWriter, r *http.Request) {
+	var req disableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		writeError(w, r, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	if url, ok := h.setDisabled(w, r, req.Reason); ok {
+		logger.FromContext(r.Context()).InfoContext(r.Context(), "link disabled", "domain", url.Domain, "short", url.Short, "reason", req.Reason)
+		writeJSON(w, http.StatusOK, url)
+	}
+}
+
+// EnableURL handles DELETE /admin/urls/{short}/disabled requests.
+// @Summary Enable a disabled link
+// @Description Resume redirects, e.g. after a false positive. A link still matching a blocklist is disabled again by the next rescan.
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Param short path string true "Short code" example("abc123")
+// @Param domain query string false "Branded domain of the link" example("brand.link")
+// @Success 200 {object} model.URL "Enabled link"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "URL not found"
+// @Router /admin/urls/{short}/disabled [delete]
+func (h *ScreeningHandler) EnableURL(w http.ResponseWriter, r *http.Request) {
+	if url, ok := h.setDisabled(w, r, ""); ok {
+		logger.FromContext(r.Context()).InfoContext(r.Context(), "link enabled", "domain", url.Domain, "short", url.Short)
+		writeJSON(w, http.StatusOK, url)
+	}
+}
+
+/*
+setDisabled sets the disabled reason of the link named in the request, writing the error response if that fails.
+*/
+func (h *ScreeningHandler) setDisabled(w http.ResponseWriter, r *http.Request, reason string) (*model.URL, bool) {
+	domain, short := linkKey(r)
+	url, err := h.Service.SetDisabled(r.Context(), domain, short, reason)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return nil, false
+	}
+	return url, true
+}
+
+// Rescan handles POST /admin/screening/scan requests.
+// @Summary Rescan all link destinations
+// @Description Screen the destinations of all enabled links again and disable those now blocked. Runs periodically in the background as well.
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Success 200 {object} service.ScanResult "Scan summary"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Router /admin/screening/scan [post]
+func (h *ScreeningHandler) Rescan(w http.ResponseWriter, r *http.Request) {
+	result, err := h.Service.Rescan(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zen-flo/url-shortener/internal/screening"
+)
+
+func TestScreening(t *testing.T) {
+	r, svc := setupRedirect(t)
+	NewURLHandler(svc).RegisterRoutes(r)
+	NewScreeningHandler(svc).RegisterRoutes(r)
+	threats := map[string]string{"evil.test": "phishing"}
+	svc.Screener = screening.New(&screening.Stub{Threats: threats})
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/urls", `{"original":"https://evil.test/login"}`); rec.Code != http.StatusUnprocessableEntity ||
+		!strings.Contains(rec.Body.String(), "stub: phishing") {
+		t.Errorf("expected 422 with the reason, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	link, err := svc.CreateShortURL(t.Context(), "https://turned.test")
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	threats["turned.test"] = "malware"
+	if rec := do(http.MethodPost, "/screening/scan", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"disabled":1`) {
+		t.Fatalf("expected the scan to disable the link, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := do(http.MethodGet, "/"+link.Short, "")
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "stub: malware") {
+		t.Errorf("expected the warning page, got %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "turned.test") || rec.Header().Get("Location") != "" {
+		t.Error("expected the warning page not to reveal the destination")
+	}
+
+	if rec := do(http.MethodPut, "/urls/"+link.Short+"/disabled", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a reason, got %d", rec.Code)
+	}
+	if rec := do(http.MethodDelete, "/urls/"+link.Short+"/disabled", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected the link to be enabled, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/"+link.Short, ""); rec.Code != http.StatusFound {
+		t.Errorf("expected the redirect to resume, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPut, "/urls/"+link.Short+"/disabled", `{"reason":"reported as spam"}`); rec.Code != http.StatusOK ||
+		!strings.Contains(rec.Body.String(), `"disabledReason":"reported as spam"`) {
+		t.Errorf("expected the link to be disabled by hand, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
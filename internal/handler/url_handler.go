@@ -1,12 +1,30 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	_ "github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/metrics"
+	"github.com/zen-flo/url-shortener/internal/model"
 	"github.com/zen-flo/url-shortener/internal/service"
+	"github.com/zen-flo/url-shortener/internal/tracing"
+)
+
+// DefaultTimeout is the per-request deadline applied to service calls
+// when URLHandler.Timeout is not set.
+const DefaultTimeout = 5 * time.Second
+
+// Page sizes of ListURLs.
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
 )
 
 /*
@@ -14,13 +32,15 @@ URLHandler provides HTTP endpoints for managing shortened URLs.
 */
 type URLHandler struct {
 	Service service.URLServiceInterface
+	// Timeout bounds every service call made while handling a request.
+	Timeout time.Duration
 }
 
 /*
 NewURLHandler creates a new instance of URLHandler.
 */
 func NewURLHandler(s service.URLServiceInterface) *URLHandler {
-	return &URLHandler{Service: s}
+	return &URLHandler{Service: s, Timeout: DefaultTimeout}
 }
 
 /*
@@ -29,8 +49,16 @@ RegisterRoutes registers all URL-related routes to the given router.
 func (h *URLHandler) RegisterRoutes(r chi.Router) {
 	// URL routes
 	r.Post("/urls", h.CreateShortURL)
+	r.Get("/urls", h.ListURLs)
 	r.Get("/urls/{short}", h.GetOriginalURL)
 	r.Delete("/urls/{short}", h.DeleteURL)
+	r.Get("/urls/{short}/rules", h.GetRules)
+	r.Put("/urls/{short}/rules", h.UpdateRules)
+	r.Put("/urls/{short}/variants", h.UpdateVariants)
+	r.Put("/urls/{short}/options", h.UpdateOptions)
+	r.Get("/urls/{short}/stats", h.GetStats)
+	r.Get("/urls/{short}/health", h.GetHealth)
+	r.Get("/urls/{short}/events", h.ClickEvents)
 }
 
 /*
@@ -43,32 +71,68 @@ Expected JSON body: {"original": "https://example.com"}
 // @Tags URLs
 // @Accept json
 // @Produce json
-// @Param url body map[string]string true "Original URL" example({"original": "https://example.com"})
+// @Param url body map[string]interface{} true "Original URL with optional branded domain, password, click limit, redirect rules, A/B variants and redirect options" example({"original": "https://example.com", "domain": "brand.link", "password": "s3cret", "maxClicks": 1, "rules": [{"destination": "https://apps.apple.com/app/id123", "os": ["ios"]}], "variants": [{"destination": "https://example.com/a", "weight": 70}, {"destination": "https://example.com/b", "weight": 30}], "options": {"forwardQuery": "merge", "passPath": true, "utm": {"source": "newsletter", "content": "{variant}"}}})
 // @Success 201 {object} model.URL "Successfully created"
 // @Failure 400 {string} string "invalid JSON"
+// @Failure 422 {string} string "destination is blocked"
 // @Failure 500 {string} string "internal server error"
+// @Failure 503 {string} string "request canceled"
+// @Failure 504 {string} string "request timed out"
 // @Router /urls [post]
 func (h *URLHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 	type request struct {
-		Original string `json:"original"`
+		Original  string                `json:"original"`
+		Domain    string                `json:"domain,omitempty"`
+		Password  string                `json:"password,omitempty"`
+		MaxClicks *int                  `json:"maxClicks,omitempty"`
+		Rules     model.Rules           `json:"rules,omitempty"`
+		Variants  model.Variants        `json:"variants,omitempty"`
+		Options   model.RedirectOptions `json:"options,omitzero"`
 	}
 
 	var req request
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		writeError(w, r, "invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	url, err := h.Service.CreateShortURL(req.Original)
+	ctx, cancel := h.requestContext(r)
+	defer cancel()
+
+	var opts []service.CreateOption
+	if req.Domain != "" {
+		opts = append(opts, service.WithDomain(req.Domain))
+	}
+	if req.Password != "" {
+		opts = append(opts, service.WithPassword(req.Password))
+	}
+	if req.MaxClicks != nil {
+		opts = append(opts, service.WithMaxClicks(*req.MaxClicks))
+	}
+	if len(req.Rules) > 0 {
+		opts = append(opts, service.WithRules(req.Rules))
+	}
+	if len(req.Variants) > 0 {
+		opts = append(opts, service.WithVariants(req.Variants))
+	}
+	if req.Options != (model.RedirectOptions{}) {
+		opts = append(opts, service.WithRedirectOptions(req.Options))
+	}
+	if p := auth.PrincipalFromContext(r.Context()); p != nil {
+		opts = append(opts, service.WithOwner(p.DisplayName()))
+	}
+
+	url, err := h.Service.CreateShortURL(ctx, req.Original, opts...)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, r, err)
 		return
 	}
+	metrics.RecordCreated(ctx, metrics.SourceAPI)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(url); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -77,43 +141,514 @@ GetOriginalURL handles GET /urls/{short} requests and returns the original URL.
 */
 // GetOriginalURL handles GET /urls/{short} requests.
 // @Summary Get original URL
-// @Description Retrieve the original URL by short code
+// @Description Retrieve the original URL by short code. The destinations of a password-protected link are left out unless the caller owns the link or is an admin.
 // @Tags URLs
 // @Produce json
 // @Param short path string true "Short code" example("abc123")
+// @Param domain query string false "Branded domain of the link; the default domain if empty" example("brand.link")
 // @Success 200 {object} model.URL "Original URL retrieved successfully"
 // @Failure 404 {string} string "URL not found"
+// @Failure 503 {string} string "request canceled"
+// @Failure 504 {string} string "request timed out"
 // @Router /urls/{short} [get]
 func (h *URLHandler) GetOriginalURL(w http.ResponseWriter, r *http.Request) {
-	short := chi.URLParam(r, "short")
-	url, err := h.Service.GetOriginalURL(short)
+	ctx, cancel := h.requestContext(r)
+	defer cancel()
+
+	domain, short := linkKey(r)
+	url, err := h.Service.GetOriginalURL(ctx, domain, short)
 	if err != nil {
-		http.Error(w, "URL not found", http.StatusNotFound)
+		if errors.Is(err, service.ErrNotFound) {
+			metrics.RecordNotFound(ctx)
+		}
+		writeServiceError(w, r, err)
 		return
 	}
+	hideDestinations(r, url)
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(url); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+/*
+URLList is a page of links with the total number of matches.
+*/
+type URLList struct {
+	URLs  []model.URL `json:"urls"`
+	Total int         `json:"total" example:"42"`
+}
+
+// ListURLs handles GET /urls requests.
+// @Summary List shortened URLs
+// @Description List links of all domains, newest first, optionally only those whose original URL or short code contains q. Requires a token; admins see the links of all owners, everyone else only their own. Where password-protected links lead is hidden from callers other than their owner and admins.
+// @Tags URLs
+// @Produce json
+// @Security AdminToken
+// @Param q query string false "Text to search for in the original URL or short code" example("example.com")
+// @Param limit query int false "Maximum number of links, up to 100" default(20)
+// @Param offset query int false "Number of links to skip" default(0)
+// @Success 200 {object} handler.URLList "Links and the total number of matches"
+// @Failure 400 {string} string "limit must be a positive number"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 503 {string} string "request canceled"
+// @Failure 504 {string} string "request timed out"
+// @Router /urls [get]
+func (h *URLHandler) ListURLs(w http.ResponseWriter, r *http.Request) {
+	p, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	limit, offset := DefaultListLimit, 0
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, r, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, MaxListLimit)
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, "offset must not be negative", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	ctx, cancel := h.requestContext(r)
+	defer cancel()
+
+	urls, total, err := h.Service.ListURLs(ctx, p.ListOwner(), q.Get("q"), limit, offset)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if urls == nil {
+		urls = []model.URL{}
+	}
+	for i := range urls {
+		hideDestinations(r, &urls[i])
+	}
+	writeJSON(w, http.StatusOK, URLList{URLs: urls, Total: total})
+}
+
 /*
 DeleteURL handles DELETE /urls/{short} requests and deletes a shortened URL.
 */
 // DeleteURL handles DELETE /urls/{short} requests.
 // @Summary Delete a shortened URL
-// @Description Remove a short URL by its code
+// @Description Remove a short URL by its code. Only the owner of the link and admins may delete it.
 // @Tags URLs
+// @Security AdminToken
 // @Param short path string true "Short code" example("abc123")
+// @Param domain query string false "Branded domain of the link; the default domain if empty" example("brand.link")
 // @Success 204 {string} string "No Content"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "only the owner of a link may change it"
+// @Failure 404 {string} string "URL not found"
 // @Failure 500 {string} string "failed to delete URL"
+// @Failure 503 {string} string "request canceled"
+// @Failure 504 {string} string "request timed out"
 // @Router /urls/{short} [delete]
 func (h *URLHandler) DeleteURL(w http.ResponseWriter, r *http.Request) {
-	short := chi.URLParam(r, "short")
-	if err := h.Service.DeleteURL(short); err != nil {
-		http.Error(w, "failed to delete URL", http.StatusInternalServerError)
+	ctx, cancel := h.requestContext(r)
+	defer cancel()
+	if h.ownedURL(ctx, w, r) == nil {
+		return
+	}
+
+	domain, short := linkKey(r)
+	if err := h.Service.DeleteURL(ctx, domain, short); err != nil {
+		if errors.Is(err, service.ErrNotFound) || contextStatus(err) != 0 {
+			writeServiceError(w, r, err)
+			return
+		}
+		writeError(w, r, "failed to delete URL", http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// GetRules handles GET /urls/{short}/rules requests.
+// @Summary Get redirect rules
+// @Description List the conditional redirect rules of a short link in evaluation order
+// @Tags URLs
+// @Produce json
+// @Param short path string true "Short code" example("abc123")
+// @Param domain query string false "Branded domain of the link; the default domain if empty" example("brand.link")
+// @Success 200 {array} model.Rule "Rules, empty if the link always redirects to the original URL"
+// @Failure 403 {string} string "rules of a password-protected link are only shown to its owner"
+// @Failure 404 {string} string "URL not found"
+// @Router /urls/{short}/rules [get]
+func (h *URLHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.requestContext(r)
+	defer cancel()
+
+	domain, short := linkKey(r)
+	url, err := h.Service.GetOriginalURL(ctx, domain, short)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if hideDestinations(r, url) {
+		writeError(w, r, "rules of a password-protected link are only shown to its owner", http.StatusForbidden)
+		return
+	}
+	if url.Rules == nil {
+		url.Rules = model.Rules{}
+	}
+	writeJSON(w, http.StatusOK, url.Rules)
+}
+
+// UpdateRules handles PUT /urls/{short}/rules requests.
+// @Summary Replace redirect rules
+// @Description Replace the ordered redirect rules of a short link. The first matching rule wins; the original URL is the fallback. An empty array removes all rules.
+// @Tags URLs
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param short path string true "Short code" example("abc123")
+// @Param domain query string false "Branded domain of the link; the default domain if empty" example("brand.link")
+// @Param rules body []model.Rule true "Rules in evaluation order"
+// @Success 200 {object} model.URL "Updated URL"
+// @Failure 400 {string} string "invalid redirect rules"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "only the owner of a link may change it"
+// @Failure 404 {string} string "URL not found"
+// @Failure 422 {string} string "destination is blocked"
+// @Router /urls/{short}/rules [put]
+func (h *URLHandler) UpdateRules(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.requestContext(r)
+	defer cancel()
+	if h.ownedURL(ctx, w, r) == nil {
+		return
+	}
+
+	var rs model.Rules
+	if err := json.NewDecoder(r.Body).Decode(&rs); err != nil {
+		writeError(w, r, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	domain, short := linkKey(r)
+	url, err := h.Service.UpdateRules(ctx, domain, short, rs)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, url)
+}
+
+// UpdateVariants handles PUT /urls/{short}/variants requests.
+// @Summary Replace A/B variants
+// @Description Split the traffic of a short link across weighted destinations. Visitors stick to their variant. Replacing the variants resets their click counts; an empty array removes the split.
+// @Tags URLs
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param short path string true "Short code" example("abc123")
+// @Param domain query string false "Branded domain of the link; the default domain if empty" example("brand.link")
+// @Param variants body []model.Variant true "Variants with weights"
+// @Success 200 {object} model.URL "Updated URL"
+// @Failure 400 {string} string "invalid A/B variants"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "only the owner of a link may change it"
+// @Failure 404 {string} string "URL not found"
+// @Failure 422 {string} string "destination is blocked"
+// @Router /urls/{short}/variants [put]
+func (h *URLHandler) UpdateVariants(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.requestContext(r)
+	defer cancel()
+	if h.ownedURL(ctx, w, r) == nil {
+		return
+	}
+
+	var vs model.Variants
+	if err := json.NewDecoder(r.Body).Decode(&vs); err != nil {
+		writeError(w, r, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	domain, short := linkKey(r)
+	url, err := h.Service.UpdateVariants(ctx, domain, short, vs)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, url)
+}
+
+// UpdateOptions handles PUT /urls/{short}/options requests.
+// @Summary Replace redirect options
+// @Description Configure query string forwarding (merge or override), UTM parameters with placeholders and path suffix pass-through. An empty object turns them all off.
+// @Tags URLs
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param short path string true "Short code" example("abc123")
+// @Param domain query string false "Branded domain of the link; the default domain if empty" example("brand.link")
+// @Param options body model.RedirectOptions true "Redirect options"
+// @Success 200 {object} model.URL "Updated URL"
+// @Failure 400 {string} string "invalid redirect options"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "only the owner of a link may change it"
+// @Failure 404 {string} string "URL not found"
+// @Router /urls/{short}/options [put]
+func (h *URLHandler) UpdateOptions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.requestContext(r)
+	defer cancel()
+	if h.ownedURL(ctx, w, r) == nil {
+		return
+	}
+
+	var o model.RedirectOptions
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		writeError(w, r, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	domain, short := linkKey(r)
+	url, err := h.Service.UpdateOptions(ctx, domain, short, o)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, url)
+}
+
+// GetStats handles GET /urls/{short}/stats requests.
+// @Summary Get link statistics
+// @Description Click counts of a short link, including every A/B variant. The variant destinations of a password-protected link are left out unless the caller owns the link or is an admin.
+// @Tags URLs
+// @Produce json
+// @Param short path string true "Short code" example("abc123")
+// @Param domain query string false "Branded domain of the link; the default domain if empty" example("brand.link")
+// @Success 200 {object} model.Stats "Statistics"
+// @Failure 404 {string} string "URL not found"
+// @Router /urls/{short}/stats [get]
+func (h *URLHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.requestContext(r)
+	defer cancel()
+
+	domain, short := linkKey(r)
+	stats, err := h.Service.Stats(ctx, domain, short)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	url, err := h.Service.GetOriginalURL(ctx, domain, short)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if hideDestinations(r, url) {
+		stats.HideDestinations()
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// GetHealth handles GET /urls/{short}/health requests.
+// @Summary Get link health
+// @Description Result of the latest periodic check of the link destinations: status, latency and redirect chain of every destination. A link is broken after several consecutive failed checks. checkedAt is empty until the first check. The destinations of a password-protected link are only shown to its owner and admins.
+// @Tags URLs
+// @Produce json
+// @Param short path string true "Short code" example("abc123")
+// @Param domain query string false "Branded domain of the link; the default domain if empty" example("brand.link")
+// @Success 200 {object} model.LinkHealth "Health"
+// @Failure 404 {string} string "URL not found"
+// @Router /urls/{short}/health [get]
+func (h *URLHandler) GetHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.requestContext(r)
+	defer cancel()
+
+	domain, short := linkKey(r)
+	health, err := h.Service.LinkHealth(ctx, domain, short)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	url, err := h.Service.GetOriginalURL(ctx, domain, short)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if hideDestinations(r, url) {
+		health.HideDestinations()
+	}
+	writeJSON(w, http.StatusOK, health)
+}
+
+// ClickEvents handles GET /urls/{short}/events requests.
+// @Summary Stream the clicks of a link
+// @Description Live feed of the redirects of a short link, starting now, as server-sent events ("click" events with a Click as data) or, with an Upgrade: websocket request, as WebSocket text messages with a Click each. Requires the token of the owner of the link or an admin; browsers may pass it in the access_token query parameter, since EventSource and WebSocket cannot set headers. A client that falls behind gets a "dropped" event or close status 1008 and is disconnected; on shutdown it gets a "closed" event or close status 1001.
+// @Tags URLs
+// @Produce text/event-stream
+// @Security AdminToken
+// @Param short path string true "Short code" example("abc123")
+// @Param domain query string false "Branded domain of the link; the default domain if empty" example("brand.link")
+// @Param access_token query string false "Token for clients that cannot set headers"
+// @Success 200 {string} string "Stream of clicks"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "clicks of a link are only streamed to its owner"
+// @Failure 404 {string} string "URL not found"
+// @Router /urls/{short}/events [get]
+func (h *URLHandler) ClickEvents(w http.ResponseWriter, r *http.Request) {
+	p, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := h.requestContext(r)
+	domain, short := linkKey(r)
+	url, err := h.Service.GetOriginalURL(ctx, domain, short)
+	if err != nil {
+		cancel()
+		writeServiceError(w, r, err)
+		return
+	}
+	if !p.Owns(url.Owner) {
+		cancel()
+		writeError(w, r, "clicks of a link are only streamed to its owner", http.StatusForbidden)
+		return
+	}
+	sub, err := h.Service.SubscribeClicks(ctx, domain, short)
+	cancel()
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	defer sub.Close()
+	streamClicks(w, r, sub)
+}
+
+/*
+hideDestinations hides where a password-protected link leads from callers other than its owner
+and admins, like the prompt does until the link is unlocked. It reports whether it did.
+*/
+func hideDestinations(r *http.Request, url *model.URL) bool {
+	if !url.Protected || auth.PrincipalFromContext(r.Context()).Owns(url.Owner) {
+		return false
+	}
+	url.HideDestinations()
+	return true
+}
+
+/*
+ownedURL loads the link addressed by the request before it is changed. Unless the caller owns
+the link or is an admin, it writes the error response and returns nil.
+*/
+func (h *URLHandler) ownedURL(ctx context.Context, w http.ResponseWriter, r *http.Request) *model.URL {
+	p, ok := requirePrincipal(w, r)
+	if !ok {
+		return nil
+	}
+	domain, short := linkKey(r)
+	url, err := h.Service.GetOriginalURL(ctx, domain, short)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return nil
+	}
+	if !p.Owns(url.Owner) {
+		writeError(w, r, "only the owner of a link may change it", http.StatusForbidden)
+		return nil
+	}
+	return url
+}
+
+/*
+requirePrincipal returns the authenticated caller, or writes 401 for anonymous requests.
+*/
+func requirePrincipal(w http.ResponseWriter, r *http.Request) (*auth.Principal, bool) {
+	p := auth.PrincipalFromContext(r.Context())
+	if p == nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, r, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return p, true
+}
+
+/*
+linkKey returns the domain and short code addressing a link in the API.
+The domain is given as the domain query parameter; without it the default domain is used.
+*/
+func linkKey(r *http.Request) (domain, short string) {
+	return service.NormalizeHost(r.URL.Query().Get("domain")), chi.URLParam(r, "short")
+}
+
+/*
+requestContext derives a context bounded by the handler timeout from the incoming request.
+*/
+func (h *URLHandler) requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return requestContext(r, h.Timeout)
+}
+
+/*
+requestContext derives a context bounded by timeout from the incoming request.
+The request context is already canceled when the client disconnects.
+*/
+func requestContext(r *http.Request, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+/*
+writeServiceError maps errors returned by the service layer to HTTP responses.
+*/
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		writeError(w, r, "URL not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrGone):
+		writeError(w, r, err.Error(), http.StatusGone)
+	case errors.Is(err, service.ErrEmptyURL), errors.Is(err, service.ErrInvalidURL), errors.Is(err, service.ErrPasswordLong), errors.Is(err, service.ErrMaxClicks),
+		errors.Is(err, service.ErrInvalidRules), errors.Is(err, service.ErrInvalidSplit),
+		errors.Is(err, service.ErrInvalidOpts), errors.Is(err, service.ErrInvalidDomain), errors.Is(err, service.ErrDomainNotFound),
+		errors.Is(err, service.ErrInvalidWebhook):
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrWebhookNotFound), errors.Is(err, service.ErrDeliveryNotFound):
+		writeError(w, r, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrDomainExists), errors.Is(err, service.ErrDomainInUse):
+		writeError(w, r, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrBlocked):
+		writeError(w, r, err.Error(), http.StatusUnprocessableEntity)
+	case contextStatus(err) != 0:
+		code := contextStatus(err)
+		writeError(w, r, http.StatusText(code), code)
+	default:
+		writeError(w, r, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+/*
+writeError writes a plain-text error response.
+The trace ID of the request is appended so that a reported error can be found in the traces.
+*/
+func writeError(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	if traceID := tracing.TraceID(r.Context()); traceID != "" {
+		msg = fmt.Sprintf("%s (trace_id: %s)", msg, traceID)
+	}
+	http.Error(w, msg, code)
+}
+
+/*
+contextStatus returns the HTTP status for context errors, or 0 if err is not one.
+A missed deadline becomes 504 Gateway Timeout, a canceled request 503 Service Unavailable.
+*/
+func contextStatus(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	default:
+		return 0
+	}
+}
@@ -2,35 +2,36 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jmoiron/sqlx"
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/clickfeed"
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/linkcheck"
+	"github.com/zen-flo/url-shortener/internal/model"
 	"github.com/zen-flo/url-shortener/internal/service"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	_ "modernc.org/sqlite" // SQLite driver
 )
 
 func setupRouter(t *testing.T) *chi.Mux {
 	// Initializing the in-memory database
-	db, err := sqlx.Connect("sqlite", ":memory:")
+	database, err := db.InitDB(":memory:")
 	if err != nil {
 		t.Fatalf("failed to connect to in-memory DB: %v", err)
 	}
-	schema := `
-	CREATE TABLE urls (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		original TEXT NOT NULL,
-		short TEXT NOT NULL UNIQUE,
-		created_at DATETIME NOT NULL
-	);`
-	if _, err := db.Exec(schema); err != nil {
-		t.Fatalf("failed to create table: %v", err)
-	}
+	t.Cleanup(func() { _ = database.Close() })
 
-	urlService := service.NewURLService(db)
+	urlService := service.NewURLService(database)
 	urlHandler := NewURLHandler(urlService)
 
 	r := chi.NewRouter()
@@ -38,6 +39,11 @@ func setupRouter(t *testing.T) *chi.Mux {
 	return r
 }
 
+// asAdmin authenticates req as the admin token does.
+func asAdmin(req *http.Request) *http.Request {
+	return req.WithContext(auth.WithPrincipal(req.Context(), auth.TokenPrincipal()))
+}
+
 func TestURLHandler(t *testing.T) {
 	router := setupRouter(t)
 
@@ -84,7 +90,7 @@ func TestURLHandler(t *testing.T) {
 	}
 
 	// Test DeleteURL
-	req = httptest.NewRequest(http.MethodDelete, "/urls/"+short, nil)
+	req = asAdmin(httptest.NewRequest(http.MethodDelete, "/urls/"+short, nil))
 	rec = httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
 
@@ -100,3 +106,363 @@ func TestURLHandler(t *testing.T) {
 		t.Fatalf("expected status 404 after deletion, got %d", rec.Code)
 	}
 }
+
+// slowService blocks until the request context is done.
+type slowService struct{}
+
+func (s *slowService) CreateShortURL(ctx context.Context, _ string, _ ...service.CreateOption) (*model.URL, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *slowService) GetOriginalURL(ctx context.Context, _, _ string) (*model.URL, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *slowService) DeleteURL(ctx context.Context, _, _ string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *slowService) UpdateRules(ctx context.Context, _, _ string, _ model.Rules) (*model.URL, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *slowService) UpdateVariants(ctx context.Context, _, _ string, _ model.Variants) (*model.URL, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *slowService) UpdateOptions(ctx context.Context, _, _ string, _ model.RedirectOptions) (*model.URL, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *slowService) Stats(ctx context.Context, _, _ string) (*model.Stats, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *slowService) LinkHealth(ctx context.Context, _, _ string) (*model.LinkHealth, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *slowService) ListURLs(ctx context.Context, _, _ string, _, _ int) ([]model.URL, int, error) {
+	<-ctx.Done()
+	return nil, 0, ctx.Err()
+}
+
+func (s *slowService) SubscribeClicks(ctx context.Context, _, _ string) (*clickfeed.Subscription, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *slowService) RegisterClick(ctx context.Context, _, _ string, _ int) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *slowService) ResolveDomain(ctx context.Context, _ string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func (s *slowService) UpdateURLCount(_ context.Context) {}
+
+func TestURLHandlerTimeout(t *testing.T) {
+	h := NewURLHandler(&slowService{})
+	h.Timeout = 10 * time.Millisecond
+
+	r := chi.NewRouter()
+	h.RegisterRoutes(r)
+
+	req := httptest.NewRequest(http.MethodGet, "/urls/abc123", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected status 504, got %d", rec.Code)
+	}
+}
+
+func TestURLHandlerCanceled(t *testing.T) {
+	h := NewURLHandler(&slowService{})
+
+	r := chi.NewRouter()
+	h.RegisterRoutes(r)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := asAdmin(httptest.NewRequest(http.MethodDelete, "/urls/abc123", nil).WithContext(ctx))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", rec.Code)
+	}
+}
+
+func TestCreateShortURLOwner(t *testing.T) {
+	router := setupRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/urls", strings.NewReader(`{"original":"https://example.com"}`))
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Name: "key:marketing"}))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var created model.URL
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || created.Owner != "marketing" {
+		t.Fatalf("expected the key name as the owner, got %s (err %v)", rec.Body.String(), err)
+	}
+}
+
+func TestOnlyOwnerChangesLink(t *testing.T) {
+	router := setupRouter(t)
+	marketing := &auth.Principal{Name: "key:marketing"}
+	sales := &auth.Principal{Name: "key:sales"}
+
+	do := func(p *auth.Principal, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if p != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var created model.URL
+	rec := do(marketing, http.MethodPost, "/urls", `{"original":"https://example.com"}`)
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	changes := []struct{ method, path, body string }{
+		{http.MethodPut, "/urls/" + created.Short + "/rules", `[]`},
+		{http.MethodPut, "/urls/" + created.Short + "/variants", `[]`},
+		{http.MethodPut, "/urls/" + created.Short + "/options", `{}`},
+		{http.MethodDelete, "/urls/" + created.Short, ``},
+	}
+	for _, c := range changes {
+		if rec := do(nil, c.method, c.path, c.body); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: expected 401 without a token, got %d", c.method, c.path, rec.Code)
+		}
+		if rec := do(sales, c.method, c.path, c.body); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s: expected 403 for another key, got %d", c.method, c.path, rec.Code)
+		}
+		want := http.StatusOK
+		if c.method == http.MethodDelete {
+			want = http.StatusNoContent
+		}
+		if rec := do(marketing, c.method, c.path, c.body); rec.Code != want {
+			t.Errorf("%s %s: expected %d for the owner, got %d %s", c.method, c.path, want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestGetHealth(t *testing.T) {
+	router := setupRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/urls", strings.NewReader(`{"original":"https://example.com"}`)))
+	var created model.URL
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/urls/"+created.Short+"/health", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"broken":false`) {
+		t.Errorf("expected an unchecked link to be reported healthy, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/urls/missing/health", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown link, got %d", rec.Code)
+	}
+}
+
+func TestProtectedLinkHealthHidden(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	database, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to connect to in-memory DB: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+	svc := service.NewURLService(database, service.WithLinkChecker(linkcheck.New(linkcheck.Config{AllowPrivate: true, HostDelay: -1}), 2))
+	router := chi.NewRouter()
+	NewURLHandler(svc).RegisterRoutes(router)
+
+	ctx := context.Background()
+	link, err := svc.CreateShortURL(ctx, srv.URL+"/secret", service.WithPassword("s3cret"), service.WithOwner("marketing"))
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	if _, err := svc.CheckLinks(ctx); err != nil {
+		t.Fatalf("CheckLinks failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		principal *auth.Principal
+		wantShown bool
+	}{
+		{"anonymous", nil, false},
+		{"other key", &auth.Principal{Name: "key:sales"}, false},
+		{"owner", &auth.Principal{Name: "key:marketing"}, true},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/urls/"+link.Short+"/health", nil)
+		if tt.principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), tt.principal))
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "/secret") != tt.wantShown {
+			t.Errorf("%s: expected 200 with the destination shown=%v, got %d %s", tt.name, tt.wantShown, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestListURLs(t *testing.T) {
+	router := setupRouter(t)
+	admin := &auth.Principal{Name: "admin-token", Scopes: []string{auth.ScopeAdmin}}
+	list := func(query string, p *auth.Principal) (*httptest.ResponseRecorder, URLList) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/urls?"+query, nil)
+		if p != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		var list URLList
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+				t.Fatalf("invalid list %s: %v", rec.Body.String(), err)
+			}
+		}
+		return rec, list
+	}
+
+	for _, original := range []string{"https://example.com/a", "https://example.com/b", "https://other.example/c"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/urls", strings.NewReader(`{"original":"`+original+`"}`)))
+	}
+
+	rec, page := list("q=example.com&limit=1", admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with a list, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if page.Total != 2 || len(page.URLs) != 1 || page.URLs[0].Original != "https://example.com/b" {
+		t.Errorf("expected the newest of two matches, got %+v", page)
+	}
+
+	if rec, _ := list("", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", rec.Code)
+	}
+
+	// Keys see only their own links, and no destinations of protected links
+	create := httptest.NewRequest(http.MethodPost, "/urls", strings.NewReader(`{"original":"https://secret.example/x","password":"s3cret"}`))
+	create = create.WithContext(auth.WithPrincipal(create.Context(), &auth.Principal{Name: "key:marketing"}))
+	router.ServeHTTP(httptest.NewRecorder(), create)
+	if _, page := list("", &auth.Principal{Name: "key:marketing"}); page.Total != 1 || page.URLs[0].Original != "https://secret.example/x" {
+		t.Errorf("expected the owner to see their own link, got %+v", page)
+	}
+	if _, page := list("", &auth.Principal{Name: "key:sales"}); page.Total != 0 {
+		t.Errorf("expected no links for another key, got %+v", page)
+	}
+	if _, page := list("", admin); page.Total != 4 || page.URLs[0].Original != "https://secret.example/x" {
+		t.Errorf("expected admins to see all links, got %+v", page)
+	}
+
+	for _, query := range []string{"limit=0", "limit=x", "offset=-1"} {
+		if rec, _ := list(query, admin); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestCreateShortURLEmpty(t *testing.T) {
+	router := setupRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/urls", strings.NewReader(`{"original":""}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestErrorResponseContainsTraceID(t *testing.T) {
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(tracetest.NewInMemoryExporter()))
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(sdktrace.NewTracerProvider()) })
+
+	router := setupRouter(t)
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+
+	req := httptest.NewRequest(http.MethodGet, "/urls/missing", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rec.Code)
+	}
+	traceID := span.SpanContext().TraceID().String()
+	if !strings.Contains(rec.Body.String(), traceID) {
+		t.Errorf("expected body to contain trace ID %s, got %q", traceID, rec.Body.String())
+	}
+}
+
+func TestProtectedLinkDestinationsHidden(t *testing.T) {
+	router := setupRouter(t)
+
+	create := httptest.NewRequest(http.MethodPost, "/urls", strings.NewReader(`{"original":"https://secret.example/x","password":"s3cret",`+
+		`"variants":[{"destination":"https://secret.example/a","weight":1},{"destination":"https://secret.example/b","weight":1}]}`))
+	create = create.WithContext(auth.WithPrincipal(create.Context(), &auth.Principal{Name: "key:marketing"}))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, create)
+	var created model.URL
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || !created.Protected {
+		t.Fatalf("expected a protected link, got %s (err %v)", rec.Body.String(), err)
+	}
+
+	admin := &auth.Principal{Name: "admin-token", Scopes: []string{auth.ScopeAdmin}}
+	owner := &auth.Principal{Name: "key:marketing"}
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		path      string
+		wantCode  int
+		wantShown bool
+	}{
+		{"anonymous", nil, "/urls/" + created.Short, http.StatusOK, false},
+		{"other key", &auth.Principal{Name: "key:sales"}, "/urls/" + created.Short, http.StatusOK, false},
+		{"owner", owner, "/urls/" + created.Short, http.StatusOK, true},
+		{"admin", admin, "/urls/" + created.Short, http.StatusOK, true},
+		{"anonymous rules", nil, "/urls/" + created.Short + "/rules", http.StatusForbidden, false},
+		{"owner rules", owner, "/urls/" + created.Short + "/rules", http.StatusOK, false},
+		{"anonymous stats", nil, "/urls/" + created.Short + "/stats", http.StatusOK, false},
+		{"owner stats", owner, "/urls/" + created.Short + "/stats", http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), tt.principal))
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.wantCode || strings.Contains(rec.Body.String(), "secret.example") != tt.wantShown {
+			t.Errorf("%s: expected %d with the destination shown=%v, got %d %s", tt.name, tt.wantCode, tt.wantShown, rec.Code, rec.Body.String())
+		}
+	}
+}
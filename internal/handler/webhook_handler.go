@@ -0,0 +1,318 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/service"
+)
+
+// Page sizes of ListDeliveries.
+const (
+	DefaultDeliveriesLimit = 50
+	MaxDeliveriesLimit     = 500
+)
+
+/*
+WebhookHandler provides administrative endpoints for webhook subscriptions and their deliveries.
+*/
+type WebhookHandler struct {
+	Service *service.URLService
+}
+
+/*
+NewWebhookHandler creates a new instance of WebhookHandler.
+*/
+func NewWebhookHandler(s *service.URLService) *WebhookHandler {
+	return &WebhookHandler{Service: s}
+}
+
+/*
+RegisterRoutes registers the webhook routes. The caller mounts them behind admin authentication.
+*/
+func (h *WebhookHandler) RegisterRoutes(r chi.Router) {
+	r.Post("/webhooks", h.CreateWebhook)
+	r.Get("/webhooks", h.ListWebhooks)
+	r.Get("/webhooks/{id}", h.GetWebhook)
+	r.Put("/webhooks/{id}", h.UpdateWebhook)
+	r.Delete("/webhooks/{id}", h.DeleteWebhook)
+	r.Get("/webhooks/{id}/deliveries", h.ListDeliveries)
+	r.Post("/webhooks/{id}/redeliver", h.RedeliverDead)
+	r.Post("/webhooks/deliveries/{id}/redeliver", h.Redeliver)
+}
+
+/*
+CreatedWebhook is the response to webhook creation. Secret signs the payloads and is never shown again.
+*/
+type CreatedWebhook struct {
+	model.Webhook
+	Secret string `json:"secret" example:"whsec_Jx9pQ2..."`
+}
+
+/*
+webhookRequest is the body of CreateWebhook and UpdateWebhook.
+*/
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+// CreateWebhook handles POST /admin/webhooks requests.
+// @Summary Subscribe to link events
+// @Description Deliver link events to a URL as signed POST requests: link.created, link.updated, link.deleted, link.expired, link.clicked, link.broken and link.recovered, or "*" for all. The secret for verifying the X-Webhook-Signature header is returned only once.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param webhook body map[string]interface{} true "Endpoint and event types" example({"url": "https://crm.example.com/hooks/links", "events": ["link.created", "link.deleted"]})
+// @Success 201 {object} handler.CreatedWebhook "Webhook created"
+// @Failure 400 {string} string "invalid webhook"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Router /admin/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.Service.CreateWebhook(r.Context(), req.URL, req.Events)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "webhook created", "id", hook.ID, "url", hook.URL, "events", hook.Events)
+	writeJSON(w, http.StatusCreated, CreatedWebhook{Webhook: *hook, Secret: hook.Secret})
+}
+
+// ListWebhooks handles GET /admin/webhooks requests.
+// @Summary List webhooks
+// @Description List all webhook subscriptions. Secrets are never returned.
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Success 200 {array} model.Webhook "Webhooks"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Router /admin/webhooks [get]
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.Service.ListWebhooks(r.Context())
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if webhooks == nil {
+		webhooks = []model.Webhook{}
+	}
+	writeJSON(w, http.StatusOK, webhooks)
+}
+
+// GetWebhook handles GET /admin/webhooks/{id} requests.
+// @Summary Get a webhook
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} model.Webhook "Webhook"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "webhook not found"
+// @Router /admin/webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r, service.ErrWebhookNotFound)
+	if !ok {
+		return
+	}
+	hook, err := h.Service.GetWebhook(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, hook)
+}
+
+// UpdateWebhook handles PUT /admin/webhooks/{id} requests.
+// @Summary Update a webhook
+// @Description Change the URL, event types or active flag of a subscription; fields left out keep their values. Events are not queued for an inactive subscription, and deliveries queued before wait until it is active again.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "Webhook ID"
+// @Param webhook body map[string]interface{} true "Fields to change" example({"events": ["*"], "active": false})
+// @Success 200 {object} model.Webhook "Updated webhook"
+// @Failure 400 {string} string "invalid webhook"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "webhook not found"
+// @Router /admin/webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r, service.ErrWebhookNotFound)
+	if !ok {
+		return
+	}
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.Service.GetWebhook(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if req.URL == "" {
+		req.URL = hook.URL
+	}
+	if req.Events == nil {
+		req.Events = hook.Events
+	}
+	if req.Active == nil {
+		req.Active = &hook.Active
+	}
+
+	hook, err = h.Service.UpdateWebhook(r.Context(), id, req.URL, req.Events, *req.Active)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "webhook updated", "id", hook.ID, "url", hook.URL, "events", hook.Events, "active", hook.Active)
+	writeJSON(w, http.StatusOK, hook)
+}
+
+// DeleteWebhook handles DELETE /admin/webhooks/{id} requests.
+// @Summary Delete a webhook
+// @Description Delete a subscription together with its queued, delivered and dead deliveries
+// @Tags Admin
+// @Security AdminToken
+// @Param id path int true "Webhook ID"
+// @Success 204 {string} string "No Content"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "webhook not found"
+// @Router /admin/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r, service.ErrWebhookNotFound)
+	if !ok {
+		return
+	}
+	if err := h.Service.DeleteWebhook(r.Context(), id); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "webhook deleted", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries handles GET /admin/webhooks/{id}/deliveries requests.
+// @Summary List webhook deliveries
+// @Description List the latest deliveries of a webhook, newest first. status=dead lists the dead letters: deliveries given up after the last retry.
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "Webhook ID"
+// @Param status query string false "Only deliveries with this status" Enums(pending, delivered, dead)
+// @Param limit query int false "Maximum number of deliveries, up to 500" default(50)
+// @Success 200 {array} model.WebhookDelivery "Deliveries"
+// @Failure 400 {string} string "invalid webhook: unknown status"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "webhook not found"
+// @Router /admin/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r, service.ErrWebhookNotFound)
+	if !ok {
+		return
+	}
+	limit := DefaultDeliveriesLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, r, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, MaxDeliveriesLimit)
+	}
+
+	deliveries, err := h.Service.ListWebhookDeliveries(r.Context(), id, r.URL.Query().Get("status"), limit)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []model.WebhookDelivery{}
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// RedeliverDead handles POST /admin/webhooks/{id}/redeliver requests.
+// @Summary Redeliver dead webhook deliveries
+// @Description Queue all dead deliveries of a webhook again with a fresh set of retries, e.g. after the endpoint recovered from an outage
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} map[string]int "Number of deliveries queued" example({"queued": 12})
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "webhook not found"
+// @Router /admin/webhooks/{id}/redeliver [post]
+func (h *WebhookHandler) RedeliverDead(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r, service.ErrWebhookNotFound)
+	if !ok {
+		return
+	}
+	n, err := h.Service.RedeliverDeadWebhooks(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "dead webhook deliveries queued again", "id", id, "queued", n)
+	writeJSON(w, http.StatusOK, map[string]int{"queued": n})
+}
+
+// Redeliver handles POST /admin/webhooks/deliveries/{id}/redeliver requests.
+// @Summary Redeliver a webhook delivery
+// @Description Queue a delivery again with a fresh set of retries, whatever its status. The payload is unchanged, so the event ID stays the same.
+// @Tags Admin
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "Delivery ID"
+// @Success 200 {object} model.WebhookDelivery "Delivery queued"
+// @Failure 401 {string} string "unauthorized"
+// @Failure 403 {string} string "forbidden"
+// @Failure 404 {string} string "webhook delivery not found"
+// @Router /admin/webhooks/deliveries/{id}/redeliver [post]
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r, service.ErrDeliveryNotFound)
+	if !ok {
+		return
+	}
+	d, err := h.Service.RedeliverWebhook(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	logger.FromContext(r.Context()).InfoContext(r.Context(), "webhook delivery queued again", "delivery", id, "webhook", d.WebhookID)
+	writeJSON(w, http.StatusOK, d)
+}
+
+/*
+webhookID parses the {id} path parameter, writing notFound as the response if it is not a number.
+*/
+func webhookID(w http.ResponseWriter, r *http.Request, notFound error) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, notFound.Error(), http.StatusNotFound)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+func TestWebhooks(t *testing.T) {
+	r, svc := setupRedirect(t)
+	NewURLHandler(svc).RegisterRoutes(r)
+	NewWebhookHandler(svc).RegisterRoutes(r)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhooks",
+		strings.NewReader(`{"url":"https://crm.example.com/hooks","events":["link.created"]}`)))
+	var created CreatedWebhook
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 with the webhook, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.HasPrefix(created.Secret, "whsec_") {
+		t.Errorf("expected the secret in the response, got %s", rec.Body.String())
+	}
+
+	// Creating a link queues an event for the subscription
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/urls", strings.NewReader(`{"original":"https://example.com"}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	id := "/webhooks/" + strconv.Itoa(created.ID)
+	tests := []struct {
+		method, path, body string
+		wantStatus         int
+	}{
+		{http.MethodPost, "/webhooks", `{"url":"https://crm.example.com","events":["link.renamed"]}`, http.StatusBadRequest},
+		{http.MethodGet, "/webhooks", "", http.StatusOK},
+		{http.MethodGet, id, "", http.StatusOK},
+		{http.MethodGet, "/webhooks/999", "", http.StatusNotFound},
+		{http.MethodGet, "/webhooks/abc", "", http.StatusNotFound},
+		{http.MethodPut, id, `{"active":false}`, http.StatusOK},
+		{http.MethodPut, id, `{"url":"ftp://crm.example.com"}`, http.StatusBadRequest},
+		{http.MethodGet, id + "/deliveries?status=failed", "", http.StatusBadRequest},
+		{http.MethodGet, id + "/deliveries?limit=0", "", http.StatusBadRequest},
+		{http.MethodPost, id + "/redeliver", "", http.StatusOK},
+		{http.MethodPost, "/webhooks/deliveries/999/redeliver", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("expected %d for %s %s, got %d: %s", tt.wantStatus, tt.method, tt.path, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, id, nil))
+	if strings.Contains(rec.Body.String(), created.Secret) || !strings.Contains(rec.Body.String(), `"active":false`) {
+		t.Errorf("expected the inactive webhook without its secret, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, id+"/deliveries?status=pending", nil))
+	var deliveries []model.WebhookDelivery
+	if err := json.Unmarshal(rec.Body.Bytes(), &deliveries); err != nil || len(deliveries) != 1 {
+		t.Fatalf("expected one pending delivery, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var event model.WebhookEvent
+	if err := json.Unmarshal(deliveries[0].Payload, &event); err != nil || event.Event != "link.created" || event.Link == nil {
+		t.Errorf("unexpected payload %s", deliveries[0].Payload)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhooks/deliveries/"+strconv.Itoa(deliveries[0].ID)+"/redeliver", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"pending"`) {
+		t.Errorf("expected the delivery to be queued again, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, id, nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,83 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/zen-flo/url-shortener/internal/db"
+)
+
+/*
+DBCheck verifies that the database answers and that the write lock can be taken,
+which catches a database file that is locked by another process.
+*/
+func DBCheck(database *sqlx.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		if err := database.PingContext(ctx); err != nil {
+			return fmt.Errorf("ping: %w", err)
+		}
+
+		conn, err := database.Connx(ctx)
+		if err != nil {
+			return fmt.Errorf("acquire connection: %w", err)
+		}
+		defer conn.Close()
+
+		if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+			return fmt.Errorf("acquire write lock: %w", err)
+		}
+		// Roll back even if ctx expired, so the connection never returns to the pool inside a transaction
+		if _, err := conn.ExecContext(context.Background(), "ROLLBACK"); err != nil {
+			return fmt.Errorf("release write lock: %w", err)
+		}
+		return nil
+	})
+}
+
+/*
+MigrationCheck verifies that the database schema is at the version the code expects.
+*/
+func MigrationCheck(database *sqlx.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		version, err := db.SchemaVersion(ctx, database)
+		if err != nil {
+			return fmt.Errorf("read schema version: %w", err)
+		}
+		if want := db.LatestVersion(); version != want {
+			return fmt.Errorf("schema version %d, want %d", version, want)
+		}
+		return nil
+	})
+}
+
+/*
+WebhookBacklogger reports the pending webhook deliveries and how many of them are overdue.
+It is implemented by service.URLService.
+*/
+type WebhookBacklogger interface {
+	WebhookBacklog(ctx context.Context, overdueBefore time.Time) (pending, overdue int, err error)
+}
+
+/*
+WebhookBacklogCheck fails when more than maxPending deliveries are pending, or when any
+delivery has been due for longer than maxDelay, which means the dispatcher is stuck or
+cannot keep up.
+*/
+func WebhookBacklogCheck(b WebhookBacklogger, maxPending int, maxDelay time.Duration) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		pending, overdue, err := b.WebhookBacklog(ctx, time.Now().Add(-maxDelay))
+		if err != nil {
+			return fmt.Errorf("count deliveries: %w", err)
+		}
+		if pending > maxPending {
+			return fmt.Errorf("%d deliveries pending, limit %d", pending, maxPending)
+		}
+		if overdue > 0 {
+			return fmt.Errorf("%d deliveries due for more than %s", overdue, maxDelay)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,194 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout bounds each readiness check when the registry has no explicit timeout.
+const DefaultTimeout = 2 * time.Second
+
+// Status values reported in the JSON output.
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDraining = "draining"
+)
+
+// ErrDraining is reported by readiness while the server is shutting down.
+var ErrDraining = errors.New("server is shutting down")
+
+/*
+Checker reports whether a dependency is usable.
+*/
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+/*
+CheckerFunc adapts a function to the Checker interface.
+*/
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f(ctx).
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+/*
+Registry holds the readiness checks of the service and its draining state.
+*/
+type Registry struct {
+	mu       sync.RWMutex
+	checks   map[string]Checker
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+/*
+NewRegistry creates an empty registry. Each check is bounded by timeout.
+*/
+func NewRegistry(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Registry{checks: make(map[string]Checker), timeout: timeout}
+}
+
+/*
+Register adds a named readiness check, replacing any check with the same name.
+*/
+func (r *Registry) Register(name string, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = c
+}
+
+/*
+SetDraining marks the server as shutting down, which makes readiness fail
+so that load balancers stop routing new requests while in-flight ones finish.
+*/
+func (r *Registry) SetDraining(draining bool) {
+	r.draining.Store(draining)
+}
+
+/*
+CheckResult is the outcome of a single check.
+*/
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+/*
+Report is the JSON body returned by the probe endpoints.
+*/
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+/*
+Run executes all checks concurrently and returns the aggregated report.
+*/
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Checker, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c Checker) {
+			defer wg.Done()
+			results[i] = r.runCheck(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(names))}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	if r.draining.Load() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+/*
+runCheck runs c with the registry timeout and converts the outcome to a CheckResult.
+*/
+func (r *Registry) runCheck(ctx context.Context, c Checker) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: StatusOK, Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+/*
+LivenessHandler reports that the process is up and able to serve HTTP.
+It never checks dependencies: a failing database must not make the orchestrator restart the process.
+*/
+func (r *Registry) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, http.StatusOK, Report{Status: StatusOK})
+	})
+}
+
+/*
+ReadinessHandler runs all registered checks and responds with 503 if any fails or the server is draining.
+The per-check details are included in the JSON body.
+*/
+func (r *Registry) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context())
+		code := http.StatusOK
+		if report.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		writeReport(w, code, report)
+	})
+}
+
+func writeReport(w http.ResponseWriter, code int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(report)
+}
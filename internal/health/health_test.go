@@ -0,0 +1,129 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/db"
+)
+
+func serve(t *testing.T, h http.Handler) (int, Report) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode report %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, report
+}
+
+func TestReadiness(t *testing.T) {
+	reg := NewRegistry(50 * time.Millisecond)
+	reg.Register("ok", CheckerFunc(func(ctx context.Context) error { return nil }))
+
+	code, report := serve(t, reg.ReadinessHandler())
+	if code != http.StatusOK || report.Status != StatusOK {
+		t.Fatalf("expected ready, got %d %+v", code, report)
+	}
+
+	reg.Register("broken", CheckerFunc(func(ctx context.Context) error { return errors.New("disk full") }))
+	reg.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}))
+
+	code, report = serve(t, reg.ReadinessHandler())
+	if code != http.StatusServiceUnavailable || report.Status != StatusFail {
+		t.Fatalf("expected not ready, got %d %+v", code, report)
+	}
+	if report.Checks["broken"].Error != "disk full" {
+		t.Errorf("expected broken check error, got %+v", report.Checks["broken"])
+	}
+	if report.Checks["slow"].Status != StatusFail {
+		t.Errorf("expected slow check to time out, got %+v", report.Checks["slow"])
+	}
+	if report.Checks["ok"].Status != StatusOK {
+		t.Errorf("expected ok check to pass, got %+v", report.Checks["ok"])
+	}
+}
+
+func TestDraining(t *testing.T) {
+	reg := NewRegistry(0)
+	reg.SetDraining(true)
+
+	code, report := serve(t, reg.ReadinessHandler())
+	if code != http.StatusServiceUnavailable || report.Status != StatusDraining {
+		t.Fatalf("expected draining, got %d %+v", code, report)
+	}
+
+	// Liveness is unaffected by draining
+	code, report = serve(t, reg.LivenessHandler())
+	if code != http.StatusOK || report.Status != StatusOK {
+		t.Fatalf("expected live, got %d %+v", code, report)
+	}
+}
+
+func TestDatabaseChecks(t *testing.T) {
+	database, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	if err := DBCheck(database).Check(ctx); err != nil {
+		t.Errorf("expected database check to pass, got %v", err)
+	}
+	if err := MigrationCheck(database).Check(ctx); err != nil {
+		t.Errorf("expected migration check to pass, got %v", err)
+	}
+
+	if _, err := database.Exec("DELETE FROM schema_migrations WHERE version = ?", db.LatestVersion()); err != nil {
+		t.Fatalf("failed to roll back schema version: %v", err)
+	}
+	if err := MigrationCheck(database).Check(ctx); err == nil {
+		t.Errorf("expected migration check to fail on outdated schema")
+	}
+
+	database.Close()
+	if err := DBCheck(database).Check(ctx); err == nil {
+		t.Errorf("expected database check to fail on closed database")
+	}
+}
+
+type backlog struct {
+	pending, overdue int
+	err              error
+}
+
+func (b backlog) WebhookBacklog(ctx context.Context, overdueBefore time.Time) (int, int, error) {
+	return b.pending, b.overdue, b.err
+}
+
+func TestWebhookBacklogCheck(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name string
+		b    backlog
+		ok   bool
+	}{
+		{"empty", backlog{}, true},
+		{"within limit", backlog{pending: 100}, true},
+		{"too many pending", backlog{pending: 101}, false},
+		{"overdue", backlog{pending: 1, overdue: 1}, false},
+		{"error", backlog{err: errors.New("database is locked")}, false},
+	}
+	for _, c := range cases {
+		err := WebhookBacklogCheck(c.b, 100, time.Minute).Check(ctx)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: expected ok=%v, got %v", c.name, c.ok, err)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package linkcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/safehttp"
+)
+
+// Defaults of Config.
+const (
+	DefaultTimeout      = 10 * time.Second
+	DefaultConcurrency  = 8
+	DefaultHostDelay    = time.Second
+	DefaultMaxRedirects = 10
+	DefaultUserAgent    = "url-shortener-linkcheck/1.0"
+)
+
+// maxDrain is how much of a response body is read so that the connection can be reused.
+const maxDrain = 64 << 10
+
+var (
+	checksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "url_link_checks_total",
+			Help: "Total number of destination checks, by result: ok or failed.",
+		},
+		[]string{"result"},
+	)
+
+	checkDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "url_link_check_duration_seconds",
+			Help:    "Time spent on the requests of a destination check, including redirects.",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(checksTotal, checkDuration)
+}
+
+/*
+Config limits the checks of a Checker.
+*/
+type Config struct {
+	// Timeout bounds a single request of a check.
+	Timeout time.Duration
+	// Concurrency is the number of destinations checked at a time by CheckAll.
+	Concurrency int
+	// HostDelay is the pause between two requests to the same host.
+	HostDelay time.Duration
+	// MaxRedirects is the number of redirects followed before the destination counts as failed.
+	MaxRedirects int
+	// UserAgent is sent with every request.
+	UserAgent string
+	// AllowPrivate permits destinations on non-public addresses, e.g. for tests against local servers.
+	AllowPrivate bool
+}
+
+/*
+Checker requests destinations to find out whether they are still alive.
+It tries HEAD first and falls back to GET for servers that do not answer HEAD properly.
+Redirects are followed one by one, so that the chain is recorded and every hop
+goes through the per-host politeness gate.
+*/
+type Checker struct {
+	client       *http.Client
+	gate         *hostGate
+	concurrency  int
+	maxRedirects int
+	userAgent    string
+}
+
+/*
+New returns a checker with the given limits; zero values take the defaults.
+*/
+func New(cfg Config) *Checker {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = DefaultConcurrency
+	}
+	if cfg.HostDelay < 0 {
+		cfg.HostDelay = 0
+	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = DefaultMaxRedirects
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = DefaultUserAgent
+	}
+
+	client := safehttp.NewClient(safehttp.Config{Timeout: cfg.Timeout, AllowPrivate: cfg.AllowPrivate})
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &Checker{
+		client:       client,
+		gate:         newHostGate(cfg.HostDelay),
+		concurrency:  cfg.Concurrency,
+		maxRedirects: cfg.MaxRedirects,
+		userAgent:    cfg.UserAgent,
+	}
+}
+
+/*
+Healthy reports whether a final status code means the destination is alive.
+429 only says that the checker was rate limited, so it does not count as a failure.
+*/
+func Healthy(status int) bool {
+	return status < http.StatusBadRequest || status == http.StatusTooManyRequests
+}
+
+/*
+Check requests one destination, following its redirects.
+*/
+func (c *Checker) Check(ctx context.Context, destination string) model.DestinationHealth {
+	h := model.DestinationHealth{URL: destination}
+	var spent time.Duration
+	defer func() {
+		h.LatencyMs = spent.Milliseconds()
+		if ctx.Err() != nil {
+			// Interrupted checks say nothing about the destination
+			return
+		}
+		checkDuration.Observe(spent.Seconds())
+		result := "ok"
+		if !h.OK {
+			result = "failed"
+		}
+		checksTotal.WithLabelValues(result).Inc()
+	}()
+
+	u, err := url.Parse(destination)
+	if err == nil {
+		err = safehttp.CheckURL(u)
+	}
+	for hops := 0; err == nil; hops++ {
+		var resp *http.Response
+		resp, err = c.request(ctx, u, &spent)
+		if err != nil {
+			break
+		}
+		h.Status = resp.StatusCode
+
+		location := resp.Header.Get("Location")
+		if !isRedirect(resp.StatusCode) || location == "" {
+			h.OK = Healthy(resp.StatusCode)
+			return h
+		}
+		if hops == c.maxRedirects {
+			err = fmt.Errorf("stopped after %d redirects", c.maxRedirects)
+			break
+		}
+		next, perr := u.Parse(location)
+		if perr != nil {
+			err = fmt.Errorf("invalid redirect location %q: %w", location, perr)
+			break
+		}
+		h.Redirects = append(h.Redirects, next.String())
+		u, err = next, safehttp.CheckURL(next)
+	}
+	h.Error = err.Error()
+	return h
+}
+
+/*
+request sends HEAD to u, or GET if the server answers HEAD with an error status,
+adding the time spent on the requests to spent.
+*/
+func (c *Checker) request(ctx context.Context, u *url.URL, spent *time.Duration) (*http.Response, error) {
+	resp, err := c.do(ctx, http.MethodHead, u, spent)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	// Some servers answer HEAD with 405 or 404 while GET works
+	return c.do(ctx, http.MethodGet, u, spent)
+}
+
+func (c *Checker) do(ctx context.Context, method string, u *url.URL, spent *time.Duration) (*http.Response, error) {
+	release, err := c.gate.acquire(ctx, strings.ToLower(u.Host))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		*spent += time.Since(start)
+		return nil, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrain))
+	resp.Body.Close()
+	*spent += time.Since(start)
+	return resp, nil
+}
+
+/*
+CheckAll checks the distinct destinations with up to Config.Concurrency requests at a time.
+Destinations are interleaved by host, so that a host with many links does not hold up the others
+while its requests wait for the politeness delay. Destinations not checked before ctx is done are
+missing from the result.
+*/
+func (c *Checker) CheckAll(ctx context.Context, destinations []string) map[string]model.DestinationHealth {
+	jobs := interleaveByHost(destinations)
+	results := make(map[string]model.DestinationHealth, len(jobs))
+	var mu sync.Mutex
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for range min(c.concurrency, len(jobs)) {
+		wg.Go(func() {
+			for d := range queue {
+				h := c.Check(ctx, d)
+				if ctx.Err() != nil {
+					continue
+				}
+				mu.Lock()
+				results[d] = h
+				mu.Unlock()
+			}
+		})
+	}
+
+send:
+	for _, d := range jobs {
+		select {
+		case queue <- d:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
+
+/*
+interleaveByHost returns the distinct destinations ordered round-robin by host.
+*/
+func interleaveByHost(destinations []string) []string {
+	var hosts []string
+	byHost := map[string][]string{}
+	seen := map[string]bool{}
+	for _, d := range destinations {
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		host := d
+		if u, err := url.Parse(d); err == nil {
+			host = strings.ToLower(u.Host)
+		}
+		if _, ok := byHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		byHost[host] = append(byHost[host], d)
+	}
+
+	jobs := make([]string, 0, len(seen))
+	for i := 0; len(jobs) < len(seen); i++ {
+		for _, host := range hosts {
+			if i < len(byHost[host]) {
+				jobs = append(jobs, byHost[host][i])
+			}
+		}
+	}
+	return jobs
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
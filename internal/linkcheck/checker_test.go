@@ -0,0 +1,141 @@
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/moved":
+			http.Redirect(w, r, "/moved-again", http.StatusMovedPermanently)
+		case "/moved-again":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/busy":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(Config{AllowPrivate: true, HostDelay: -1, MaxRedirects: 3})
+	ctx := context.Background()
+
+	h := c.Check(ctx, srv.URL+"/moved")
+	if !h.OK || h.Status != http.StatusOK || strings.Join(h.Redirects, " ") != srv.URL+"/moved-again "+srv.URL+"/ok" {
+		t.Errorf("expected the redirect chain to end at 200, got %+v", h)
+	}
+	if h := c.Check(ctx, srv.URL+"/no-head"); !h.OK {
+		t.Errorf("expected the GET fallback to succeed, got %+v", h)
+	}
+	if h := c.Check(ctx, srv.URL+"/gone"); h.OK || h.Status != http.StatusNotFound {
+		t.Errorf("expected a failed check with 404, got %+v", h)
+	}
+	if h := c.Check(ctx, srv.URL+"/busy"); !h.OK {
+		t.Errorf("expected 429 not to count as a failure, got %+v", h)
+	}
+	if h := c.Check(ctx, srv.URL+"/loop"); h.OK || len(h.Redirects) != 3 || !strings.Contains(h.Error, "redirects") {
+		t.Errorf("expected a redirect loop to fail, got %+v", h)
+	}
+	if h := c.Check(ctx, "mailto:someone@example.com"); h.OK || h.Error == "" {
+		t.Errorf("expected an unsupported URL to fail, got %+v", h)
+	}
+
+	// Destinations on internal addresses are not requested by default
+	if h := New(Config{}).Check(ctx, srv.URL+"/ok"); h.OK || !strings.Contains(h.Error, "not public") {
+		t.Errorf("expected a loopback destination to be refused, got %+v", h)
+	}
+}
+
+func TestCheckAllPoliteness(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight = map[string]int{}
+		last     = map[string]time.Time{}
+		overlap  atomic.Bool
+		tooSoon  atomic.Bool
+	)
+	const delay = 30 * time.Millisecond
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			inFlight[name]++
+			if inFlight[name] > 1 {
+				overlap.Store(true)
+			}
+			if prev, ok := last[name]; ok && time.Since(prev) < delay-5*time.Millisecond {
+				tooSoon.Store(true)
+			}
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			inFlight[name]--
+			last[name] = time.Now()
+			mu.Unlock()
+		})
+	}
+	a := httptest.NewServer(handler("a"))
+	defer a.Close()
+	b := httptest.NewServer(handler("b"))
+	defer b.Close()
+
+	var dests []string
+	for _, p := range []string{"/1", "/2", "/3", "/4"} {
+		dests = append(dests, a.URL+p, b.URL+p)
+	}
+	dests = append(dests, a.URL+"/1") // duplicates are checked once
+
+	c := New(Config{AllowPrivate: true, Concurrency: 4, HostDelay: delay})
+	results := c.CheckAll(context.Background(), dests)
+	if len(results) != 8 {
+		t.Fatalf("expected 8 distinct results, got %d", len(results))
+	}
+	for d, h := range results {
+		if !h.OK {
+			t.Errorf("%s: expected success, got %+v", d, h)
+		}
+	}
+	if overlap.Load() {
+		t.Error("expected at most one request at a time per host")
+	}
+	if tooSoon.Load() {
+		t.Error("expected requests to one host to be spaced by the delay")
+	}
+}
+
+func TestCheckAllCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c := New(Config{AllowPrivate: true, HostDelay: time.Hour})
+	results := c.CheckAll(ctx, []string{srv.URL + "/1", srv.URL + "/2"})
+	if len(results) != 1 {
+		t.Errorf("expected only the first destination before the delay, got %d results", len(results))
+	}
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to pass, got %v", ctx.Err())
+	}
+}
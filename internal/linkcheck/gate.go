@@ -0,0 +1,89 @@
+package linkcheck
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pruneAbove is the number of tracked hosts above which idle ones are forgotten.
+const pruneAbove = 1024
+
+/*
+hostGate keeps checks polite: one request at a time per host, with a pause between them.
+*/
+type hostGate struct {
+	delay time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostSlot
+}
+
+type hostSlot struct {
+	busy  chan struct{} // holds a value while a request to the host is in flight
+	next  time.Time     // earliest start of the next request, written while holding busy
+	users int           // goroutines holding or waiting for the slot, guarded by hostGate.mu
+}
+
+func newHostGate(delay time.Duration) *hostGate {
+	return &hostGate{delay: delay, hosts: make(map[string]*hostSlot)}
+}
+
+/*
+acquire waits until a request to host may start. The returned function must be called
+when the request is done; the next request to the host starts no sooner than the delay after that.
+*/
+func (g *hostGate) acquire(ctx context.Context, host string) (release func(), err error) {
+	g.mu.Lock()
+	if len(g.hosts) > pruneAbove {
+		g.prune()
+	}
+	slot := g.hosts[host]
+	if slot == nil {
+		slot = &hostSlot{busy: make(chan struct{}, 1)}
+		g.hosts[host] = slot
+	}
+	slot.users++
+	g.mu.Unlock()
+
+	leave := func() {
+		g.mu.Lock()
+		slot.users--
+		g.mu.Unlock()
+	}
+
+	select {
+	case slot.busy <- struct{}{}:
+	case <-ctx.Done():
+		leave()
+		return nil, ctx.Err()
+	}
+	if wait := time.Until(slot.next); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			<-slot.busy
+			leave()
+			return nil, ctx.Err()
+		}
+	}
+	return func() {
+		slot.next = time.Now().Add(g.delay)
+		<-slot.busy
+		leave()
+	}, nil
+}
+
+/*
+prune forgets hosts nobody is waiting for whose delay has passed. The caller holds g.mu.
+*/
+func (g *hostGate) prune() {
+	now := time.Now()
+	for host, slot := range g.hosts {
+		if slot.users == 0 && !slot.next.After(now) {
+			delete(g.hosts, host)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package linkcheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+// Events of a Notification.
+const (
+	EventBroken    = "link.broken"    // The link has just been found broken
+	EventRecovered = "link.recovered" // A broken link works again
+)
+
+/*
+Notification tells about a link whose health changed.
+*/
+type Notification struct {
+	Event  string            `json:"event"`
+	Link   *model.URL        `json:"link"`
+	Health *model.LinkHealth `json:"health"`
+}
+
+/*
+Notifier delivers notifications, e.g. to the owners of links.
+*/
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+/*
+Webhook posts notifications as JSON. Every notification goes to URL, and notifications
+about links of an owner listed in Owners go to the owner's URL as well.
+*/
+type Webhook struct {
+	URL    string
+	Owners map[string]string // owner display name → webhook URL
+	Client *http.Client
+}
+
+/*
+NewWebhook returns a webhook notifier with a client that gives up after timeout.
+*/
+func NewWebhook(url string, owners map[string]string, timeout time.Duration) *Webhook {
+	return &Webhook{URL: url, Owners: owners, Client: &http.Client{Timeout: timeout}}
+}
+
+func (w *Webhook) Notify(ctx context.Context, n Notification) error {
+	var targets []string
+	if w.URL != "" {
+		targets = append(targets, w.URL)
+	}
+	if target := w.Owners[n.Link.Owner]; n.Link.Owner != "" && target != "" && target != w.URL {
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, target := range targets {
+		if err := w.post(ctx, target, body); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", target, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w *Webhook) post(ctx context.Context, target string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
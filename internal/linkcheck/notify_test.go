@@ -0,0 +1,59 @@
+package linkcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+func TestWebhook(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received = map[string]Notification{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received[r.URL.Path] = n
+		mu.Unlock()
+		if r.URL.Path == "/failing" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	hook := NewWebhook(srv.URL+"/all", map[string]string{"marketing": srv.URL + "/marketing"}, time.Second)
+	n := Notification{
+		Event:  EventBroken,
+		Link:   &model.URL{Short: "abc123", Owner: "marketing"},
+		Health: &model.LinkHealth{Broken: true},
+	}
+	if err := hook.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if len(received) != 2 || received["/marketing"].Link.Short != "abc123" || received["/all"].Event != EventBroken {
+		t.Errorf("expected the notification at both webhooks, got %+v", received)
+	}
+
+	// Links of other owners only go to the common webhook
+	clear(received)
+	n.Link.Owner = "sales"
+	_ = hook.Notify(context.Background(), n)
+	if len(received) != 1 {
+		t.Errorf("expected only the common webhook, got %+v", received)
+	}
+
+	if err := NewWebhook(srv.URL+"/failing", nil, time.Second).Notify(context.Background(), n); err == nil {
+		t.Error("expected an error for a failing webhook")
+	}
+}
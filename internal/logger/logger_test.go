@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestNewJSONWithTraceIDs(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+
+	l.DebugContext(ctx, "hidden")
+	l.InfoContext(ctx, "visible", "key", "value")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON record, got %q: %v", buf.String(), err)
+	}
+	if entry["msg"] != "visible" || entry["key"] != "value" {
+		t.Errorf("unexpected record %v", entry)
+	}
+	if entry["trace_id"] != span.SpanContext().TraceID().String() {
+		t.Errorf("expected trace_id %s, got %v", span.SpanContext().TraceID(), entry["trace_id"])
+	}
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "verbose", "json"); err == nil {
+		t.Errorf("expected error for invalid level")
+	}
+	if _, err := New(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Errorf("expected error for invalid format")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Errorf("expected default logger without context value")
+	}
+
+	l := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	if FromContext(WithContext(context.Background(), l)) != l {
+		t.Errorf("expected logger stored in context")
+	}
+}
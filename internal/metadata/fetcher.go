@@ -0,0 +1,112 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/safehttp"
+)
+
+// Defaults of Config.
+const (
+	DefaultTimeout   = 5 * time.Second
+	DefaultMaxBytes  = 512 << 10
+	DefaultUserAgent = "url-shortener-metadata/1.0"
+)
+
+/*
+Config limits the requests of a Fetcher.
+*/
+type Config struct {
+	// Timeout bounds fetching a page, including redirects.
+	Timeout time.Duration
+	// MaxBytes is how much of a page is read; the tags are expected in its head.
+	MaxBytes int64
+	// UserAgent is sent with every request.
+	UserAgent string
+	// AllowPrivate permits destinations on non-public addresses, e.g. for tests against local servers.
+	AllowPrivate bool
+}
+
+/*
+Fetcher retrieves the metadata of destination pages. Destinations are user input, so
+requests are limited in time and size and may only reach public addresses.
+*/
+type Fetcher struct {
+	client    *http.Client
+	maxBytes  int64
+	userAgent string
+}
+
+/*
+New returns a fetcher with the given limits; zero values take the defaults.
+*/
+func New(cfg Config) *Fetcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultMaxBytes
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = DefaultUserAgent
+	}
+	return &Fetcher{
+		client:    safehttp.NewClient(safehttp.Config{Timeout: cfg.Timeout, AllowPrivate: cfg.AllowPrivate}),
+		maxBytes:  cfg.MaxBytes,
+		userAgent: cfg.UserAgent,
+	}
+}
+
+/*
+Fetch retrieves the page at rawURL and extracts its metadata. Responses that are not
+HTML pages yield empty metadata rather than an error; there is nothing to extract from them.
+A response without a Content-Type is parsed as HTML.
+*/
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (model.Metadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return model.Metadata{}, fmt.Errorf("%w: %v", safehttp.ErrUnsupportedURL, err)
+	}
+	if err := safehttp.CheckURL(u); err != nil {
+		return model.Metadata{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return model.Metadata{}, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return model.Metadata{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return model.Metadata{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); contentType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return model.Metadata{FetchedAt: time.Now()}, nil
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.maxBytes), contentType)
+	if err != nil {
+		return model.Metadata{}, fmt.Errorf("decode page: %w", err)
+	}
+	// resp.Request is the last request, so relative URLs resolve against the page after redirects
+	m := Parse(body, resp.Request.URL)
+	m.FetchedAt = time.Now()
+	return m, nil
+}
@@ -0,0 +1,69 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/safehttp"
+)
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/articles/1", http.StatusMovedPermanently)
+		case "/articles/1":
+			w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+			// "Привет" in windows-1251
+			_, _ = w.Write([]byte("<title>\xcf\xf0\xe8\xe2\xe5\xf2</title><meta property=\"og:image\" content=\"cover.png\">"))
+		case "/file.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write([]byte("%PDF-1.7"))
+		case "/huge":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<!-- " + strings.Repeat("x", 4096) + " --><title>Too far</title>"))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	f := New(Config{AllowPrivate: true, MaxBytes: 1024, Timeout: 100 * time.Millisecond})
+	ctx := context.Background()
+
+	m, err := f.Fetch(ctx, srv.URL+"/moved")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if m.Title != "Привет" || m.Image != srv.URL+"/articles/cover.png" || m.FetchedAt.IsZero() {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+
+	if m, err := f.Fetch(ctx, srv.URL+"/file.pdf"); err != nil || m.Title != "" || m.FetchedAt.IsZero() {
+		t.Errorf("expected empty metadata for a PDF, got %+v (err %v)", m, err)
+	}
+	if m, err := f.Fetch(ctx, srv.URL+"/huge"); err != nil || m.Title != "" {
+		t.Errorf("expected the page to be cut at the size limit, got %+v (err %v)", m, err)
+	}
+	if _, err := f.Fetch(ctx, srv.URL+"/missing"); err == nil {
+		t.Error("expected an error for a 404 page")
+	}
+	if _, err := f.Fetch(ctx, srv.URL+"/slow"); err == nil {
+		t.Error("expected a timeout for a slow page")
+	}
+	if _, err := f.Fetch(ctx, "ftp://example.com/file"); !errors.Is(err, safehttp.ErrUnsupportedURL) {
+		t.Errorf("expected ErrUnsupportedURL, got %v", err)
+	}
+
+	// Without AllowPrivate the local test server is out of reach
+	if _, err := New(Config{}).Fetch(ctx, srv.URL+"/moved"); !errors.Is(err, safehttp.ErrForbiddenAddress) {
+		t.Errorf("expected ErrForbiddenAddress, got %v", err)
+	}
+}
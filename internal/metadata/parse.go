@@ -0,0 +1,99 @@
+package metadata
+
+import (
+	"cmp"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+// Longest values kept, in runes. Pages are free to put anything in their tags.
+const (
+	maxTitle       = 300
+	maxDescription = 1000
+	maxImage       = 2048
+)
+
+/*
+Parse extracts the metadata from the head of an HTML page. Relative image URLs are
+resolved against base, the URL the page was served from. Parsing stops at the body,
+so a truncated page still yields the tags of its head.
+*/
+func Parse(r io.Reader, base *url.URL) model.Metadata {
+	var (
+		title, description string
+		og                 = map[string]string{}
+	)
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return build(title, description, og, base)
+		case html.EndTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.Head {
+				return build(title, description, og, base)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				return build(title, description, og, base)
+			case atom.Title:
+				if title == "" && tt == html.StartTagToken && z.Next() == html.TextToken {
+					title = string(z.Text())
+				}
+			case atom.Meta:
+				if !hasAttr {
+					continue
+				}
+				attrs := map[string]string{}
+				for more := true; more; {
+					var key, val []byte
+					key, val, more = z.TagAttr()
+					attrs[string(key)] = string(val)
+				}
+				content := strings.TrimSpace(attrs["content"])
+				if strings.EqualFold(attrs["name"], "description") && description == "" {
+					description = content
+				}
+				// Some pages use name instead of property for Open Graph tags
+				property := strings.ToLower(cmp.Or(attrs["property"], attrs["name"]))
+				if strings.HasPrefix(property, "og:") && og[property] == "" {
+					og[property] = content
+				}
+			}
+		}
+	}
+}
+
+func build(title, description string, og map[string]string, base *url.URL) model.Metadata {
+	m := model.Metadata{
+		Title:       clean(cmp.Or(title, og["og:title"]), maxTitle),
+		Description: clean(cmp.Or(description, og["og:description"]), maxDescription),
+		SiteName:    clean(og["og:site_name"], maxTitle),
+	}
+	if image := strings.TrimSpace(cmp.Or(og["og:image"], og["og:image:url"], og["og:image:secure_url"])); image != "" {
+		if u, err := base.Parse(image); err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.String()) <= maxImage {
+			m.Image = u.String()
+		}
+	}
+	return m
+}
+
+/*
+clean collapses whitespace and truncates s to max runes.
+*/
+func clean(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > max {
+		s = strings.TrimSpace(string(r[:max-1])) + "…"
+	}
+	return s
+}
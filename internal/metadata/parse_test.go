@@ -0,0 +1,61 @@
+package metadata
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post")
+	page := `<!DOCTYPE html>
+<html>
+<head>
+  <title>
+    Hello &amp; welcome
+  </title>
+  <meta name="Description" content="A page about things.">
+  <meta property="og:title" content="Open Graph title">
+  <meta property="og:image" content="/img/cover.png">
+  <meta property="og:site_name" content="Example">
+</head>
+<body>
+  <title>Not the title</title>
+  <meta name="description" content="Not the description">
+</body>
+</html>`
+
+	m := Parse(strings.NewReader(page), base)
+	if m.Title != "Hello & welcome" {
+		t.Errorf("unexpected title %q", m.Title)
+	}
+	if m.Description != "A page about things." {
+		t.Errorf("unexpected description %q", m.Description)
+	}
+	if m.Image != "https://example.com/img/cover.png" {
+		t.Errorf("expected the image resolved against the page, got %q", m.Image)
+	}
+	if m.SiteName != "Example" {
+		t.Errorf("unexpected site name %q", m.SiteName)
+	}
+}
+
+func TestParseFallbacks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/")
+	page := `<meta property="og:title" content="OG title">
+<meta name="og:description" content="OG description">
+<meta property="og:image" content="javascript:alert(1)">`
+
+	m := Parse(strings.NewReader(page), base)
+	if m.Title != "OG title" || m.Description != "OG description" {
+		t.Errorf("expected the Open Graph tags as fallbacks, got %+v", m)
+	}
+	if m.Image != "" {
+		t.Errorf("expected a non-http image to be dropped, got %q", m.Image)
+	}
+
+	long := "<title>" + strings.Repeat("word ", 200) + "</title>"
+	if m := Parse(strings.NewReader(long), base); len([]rune(m.Title)) != maxTitle || !strings.HasSuffix(m.Title, "…") {
+		t.Errorf("expected the title truncated to %d runes, got %d", maxTitle, len([]rune(m.Title)))
+	}
+}
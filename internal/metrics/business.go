@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Sources of link creation used as the "source" label of urls_created_total.
+const (
+	SourceAPI  = "api"
+	SourceGRPC = "grpc"
+)
+
+var (
+	redirectsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "url_redirects_total",
+			Help: "Total number of redirects served for short links.",
+		},
+	)
+
+	notFoundTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "url_not_found_total",
+			Help: "Total number of lookups for short codes that do not exist.",
+		},
+	)
+
+	expiredTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "url_expired_total",
+			Help: "Total number of requests for short links that are no longer available.",
+		},
+	)
+
+	blockedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "url_blocked_total",
+			Help: "Total number of requests for short links disabled by destination screening.",
+		},
+	)
+
+	createdTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "urls_created_total",
+			Help: "Total number of short links created, by source.",
+		},
+		[]string{"source"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(redirectsTotal, notFoundTotal, expiredTotal, blockedTotal, createdTotal)
+}
+
+// RecordRedirect counts a redirect served for a short link.
+func RecordRedirect(ctx context.Context) {
+	AddWithExemplar(redirectsTotal, 1, Exemplar(ctx))
+}
+
+// RecordNotFound counts a lookup of an unknown short code.
+func RecordNotFound(ctx context.Context) {
+	AddWithExemplar(notFoundTotal, 1, Exemplar(ctx))
+}
+
+// RecordExpired counts a request for a link that is no longer available.
+func RecordExpired(ctx context.Context) {
+	AddWithExemplar(expiredTotal, 1, Exemplar(ctx))
+}
+
+// RecordBlocked counts a request for a link disabled by destination screening.
+func RecordBlocked(ctx context.Context) {
+	AddWithExemplar(blockedTotal, 1, Exemplar(ctx))
+}
+
+// RecordCreated counts a link created through the given source.
+func RecordCreated(ctx context.Context, source string) {
+	AddWithExemplar(createdTotal.WithLabelValues(source), 1, Exemplar(ctx))
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestRecordCreated(t *testing.T) {
+	before := testutil.ToFloat64(createdTotal.WithLabelValues(SourceAPI))
+
+	RecordCreated(context.Background(), SourceAPI)
+
+	if got := testutil.ToFloat64(createdTotal.WithLabelValues(SourceAPI)); got != before+1 {
+		t.Errorf("expected urls_created_total{source=api}=%v, got %v", before+1, got)
+	}
+}
+
+func TestRecordNotFoundExemplar(t *testing.T) {
+	ctx, span := trace.NewTracerProvider().Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+
+	RecordNotFound(ctx)
+
+	m := &dto.Metric{}
+	if err := notFoundTotal.Write(m); err != nil {
+		t.Fatalf("failed to get metric: %v", err)
+	}
+	exemplar := m.Counter.GetExemplar()
+	if exemplar == nil || exemplar.Label[0].GetValue() != span.SpanContext().TraceID().String() {
+		t.Errorf("expected exemplar with trace ID %s, got %v", span.SpanContext().TraceID(), exemplar)
+	}
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zen-flo/url-shortener/internal/tracing"
+)
+
+/*
+Exemplar returns exemplar labels linking a metric observation to the trace in ctx, or nil if ctx is not traced.
+*/
+func Exemplar(ctx context.Context) prometheus.Labels {
+	traceID := tracing.TraceID(ctx)
+	if traceID == "" {
+		return nil
+	}
+	return prometheus.Labels{"trace_id": traceID}
+}
+
+/*
+AddWithExemplar adds v to the counter, attaching the exemplar when there is one.
+*/
+func AddWithExemplar(c prometheus.Counter, v float64, exemplar prometheus.Labels) {
+	if ea, ok := c.(prometheus.ExemplarAdder); ok && exemplar != nil {
+		ea.AddWithExemplar(v, exemplar)
+		return
+	}
+	c.Add(v)
+}
+
+/*
+ObserveWithExemplar records v in the histogram, attaching the exemplar when there is one.
+*/
+func ObserveWithExemplar(o prometheus.Observer, v float64, exemplar prometheus.Labels) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		eo.ObserveWithExemplar(v, exemplar)
+		return
+	}
+	o.Observe(v)
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/logger"
+)
+
+/*
+RequireScope authenticates the request with a bearer token or X-API-Key header
+and lets it through only if the principal has the given scope.
+The principal is stored in the request context.
+*/
+func RequireScope(a auth.Authenticator, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := auth.TokenFromRequest(r)
+			if token == "" {
+				unauthorized(w)
+				return
+			}
+
+			p, ok := authenticate(w, r, a, token)
+			if !ok {
+				return
+			}
+			if !p.HasScope(scope) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+/*
+Authenticate stores the principal in the request context if the request carries a token.
+Anonymous requests pass through, but a token that does not authenticate is rejected
+rather than silently ignored.
+*/
+func Authenticate(a auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := auth.TokenFromRequest(r)
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			p, ok := authenticate(w, r, a, token)
+			if !ok {
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+/*
+authenticate resolves token to a principal, writing the error response if that fails.
+*/
+func authenticate(w http.ResponseWriter, r *http.Request, a auth.Authenticator, token string) (*auth.Principal, bool) {
+	p, err := a.Authenticate(r.Context(), token)
+	if err != nil {
+		if !errors.Is(err, auth.ErrInvalidCredentials) {
+			logger.FromContext(r.Context()).ErrorContext(r.Context(), "authentication failed", "error", err)
+			http.Error(w, "authentication unavailable", http.StatusServiceUnavailable)
+			return nil, false
+		}
+		unauthorized(w)
+		return nil, false
+	}
+	return p, true
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zen-flo/url-shortener/internal/auth"
+)
+
+// Проверяем, что RequireScope пропускает только запросы с верным токеном и нужным scope
+func TestRequireScope(t *testing.T) {
+	authn := auth.Chain(
+		auth.StaticToken("secret"),
+		auth.AuthenticatorFunc(func(_ context.Context, token string) (*auth.Principal, error) {
+			if token == "reader-key" {
+				return &auth.Principal{Name: "reader", Scopes: []string{"read"}}, nil
+			}
+			return nil, auth.ErrInvalidCredentials
+		}),
+	)
+
+	var principal *auth.Principal
+	handler := RequireScope(authn, auth.ScopeAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = auth.PrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"Authorization", "Bearer secret", http.StatusOK},
+		{"Authorization", "bearer secret", http.StatusOK},
+		{"X-API-Key", "secret", http.StatusOK},
+		{"Authorization", "Bearer wrong", http.StatusUnauthorized},
+		{"Authorization", "Bearer reader-key", http.StatusForbidden},
+		{"Authorization", "secret", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		principal = nil
+		req := httptest.NewRequest("GET", "/admin/backups", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %q: expected status %d, got %d", tt.header, tt.value, tt.wantStatus, rec.Code)
+		}
+		if tt.wantStatus == http.StatusOK && (principal == nil || principal.Name != "admin-token") {
+			t.Errorf("%s %q: expected principal in context, got %+v", tt.header, tt.value, principal)
+		}
+	}
+}
+
+// Проверяем, что Authenticate пропускает анонимные запросы, но отклоняет неверный токен
+func TestAuthenticate(t *testing.T) {
+	authn := auth.StaticToken("secret")
+
+	var principal *auth.Principal
+	handler := Authenticate(authn)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = auth.PrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		token         string
+		wantStatus    int
+		wantPrincipal bool
+	}{
+		{"", http.StatusOK, false},
+		{"secret", http.StatusOK, true},
+		{"wrong", http.StatusUnauthorized, false},
+	}
+
+	for _, tt := range tests {
+		principal = nil
+		req := httptest.NewRequest("POST", "/shorten", nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("token %q: expected status %d, got %d", tt.token, tt.wantStatus, rec.Code)
+		}
+		if (principal != nil) != tt.wantPrincipal {
+			t.Errorf("token %q: expected principal %v, got %+v", tt.token, tt.wantPrincipal, principal)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+/*
+HSTS tells browsers to use HTTPS only for maxAge. The header is set on TLS
+responses only, as browsers ignore it over plain HTTP.
+*/
+func HSTS(maxAge time.Duration) func(http.Handler) http.Handler {
+	value := fmt.Sprintf("max-age=%d", int64(maxAge.Seconds()))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil {
+				w.Header().Set("Strict-Transport-Security", value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
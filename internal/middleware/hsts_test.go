@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Проверяем, что заголовок HSTS выставляется только для запросов по TLS
+func TestHSTS(t *testing.T) {
+	handler := HSTS(24 * time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("expected no HSTS header over HTTP, got %q", got)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=86400" {
+		t.Errorf("expected max-age=86400, got %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/logger"
+)
+
+/*
+AccessLog writes one structured log record per request with the method,
+matched route pattern, status, latency and response size.
+*/
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rr := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+
+		next.ServeHTTP(rr, r)
+
+		level := slog.LevelInfo
+		if rr.statusCode >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		logger.FromContext(r.Context()).LogAttrs(r.Context(), level, "http request",
+			slog.String("method", r.Method),
+			slog.String("route", routePattern(r)),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rr.statusCode),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("bytes", rr.bytes),
+			slog.String("remote_addr", r.RemoteAddr),
+		)
+	})
+}
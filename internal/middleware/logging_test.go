@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Проверяем, что access log содержит шаблон маршрута, статус, размер ответа и request ID
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	r := chi.NewRouter()
+	r.Use(RequestID)
+	r.Use(AccessLog)
+	r.Get("/urls/{short}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	req := httptest.NewRequest("GET", "/urls/abc123", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to decode log record %q: %v", buf.String(), err)
+	}
+
+	want := map[string]interface{}{
+		"msg":        "http request",
+		"method":     "GET",
+		"route":      "/urls/{short}",
+		"status":     float64(http.StatusTeapot),
+		"bytes":      float64(5),
+		"request_id": "req-1",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("expected %s=%v, got %v", k, v, entry[k])
+		}
+	}
+}
@@ -7,8 +7,14 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/zen-flo/url-shortener/internal/metrics"
 )
 
+// unmatchedRoute labels requests that did not match any route,
+// so that random paths cannot create new series.
+const unmatchedRoute = "unmatched"
+
 var (
 	httpRequestsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -26,36 +32,62 @@ var (
 		},
 		[]string{"path", "method"},
 	)
+
+	httpResponseSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "Size of HTTP response bodies.",
+			Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+		},
+		[]string{"path", "method"},
+	)
+
+	httpRequestsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served.",
+		},
+	)
 )
 
 func init() {
-	prometheus.MustRegister(httpRequestsTotal, httpRequestDuration)
+	prometheus.MustRegister(httpRequestsTotal, httpRequestDuration, httpResponseSize, httpRequestsInFlight)
 }
 
+/*
+MetricsMiddleware records request count, latency and response size labeled by the chi route pattern
+(e.g. /urls/{short}) rather than the raw path, which keeps the number of series bounded.
+Observations carry the trace ID as an exemplar when the request is traced.
+*/
 func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		rr := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
 
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+
 		next.ServeHTTP(rr, r)
 
-		duration := time.Since(start).Seconds()
-		httpRequestsTotal.WithLabelValues(r.URL.Path, r.Method, strconv.Itoa(rr.statusCode)).Inc()
-		httpRequestDuration.WithLabelValues(r.URL.Path, r.Method).Observe(duration)
-	})
-}
+		path := routePattern(r)
+		if path == "" {
+			path = unmatchedRoute
+		}
 
-type responseRecorder struct {
-	http.ResponseWriter
-	statusCode int
-}
+		duration := time.Since(start).Seconds()
+		exemplar := metrics.Exemplar(r.Context())
 
-func (rr *responseRecorder) WriteHeader(code int) {
-	rr.statusCode = code
-	rr.ResponseWriter.WriteHeader(code)
+		metrics.AddWithExemplar(httpRequestsTotal.WithLabelValues(path, r.Method, strconv.Itoa(rr.statusCode)), 1, exemplar)
+		metrics.ObserveWithExemplar(httpRequestDuration.WithLabelValues(path, r.Method), duration, exemplar)
+		metrics.ObserveWithExemplar(httpResponseSize.WithLabelValues(path, r.Method), float64(rr.bytes), exemplar)
+	})
 }
 
-// MetricsHandler Handler for metrics
+// MetricsHandler Handler for metrics.
+// Exemplars are only exposed in the OpenMetrics format, so it is enabled here.
 func MetricsHandler() http.Handler {
-	return promhttp.Handler()
+	return promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	)
 }
@@ -1,38 +1,144 @@
 package middleware
 
 import (
+	"bufio"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
-// Проверяем, что middleware учитывает запросы
+// Проверяем, что middleware учитывает запросы по шаблону маршрута, а не по сырому пути
 func TestMetricsMiddleware(t *testing.T) {
-	handler := MetricsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	r := chi.NewRouter()
+	r.Use(MetricsMiddleware)
+	r.Get("/test/{id}", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
-	}))
+		_, _ = w.Write([]byte("hello"))
+	})
 
-	req := httptest.NewRequest("GET", "/test", nil)
-	rec := httptest.NewRecorder()
+	for _, path := range []string{"/test/a", "/test/b"} {
+		req := httptest.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
 
-	handler.ServeHTTP(rec, req)
+		r.ServeHTTP(rec, req)
 
-	if rec.Result().StatusCode != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rec.Result().StatusCode)
+		if rec.Result().StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Result().StatusCode)
+		}
 	}
 
-	// Проверяем, что httpRequestsTotal увеличился
+	// Проверяем, что httpRequestsTotal увеличился для шаблона маршрута
 	m := &dto.Metric{}
-	err := httpRequestsTotal.WithLabelValues("/test", "GET", strconv.Itoa(http.StatusOK)).Write(m)
+	err := httpRequestsTotal.WithLabelValues("/test/{id}", "GET", strconv.Itoa(http.StatusOK)).Write(m)
 	if err != nil {
 		t.Fatalf("failed to get metric: %v", err)
 	}
 
+	if *m.Counter.Value != 2 {
+		t.Errorf("expected http_requests_total=2, got %v", *m.Counter.Value)
+	}
+
+	// Сырые пути не должны становиться метками
+	if n := testutil.CollectAndCount(httpRequestsTotal, "http_requests_total"); n != 1 {
+		t.Errorf("expected 1 series, got %d", n)
+	}
+
+	// Размер ответа учитывается
+	m = &dto.Metric{}
+	if err := httpResponseSize.WithLabelValues("/test/{id}", "GET").(interface{ Write(*dto.Metric) error }).Write(m); err != nil {
+		t.Fatalf("failed to get metric: %v", err)
+	}
+	if got := m.Histogram.GetSampleSum(); got != 10 {
+		t.Errorf("expected 10 response bytes, got %v", got)
+	}
+
+	if v := testutil.ToFloat64(httpRequestsInFlight); v != 0 {
+		t.Errorf("expected no requests in flight, got %v", v)
+	}
+}
+
+// Неизвестные пути попадают в одну серию
+func TestMetricsMiddlewareUnmatched(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(MetricsMiddleware)
+	r.Get("/known", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest("GET", "/random-path-123", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	m := &dto.Metric{}
+	err := httpRequestsTotal.WithLabelValues(unmatchedRoute, "GET", strconv.Itoa(http.StatusNotFound)).Write(m)
+	if err != nil {
+		t.Fatalf("failed to get metric: %v", err)
+	}
 	if *m.Counter.Value != 1 {
-		t.Errorf("expected http_requests_total=1, got %v", *m.Counter.Value)
+		t.Errorf("expected unmatched request to be counted once, got %v", *m.Counter.Value)
+	}
+}
+
+// Проверяем, что trace ID попадает в exemplar
+func TestMetricsExemplar(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(sdktrace.NewTracerProvider()) })
+
+	r := chi.NewRouter()
+	r.Use(TracingMiddleware)
+	r.Use(MetricsMiddleware)
+	r.Get("/exemplar", func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/exemplar", nil))
+
+	m := &dto.Metric{}
+	if err := httpRequestsTotal.WithLabelValues("/exemplar", "GET", "200").Write(m); err != nil {
+		t.Fatalf("failed to get metric: %v", err)
+	}
+	exemplar := m.Counter.GetExemplar()
+	if exemplar == nil || len(exemplar.Label) == 0 {
+		t.Fatalf("expected exemplar on counter")
+	}
+	if got := exemplar.Label[0].GetValue(); got != rec.Header().Get(TraceIDHeader) {
+		t.Errorf("expected exemplar trace_id %s, got %s", rec.Header().Get(TraceIDHeader), got)
+	}
+}
+
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+// Recorder пробрасывает Flusher и Hijacker
+func TestResponseRecorderInterfaces(t *testing.T) {
+	underlying := &hijackableRecorder{ResponseRecorder: httptest.NewRecorder()}
+	rr := &responseRecorder{ResponseWriter: underlying, statusCode: http.StatusOK}
+
+	rr.Flush()
+	if !underlying.Flushed {
+		t.Errorf("expected Flush to reach the underlying writer")
+	}
+
+	if _, _, err := rr.Hijack(); err != nil || !underlying.hijacked {
+		t.Errorf("expected Hijack to reach the underlying writer, err=%v", err)
+	}
+
+	plain := &responseRecorder{ResponseWriter: httptest.NewRecorder()}
+	if _, _, err := plain.Hijack(); err == nil {
+		t.Errorf("expected error when the underlying writer cannot be hijacked")
 	}
 }
 
@@ -50,4 +156,8 @@ func TestMetricsHandler(t *testing.T) {
 	if rec.Body.Len() == 0 {
 		t.Errorf("expected non-empty metrics body")
 	}
+
+	if !strings.Contains(rec.Body.String(), "http_requests_in_flight") {
+		t.Errorf("expected http_requests_in_flight in metrics output")
+	}
 }
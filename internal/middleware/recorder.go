@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+/*
+responseRecorder wraps http.ResponseWriter to capture the status code and the number of body bytes written.
+It passes Flush and Hijack through so that streaming responses keep working behind the middleware.
+*/
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	bytes      int
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	rr.statusCode = code
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += n
+	return n, err
+}
+
+// Flush implements http.Flusher.
+func (rr *responseRecorder) Flush() {
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (rr *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer %T does not support hijacking", rr.ResponseWriter)
+	}
+	conn, brw, err := h.Hijack()
+	if err == nil {
+		// The connection now speaks another protocol, e.g. WebSocket
+		rr.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
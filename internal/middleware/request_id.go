@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/zen-flo/url-shortener/internal/logger"
+)
+
+// RequestIDHeader is the header used to accept and return the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limits client-supplied IDs so they cannot bloat the logs.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+/*
+RequestID assigns an ID to every request.
+A valid X-Request-ID header sent by the client is reused, otherwise a random ID is generated.
+The ID is returned in the response header and attached to the request-scoped logger.
+*/
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = logger.WithContext(ctx, logger.FromContext(ctx).With("request_id", id))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/*
+GetRequestID returns the request ID stored in ctx, or an empty string.
+*/
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+/*
+validRequestID accepts non-empty IDs of printable ASCII characters only.
+*/
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+newRequestID generates a random 128-bit hex ID.
+*/
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
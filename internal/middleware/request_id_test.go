@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Проверяем, что middleware переиспользует корректный X-Request-ID и генерирует новый для некорректного
+func TestRequestID(t *testing.T) {
+	var got string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = GetRequestID(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		reuse    bool
+	}{
+		{"client supplied", "req-123", true},
+		{"missing", "", false},
+		{"invalid characters", "bad id\n", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.incoming != "" {
+			req.Header.Set(RequestIDHeader, tt.incoming)
+		}
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if got == "" {
+			t.Fatalf("%s: expected request ID in context", tt.name)
+		}
+		if rec.Header().Get(RequestIDHeader) != got {
+			t.Errorf("%s: response header %q does not match context ID %q", tt.name, rec.Header().Get(RequestIDHeader), got)
+		}
+		if tt.reuse && got != tt.incoming {
+			t.Errorf("%s: expected %q to be reused, got %q", tt.name, tt.incoming, got)
+		}
+		if !tt.reuse && got == tt.incoming {
+			t.Errorf("%s: expected a generated ID, got %q", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+
+	"github.com/zen-flo/url-shortener/internal/auth"
+)
+
+/*
+StreamToken lets streaming requests carry their token in the access_token query parameter,
+since browsers cannot set headers on EventSource and WebSocket. For server-sent event and
+WebSocket requests without a token header, the parameter is moved into the Authorization
+header; other requests must send their token in a header as usual.
+*/
+func StreamToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		token := query.Get("access_token")
+		streaming := strings.Contains(r.Header.Get("Accept"), "text/event-stream") || httpguts.HeaderValuesContainsToken(r.Header["Upgrade"], "websocket")
+		if token == "" || !streaming || auth.TokenFromRequest(r) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The token is removed from the URL, so that nothing downstream records it
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer "+token)
+		query.Del("access_token")
+		r.URL.RawQuery = query.Encode()
+		r.RequestURI = r.URL.RequestURI()
+		next.ServeHTTP(w, r)
+	})
+}
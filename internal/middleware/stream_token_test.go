@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Проверяем, что токен из параметра access_token принимается только для потоковых запросов
+func TestStreamToken(t *testing.T) {
+	var gotAuth, gotQuery string
+	handler := StreamToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth, gotQuery = r.Header.Get("Authorization"), r.URL.RawQuery
+	}))
+
+	tests := []struct {
+		name      string
+		headers   map[string]string
+		wantAuth  string
+		wantQuery string
+	}{
+		{"server-sent events", map[string]string{"Accept": "text/event-stream"}, "Bearer secret", "domain=brand.link"},
+		{"WebSocket", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket"}, "Bearer secret", "domain=brand.link"},
+		// Обычные запросы должны передавать токен в заголовке
+		{"plain request", nil, "", "access_token=secret&domain=brand.link"},
+		// Заголовок имеет приоритет над параметром
+		{"header wins", map[string]string{"Accept": "text/event-stream", "Authorization": "Bearer other"}, "Bearer other", "access_token=secret&domain=brand.link"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/urls/abc/events?access_token=secret&domain=brand.link", nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if gotAuth != tt.wantAuth || gotQuery != tt.wantQuery {
+			t.Errorf("%s: expected %q and %q, got %q and %q", tt.name, tt.wantAuth, tt.wantQuery, gotAuth, gotQuery)
+		}
+	}
+}
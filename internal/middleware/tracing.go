@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/zen-flo/url-shortener/internal/tracing"
+)
+
+// TraceIDHeader carries the trace ID of the request back to the client.
+const TraceIDHeader = "X-Trace-ID"
+
+/*
+TracingMiddleware starts a server span for every request.
+An incoming W3C traceparent header makes the span a child of the caller's trace.
+*/
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		if traceID := tracing.TraceID(ctx); traceID != "" {
+			w.Header().Set(TraceIDHeader, traceID)
+		}
+
+		rr := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rr, r.WithContext(ctx))
+
+		// The route pattern is known only after chi has matched the request
+		if pattern := routePattern(r); pattern != "" {
+			span.SetName(r.Method + " " + pattern)
+			span.SetAttributes(attribute.String("http.route", pattern))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", rr.statusCode))
+		if rr.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rr.statusCode))
+		}
+	})
+}
+
+/*
+routePattern returns the chi route pattern matched for the request, if any.
+*/
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
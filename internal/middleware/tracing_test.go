@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Проверяем, что middleware продолжает входящий trace и называет span по шаблону маршрута
+func TestTracingMiddleware(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(sdktrace.NewTracerProvider()) })
+
+	r := chi.NewRouter()
+	r.Use(TracingMiddleware)
+	r.Get("/urls/{short}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/urls/abc123", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+
+	r.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(TraceIDHeader); got != traceID {
+		t.Errorf("expected %s header %s, got %q", TraceIDHeader, traceID, got)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /urls/{short}" {
+		t.Errorf("expected span name %q, got %q", "GET /urls/{short}", span.Name)
+	}
+	if span.SpanContext.TraceID().String() != traceID {
+		t.Errorf("expected trace ID %s, got %s", traceID, span.SpanContext.TraceID())
+	}
+	if span.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("expected parent span 00f067aa0ba902b7, got %s", span.Parent.SpanID())
+	}
+}
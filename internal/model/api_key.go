@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// APIKey represents a credential issued to a client of the API.
+// Only a hash of the key is stored; the plaintext is shown once at creation.
+// @name APIKey
+type APIKey struct {
+	ID        int        `db:"id" json:"id"`                          // Unique identifier
+	Name      string     `db:"name" json:"name"`                      // Human-readable label
+	Prefix    string     `db:"prefix" json:"prefix"`                  // First characters of the key, for identification
+	Scopes    string     `db:"scopes" json:"scopes"`                  // Comma-separated scopes, e.g. "admin"
+	CreatedAt time.Time  `db:"created_at" json:"createdAt"`           // Timestamp when the key was created
+	RevokedAt *time.Time `db:"revoked_at" json:"revokedAt,omitempty"` // Timestamp when the key was revoked
+}
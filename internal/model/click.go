@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Click is a redirect served for a short link, as published to click feed subscribers.
+// @name Click
+type Click struct {
+	Domain  string    `json:"domain,omitempty"`              // Branded domain of the link, empty for the default domain
+	Short   string    `json:"short" example:"abc123"`        // Short code
+	Variant *int      `json:"variant,omitempty" example:"1"` // Index of the A/B variant the visitor got, nil without a split
+	Time    time.Time `json:"time"`                          // Time of the redirect
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// Domain is a branded short domain. Links created on it are resolved by the request Host.
+// @name Domain
+type Domain struct {
+	Name         string         `db:"name" json:"name" example:"brand.link"`                  // Host name without port
+	Defaults     DomainDefaults `db:"defaults" json:"defaults"`                               // Settings applied to new links on the domain
+	Interstitial int            `db:"interstitial" json:"interstitial,omitempty" example:"5"` // Countdown in seconds forced on every link of the domain, 0 if none
+	CreatedAt    time.Time      `db:"created_at" json:"createdAt"`                            // Timestamp when the domain was added
+}
+
+// DomainDefaults are applied to links created on a domain unless the request sets them.
+// @name DomainDefaults
+type DomainDefaults struct {
+	MaxClicks *int            `json:"maxClicks,omitempty"` // Redirect limit of new links
+	Options   RedirectOptions `json:"options,omitzero"`    // Redirect options of new links
+}
+
+// Value implements driver.Valuer.
+func (d DomainDefaults) Value() (driver.Value, error) {
+	if d.MaxClicks == nil && d.Options == (RedirectOptions{}) {
+		return "", nil
+	}
+	return jsonValue(d)
+}
+
+// Scan implements sql.Scanner.
+func (d *DomainDefaults) Scan(src interface{}) error {
+	*d = DomainDefaults{}
+	return scanJSON(src, d)
+}
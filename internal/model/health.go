@@ -0,0 +1,55 @@
+package model
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// LinkHealth is the result of the latest check of the destinations of a link.
+// A link is broken after several consecutive failed checks, so that a single outage does not count.
+// @name LinkHealth
+type LinkHealth struct {
+	URLID        int                `db:"url_id" json:"-"`
+	CheckedAt    time.Time          `db:"checked_at" json:"checkedAt,omitzero"`      // Time of the latest check, empty if the link was not checked yet
+	Broken       bool               `db:"broken" json:"broken"`                      // Whether a destination has been failing long enough to count as dead
+	BrokenSince  *time.Time         `db:"broken_since" json:"brokenSince,omitempty"` // When the link was first found broken
+	Failures     int                `db:"failures" json:"failures"`                  // Consecutive checks with a failing destination
+	Destinations DestinationsHealth `db:"destinations" json:"destinations,omitempty"`
+}
+
+// DestinationHealth is the result of checking one destination of a link.
+// @name DestinationHealth
+type DestinationHealth struct {
+	URL       string   `json:"url" example:"https://example.com/docs"`                  // Destination as configured on the link
+	OK        bool     `json:"ok"`                                                      // Whether the destination answered with a success, a redirect or 429
+	Status    int      `json:"status,omitempty" example:"200"`                          // Status code of the last response, 0 if there was none
+	LatencyMs int64    `json:"latencyMs" example:"120"`                                 // Time spent on the requests, including redirects
+	Redirects []string `json:"redirects,omitempty" example:"https://example.com/docs/"` // Locations followed, in order
+	Error     string   `json:"error,omitempty"`                                         // Why the request failed, e.g. a DNS or TLS error
+}
+
+// DestinationsHealth are stored as JSON in the link_health table.
+type DestinationsHealth []DestinationHealth
+
+// Value implements driver.Valuer.
+func (d DestinationsHealth) Value() (driver.Value, error) {
+	if len(d) == 0 {
+		return "", nil
+	}
+	return jsonValue(d)
+}
+
+// Scan implements sql.Scanner.
+func (d *DestinationsHealth) Scan(src interface{}) error {
+	*d = nil
+	return scanJSON(src, d)
+}
+
+// HideDestinations clears the destinations and the redirects followed from them, for callers who may not see where the link leads.
+func (h *LinkHealth) HideDestinations() {
+	for i := range h.Destinations {
+		h.Destinations[i].URL = ""
+		h.Destinations[i].Redirects = nil
+		h.Destinations[i].Error = ""
+	}
+}
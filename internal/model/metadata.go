@@ -0,0 +1,31 @@
+package model
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// Metadata describes the destination page of a link. It is fetched in the background after
+// the link is created, so it is empty at first and stays empty for pages that could not be read.
+// @name Metadata
+type Metadata struct {
+	Title       string    `json:"title,omitempty" example:"Example Domain"`             // <title>, or og:title if the page has none
+	Description string    `json:"description,omitempty"`                                // Meta description, or og:description
+	Image       string    `json:"image,omitempty" example:"https://example.com/og.png"` // Absolute og:image URL
+	SiteName    string    `json:"siteName,omitempty"`                                   // og:site_name
+	FetchedAt   time.Time `json:"fetchedAt,omitzero"`                                   // When the page was fetched
+}
+
+// Value implements driver.Valuer.
+func (m Metadata) Value() (driver.Value, error) {
+	if m == (Metadata{}) {
+		return "", nil
+	}
+	return jsonValue(m)
+}
+
+// Scan implements sql.Scanner.
+func (m *Metadata) Scan(src interface{}) error {
+	*m = Metadata{}
+	return scanJSON(src, m)
+}
@@ -0,0 +1,61 @@
+package model
+
+import "database/sql/driver"
+
+// Query forwarding policies of RedirectOptions.ForwardQuery.
+const (
+	ForwardQueryMerge    = "merge"    // Incoming parameters are added unless the destination already has them
+	ForwardQueryOverride = "override" // Incoming parameters replace those of the destination
+)
+
+// RedirectOptions control how the destination URL is built on redirect.
+// Parameters are applied in order: the destination's own query, then UTM, then the forwarded query.
+// @name RedirectOptions
+type RedirectOptions struct {
+	ForwardQuery string `json:"forwardQuery,omitempty" enums:"merge,override"` // Forward the incoming query string: merge or override; off if empty
+	PassPath     bool   `json:"passPath,omitempty"`                            // Append the path after the short code: /abc/x → destination/x
+	UTM          UTM    `json:"utm,omitzero"`                                  // UTM parameters set on the destination
+	Interstitial int    `json:"interstitial,omitempty" example:"5"`            // Seconds of a countdown page showing the destination before redirecting
+}
+
+// UTM holds campaign parameters. Values may use the placeholders {short}, {device}, {os},
+// {language}, {country}, {variant} and {date}, filled in per visitor.
+// @name UTM
+type UTM struct {
+	Source   string `json:"source,omitempty" example:"newsletter"` // utm_source
+	Medium   string `json:"medium,omitempty" example:"email"`      // utm_medium
+	Campaign string `json:"campaign,omitempty" example:"spring"`   // utm_campaign
+	Term     string `json:"term,omitempty"`                        // utm_term
+	Content  string `json:"content,omitempty" example:"{variant}"` // utm_content
+}
+
+// Params returns the non-empty UTM parameters by query parameter name.
+func (u UTM) Params() map[string]string {
+	params := map[string]string{}
+	for name, v := range map[string]string{
+		"utm_source":   u.Source,
+		"utm_medium":   u.Medium,
+		"utm_campaign": u.Campaign,
+		"utm_term":     u.Term,
+		"utm_content":  u.Content,
+	} {
+		if v != "" {
+			params[name] = v
+		}
+	}
+	return params
+}
+
+// Value implements driver.Valuer.
+func (o RedirectOptions) Value() (driver.Value, error) {
+	if o == (RedirectOptions{}) {
+		return "", nil
+	}
+	return jsonValue(o)
+}
+
+// Scan implements sql.Scanner.
+func (o *RedirectOptions) Scan(src interface{}) error {
+	*o = RedirectOptions{}
+	return scanJSON(src, o)
+}
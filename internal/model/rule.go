@@ -0,0 +1,76 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Rule sends visitors that match all of its conditions to Destination.
+// Within one condition any of the listed values may match; empty conditions match everyone.
+// @name Rule
+//
+//	@example {
+//	  "destination": "https://apps.apple.com/app/id123",
+//	  "os": ["ios"]
+//	}
+type Rule struct {
+	Destination string            `json:"destination"`        // URL to redirect to when the rule matches
+	Device      []string          `json:"device,omitempty"`   // Device class: mobile, tablet, desktop or bot
+	OS          []string          `json:"os,omitempty"`       // Operating system: ios, android, windows, macos, chromeos or linux
+	Language    []string          `json:"language,omitempty"` // Preferred language from Accept-Language, e.g. "de" or "pt-BR"
+	Country     []string          `json:"country,omitempty"`  // ISO 3166-1 alpha-2 country of the client address
+	Time        *TimeWindow       `json:"time,omitempty"`     // Time window in which the rule applies
+	Query       map[string]string `json:"query,omitempty"`    // Query parameters that must equal the value; "*" only requires presence
+}
+
+// TimeWindow restricts a rule to days of the week and a daily time range.
+// @name TimeWindow
+type TimeWindow struct {
+	Days     []string `json:"days,omitempty"`     // Days of the week: mon, tue, wed, thu, fri, sat, sun
+	From     string   `json:"from,omitempty"`     // Start of the range as HH:MM, inclusive
+	To       string   `json:"to,omitempty"`       // End of the range as HH:MM, exclusive; earlier than From wraps past midnight
+	TimeZone string   `json:"timezone,omitempty"` // IANA time zone, UTC if empty
+}
+
+// Rules is the ordered list of redirect rules of a link, stored as JSON in the urls table.
+// The first matching rule wins; the original URL is the fallback.
+type Rules []Rule
+
+// Value implements driver.Valuer.
+func (r Rules) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return "", nil
+	}
+	return jsonValue(r)
+}
+
+// Scan implements sql.Scanner.
+func (r *Rules) Scan(src interface{}) error {
+	*r = nil
+	return scanJSON(src, r)
+}
+
+// jsonValue encodes v for a TEXT column.
+func jsonValue(v interface{}) (driver.Value, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// scanJSON decodes a TEXT column into dest. An empty column leaves dest unchanged.
+func scanJSON(src interface{}, dest interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dest)
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, dest)
+}
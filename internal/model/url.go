@@ -9,11 +9,62 @@ import "time"
 //	  "id": 1,
 //	  "original": "https://example.com",
 //	  "short": "abc123",
+//	  "shortUrl": "https://brand.link/abc123",
 //	  "createdAt": "2025-10-30T12:00:00Z"
 //	}
 type URL struct {
-	ID        int       `db:"id" json:"id"`                // Unique identifier
-	Original  string    `db:"original" json:"original"`    // Original URL
-	Short     string    `db:"short" json:"short"`          // Shortened URL
-	CreatedAt time.Time `db:"created_at" json:"createdAt"` // Timestamp when URL was created
+	ID        int       `db:"id" json:"id"`                   // Unique identifier
+	Domain    string    `db:"domain" json:"domain,omitempty"` // Branded domain of the link, empty for the default domain
+	Original  string    `db:"original" json:"original"`       // Original URL
+	Short     string    `db:"short" json:"short"`             // Short code, unique per domain
+	ShortURL  string    `db:"-" json:"shortUrl,omitempty"`    // Full short link, e.g. https://brand.link/abc123
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`    // Timestamp when URL was created
+	Owner     string    `db:"owner" json:"owner,omitempty"`   // Display name of the authenticated creator, empty if anonymous
+
+	PasswordHash string `db:"password_hash" json:"-"`               // bcrypt hash of the link password, empty if none
+	Protected    bool   `db:"protected" json:"protected,omitempty"` // Whether the redirect asks for a password
+
+	Clicks     int  `db:"clicks" json:"clicks"`                    // Number of redirects served
+	MaxClicks  *int `db:"max_clicks" json:"maxClicks,omitempty"`   // Redirect limit, nil if unlimited
+	ClicksLeft *int `db:"clicks_left" json:"clicksLeft,omitempty"` // Redirects left before the link is gone
+
+	Rules    Rules    `db:"rules" json:"rules,omitempty"`       // Conditional redirects evaluated before falling back to Original
+	Variants Variants `db:"variants" json:"variants,omitempty"` // Weighted A/B split used instead of Original
+
+	Options            RedirectOptions `db:"options" json:"options,omitzero"` // Query forwarding, UTM parameters, path pass-through and interstitial
+	DomainInterstitial int             `db:"domain_interstitial" json:"-"`    // Interstitial forced by the domain of the link
+
+	Metadata Metadata `db:"metadata" json:"metadata,omitzero"` // Title, description and image of the destination page
+
+	Disabled       bool   `db:"disabled" json:"disabled,omitempty"`              // Whether visitors see a warning instead of the redirect
+	DisabledReason string `db:"disabled_reason" json:"disabledReason,omitempty"` // Why the link was disabled, e.g. the blocklist entry it matched
+}
+
+// Countdown returns the seconds of the interstitial shown before redirecting, 0 if there is none.
+// The longer of the link and domain settings applies.
+func (u *URL) Countdown() int {
+	return max(u.Options.Interstitial, u.DomainInterstitial)
+}
+
+// HideDestinations clears everything that reveals where the link leads, for callers who may not see it.
+func (u *URL) HideDestinations() {
+	u.Original = ""
+	u.Rules = nil
+	u.Variants = nil
+	u.Metadata = Metadata{}
+}
+
+// Destinations lists the URLs the link may redirect to: the original URL, then those of its rules and variants.
+func (u *URL) Destinations() []string {
+	var urls []string
+	if u.Original != "" {
+		urls = append(urls, u.Original)
+	}
+	for _, r := range u.Rules {
+		urls = append(urls, r.Destination)
+	}
+	for _, v := range u.Variants {
+		urls = append(urls, v.Destination)
+	}
+	return urls
 }
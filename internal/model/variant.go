@@ -0,0 +1,53 @@
+package model
+
+import "database/sql/driver"
+
+// Variant is one destination of an A/B split with its share of the traffic.
+// @name Variant
+type Variant struct {
+	Name        string `json:"name,omitempty"` // Label shown in stats, e.g. "A"
+	Destination string `json:"destination"`    // URL to redirect to
+	Weight      int    `json:"weight"`         // Relative share of visitors, e.g. 70 and 30
+}
+
+// Variants split the traffic of a link that no redirect rule matched. They replace the
+// original URL as the destination. Stored as JSON in the urls table.
+type Variants []Variant
+
+// Value implements driver.Valuer.
+func (v Variants) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return "", nil
+	}
+	return jsonValue(v)
+}
+
+// Scan implements sql.Scanner.
+func (v *Variants) Scan(src interface{}) error {
+	*v = nil
+	return scanJSON(src, v)
+}
+
+// Stats summarizes the traffic of a short link.
+// @name Stats
+type Stats struct {
+	Short      string         `json:"short"`                // Short code
+	Clicks     int            `json:"clicks"`               // Redirects served
+	MaxClicks  *int           `json:"maxClicks,omitempty"`  // Redirect limit, nil if unlimited
+	ClicksLeft *int           `json:"clicksLeft,omitempty"` // Redirects left before the link is gone
+	Variants   []VariantStats `json:"variants,omitempty"`   // Clicks per A/B variant
+}
+
+// HideDestinations clears the destinations of the variants, for callers who may not see where the link leads.
+func (s *Stats) HideDestinations() {
+	for i := range s.Variants {
+		s.Variants[i].Destination = ""
+	}
+}
+
+// VariantStats is the click count of one A/B variant.
+// @name VariantStats
+type VariantStats struct {
+	Variant
+	Clicks int `json:"clicks"` // Redirects to this variant since the variants were last changed
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// Statuses of a WebhookDelivery.
+const (
+	DeliveryPending   = "pending"   // Waiting for its next attempt
+	DeliveryDelivered = "delivered" // Acknowledged by the subscriber with a 2xx response
+	DeliveryDead      = "dead"      // Given up after the last retry, kept for redelivery
+)
+
+// Webhook is a subscription to link lifecycle events, delivered as signed POST requests.
+// The signing secret is shown once at creation.
+// @name Webhook
+type Webhook struct {
+	ID        int           `db:"id" json:"id"`                                                 // Unique identifier
+	URL       string        `db:"url" json:"url" example:"https://crm.example.com/hooks/links"` // Endpoint receiving the events
+	Events    WebhookEvents `db:"events" json:"events" example:"link.created,link.clicked"`     // Event types delivered, "*" for all
+	Active    bool          `db:"active" json:"active"`                                         // Whether new events are delivered
+	Secret    string        `db:"secret" json:"-"`                                              // HMAC-SHA256 key signing the payloads
+	CreatedAt time.Time     `db:"created_at" json:"createdAt"`                                  // Timestamp when the subscription was created
+}
+
+// Matches reports whether the subscription receives events of the given type.
+func (w *Webhook) Matches(event string) bool {
+	for _, e := range w.Events {
+		if e == "*" || e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvents are stored as JSON in the webhooks table.
+type WebhookEvents []string
+
+// Value implements driver.Valuer.
+func (e WebhookEvents) Value() (driver.Value, error) {
+	return jsonValue(e)
+}
+
+// Scan implements sql.Scanner.
+func (e *WebhookEvents) Scan(src interface{}) error {
+	*e = nil
+	return scanJSON(src, e)
+}
+
+// WebhookEvent is the body of a webhook request. ID stays the same across retries and
+// redeliveries, so that subscribers can drop duplicates.
+// @name WebhookEvent
+type WebhookEvent struct {
+	ID        string      `json:"id" example:"evt_3q2v9KxPZ0m8"` // Unique identifier of the event
+	Event     string      `json:"event" example:"link.created"`  // Event type
+	CreatedAt time.Time   `json:"createdAt"`                     // Time the event happened
+	Link      *URL        `json:"link"`                          // The link as of the event; deleted links as before deletion
+	Health    *LinkHealth `json:"health,omitempty"`              // Check result of link.broken and link.recovered events
+}
+
+// WebhookDelivery is one event queued for one subscription.
+// @name WebhookDelivery
+type WebhookDelivery struct {
+	ID            int        `db:"id" json:"id"`                                          // Unique identifier
+	WebhookID     int        `db:"webhook_id" json:"webhookId"`                           // Subscription the event is delivered to
+	Event         string     `db:"event" json:"event" example:"link.clicked"`             // Event type
+	Payload       RawJSON    `db:"payload" json:"payload" swaggertype:"object"`           // Request body, a WebhookEvent
+	Status        string     `db:"status" json:"status" enums:"pending,delivered,dead"`   // Delivery state
+	Attempts      int        `db:"attempts" json:"attempts"`                              // Requests made so far
+	NextAttemptAt time.Time  `db:"next_attempt_at" json:"nextAttemptAt"`                  // When a pending delivery is tried next
+	LastStatus    int        `db:"last_status" json:"lastStatus,omitempty" example:"503"` // Status code of the last response, 0 if there was none
+	LastError     string     `db:"last_error" json:"lastError,omitempty"`                 // Why the last attempt failed
+	CreatedAt     time.Time  `db:"created_at" json:"createdAt"`                           // Timestamp when the event was queued
+	DeliveredAt   *time.Time `db:"delivered_at" json:"deliveredAt,omitempty"`             // Timestamp of the successful attempt
+}
+
+// RawJSON is a JSON document stored as TEXT and embedded as is in API responses.
+type RawJSON []byte
+
+// MarshalJSON implements json.Marshaler.
+func (r RawJSON) MarshalJSON() ([]byte, error) {
+	if len(r) == 0 {
+		return []byte("null"), nil
+	}
+	return r, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *RawJSON) UnmarshalJSON(b []byte) error {
+	*r = append(RawJSON(nil), b...)
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (r RawJSON) Value() (driver.Value, error) {
+	return string(r), nil
+}
+
+// Scan implements sql.Scanner.
+func (r *RawJSON) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*r = nil
+	case string:
+		*r = RawJSON(v)
+	case []byte:
+		*r = append(RawJSON(nil), v...)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, r)
+	}
+	return nil
+}
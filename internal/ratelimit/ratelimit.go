@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+Limiter allows at most Limit events per key within a fixed Window.
+It keeps state in memory, so limits are per process and reset on restart.
+*/
+type Limiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+/*
+New creates a limiter allowing limit events per key and window.
+*/
+func New(limit int, per time.Duration) *Limiter {
+	return &Limiter{Limit: limit, Window: per, windows: map[string]*window{}, now: time.Now}
+}
+
+/*
+Allow records an event for key and reports whether it is within the limit.
+When it is not, the returned duration is the time until the window resets.
+*/
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.Window {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= l.Limit {
+		return false, w.start.Add(l.Window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+/*
+Reset forgets the events recorded for key, e.g. after a successful attempt.
+*/
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.windows, key)
+}
+
+/*
+sweep drops expired windows at most once per window so that the map does not grow without bound.
+*/
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.Window {
+		return
+	}
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.Window {
+			delete(l.windows, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := New(2, time.Minute)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("attempt %d should be allowed", i+1)
+		}
+	}
+	ok, retry := l.Allow("a")
+	if ok || retry != time.Minute {
+		t.Fatalf("expected third attempt to be rejected for a minute, got %v %v", ok, retry)
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("keys must be limited independently")
+	}
+
+	l.Reset("a")
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("expected Reset to clear the limit")
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("expected the limit to reset after the window")
+	}
+	if len(l.windows) != 1 {
+		t.Errorf("expected expired windows to be swept, got %d", len(l.windows))
+	}
+}
@@ -0,0 +1,85 @@
+package rules
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+ClassifyUserAgent derives the device class and operating system from a User-Agent header.
+It is a heuristic meant for routing, not an exhaustive parser; unknown systems return "".
+*/
+func ClassifyUserAgent(ua string) (device, os string) {
+	lower := strings.ToLower(ua)
+
+	switch {
+	case strings.Contains(lower, "iphone"), strings.Contains(lower, "ipad"), strings.Contains(lower, "ipod"):
+		os = "ios"
+	case strings.Contains(lower, "android"):
+		os = "android"
+	case strings.Contains(lower, "windows"):
+		os = "windows"
+	case strings.Contains(lower, "cros"):
+		os = "chromeos"
+	case strings.Contains(lower, "macintosh"), strings.Contains(lower, "mac os x"):
+		os = "macos"
+	case strings.Contains(lower, "linux"):
+		os = "linux"
+	}
+
+	switch {
+	case lower == "", containsAny(lower, "bot", "crawler", "spider", "slurp", "facebookexternalhit", "curl/", "wget/"):
+		device = "bot"
+	case strings.Contains(lower, "ipad"), strings.Contains(lower, "tablet"),
+		os == "android" && !strings.Contains(lower, "mobile"):
+		device = "tablet"
+	case strings.Contains(lower, "mobi"), strings.Contains(lower, "iphone"), strings.Contains(lower, "ipod"):
+		device = "mobile"
+	default:
+		device = "desktop"
+	}
+	return device, os
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+PreferredLanguage returns the lower-cased language tag with the highest quality
+in an Accept-Language header, or "" if there is none.
+*/
+func PreferredLanguage(header string) string {
+	type tag struct {
+		lang string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang == "" || lang == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tag{lang, q})
+		}
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	return tags[0].lang
+}
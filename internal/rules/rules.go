@@ -0,0 +1,309 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/safehttp"
+)
+
+// Values accepted by the device, os and time-window day conditions.
+var (
+	Devices = []string{"mobile", "tablet", "desktop", "bot"}
+	Systems = []string{"ios", "android", "windows", "macos", "chromeos", "linux"}
+	Days    = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// MaxRules limits the number of rules per link to keep redirects cheap.
+const MaxRules = 50
+
+/*
+CountryResolver maps a client address to an ISO 3166-1 alpha-2 country code,
+or "" if it is unknown.
+*/
+type CountryResolver interface {
+	Country(addr netip.Addr) string
+}
+
+/*
+Engine evaluates the redirect rules of a link against an incoming request.
+*/
+type Engine struct {
+	// Countries resolves client addresses for country conditions. Without it they never match.
+	Countries CountryResolver
+
+	now func() time.Time
+}
+
+/*
+NewEngine creates a rule engine. countries may be nil.
+*/
+func NewEngine(countries CountryResolver) *Engine {
+	return &Engine{Countries: countries, now: time.Now}
+}
+
+/*
+Decision is the outcome of evaluating a link for a request.
+*/
+type Decision struct {
+	Destination string
+	// Rule is the index of the matching rule, or -1.
+	Rule int
+	// Variant is the index of the chosen A/B variant, or -1.
+	Variant int
+
+	visitor *visitor
+}
+
+/*
+Resolve picks the destination for the request: the first matching rule, otherwise
+a weighted A/B variant, otherwise the original URL of the link.
+*/
+func (e *Engine) Resolve(r *http.Request, link *model.URL) Decision {
+	var v *visitor
+	if len(link.Rules) > 0 {
+		v = e.visitor(r)
+		for i, rule := range link.Rules {
+			if v.matches(rule) {
+				return Decision{Destination: rule.Destination, Rule: i, Variant: -1, visitor: v}
+			}
+		}
+	}
+	if len(link.Variants) > 0 {
+		i := PickVariant(r, link)
+		return Decision{Destination: link.Variants[i].Destination, Rule: -1, Variant: i, visitor: v}
+	}
+	return Decision{Destination: link.Original, Rule: -1, Variant: -1, visitor: v}
+}
+
+/*
+Destination returns the destination chosen by Resolve.
+*/
+func (e *Engine) Destination(r *http.Request, link *model.URL) string {
+	return e.Resolve(r, link).Destination
+}
+
+/*
+visitor holds the request properties the conditions are checked against, computed once per request.
+*/
+type visitor struct {
+	device, os string
+	language   string
+	country    func() string
+	query      url.Values
+	now        time.Time
+}
+
+func (e *Engine) visitor(r *http.Request) *visitor {
+	device, os := ClassifyUserAgent(r.UserAgent())
+	v := &visitor{
+		device:   device,
+		os:       os,
+		language: PreferredLanguage(r.Header.Get("Accept-Language")),
+		query:    r.URL.Query(),
+		now:      e.now(),
+	}
+
+	// Country lookups are the expensive part, so they run at most once and only when a rule needs them
+	var country *string
+	v.country = func() string {
+		if country == nil {
+			c := ""
+			if e.Countries != nil {
+				if addr, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+					c = e.Countries.Country(addr.Addr().Unmap())
+				} else if addr, err := netip.ParseAddr(r.RemoteAddr); err == nil {
+					c = e.Countries.Country(addr.Unmap())
+				}
+			}
+			country = &c
+		}
+		return *country
+	}
+	return v
+}
+
+func (v *visitor) matches(rule model.Rule) bool {
+	if len(rule.Device) > 0 && !contains(rule.Device, v.device) {
+		return false
+	}
+	if len(rule.OS) > 0 && !contains(rule.OS, v.os) {
+		return false
+	}
+	if len(rule.Language) > 0 && !matchLanguage(rule.Language, v.language) {
+		return false
+	}
+	for key, want := range rule.Query {
+		if !v.query.Has(key) || (want != "*" && v.query.Get(key) != want) {
+			return false
+		}
+	}
+	if rule.Time != nil && !inWindow(*rule.Time, v.now) {
+		return false
+	}
+	if len(rule.Country) > 0 && !contains(rule.Country, v.country()) {
+		return false
+	}
+	return true
+}
+
+/*
+matchLanguage reports whether the visitor language matches one of the rule languages.
+A bare language such as "en" also matches regional variants such as "en-GB".
+*/
+func matchLanguage(languages []string, lang string) bool {
+	if lang == "" {
+		return false
+	}
+	for _, l := range languages {
+		l = strings.ToLower(l)
+		if lang == l || strings.HasPrefix(lang, l+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+inWindow reports whether t falls into the time window, evaluated in the window's time zone.
+The window was validated when the rules were saved.
+*/
+func inWindow(w model.TimeWindow, t time.Time) bool {
+	loc := time.UTC
+	if w.TimeZone != "" {
+		if l, err := time.LoadLocation(w.TimeZone); err == nil {
+			loc = l
+		}
+	}
+	t = t.In(loc)
+
+	if len(w.Days) > 0 && !contains(w.Days, Days[t.Weekday()]) {
+		return false
+	}
+	if w.From == "" && w.To == "" {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	from, _ := parseClock(w.From)
+	to := 24 * 60
+	if w.To != "" {
+		to, _ = parseClock(w.To)
+	}
+	if from <= to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+/*
+parseClock parses HH:MM into minutes since midnight.
+*/
+func parseClock(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+/*
+Validate checks the rules and normalizes their values to lower case (country codes to upper case).
+*/
+func Validate(rs model.Rules) error {
+	if len(rs) > MaxRules {
+		return fmt.Errorf("at most %d rules are allowed", MaxRules)
+	}
+	for i := range rs {
+		if err := validateRule(&rs[i]); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateRule(r *model.Rule) error {
+	if r.Destination == "" {
+		return errors.New("destination is required")
+	}
+	if err := validateDestination(r.Destination); err != nil {
+		return err
+	}
+
+	if err := normalizeEnum("device", r.Device, Devices); err != nil {
+		return err
+	}
+	if err := normalizeEnum("os", r.OS, Systems); err != nil {
+		return err
+	}
+	for i, l := range r.Language {
+		r.Language[i] = strings.ToLower(strings.TrimSpace(l))
+		if r.Language[i] == "" {
+			return errors.New("empty language")
+		}
+	}
+	for i, c := range r.Country {
+		r.Country[i] = strings.ToUpper(strings.TrimSpace(c))
+		if len(r.Country[i]) != 2 {
+			return fmt.Errorf("country %q is not an ISO 3166-1 alpha-2 code", c)
+		}
+	}
+	for key := range r.Query {
+		if key == "" {
+			return errors.New("empty query parameter name")
+		}
+	}
+
+	if w := r.Time; w != nil {
+		if err := normalizeEnum("day", w.Days, Days); err != nil {
+			return err
+		}
+		if _, err := parseClock(w.From); err != nil {
+			return err
+		}
+		if _, err := parseClock(w.To); err != nil {
+			return err
+		}
+		if w.TimeZone != "" {
+			if _, err := time.LoadLocation(w.TimeZone); err != nil {
+				return fmt.Errorf("unknown time zone %q", w.TimeZone)
+			}
+		}
+	}
+	return nil
+}
+
+func validateDestination(destination string) error {
+	if u, err := url.Parse(destination); err != nil || safehttp.CheckURL(u) != nil {
+		return fmt.Errorf("destination %q is not an absolute http or https URL", destination)
+	}
+	return nil
+}
+
+func normalizeEnum(name string, values, allowed []string) error {
+	for i, v := range values {
+		values[i] = strings.ToLower(strings.TrimSpace(v))
+		if !contains(allowed, values[i]) {
+			return fmt.Errorf("unknown %s %q, expected one of %s", name, v, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
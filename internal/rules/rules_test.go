@@ -0,0 +1,150 @@
+package rules
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+const (
+	uaIPhone  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+	uaAndroid = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36"
+	uaTablet  = "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+	uaMac     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 Safari/605.1.15"
+	uaBot     = "Googlebot/2.1 (+http://www.google.com/bot.html)"
+)
+
+type staticCountries map[string]string
+
+func (s staticCountries) Country(addr netip.Addr) string {
+	return s[addr.String()]
+}
+
+func TestClassifyUserAgent(t *testing.T) {
+	tests := []struct {
+		ua, device, os string
+	}{
+		{uaIPhone, "mobile", "ios"},
+		{uaAndroid, "mobile", "android"},
+		{uaTablet, "tablet", "android"},
+		{uaMac, "desktop", "macos"},
+		{uaBot, "bot", ""},
+		{"", "bot", ""},
+	}
+	for _, tt := range tests {
+		device, os := ClassifyUserAgent(tt.ua)
+		if device != tt.device || os != tt.os {
+			t.Errorf("ClassifyUserAgent(%q) = %s/%s, want %s/%s", tt.ua, device, os, tt.device, tt.os)
+		}
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := map[string]string{
+		"de-DE,de;q=0.9,en;q=0.8": "de-de",
+		"en;q=0.5, fr":            "fr",
+		"*":                       "",
+		"":                        "",
+	}
+	for header, want := range tests {
+		if got := PreferredLanguage(header); got != want {
+			t.Errorf("PreferredLanguage(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	link := &model.URL{
+		Original: "https://example.com",
+		Rules: model.Rules{
+			{Destination: "https://beta.example.com", Query: map[string]string{"beta": "*"}},
+			{Destination: "https://apps.apple.com/app", OS: []string{"ios"}},
+			{Destination: "https://play.google.com/app", OS: []string{"android"}},
+			{Destination: "https://example.de", Language: []string{"de"}, Country: []string{"DE", "AT"}},
+			{Destination: "https://example.com/night", Time: &model.TimeWindow{From: "22:00", To: "06:00", TimeZone: "UTC"}},
+		},
+	}
+	if err := Validate(link.Rules); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	e := NewEngine(staticCountries{"203.0.113.1": "DE"})
+	e.now = func() time.Time { return time.Date(2025, 10, 30, 12, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name, target, ua, lang, addr, want string
+	}{
+		{"ios", "/app", uaIPhone, "", "192.0.2.1:1234", "https://apps.apple.com/app"},
+		{"android", "/app", uaAndroid, "", "192.0.2.1:1234", "https://play.google.com/app"},
+		{"query wins by order", "/app?beta=1", uaIPhone, "", "192.0.2.1:1234", "https://beta.example.com"},
+		{"language and country", "/app", uaMac, "de-AT,de;q=0.9", "203.0.113.1:1234", "https://example.de"},
+		{"language without country", "/app", uaMac, "de", "192.0.2.1:1234", "https://example.com"},
+		{"fallback", "/app", uaMac, "en-US", "192.0.2.1:1234", "https://example.com"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.target, nil)
+		r.Header.Set("User-Agent", tt.ua)
+		r.Header.Set("Accept-Language", tt.lang)
+		r.RemoteAddr = tt.addr
+		if got := e.Destination(r, link); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// The night window wraps past midnight
+	e.now = func() time.Time { return time.Date(2025, 10, 30, 23, 30, 0, 0, time.UTC) }
+	r := httptest.NewRequest("GET", "/app", nil)
+	r.Header.Set("User-Agent", uaMac)
+	if got := e.Destination(r, link); got != "https://example.com/night" {
+		t.Errorf("expected the night rule at 23:30, got %s", got)
+	}
+}
+
+func TestInWindow(t *testing.T) {
+	// Thursday 2025-10-30 09:30 in Berlin is 08:30 UTC
+	now := time.Date(2025, 10, 30, 8, 30, 0, 0, time.UTC)
+	tests := []struct {
+		window model.TimeWindow
+		want   bool
+	}{
+		{model.TimeWindow{Days: []string{"thu"}}, true},
+		{model.TimeWindow{Days: []string{"sat", "sun"}}, false},
+		{model.TimeWindow{From: "09:00", To: "17:00", TimeZone: "Europe/Berlin"}, true},
+		{model.TimeWindow{From: "09:00", To: "17:00"}, false},
+		{model.TimeWindow{From: "08:30"}, true},
+	}
+	for _, tt := range tests {
+		if got := inWindow(tt.window, now); got != tt.want {
+			t.Errorf("inWindow(%+v) = %v, want %v", tt.window, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := model.Rules{{Destination: "https://example.com", OS: []string{" iOS "}, Country: []string{"de"}}}
+	if err := Validate(valid); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if valid[0].OS[0] != "ios" || valid[0].Country[0] != "DE" {
+		t.Errorf("expected normalized values, got %+v", valid[0])
+	}
+
+	invalid := []model.Rule{
+		{},
+		{Destination: "/relative"},
+		{Destination: "javascript:alert(document.cookie)"},
+		{Destination: "data:text/html,<script>alert(1)</script>"},
+		{Destination: "https://example.com", Device: []string{"phone"}},
+		{Destination: "https://example.com", Country: []string{"Germany"}},
+		{Destination: "https://example.com", Time: &model.TimeWindow{From: "9am"}},
+		{Destination: "https://example.com", Time: &model.TimeWindow{TimeZone: "Mars/Olympus"}},
+	}
+	for _, rule := range invalid {
+		if err := Validate(model.Rules{rule}); err == nil {
+			t.Errorf("expected an error for %+v", rule)
+		}
+	}
+}
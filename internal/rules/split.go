@@ -0,0 +1,98 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+// MaxVariants limits the number of A/B variants per link.
+const MaxVariants = 20
+
+/*
+VariantCookie returns the name of the cookie remembering the variant a visitor was assigned for a link.
+*/
+func VariantCookie(short string) string {
+	return "ab_" + short
+}
+
+/*
+PickVariant assigns the visitor to a variant of the link. A visitor keeps the variant stored in
+their cookie; without one the assignment is derived from a hash of the client address and user agent,
+so it is stable even for clients that drop cookies. Variants must be non-empty.
+*/
+func PickVariant(r *http.Request, link *model.URL) int {
+	if c, err := r.Cookie(VariantCookie(link.Short)); err == nil {
+		if i, err := strconv.Atoi(c.Value); err == nil && i >= 0 && i < len(link.Variants) && link.Variants[i].Weight > 0 {
+			return i
+		}
+	}
+
+	total := 0
+	for _, v := range link.Variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(link.Short + "|" + clientID(r)))
+	bucket := int(h.Sum64() % uint64(total))
+	for i, v := range link.Variants {
+		if bucket < v.Weight {
+			return i
+		}
+		bucket -= v.Weight
+	}
+	return len(link.Variants) - 1
+}
+
+/*
+clientID identifies a client for sticky assignment without storing anything.
+*/
+func clientID(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host + "|" + r.UserAgent()
+}
+
+/*
+ValidateVariants checks that every variant has an absolute destination and a positive weight.
+Unnamed variants are labelled A, B, C and so on.
+*/
+func ValidateVariants(vs model.Variants) error {
+	if len(vs) > MaxVariants {
+		return fmt.Errorf("at most %d variants are allowed", MaxVariants)
+	}
+	for i := range vs {
+		v := &vs[i]
+		if v.Destination == "" {
+			return fmt.Errorf("variant %d: %w", i+1, errors.New("destination is required"))
+		}
+		if err := validateDestination(v.Destination); err != nil {
+			return fmt.Errorf("variant %d: %w", i+1, err)
+		}
+		if v.Weight <= 0 {
+			return fmt.Errorf("variant %d: weight must be positive", i+1)
+		}
+		if v.Name == "" {
+			v.Name = variantName(i)
+		}
+	}
+	return nil
+}
+
+func variantName(i int) string {
+	if i < 26 {
+		return string(rune('A' + i))
+	}
+	return strconv.Itoa(i + 1)
+}
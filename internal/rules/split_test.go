@@ -0,0 +1,85 @@
+package rules
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+func TestPickVariant(t *testing.T) {
+	link := &model.URL{
+		Short: "abc123",
+		Variants: model.Variants{
+			{Destination: "https://example.com/a", Weight: 70},
+			{Destination: "https://example.com/b", Weight: 30},
+		},
+	}
+
+	// Weights are honored across many clients
+	counts := make([]int, 2)
+	for i := 0; i < 10000; i++ {
+		r := httptest.NewRequest("GET", "/abc123", nil)
+		r.RemoteAddr = fmt.Sprintf("10.0.%d.%d:1234", i/256, i%256)
+		counts[PickVariant(r, link)]++
+	}
+	if counts[0] < 6500 || counts[0] > 7500 {
+		t.Errorf("expected about 70%% for variant A, got %v", counts)
+	}
+
+	// The same client always gets the same variant
+	r := httptest.NewRequest("GET", "/abc123", nil)
+	first := PickVariant(r, link)
+	for i := 0; i < 10; i++ {
+		if got := PickVariant(r, link); got != first {
+			t.Fatalf("expected a sticky assignment, got %d and %d", first, got)
+		}
+	}
+
+	// The cookie wins over the hash, unless it is out of range
+	for value, want := range map[string]int{"1": 1, "0": 0, "7": first, "x": first} {
+		r := httptest.NewRequest("GET", "/abc123", nil)
+		r.Header.Set("Cookie", VariantCookie("abc123")+"="+value)
+		if got := PickVariant(r, link); got != want {
+			t.Errorf("cookie %q: expected variant %d, got %d", value, want, got)
+		}
+	}
+}
+
+func TestResolvePrefersRules(t *testing.T) {
+	link := &model.URL{
+		Short:    "abc123",
+		Original: "https://example.com",
+		Rules:    model.Rules{{Destination: "https://example.com/ios", OS: []string{"ios"}}},
+		Variants: model.Variants{{Destination: "https://example.com/a", Weight: 1}},
+	}
+	e := NewEngine(nil)
+
+	r := httptest.NewRequest("GET", "/abc123", nil)
+	r.Header.Set("User-Agent", uaIPhone)
+	if d := e.Resolve(r, link); d.Rule != 0 || d.Variant != -1 || d.Destination != "https://example.com/ios" {
+		t.Errorf("expected the rule to win, got %+v", d)
+	}
+
+	r.Header.Set("User-Agent", uaMac)
+	if d := e.Resolve(r, link); d.Variant != 0 || d.Destination != "https://example.com/a" {
+		t.Errorf("expected the variant as fallback, got %+v", d)
+	}
+}
+
+func TestValidateVariants(t *testing.T) {
+	vs := model.Variants{{Destination: "https://example.com/a", Weight: 1}, {Name: "control", Destination: "https://example.com/b", Weight: 1}}
+	if err := ValidateVariants(vs); err != nil {
+		t.Fatalf("ValidateVariants failed: %v", err)
+	}
+	if vs[0].Name != "A" || vs[1].Name != "control" {
+		t.Errorf("expected default names for unnamed variants, got %+v", vs)
+	}
+
+	for _, v := range []model.Variant{{Weight: 1}, {Destination: "https://example.com", Weight: 0}, {Destination: "relative", Weight: 1}} {
+		if err := ValidateVariants(model.Variants{v}); err == nil {
+			t.Errorf("expected an error for %+v", v)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+// ErrPathNotAllowed is returned for a path suffix on a link without path pass-through.
+var ErrPathNotAllowed = errors.New("link does not pass paths through")
+
+// MaxInterstitial is the longest countdown in seconds before an interstitial redirects.
+const MaxInterstitial = 60
+
+/*
+Target builds the URL to redirect to from the decision and the redirect options of the link:
+the path suffix after the short code is appended, UTM parameters are set and the incoming
+query string is forwarded.
+*/
+func (e *Engine) Target(r *http.Request, link *model.URL, d Decision, suffix string) (string, error) {
+	opts := link.Options
+	suffix = strings.Trim(suffix, "/")
+	if suffix != "" && !opts.PassPath {
+		return "", ErrPathNotAllowed
+	}
+
+	utm := opts.UTM.Params()
+	incoming := r.URL.Query()
+	forward := opts.ForwardQuery != "" && len(incoming) > 0
+	if suffix == "" && len(utm) == 0 && !forward {
+		return d.Destination, nil
+	}
+
+	u, err := url.Parse(d.Destination)
+	if err != nil {
+		return "", fmt.Errorf("parse destination: %w", err)
+	}
+	if suffix != "" {
+		// JoinPath resolves dot segments, so the suffix cannot climb above the destination path
+		u = u.JoinPath(suffix)
+	}
+
+	if len(utm) > 0 || forward {
+		q := u.Query()
+		if len(utm) > 0 {
+			expand := e.placeholders(r, link, d)
+			for name, value := range utm {
+				q.Set(name, expand.Replace(value))
+			}
+		}
+		if forward {
+			for key, values := range incoming {
+				if opts.ForwardQuery == model.ForwardQueryMerge && q.Has(key) {
+					continue
+				}
+				q[key] = values
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
+
+/*
+placeholders returns a replacer filling in the UTM template variables for the visitor.
+*/
+func (e *Engine) placeholders(r *http.Request, link *model.URL, d Decision) *strings.Replacer {
+	v := d.visitor
+	if v == nil {
+		v = e.visitor(r)
+	}
+	variant := ""
+	if d.Variant >= 0 && d.Variant < len(link.Variants) {
+		variant = link.Variants[d.Variant].Name
+	}
+	return strings.NewReplacer(
+		"{short}", link.Short,
+		"{device}", v.device,
+		"{os}", v.os,
+		"{language}", v.language,
+		"{country}", strings.ToLower(v.country()),
+		"{variant}", variant,
+		"{date}", v.now.UTC().Format("2006-01-02"),
+	)
+}
+
+/*
+ValidateOptions checks the redirect options of a link.
+*/
+func ValidateOptions(o model.RedirectOptions) error {
+	switch o.ForwardQuery {
+	case "", model.ForwardQueryMerge, model.ForwardQueryOverride:
+	default:
+		return fmt.Errorf("unknown forwardQuery %q, expected %s or %s", o.ForwardQuery, model.ForwardQueryMerge, model.ForwardQueryOverride)
+	}
+	for name, value := range o.UTM.Params() {
+		if len(value) > 200 {
+			return fmt.Errorf("%s is longer than 200 characters", name)
+		}
+	}
+	if o.Interstitial < 0 || o.Interstitial > MaxInterstitial {
+		return fmt.Errorf("interstitial must be between 0 and %d seconds", MaxInterstitial)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package rules
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+func TestTarget(t *testing.T) {
+	e := NewEngine(staticCountries{"203.0.113.1": "DE"})
+	e.now = func() time.Time { return time.Date(2025, 11, 3, 12, 0, 0, 0, time.UTC) }
+
+	utm := model.UTM{Source: "newsletter", Campaign: "{short}-{date}", Content: "{os}-{country}"}
+	tests := []struct {
+		name, original, target, suffix string
+		opts                           model.RedirectOptions
+		want                           string
+		wantErr                        error
+	}{
+		{"query dropped by default", "https://example.com/p", "/abc?ref=x", "", model.RedirectOptions{}, "https://example.com/p", nil},
+		{"merge keeps destination", "https://example.com/p?ref=site", "/abc?ref=x&id=1", "",
+			model.RedirectOptions{ForwardQuery: model.ForwardQueryMerge}, "https://example.com/p?id=1&ref=site", nil},
+		{"override replaces destination", "https://example.com/p?ref=site", "/abc?ref=x", "",
+			model.RedirectOptions{ForwardQuery: model.ForwardQueryOverride}, "https://example.com/p?ref=x", nil},
+		{"utm placeholders", "https://example.com/p", "/abc", "", model.RedirectOptions{UTM: utm},
+			"https://example.com/p?utm_campaign=abc-2025-11-03&utm_content=ios-de&utm_source=newsletter", nil},
+		{"forwarded query beats utm on override", "https://example.com/p", "/abc?utm_source=ad", "",
+			model.RedirectOptions{ForwardQuery: model.ForwardQueryOverride, UTM: model.UTM{Source: "newsletter"}}, "https://example.com/p?utm_source=ad", nil},
+		{"path suffix", "https://example.com/docs/", "/abc/guide/intro", "guide/intro",
+			model.RedirectOptions{PassPath: true}, "https://example.com/docs/guide/intro", nil},
+		{"suffix cannot escape", "https://example.com/docs", "/abc/../../etc", "../../etc",
+			model.RedirectOptions{PassPath: true}, "https://example.com/etc", nil},
+		{"suffix not allowed", "https://example.com", "/abc/extra", "extra", model.RedirectOptions{}, "", ErrPathNotAllowed},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.target, nil)
+		r.Header.Set("User-Agent", uaIPhone)
+		r.RemoteAddr = "203.0.113.1:1234"
+		link := &model.URL{Short: "abc", Original: tt.original, Options: tt.opts}
+
+		got, err := e.Target(r, link, e.Resolve(r, link), tt.suffix)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	if err := ValidateOptions(model.RedirectOptions{ForwardQuery: model.ForwardQueryMerge, PassPath: true}); err != nil {
+		t.Errorf("expected valid options, got %v", err)
+	}
+	if err := ValidateOptions(model.RedirectOptions{ForwardQuery: "append"}); err == nil {
+		t.Error("expected an error for an unknown forwarding policy")
+	}
+}
@@ -0,0 +1,127 @@
+package safehttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Defaults of Config.
+const (
+	DefaultTimeout      = 5 * time.Second
+	DefaultMaxRedirects = 5
+)
+
+var (
+	// ErrForbiddenAddress is returned for connections to loopback, private and other non-public addresses.
+	ErrForbiddenAddress = errors.New("address is not public")
+	// ErrUnsupportedURL is returned for URLs other than absolute http and https ones.
+	ErrUnsupportedURL = errors.New("only http and https URLs can be fetched")
+)
+
+/*
+Config limits the requests of a client.
+*/
+type Config struct {
+	// Timeout bounds a whole request, including redirects and reading the body.
+	Timeout time.Duration
+	// MaxRedirects is the number of redirects followed before giving up.
+	MaxRedirects int
+	// AllowPrivate permits non-public addresses, e.g. for tests against local servers.
+	AllowPrivate bool
+}
+
+// forbiddenPrefixes are ranges that are not covered by the netip.Addr predicates but are not reachable publicly either.
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, may translate to private IPv4 addresses
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+/*
+PublicAddr reports whether ip is a public unicast address.
+IPv4-mapped IPv6 addresses are judged by their IPv4 address.
+*/
+func PublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, p := range forbiddenPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+CheckURL returns ErrUnsupportedURL unless u is an absolute http or https URL with a host.
+*/
+func CheckURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return fmt.Errorf("%w: %s", ErrUnsupportedURL, u.Redacted())
+	}
+	return nil
+}
+
+/*
+NewClient returns a client for fetching user-supplied URLs. Unless the config allows it,
+the client refuses to connect to non-public addresses. The address is checked after DNS
+resolution, on every connection and redirect, so a host name resolving to an internal
+address is refused as well. Proxies from the environment are not used.
+*/
+func NewClient(cfg Config) *http.Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = DefaultMaxRedirects
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivate {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+			}
+			if !PublicAddr(addr.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr.Addr())
+			}
+			return nil
+		}
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+			}
+			return CheckURL(req.URL)
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package safehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.215.14":         true,
+		"2606:2800:21f:cb07::1": true,
+		"127.0.0.1":             false,
+		"10.1.2.3":              false,
+		"172.16.0.1":            false,
+		"192.168.1.1":           false,
+		"169.254.169.254":       false,
+		"100.64.0.1":            false,
+		"0.0.0.0":               false,
+		"255.255.255.255":       false,
+		"::1":                   false,
+		"fd00::1":               false,
+		"fe80::1":               false,
+		"::ffff:127.0.0.1":      false,
+		"64:ff9b::a00:1":        false,
+	}
+	for addr, want := range tests {
+		if got := PublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("PublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient(Config{}).Get(srv.URL); !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("expected ErrForbiddenAddress for a loopback server, got %v", err)
+	}
+
+	resp, err := NewClient(Config{AllowPrivate: true}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected the request to succeed when private addresses are allowed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestClientRedirects(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, srv.URL+"/loop", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{AllowPrivate: true, MaxRedirects: 2})
+	if _, err := client.Get(srv.URL + "/file"); !errors.Is(err, ErrUnsupportedURL) {
+		t.Errorf("expected ErrUnsupportedURL for a redirect to a file URL, got %v", err)
+	}
+	if _, err := client.Get(srv.URL + "/loop"); err == nil {
+		t.Error("expected a redirect loop to stop")
+	}
+}
@@ -0,0 +1,244 @@
+package screening
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Blocklist is a Provider matching destinations against local list files.
+Each line of a file holds one entry; "#" starts a comment. The entries are:
+
+	0.0.0.0 evil.example www.evil.example   hosts-file format: the names after the address
+	evil.example                            a domain, blocking its subdomains as well
+	https://host.example/phish              a URL prefix, matched with any scheme and a default port
+	/^https?://[^/]+/login\.php/            a regular expression matched against the whole URL
+
+Files are re-read when their modification time changes, see Watch.
+*/
+type Blocklist struct {
+	files []string
+
+	mu    sync.RWMutex
+	rules *blockRules
+	mods  []time.Time
+}
+
+/*
+blockRules are the entries of all files. Every entry maps to the reason reported for it.
+*/
+type blockRules struct {
+	domains  map[string]string
+	prefixes []blockEntry
+	patterns []blockPattern
+}
+
+type blockEntry struct {
+	prefix, reason string
+}
+
+type blockPattern struct {
+	re     *regexp.Regexp
+	reason string
+}
+
+// hostsNames are the names of local addresses found in every hosts file.
+var hostsNames = map[string]bool{
+	"localhost": true, "localhost.localdomain": true, "local": true, "broadcasthost": true,
+	"ip6-localhost": true, "ip6-loopback": true, "ip6-localnet": true, "ip6-mcastprefix": true,
+	"ip6-allnodes": true, "ip6-allrouters": true, "ip6-allhosts": true, "0.0.0.0": true,
+}
+
+/*
+LoadBlocklist reads the given files, failing if any of them cannot be parsed.
+*/
+func LoadBlocklist(files ...string) (*Blocklist, error) {
+	b := &Blocklist{files: files}
+	if _, err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Blocklist) Name() string {
+	return "blocklist"
+}
+
+func (b *Blocklist) Check(_ context.Context, u *url.URL) (Verdict, error) {
+	b.mu.RLock()
+	rules := b.rules
+	b.mu.RUnlock()
+
+	if host, ok := matchDomain(rules.domains, u.Hostname()); ok {
+		return Verdict{Blocked: true, Reason: rules.domains[host]}, nil
+	}
+	key := prefixKey(u)
+	for _, e := range rules.prefixes {
+		if strings.HasPrefix(key, e.prefix) {
+			return Verdict{Blocked: true, Reason: e.reason}, nil
+		}
+	}
+	full := u.String()
+	for _, p := range rules.patterns {
+		if p.re.MatchString(full) {
+			return Verdict{Blocked: true, Reason: p.reason}, nil
+		}
+	}
+	return Verdict{}, nil
+}
+
+/*
+Reload re-reads all files if any of them changed since the last load and reports whether
+the entries were replaced. If a file fails to load, the previous entries stay in use.
+*/
+func (b *Blocklist) Reload() (bool, error) {
+	mods := make([]time.Time, len(b.files))
+	for i, path := range b.files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, fmt.Errorf("stat blocklist: %w", err)
+		}
+		mods[i] = info.ModTime()
+	}
+
+	b.mu.RLock()
+	unchanged := b.rules != nil && slices.EqualFunc(mods, b.mods, time.Time.Equal)
+	b.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	rules := &blockRules{domains: make(map[string]string)}
+	for _, path := range b.files {
+		if err := rules.load(path); err != nil {
+			return false, err
+		}
+	}
+
+	b.mu.Lock()
+	b.rules, b.mods = rules, mods
+	b.mu.Unlock()
+	return true, nil
+}
+
+/*
+Watch checks the files for changes every interval until ctx is done.
+*/
+func (b *Blocklist) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := b.Reload()
+			if err != nil {
+				slog.Warn("failed to reload blocklist, keeping the previous entries", "error", err)
+				continue
+			}
+			if reloaded {
+				slog.Info("blocklist reloaded", "files", b.files)
+			}
+		}
+	}
+}
+
+/*
+prefixKey returns the form in which URL prefixes are compared: the lowercase host without
+a trailing dot, its port unless it is 80 or 443, then the path and the query. The scheme is left out,
+so that an entry for https://host.example/phish blocks http://HOST.example:443/phish as well.
+*/
+func prefixKey(u *url.URL) string {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return host + path
+}
+
+func (rs *blockRules) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open blocklist: %w", err)
+	}
+	defer f.Close()
+	if err := rs.parse(f, filepath.Base(path)); err != nil {
+		return fmt.Errorf("parse blocklist %s: %w", path, err)
+	}
+	return nil
+}
+
+/*
+parse adds the entries read from r. Reasons name the list, so that a blocked link
+can be traced back to the file that blocked it.
+*/
+func (rs *blockRules) parse(r io.Reader, list string) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		reason := func(entry string) string {
+			return "blocklist " + list + ": " + entry
+		}
+
+		// Regular expressions may contain "#", so they are taken before comments are stripped
+		if len(line) > 2 && line[0] == '/' && line[len(line)-1] == '/' {
+			re, err := regexp.Compile(line[1 : len(line)-1])
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			rs.patterns = append(rs.patterns, blockPattern{re: re, reason: reason(line)})
+			continue
+		}
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) > 1 && net.ParseIP(fields[0]) != nil:
+			for _, name := range fields[1:] {
+				name = strings.TrimSuffix(strings.ToLower(name), ".")
+				if !hostsNames[name] {
+					rs.domains[name] = reason(name)
+				}
+			}
+		case len(fields) != 1:
+			return fmt.Errorf("line %d: expected a domain, URL, /pattern/ or hosts entry", n)
+		case strings.Contains(line, "://"):
+			u, err := Parse(line)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			rs.prefixes = append(rs.prefixes, blockEntry{prefix: prefixKey(u), reason: reason(line)})
+		default:
+			name := strings.TrimSuffix(strings.ToLower(strings.TrimPrefix(line, "*.")), ".")
+			rs.domains[name] = reason(name)
+		}
+	}
+	return scanner.Err()
+}
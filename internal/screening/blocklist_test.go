@@ -0,0 +1,109 @@
+package screening
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testList = `# hosts file
+127.0.0.1 localhost
+0.0.0.0 evil.example tracker.example. # ad tracker
+
+phish.example
+*.Wild.Example
+https://docs.example.com/forms/steal
+HTTPS://Files.Example.net:443/drop
+/^https?://[^/]+/wp-admin/.*\.zip$/
+`
+
+func TestBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "phishing.txt")
+	if err := os.WriteFile(path, []byte(testList), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadBlocklist(path)
+	if err != nil {
+		t.Fatalf("LoadBlocklist failed: %v", err)
+	}
+
+	tests := []struct {
+		destination string
+		reason      string // empty if the destination is clean
+	}{
+		{"https://evil.example/login", "blocklist phishing.txt: evil.example"},
+		{"http://cdn.tracker.example", "blocklist phishing.txt: tracker.example"},
+		{"https://a.b.phish.example/x", "blocklist phishing.txt: phish.example"},
+		{"PHISH.EXAMPLE./", "blocklist phishing.txt: phish.example"},
+		{"https://wild.example", "blocklist phishing.txt: wild.example"},
+		{"https://docs.example.com/forms/steal?id=1", "blocklist phishing.txt: https://docs.example.com/forms/steal"},
+		{"http://DOCS.example.com:443/forms/steal", "blocklist phishing.txt: https://docs.example.com/forms/steal"},
+		{"https://docs.example.com./forms/steal", "blocklist phishing.txt: https://docs.example.com/forms/steal"},
+		{"http://files.example.net/drop/kit.exe", "blocklist phishing.txt: HTTPS://Files.Example.net:443/drop"},
+		{"https://site.example/wp-admin/x/kit.zip", `blocklist phishing.txt: /^https?://[^/]+/wp-admin/.*\.zip$/`},
+		{"http://localhost:8080/", ""},
+		{"https://notevil.example", ""},
+		{"https://docs.example.com/forms/other", ""},
+		{"https://docs.example.com:8443/forms/steal", ""},
+		{"https://site.example/wp-admin/kit.zip.html", ""},
+	}
+	for _, tt := range tests {
+		u, err := Parse(tt.destination)
+		if err != nil {
+			t.Fatalf("Parse(%s) failed: %v", tt.destination, err)
+		}
+		v, err := b.Check(context.Background(), u)
+		if err != nil {
+			t.Fatalf("Check(%s) failed: %v", tt.destination, err)
+		}
+		if v.Blocked != (tt.reason != "") || v.Reason != tt.reason {
+			t.Errorf("%s: expected reason %q, got %+v", tt.destination, tt.reason, v)
+		}
+	}
+}
+
+func TestBlocklistReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	write := func(content string, mod time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocked := func(b *Blocklist, destination string) bool {
+		u, _ := Parse(destination)
+		v, _ := b.Check(context.Background(), u)
+		return v.Blocked
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write("old.example\n", start)
+	b, err := LoadBlocklist(path)
+	if err != nil {
+		t.Fatalf("LoadBlocklist failed: %v", err)
+	}
+	if reloaded, err := b.Reload(); err != nil || reloaded {
+		t.Fatalf("expected an unchanged file to be skipped, got %v %v", reloaded, err)
+	}
+
+	write("new.example\n", start.Add(time.Minute))
+	if reloaded, err := b.Reload(); err != nil || !reloaded {
+		t.Fatalf("expected the list to be reloaded, got %v %v", reloaded, err)
+	}
+	if blocked(b, "old.example") || !blocked(b, "new.example") {
+		t.Error("expected the entries to be replaced")
+	}
+
+	write("/[unclosed/\n", start.Add(2*time.Minute))
+	if _, err := b.Reload(); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected a parse error naming the line, got %v", err)
+	}
+	if !blocked(b, "new.example") {
+		t.Error("expected the previous entries to stay in use")
+	}
+}
@@ -0,0 +1,102 @@
+package screening
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+/*
+Verdict is the result of screening a destination.
+*/
+type Verdict struct {
+	Blocked bool
+	// Reason names the provider and the matching entry or threat, e.g. "blocklist phishing.txt: evil.example"
+	Reason string
+}
+
+/*
+Provider checks destinations against one source of reputation data,
+e.g. local blocklists or an external reputation API.
+*/
+type Provider interface {
+	Name() string
+	Check(ctx context.Context, u *url.URL) (Verdict, error)
+}
+
+/*
+Screener runs destinations through its providers in order. The first provider
+that blocks a destination decides the verdict.
+*/
+type Screener struct {
+	providers []Provider
+}
+
+/*
+New returns a screener using the given providers.
+*/
+func New(providers ...Provider) *Screener {
+	return &Screener{providers: providers}
+}
+
+/*
+Check screens a destination. A failing provider does not stop the others; its error is
+returned along with the verdict of the rest, so that callers can decide to fail open.
+*/
+func (s *Screener) Check(ctx context.Context, destination string) (Verdict, error) {
+	u, err := Parse(destination)
+	if err != nil {
+		// Not a URL a browser would follow; there is nothing to screen
+		return Verdict{}, nil
+	}
+
+	var errs []error
+	for _, p := range s.providers {
+		v, err := p.Check(ctx, u)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+			continue
+		}
+		if v.Blocked {
+			return v, nil
+		}
+	}
+	return Verdict{}, errors.Join(errs...)
+}
+
+/*
+Parse parses a destination for screening. Destinations without a scheme are taken as http URLs,
+and the host is lowercased without a trailing dot.
+*/
+func Parse(destination string) (*url.URL, error) {
+	if !strings.Contains(destination, "://") {
+		destination = "http://" + destination
+	}
+	u, err := url.Parse(destination)
+	if err != nil {
+		return nil, err
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("destination %q has no host", destination)
+	}
+	u.Host = strings.TrimSuffix(strings.ToLower(u.Host), ".")
+	return u, nil
+}
+
+/*
+matchDomain returns the entry of domains matching host or one of its parent domains.
+*/
+func matchDomain[V any](domains map[string]V, host string) (string, bool) {
+	for {
+		if _, ok := domains[host]; ok {
+			return host, true
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return "", false
+		}
+		host = host[i+1:]
+	}
+}
@@ -0,0 +1,30 @@
+package screening
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestScreener(t *testing.T) {
+	ctx := context.Background()
+	unavailable := &Stub{Err: errors.New("reputation API unavailable")}
+	s := New(unavailable, &Stub{Threats: map[string]string{"evil.test": "phishing"}})
+
+	v, err := s.Check(ctx, "https://login.evil.test/account")
+	if err != nil || !v.Blocked || v.Reason != "stub: phishing" {
+		t.Errorf("expected the second provider to block, got %+v %v", v, err)
+	}
+
+	v, err = s.Check(ctx, "https://example.com")
+	if v.Blocked {
+		t.Errorf("expected a clean destination to pass, got %+v", v)
+	}
+	if err == nil {
+		t.Error("expected the failing provider to be reported")
+	}
+
+	if v, err := New().Check(ctx, "not a url with spaces://"); v.Blocked || err != nil {
+		t.Errorf("expected an unparsable destination to pass, got %+v %v", v, err)
+	}
+}
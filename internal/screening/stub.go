@@ -0,0 +1,31 @@
+package screening
+
+import (
+	"context"
+	"net/url"
+)
+
+/*
+Stub is a Provider standing in for an external reputation API in development and tests.
+It reports the configured hosts and their subdomains with the given threat type.
+*/
+type Stub struct {
+	// Threats maps hosts to a threat type, e.g. "evil.test" to "phishing"
+	Threats map[string]string
+	// Err, if set, is returned for every check, simulating an unavailable API
+	Err error
+}
+
+func (s *Stub) Name() string {
+	return "stub"
+}
+
+func (s *Stub) Check(_ context.Context, u *url.URL) (Verdict, error) {
+	if s.Err != nil {
+		return Verdict{}, s.Err
+	}
+	if host, ok := matchDomain(s.Threats, u.Hostname()); ok {
+		return Verdict{Blocked: true, Reason: "stub: " + s.Threats[host]}, nil
+	}
+	return Verdict{}, nil
+}
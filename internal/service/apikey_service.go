@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/zen-flo/url-shortener/internal/auth"
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/tracing"
+)
+
+// apiKeyPrefix marks strings as API keys of this service, which helps secret scanners.
+const apiKeyPrefix = "usk_"
+
+// ErrAPIKeyNotFound is returned when an API key does not exist or is already revoked.
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// ErrInvalidAPIKey is returned when an API key cannot be created from the given input.
+var ErrInvalidAPIKey = errors.New("API key needs a name and at least one scope")
+
+/*
+APIKeyService issues, lists and revokes API keys and authenticates requests made with them.
+Keys are random 256-bit secrets, so a fast SHA-256 hash is sufficient for storage.
+*/
+type APIKeyService struct {
+	DB *sqlx.DB
+
+	stmts *db.StmtCache
+}
+
+/*
+NewAPIKeyService creates a new instance of APIKeyService.
+*/
+func NewAPIKeyService(database *sqlx.DB) *APIKeyService {
+	return &APIKeyService{DB: database, stmts: db.NewStmtCache(database)}
+}
+
+/*
+Close releases the prepared statements.
+*/
+func (s *APIKeyService) Close() error {
+	return s.stmts.Close()
+}
+
+/*
+CreateAPIKey issues a new key with the given scopes.
+The plaintext key is returned only here and cannot be recovered later.
+*/
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, name string, scopes []string) (key *model.APIKey, plaintext string, err error) {
+	ctx, span := startSpan(ctx, "APIKeyService.CreateAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	name = strings.TrimSpace(name)
+	scopes = normalizeScopes(scopes)
+	if name == "" || len(scopes) == 0 {
+		return nil, "", ErrInvalidAPIKey
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	plaintext = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key = &model.APIKey{
+		Name:      name,
+		Prefix:    plaintext[:len(apiKeyPrefix)+6],
+		Scopes:    strings.Join(scopes, ","),
+		CreatedAt: time.Now(),
+	}
+	result, err := db.ExecContext(ctx, s.stmts,
+		"INSERT INTO api_keys (name, prefix, key_hash, scopes, created_at) VALUES (?, ?, ?, ?, ?)",
+		key.Name, key.Prefix, hashAPIKey(plaintext), key.Scopes, key.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+	key.ID = int(id)
+	return key, plaintext, nil
+}
+
+/*
+ListAPIKeys returns all keys, including revoked ones, newest first.
+*/
+func (s *APIKeyService) ListAPIKeys(ctx context.Context) (keys []model.APIKey, err error) {
+	ctx, span := startSpan(ctx, "APIKeyService.ListAPIKeys")
+	defer func() { tracing.End(span, err) }()
+
+	err = db.SelectContext(ctx, s.stmts, &keys,
+		"SELECT id, name, prefix, scopes, created_at, revoked_at FROM api_keys ORDER BY id DESC")
+	return keys, err
+}
+
+/*
+RevokeAPIKey disables a key. Returns ErrAPIKeyNotFound if it does not exist or is already revoked.
+*/
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, id int) (err error) {
+	ctx, span := startSpan(ctx, "APIKeyService.RevokeAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	result, err := db.ExecContext(ctx, s.stmts,
+		"UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now(), id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+/*
+Authenticate implements auth.Authenticator for API keys.
+*/
+func (s *APIKeyService) Authenticate(ctx context.Context, token string) (p *auth.Principal, err error) {
+	if !strings.HasPrefix(token, apiKeyPrefix) {
+		return nil, auth.ErrInvalidCredentials
+	}
+
+	ctx, span := startSpan(ctx, "APIKeyService.Authenticate")
+	defer func() { tracing.End(span, err) }()
+
+	var key model.APIKey
+	err = db.GetContext(ctx, s.stmts, &key,
+		"SELECT id, name, prefix, scopes, created_at, revoked_at FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL",
+		hashAPIKey(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, auth.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	return keyPrincipal(&key), nil
+}
+
+/*
+KeyPrincipal returns the principal of the key with the given ID.
+Returns ErrAPIKeyNotFound if it does not exist or was revoked.
+*/
+func (s *APIKeyService) KeyPrincipal(ctx context.Context, id int) (p *auth.Principal, err error) {
+	ctx, span := startSpan(ctx, "APIKeyService.KeyPrincipal")
+	defer func() { tracing.End(span, err) }()
+
+	var key model.APIKey
+	err = db.GetContext(ctx, s.stmts, &key,
+		"SELECT id, name, prefix, scopes, created_at, revoked_at FROM api_keys WHERE id = ? AND revoked_at IS NULL", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return keyPrincipal(&key), nil
+}
+
+func keyPrincipal(key *model.APIKey) *auth.Principal {
+	return &auth.Principal{Name: "key:" + key.Name, Scopes: strings.Split(key.Scopes, ","), KeyID: key.ID}
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+normalizeScopes trims, lowercases and deduplicates scopes.
+*/
+func normalizeScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	var out []string
+	for _, s := range scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	return out
+}
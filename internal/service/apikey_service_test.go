@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zen-flo/url-shortener/internal/auth"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	keys := NewAPIKeyService(setupTestDB(t))
+	defer keys.Close()
+	ctx := context.Background()
+
+	key, plaintext, err := keys.CreateAPIKey(ctx, " ci-bot ", []string{"Admin", "admin", " "})
+	if err != nil {
+		t.Fatalf("CreateAPIKey failed: %v", err)
+	}
+	if !strings.HasPrefix(plaintext, apiKeyPrefix) || !strings.HasPrefix(plaintext, key.Prefix) {
+		t.Errorf("unexpected key %q with prefix %q", plaintext, key.Prefix)
+	}
+	if key.Name != "ci-bot" || key.Scopes != "admin" {
+		t.Errorf("expected normalized name and scopes, got %+v", key)
+	}
+
+	p, err := keys.Authenticate(ctx, plaintext)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if !p.HasScope(auth.ScopeAdmin) || p.KeyID != key.ID {
+		t.Errorf("expected admin scope and key %d, got %+v", key.ID, p)
+	}
+	if p, err := keys.KeyPrincipal(ctx, key.ID); err != nil || p.Name != "key:ci-bot" {
+		t.Errorf("expected the principal of the key, got %+v (err %v)", p, err)
+	}
+
+	for _, token := range []string{plaintext + "x", "not-a-key"} {
+		if _, err := keys.Authenticate(ctx, token); !errors.Is(err, auth.ErrInvalidCredentials) {
+			t.Errorf("expected ErrInvalidCredentials for %q, got %v", token, err)
+		}
+	}
+
+	if err := keys.RevokeAPIKey(ctx, key.ID); err != nil {
+		t.Fatalf("RevokeAPIKey failed: %v", err)
+	}
+	if _, err := keys.Authenticate(ctx, plaintext); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Errorf("expected revoked key to be rejected, got %v", err)
+	}
+	if _, err := keys.KeyPrincipal(ctx, key.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("expected ErrAPIKeyNotFound for a revoked key, got %v", err)
+	}
+	if err := keys.RevokeAPIKey(ctx, key.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("expected ErrAPIKeyNotFound on second revoke, got %v", err)
+	}
+
+	list, err := keys.ListAPIKeys(ctx)
+	if err != nil || len(list) != 1 || list[0].RevokedAt == nil {
+		t.Errorf("expected one revoked key, got %+v (err %v)", list, err)
+	}
+
+	if _, _, err := keys.CreateAPIKey(ctx, "empty", nil); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("expected ErrInvalidAPIKey, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/clickfeed"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/tracing"
+)
+
+/*
+SubscribeClicks returns a live feed of the redirects of a link, starting now.
+The caller must close the subscription; it is closed by the service if the caller falls behind.
+Returns ErrNotFound if the link does not exist.
+*/
+func (s *URLService) SubscribeClicks(ctx context.Context, domain, short string) (_ *clickfeed.Subscription, err error) {
+	ctx, span := startSpan(ctx, "URLService.SubscribeClicks", linkAttrs(domain, short)...)
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.getURL(ctx, s.readStmts, domain, short); err != nil {
+		return nil, err
+	}
+	return s.Clicks.Subscribe(func(c model.Click) bool {
+		return c.Domain == domain && c.Short == short
+	}), nil
+}
+
+/*
+SubscribeAllClicks returns a live feed of the redirects of all links, starting now.
+The caller must close the subscription; it is closed by the service if the caller falls behind.
+*/
+func (s *URLService) SubscribeAllClicks() *clickfeed.Subscription {
+	return s.Clicks.Subscribe(nil)
+}
+
+/*
+publishClick sends a counted redirect to the click feed. A negative variant means the link has no split.
+*/
+func (s *URLService) publishClick(domain, short string, variant int) {
+	c := model.Click{Domain: domain, Short: short, Time: time.Now()}
+	if variant >= 0 {
+		c.Variant = &variant
+	}
+	s.Clicks.Publish(c)
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/rules"
+	"github.com/zen-flo/url-shortener/internal/tracing"
+)
+
+// Errors returned when managing branded domains.
+var (
+	ErrDomainNotFound = errors.New("domain not found")
+	ErrDomainExists   = errors.New("domain already exists")
+	ErrDomainInUse    = errors.New("domain still has links")
+	ErrInvalidDomain  = errors.New("invalid domain")
+)
+
+// domainName matches a lowercase host name of dot-separated labels.
+var domainName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+
+/*
+CreateDomain registers a branded domain with the defaults applied to links created on it
+and the interstitial forced on all of them.
+Returns ErrInvalidDomain for a malformed name or settings and ErrDomainExists if it is already registered.
+*/
+func (s *URLService) CreateDomain(ctx context.Context, d model.Domain) (_ *model.Domain, err error) {
+	ctx, span := startSpan(ctx, "URLService.CreateDomain", attribute.String("url.domain", d.Name))
+	defer func() { tracing.End(span, err) }()
+
+	name := NormalizeHost(d.Name)
+	if len(name) > 253 || !domainName.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q is not a host name", ErrInvalidDomain, name)
+	}
+	if err := validateDefaults(d.Defaults); err != nil {
+		return nil, err
+	}
+	if err := validateInterstitial(d.Interstitial); err != nil {
+		return nil, err
+	}
+
+	d = model.Domain{Name: name, Defaults: d.Defaults, Interstitial: d.Interstitial, CreatedAt: time.Now()}
+	result, err := db.ExecContext(ctx, s.writeStmts,
+		"INSERT INTO domains (name, defaults, interstitial, created_at) VALUES (?, ?, ?, ?) ON CONFLICT (name) DO NOTHING",
+		d.Name, d.Defaults, d.Interstitial, d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrDomainExists
+	}
+
+	s.invalidateDomains()
+	return &d, nil
+}
+
+/*
+GetDomain returns a registered domain. Returns ErrDomainNotFound if it does not exist.
+*/
+func (s *URLService) GetDomain(ctx context.Context, name string) (_ *model.Domain, err error) {
+	ctx, span := startSpan(ctx, "URLService.GetDomain", attribute.String("url.domain", name))
+	defer func() { tracing.End(span, err) }()
+
+	var d model.Domain
+	err = db.GetContext(ctx, s.readStmts, &d, "SELECT * FROM domains WHERE name = ?", NormalizeHost(name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDomainNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+/*
+ListDomains returns all registered domains ordered by name.
+*/
+func (s *URLService) ListDomains(ctx context.Context) (domains []model.Domain, err error) {
+	ctx, span := startSpan(ctx, "URLService.ListDomains")
+	defer func() { tracing.End(span, err) }()
+
+	err = db.SelectContext(ctx, s.readStmts, &domains, "SELECT * FROM domains ORDER BY name")
+	return domains, err
+}
+
+/*
+UpdateDomain replaces the defaults of a domain. Existing links keep their settings.
+Returns ErrInvalidDomain for malformed defaults and ErrDomainNotFound if the domain does not exist.
+*/
+func (s *URLService) UpdateDomain(ctx context.Context, name string, defaults model.DomainDefaults) (_ *model.Domain, err error) {
+	ctx, span := startSpan(ctx, "URLService.UpdateDomain", attribute.String("url.domain", name))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateDefaults(defaults); err != nil {
+		return nil, err
+	}
+	return s.updateDomainColumn(ctx, name, "defaults", defaults)
+}
+
+/*
+SetDomainInterstitial forces a countdown of the given seconds on every link of the domain,
+including existing ones; 0 removes it. Links with a longer countdown of their own keep it.
+Returns ErrInvalidDomain for a countdown out of range and ErrDomainNotFound if the domain does not exist.
+*/
+func (s *URLService) SetDomainInterstitial(ctx context.Context, name string, seconds int) (_ *model.Domain, err error) {
+	ctx, span := startSpan(ctx, "URLService.SetDomainInterstitial", attribute.String("url.domain", name))
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateInterstitial(seconds); err != nil {
+		return nil, err
+	}
+	return s.updateDomainColumn(ctx, name, "interstitial", seconds)
+}
+
+/*
+updateDomainColumn sets one column of a domain and returns the updated domain.
+The column name must be a constant, never user input.
+*/
+func (s *URLService) updateDomainColumn(ctx context.Context, name, column string, value interface{}) (*model.Domain, error) {
+	name = NormalizeHost(name)
+	result, err := db.ExecContext(ctx, s.writeStmts, "UPDATE domains SET "+column+" = ? WHERE name = ?", value, name)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrDomainNotFound
+	}
+
+	var d model.Domain
+	if err := db.GetContext(ctx, s.writeStmts, &d, "SELECT * FROM domains WHERE name = ?", name); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+/*
+DeleteDomain removes a domain. Its links must be deleted first, otherwise ErrDomainInUse is returned.
+Returns ErrDomainNotFound if the domain does not exist.
+*/
+func (s *URLService) DeleteDomain(ctx context.Context, name string) (err error) {
+	ctx, span := startSpan(ctx, "URLService.DeleteDomain", attribute.String("url.domain", name))
+	defer func() { tracing.End(span, err) }()
+
+	name = NormalizeHost(name)
+	result, err := db.ExecContext(ctx, s.writeStmts,
+		"DELETE FROM domains WHERE name = ? AND NOT EXISTS (SELECT 1 FROM urls WHERE domain = ?)", name, name)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if _, err := s.GetDomain(ctx, name); err != nil {
+			return err
+		}
+		return ErrDomainInUse
+	}
+
+	s.invalidateDomains()
+	return nil
+}
+
+/*
+ResolveDomain maps the Host header of a request to the domain its links are stored under:
+the name of a registered domain, or the empty default domain for any other host.
+The set of registered domains is cached and reloaded after it changes.
+*/
+func (s *URLService) ResolveDomain(ctx context.Context, host string) (_ string, err error) {
+	host = NormalizeHost(host)
+
+	s.domainsMu.RLock()
+	domains := s.domains
+	s.domainsMu.RUnlock()
+
+	if domains == nil {
+		ctx, span := startSpan(ctx, "URLService.ResolveDomain")
+		defer func() { tracing.End(span, err) }()
+
+		var names []string
+		if err := db.SelectContext(ctx, s.readStmts, &names, "SELECT name FROM domains"); err != nil {
+			return "", err
+		}
+		domains = make(map[string]bool, len(names))
+		for _, name := range names {
+			domains[name] = true
+		}
+		s.domainsMu.Lock()
+		s.domains = domains
+		s.domainsMu.Unlock()
+	}
+
+	if domains[host] {
+		return host, nil
+	}
+	return "", nil
+}
+
+func (s *URLService) invalidateDomains() {
+	s.domainsMu.Lock()
+	s.domains = nil
+	s.domainsMu.Unlock()
+}
+
+/*
+setShortURL fills in the full short link from the domain and code.
+*/
+func (s *URLService) setShortURL(u *model.URL) {
+	if u.Domain == "" {
+		u.ShortURL = s.BaseURL + "/" + u.Short
+		return
+	}
+	scheme := "https"
+	if base, err := url.Parse(s.BaseURL); err == nil && base.Scheme != "" {
+		scheme = base.Scheme
+	}
+	u.ShortURL = scheme + "://" + u.Domain + "/" + u.Short
+}
+
+/*
+NormalizeHost lowercases a host name and strips the port and a trailing dot.
+*/
+func NormalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+func validateDefaults(d model.DomainDefaults) error {
+	if d.MaxClicks != nil && *d.MaxClicks <= 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidDomain, ErrMaxClicks)
+	}
+	if err := rules.ValidateOptions(d.Options); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDomain, err)
+	}
+	return nil
+}
+
+func validateInterstitial(seconds int) error {
+	if seconds < 0 || seconds > rules.MaxInterstitial {
+		return fmt.Errorf("%w: interstitial must be between 0 and %d seconds", ErrInvalidDomain, rules.MaxInterstitial)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+func TestDomains(t *testing.T) {
+	service := NewURLService(setupTestDB(t), WithBaseURL("https://sho.rt/"))
+	ctx := context.Background()
+
+	limit := 10
+	defaults := model.DomainDefaults{MaxClicks: &limit, Options: model.RedirectOptions{UTM: model.UTM{Source: "brand"}}}
+	d, err := service.CreateDomain(ctx, model.Domain{Name: "Brand.Link.", Defaults: defaults})
+	if err != nil || d.Name != "brand.link" {
+		t.Fatalf("expected the normalized domain, got %+v (err %v)", d, err)
+	}
+	if _, err := service.CreateDomain(ctx, model.Domain{Name: "brand.link"}); !errors.Is(err, ErrDomainExists) {
+		t.Errorf("expected ErrDomainExists, got %v", err)
+	}
+	if _, err := service.CreateDomain(ctx, model.Domain{Name: "not a host"}); !errors.Is(err, ErrInvalidDomain) {
+		t.Errorf("expected ErrInvalidDomain, got %v", err)
+	}
+
+	// Links on the domain get its defaults unless set explicitly, and the full short URL
+	branded, err := service.CreateShortURL(ctx, "https://example.com", WithDomain("brand.link"), WithMaxClicks(1))
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	if *branded.MaxClicks != 1 || branded.Options.UTM.Source != "brand" {
+		t.Errorf("expected explicit maxClicks and the default options, got %+v", branded)
+	}
+	if branded.ShortURL != "https://brand.link/"+branded.Short {
+		t.Errorf("unexpected short URL %s", branded.ShortURL)
+	}
+	plain, _ := service.CreateShortURL(ctx, "https://example.com")
+	if plain.ShortURL != "https://sho.rt/"+plain.Short {
+		t.Errorf("unexpected short URL %s", plain.ShortURL)
+	}
+	if _, err := service.CreateShortURL(ctx, "https://example.com", WithDomain("other.link")); !errors.Is(err, ErrDomainNotFound) {
+		t.Errorf("expected ErrDomainNotFound, got %v", err)
+	}
+
+	// Links are only found on their own domain
+	if _, err := service.GetOriginalURL(ctx, "", branded.Short); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the branded link to be missing on the default domain, got %v", err)
+	}
+	got, err := service.GetOriginalURL(ctx, "brand.link", branded.Short)
+	if err != nil || got.ShortURL != branded.ShortURL {
+		t.Fatalf("expected the branded link, got %+v (err %v)", got, err)
+	}
+
+	for host, want := range map[string]string{"BRAND.link:8080": "brand.link", "localhost:8080": "", "sho.rt": ""} {
+		if got, err := service.ResolveDomain(ctx, host); err != nil || got != want {
+			t.Errorf("ResolveDomain(%q) = %q, %v; want %q", host, got, err, want)
+		}
+	}
+
+	updated, err := service.UpdateDomain(ctx, "brand.link", model.DomainDefaults{})
+	if err != nil || updated.Defaults.MaxClicks != nil {
+		t.Errorf("expected the defaults to be cleared, got %+v (err %v)", updated, err)
+	}
+
+	if err := service.DeleteDomain(ctx, "brand.link"); !errors.Is(err, ErrDomainInUse) {
+		t.Errorf("expected ErrDomainInUse, got %v", err)
+	}
+	if err := service.DeleteURL(ctx, "brand.link", branded.Short); err != nil {
+		t.Fatalf("DeleteURL failed: %v", err)
+	}
+	if err := service.DeleteDomain(ctx, "brand.link"); err != nil {
+		t.Fatalf("DeleteDomain failed: %v", err)
+	}
+	if err := service.DeleteDomain(ctx, "brand.link"); !errors.Is(err, ErrDomainNotFound) {
+		t.Errorf("expected ErrDomainNotFound, got %v", err)
+	}
+	if got, _ := service.ResolveDomain(ctx, "brand.link"); got != "" {
+		t.Errorf("expected a removed domain to resolve to the default one, got %q", got)
+	}
+}
+
+func TestDomainInterstitial(t *testing.T) {
+	service := NewURLService(setupTestDB(t))
+	ctx := context.Background()
+
+	if _, err := service.CreateDomain(ctx, model.Domain{Name: "brand.link", Interstitial: 3}); err != nil {
+		t.Fatalf("CreateDomain failed: %v", err)
+	}
+	link, err := service.CreateShortURL(ctx, "https://example.com", WithDomain("brand.link"), WithOwner("marketing"))
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	if link.Owner != "marketing" || link.Countdown() != 3 {
+		t.Errorf("expected the owner and the domain countdown, got %+v", link)
+	}
+
+	// The domain setting applies to existing links, unless theirs is longer
+	if _, err := service.SetDomainInterstitial(ctx, "brand.link", 10); err != nil {
+		t.Fatalf("SetDomainInterstitial failed: %v", err)
+	}
+	got, _ := service.GetOriginalURL(ctx, "brand.link", link.Short)
+	if got.Owner != "marketing" || got.Countdown() != 10 {
+		t.Errorf("expected a 10 s countdown, got %+v", got)
+	}
+	if _, err := service.UpdateOptions(ctx, "brand.link", link.Short, model.RedirectOptions{Interstitial: 20}); err != nil {
+		t.Fatalf("UpdateOptions failed: %v", err)
+	}
+	got, _ = service.GetOriginalURL(ctx, "brand.link", link.Short)
+	if got.Countdown() != 20 {
+		t.Errorf("expected the longer countdown of the link, got %d", got.Countdown())
+	}
+
+	if _, err := service.SetDomainInterstitial(ctx, "brand.link", -1); !errors.Is(err, ErrInvalidDomain) {
+		t.Errorf("expected ErrInvalidDomain, got %v", err)
+	}
+	if _, err := service.SetDomainInterstitial(ctx, "other.link", 5); !errors.Is(err, ErrDomainNotFound) {
+		t.Errorf("expected ErrDomainNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/linkcheck"
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/tracing"
+)
+
+// DefaultBrokenAfter is the number of consecutive failed checks after which a link counts as broken.
+const DefaultBrokenAfter = 2
+
+// healthBatch is the number of links checked at a time by CheckLinks.
+const healthBatch = 200
+
+var brokenLinks = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "urls_broken",
+		Help: "Current number of short links whose destinations failed their latest health checks.",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(brokenLinks)
+}
+
+/*
+HealthResult summarizes a round of destination health checks.
+*/
+type HealthResult struct {
+	Checked   int `json:"checked"`   // Links checked
+	Broken    int `json:"broken"`    // Links found broken by this round
+	Recovered int `json:"recovered"` // Broken links that work again
+}
+
+/*
+WithLinkChecker enables destination health checks, see CheckLinks. A link counts as broken
+after brokenAfter consecutive failed checks; values below 1 use DefaultBrokenAfter.
+*/
+func WithLinkChecker(checker *linkcheck.Checker, brokenAfter int) Option {
+	return func(s *URLService) {
+		s.Checker = checker
+		s.BrokenAfter = brokenAfter
+		if s.BrokenAfter < 1 {
+			s.BrokenAfter = DefaultBrokenAfter
+		}
+	}
+}
+
+/*
+WithHealthNotifier notifies about links that break or recover.
+*/
+func WithHealthNotifier(n linkcheck.Notifier) Option {
+	return func(s *URLService) {
+		s.Notifier = n
+	}
+}
+
+/*
+LinkHealth returns the result of the latest health check of a link. A link that was not
+checked yet has an empty result. Returns ErrNotFound if the link does not exist.
+*/
+func (s *URLService) LinkHealth(ctx context.Context, domain, short string) (_ *model.LinkHealth, err error) {
+	ctx, span := startSpan(ctx, "URLService.LinkHealth", linkAttrs(domain, short)...)
+	defer func() { tracing.End(span, err) }()
+
+	url, err := s.getURL(ctx, s.readStmts, domain, short)
+	if err != nil {
+		return nil, err
+	}
+	health, err := s.loadHealth(ctx, s.readStmts, url.ID)
+	if err != nil {
+		return nil, err
+	}
+	return health, nil
+}
+
+/*
+loadHealth returns the stored health of the link with the given ID, or an empty result.
+*/
+func (s *URLService) loadHealth(ctx context.Context, q sqlx.QueryerContext, urlID int) (*model.LinkHealth, error) {
+	var health model.LinkHealth
+	err := db.GetContext(ctx, q, &health, "SELECT * FROM link_health WHERE url_id = ?", urlID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &model.LinkHealth{URLID: urlID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+/*
+CheckLinks checks the destinations of all enabled links and records the results.
+Links are loaded in batches by ID, and the destinations of a batch are checked together
+with the concurrency and per-host limits of the checker. Links that break or recover
+are reported to the notifier.
+*/
+func (s *URLService) CheckLinks(ctx context.Context) (result HealthResult, err error) {
+	ctx, span := startSpan(ctx, "URLService.CheckLinks")
+	defer func() {
+		span.SetAttributes(attribute.Int("health.checked", result.Checked), attribute.Int("health.broken", result.Broken))
+		tracing.End(span, err)
+	}()
+
+	if s.Checker == nil {
+		return result, nil
+	}
+	defer s.UpdateURLCount(ctx)
+
+	lastID := 0
+	for {
+		var batch []model.URL
+		if err := db.SelectContext(ctx, s.readStmts, &batch,
+			"SELECT "+urlColumns+" FROM urls WHERE id > ? AND disabled_reason = '' ORDER BY id LIMIT ?", lastID, healthBatch); err != nil {
+			return result, err
+		}
+		if len(batch) == 0 {
+			return result, nil
+		}
+
+		var dests []string
+		for _, link := range batch {
+			dests = append(dests, link.Destinations()...)
+		}
+		checked := s.Checker.CheckAll(ctx, dests)
+		if err := ctx.Err(); err != nil {
+			// The round was cut short, partial results would count as failures
+			return result, err
+		}
+
+		for i := range batch {
+			link := &batch[i]
+			lastID = link.ID
+			health, event, err := s.recordHealth(ctx, link, checked)
+			if err != nil {
+				return result, err
+			}
+			result.Checked++
+			switch event {
+			case linkcheck.EventBroken:
+				result.Broken++
+			case linkcheck.EventRecovered:
+				result.Recovered++
+			default:
+				continue
+			}
+			s.setShortURL(link)
+			s.notifyHealth(ctx, event, link, health)
+		}
+	}
+}
+
+/*
+recordHealth stores the results for the destinations of a link and returns the new health
+with the event it caused, if any.
+*/
+func (s *URLService) recordHealth(ctx context.Context, link *model.URL, checked map[string]model.DestinationHealth) (*model.LinkHealth, string, error) {
+	prev, err := s.loadHealth(ctx, s.writeStmts, link.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	health := &model.LinkHealth{URLID: link.ID, CheckedAt: now}
+	failed := false
+	for _, d := range link.Destinations() {
+		h := checked[d]
+		health.Destinations = append(health.Destinations, h)
+		failed = failed || !h.OK
+	}
+	if failed {
+		health.Failures = prev.Failures + 1
+	}
+	health.Broken = health.Failures >= s.BrokenAfter
+	if health.Broken {
+		health.BrokenSince = prev.BrokenSince
+		if !prev.Broken || health.BrokenSince == nil {
+			health.BrokenSince = &now
+		}
+	}
+
+	_, err = db.ExecContext(ctx, s.writeStmts, `INSERT INTO link_health (url_id, checked_at, broken, broken_since, failures, destinations)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (url_id) DO UPDATE SET checked_at = excluded.checked_at, broken = excluded.broken,
+			broken_since = excluded.broken_since, failures = excluded.failures, destinations = excluded.destinations`,
+		health.URLID, health.CheckedAt, health.Broken, health.BrokenSince, health.Failures, health.Destinations)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case health.Broken && !prev.Broken:
+		return health, linkcheck.EventBroken, nil
+	case !health.Broken && prev.Broken:
+		return health, linkcheck.EventRecovered, nil
+	}
+	return health, "", nil
+}
+
+/*
+notifyHealth logs a change in the health of a link, queues it for webhook subscribers and
+passes it on to the notifier. A failed notification is logged; it does not stop the round.
+*/
+func (s *URLService) notifyHealth(ctx context.Context, event string, link *model.URL, health *model.LinkHealth) {
+	log := logger.FromContext(ctx)
+	if event == linkcheck.EventBroken {
+		log.WarnContext(ctx, "link destination broken", "domain", link.Domain, "short", link.Short, "owner", link.Owner)
+	} else {
+		log.InfoContext(ctx, "link destination recovered", "domain", link.Domain, "short", link.Short, "owner", link.Owner)
+	}
+	s.emit(ctx, event, link, health)
+	if s.Notifier == nil {
+		return
+	}
+	if err := s.Notifier.Notify(ctx, linkcheck.Notification{Event: event, Link: link, Health: health}); err != nil {
+		log.ErrorContext(ctx, "failed to send link health notification", "event", event, "short", link.Short, "error", err)
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/zen-flo/url-shortener/internal/linkcheck"
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, note linkcheck.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, note.Event+" "+note.Link.Short)
+	return nil
+}
+
+func TestCheckLinks(t *testing.T) {
+	var down sync.Map
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := down.Load(r.URL.Path); ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	notifier := &recordingNotifier{}
+	service := NewURLService(setupTestDB(t),
+		WithLinkChecker(linkcheck.New(linkcheck.Config{AllowPrivate: true, HostDelay: -1}), 2),
+		WithHealthNotifier(notifier))
+	ctx := context.Background()
+
+	healthy, _ := service.CreateShortURL(ctx, srv.URL+"/healthy")
+	flaky, _ := service.CreateShortURL(ctx, srv.URL+"/flaky", WithVariants(model.Variants{
+		{Destination: srv.URL + "/a", Weight: 1},
+		{Destination: srv.URL + "/b", Weight: 1},
+	}))
+
+	if h, err := service.LinkHealth(ctx, "", healthy.Short); err != nil || !h.CheckedAt.IsZero() {
+		t.Fatalf("expected an empty result before the first check, got %+v (err %v)", h, err)
+	}
+	if _, err := service.LinkHealth(ctx, "", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// One failing variant fails the link, but it only breaks after two checks in a row
+	down.Store("/b", true)
+	for round, wantBroken := range []int{0, 1, 0} {
+		result, err := service.CheckLinks(ctx)
+		if err != nil {
+			t.Fatalf("round %d: CheckLinks failed: %v", round, err)
+		}
+		if result.Checked != 2 || result.Broken != wantBroken {
+			t.Errorf("round %d: unexpected result %+v", round, result)
+		}
+	}
+
+	h, _ := service.LinkHealth(ctx, "", flaky.Short)
+	if !h.Broken || h.Failures != 3 || h.BrokenSince == nil || len(h.Destinations) != 3 {
+		t.Fatalf("expected the link to be broken, got %+v", h)
+	}
+	if d := h.Destinations[2]; d.URL != srv.URL+"/b" || d.OK || d.Status != http.StatusNotFound {
+		t.Errorf("unexpected result for the failing variant %+v", d)
+	}
+	if h, _ := service.LinkHealth(ctx, "", healthy.Short); h.Broken || h.Failures != 0 || !h.Destinations[0].OK {
+		t.Errorf("expected the healthy link to stay healthy, got %+v", h)
+	}
+
+	down.Delete("/b")
+	if result, _ := service.CheckLinks(ctx); result.Recovered != 1 {
+		t.Errorf("expected the link to recover, got %+v", result)
+	}
+	if h, _ := service.LinkHealth(ctx, "", flaky.Short); h.Broken || h.BrokenSince != nil {
+		t.Errorf("expected the link to be healthy again, got %+v", h)
+	}
+
+	want := []string{linkcheck.EventBroken + " " + flaky.Short, linkcheck.EventRecovered + " " + flaky.Short}
+	if len(notifier.events) != 2 || notifier.events[0] != want[0] || notifier.events[1] != want[1] {
+		t.Errorf("expected notifications %v, got %v", want, notifier.events)
+	}
+
+	// Deleting the link removes its health
+	if err := service.DeleteURL(ctx, "", flaky.Short); err != nil {
+		t.Fatalf("DeleteURL failed: %v", err)
+	}
+	var rows int
+	_ = service.DB.Get(&rows, "SELECT COUNT(*) FROM link_health")
+	if rows != 1 {
+		t.Errorf("expected the health of the deleted link to be removed, got %d rows", rows)
+	}
+}
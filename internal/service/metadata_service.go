@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/metadata"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/tracing"
+)
+
+// MetadataQueueSize is how many links may wait for their metadata. Links beyond that are skipped.
+const MetadataQueueSize = 256
+
+var metadataFetches = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "url_metadata_fetches_total",
+		Help: "Total number of destination pages fetched for link metadata, by result: ok, error or skipped.",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(metadataFetches)
+}
+
+/*
+linkRef names a link queued for a metadata fetch.
+*/
+type linkRef struct {
+	domain, short string
+}
+
+/*
+WithMetadata fetches the title, description and image of destination pages after links are
+created or their destination changes. The fetches run in the background, see RunMetadataFetcher.
+*/
+func WithMetadata(fetcher *metadata.Fetcher) Option {
+	return func(s *URLService) {
+		s.Metadata = fetcher
+		s.metadataQueue = make(chan linkRef, MetadataQueueSize)
+	}
+}
+
+/*
+queueMetadata schedules a metadata fetch for the link without waiting for it.
+If the queue is full, the link keeps empty metadata.
+*/
+func (s *URLService) queueMetadata(ctx context.Context, u *model.URL) {
+	if s.Metadata == nil {
+		return
+	}
+	select {
+	case s.metadataQueue <- linkRef{u.Domain, u.Short}:
+	default:
+		metadataFetches.WithLabelValues("skipped").Inc()
+		logger.FromContext(ctx).WarnContext(ctx, "metadata queue full, skipping link", "domain", u.Domain, "short", u.Short)
+	}
+}
+
+/*
+RunMetadataFetcher fetches metadata for queued links with the given number of workers
+until ctx is done. It returns immediately if metadata fetching is not configured.
+*/
+func (s *URLService) RunMetadataFetcher(ctx context.Context, workers int) {
+	if s.Metadata == nil {
+		return
+	}
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case ref := <-s.metadataQueue:
+					if _, err := s.FetchMetadata(ctx, ref.domain, ref.short); err != nil {
+						logger.FromContext(ctx).WarnContext(ctx, "failed to fetch link metadata", "domain", ref.domain, "short", ref.short, "error", err)
+					}
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+/*
+FetchMetadata fetches the destination page of a link and stores its metadata.
+If the destination changes while the page is fetched, the result is discarded;
+the change queues a fetch of its own.
+Returns ErrNotFound if the link does not exist.
+*/
+func (s *URLService) FetchMetadata(ctx context.Context, domain, short string) (_ *model.URL, err error) {
+	ctx, span := startSpan(ctx, "URLService.FetchMetadata", linkAttrs(domain, short)...)
+	defer func() { tracing.End(span, err) }()
+
+	url, err := s.getURL(ctx, s.readStmts, domain, short)
+	if err != nil {
+		return nil, err
+	}
+	if s.Metadata == nil {
+		return url, nil
+	}
+
+	m, err := s.Metadata.Fetch(ctx, url.Original)
+	if err != nil {
+		metadataFetches.WithLabelValues("error").Inc()
+		return nil, err
+	}
+	metadataFetches.WithLabelValues("ok").Inc()
+
+	_, err = db.ExecContext(ctx, s.writeStmts, "UPDATE urls SET metadata = ? WHERE domain = ? AND short = ? AND original = ?",
+		m, domain, short, url.Original)
+	if err != nil {
+		return nil, err
+	}
+	return s.getURL(ctx, s.writeStmts, domain, short)
+}
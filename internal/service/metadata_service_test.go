@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/metadata"
+)
+
+func TestMetadataFetchedInBackground(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<title>Page ` + r.URL.Path + `</title><meta name="description" content="About it">`))
+	}))
+	defer srv.Close()
+
+	service := NewURLService(setupTestDB(t), WithMetadata(metadata.New(metadata.Config{AllowPrivate: true})))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.RunMetadataFetcher(ctx, 2)
+
+	link, err := service.CreateShortURL(ctx, srv.URL+"/one")
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	if link.Metadata.Title != "" {
+		t.Errorf("expected the link to be returned before its metadata is fetched, got %+v", link.Metadata)
+	}
+	waitForTitle(t, service, link.Short, "Page /one")
+
+	got, _ := service.GetOriginalURL(ctx, "", link.Short)
+	if got.Metadata.Description != "About it" || got.Metadata.FetchedAt.IsZero() {
+		t.Errorf("unexpected metadata %+v", got.Metadata)
+	}
+
+	// A new destination clears the metadata of the old one until it is fetched again
+	updated, err := service.UpdateURL(ctx, "", link.Short, srv.URL+"/two")
+	if err != nil {
+		t.Fatalf("UpdateURL failed: %v", err)
+	}
+	if updated.Metadata.Title == "Page /one" {
+		t.Error("expected the old metadata to be cleared")
+	}
+	waitForTitle(t, service, link.Short, "Page /two")
+}
+
+func TestFetchMetadataUnreachable(t *testing.T) {
+	service := NewURLService(setupTestDB(t), WithMetadata(metadata.New(metadata.Config{})))
+	ctx := context.Background()
+
+	// The default fetcher refuses internal addresses, so the link keeps empty metadata
+	link, _ := service.CreateShortURL(ctx, "http://169.254.169.254/latest/meta-data/")
+	if _, err := service.FetchMetadata(ctx, "", link.Short); err == nil {
+		t.Error("expected the metadata endpoint to be refused")
+	}
+	got, _ := service.GetOriginalURL(ctx, "", link.Short)
+	if !got.Metadata.FetchedAt.IsZero() {
+		t.Errorf("expected no metadata, got %+v", got.Metadata)
+	}
+}
+
+func waitForTitle(t *testing.T, service *URLService, short, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		got, err := service.GetOriginalURL(context.Background(), "", short)
+		if err != nil {
+			t.Fatalf("GetOriginalURL failed: %v", err)
+		}
+		if got.Metadata.Title == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("metadata title %q was not fetched in time", want)
+}
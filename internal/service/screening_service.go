@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/tracing"
+)
+
+// rescanBatch is the number of links loaded at a time by Rescan.
+const rescanBatch = 200
+
+/*
+ScanResult summarizes a rescan of existing links.
+*/
+type ScanResult struct {
+	Scanned  int `json:"scanned"`  // Links checked
+	Disabled int `json:"disabled"` // Links disabled by this scan
+}
+
+/*
+screen returns ErrBlocked, wrapped with the reason, if any of the destinations is blocked.
+*/
+func (s *URLService) screen(ctx context.Context, destinations ...string) error {
+	if reason := s.blockReason(ctx, destinations...); reason != "" {
+		return fmt.Errorf("%w: %s", ErrBlocked, reason)
+	}
+	return nil
+}
+
+/*
+blockReason returns why the first blocked destination is blocked, or an empty string.
+Providers that fail are logged and skipped, so an unavailable reputation API does not
+stop links from being created.
+*/
+func (s *URLService) blockReason(ctx context.Context, destinations ...string) string {
+	if s.Screener == nil {
+		return ""
+	}
+	for _, d := range destinations {
+		verdict, err := s.Screener.Check(ctx, d)
+		if err != nil {
+			logger.FromContext(ctx).WarnContext(ctx, "destination screening incomplete", "destination", d, "error", err)
+		}
+		if verdict.Blocked {
+			return verdict.Reason
+		}
+	}
+	return ""
+}
+
+/*
+destinations lists the URLs a link may redirect to.
+*/
+func destinations(original string, rs model.Rules, vs model.Variants) []string {
+	u := model.URL{Original: original, Rules: rs, Variants: vs}
+	return u.Destinations()
+}
+
+/*
+SetDisabled disables a link with the given reason, so that visitors see a warning instead
+of being redirected. An empty reason enables the link again.
+Returns ErrNotFound if the link does not exist.
+*/
+func (s *URLService) SetDisabled(ctx context.Context, domain, short, reason string) (_ *model.URL, err error) {
+	ctx, span := startSpan(ctx, "URLService.SetDisabled", linkAttrs(domain, short)...)
+	defer func() { tracing.End(span, err) }()
+
+	return s.updateColumn(ctx, domain, short, "disabled_reason", reason)
+}
+
+/*
+Rescan screens the destinations of all enabled links again, disabling those that are now
+blocked, e.g. after a blocklist update. Links are loaded in batches by ID, so that the
+writer connection is free to disable them between batches.
+*/
+func (s *URLService) Rescan(ctx context.Context) (result ScanResult, err error) {
+	ctx, span := startSpan(ctx, "URLService.Rescan")
+	defer func() {
+		span.SetAttributes(attribute.Int("scan.scanned", result.Scanned), attribute.Int("scan.disabled", result.Disabled))
+		tracing.End(span, err)
+	}()
+
+	if s.Screener == nil {
+		return result, nil
+	}
+
+	lastID := 0
+	for {
+		var batch []model.URL
+		if err := db.SelectContext(ctx, s.readStmts, &batch,
+			"SELECT "+urlColumns+" FROM urls WHERE id > ? AND disabled_reason = '' ORDER BY id LIMIT ?", lastID, rescanBatch); err != nil {
+			return result, err
+		}
+		if len(batch) == 0 {
+			return result, nil
+		}
+
+		for _, link := range batch {
+			lastID = link.ID
+			result.Scanned++
+			reason := s.blockReason(ctx, destinations(link.Original, link.Rules, link.Variants)...)
+			if reason == "" {
+				continue
+			}
+			// Only disable links that are still enabled, an admin may have changed them meanwhile
+			res, dbErr := db.ExecContext(ctx, s.writeStmts,
+				"UPDATE urls SET disabled_reason = ? WHERE id = ? AND disabled_reason = ''", reason, link.ID)
+			if dbErr != nil {
+				return result, dbErr
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				result.Disabled++
+				logger.FromContext(ctx).WarnContext(ctx, "link disabled by screening",
+					"domain", link.Domain, "short", link.Short, "reason", reason)
+				link.Disabled, link.DisabledReason = true, reason
+				s.setShortURL(&link)
+				s.emit(ctx, EventLinkUpdated, &link, nil)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/screening"
+)
+
+func TestScreening(t *testing.T) {
+	threats := map[string]string{"evil.test": "phishing"}
+	service := NewURLService(setupTestDB(t), WithScreener(screening.New(&screening.Stub{Threats: threats})))
+	ctx := context.Background()
+
+	if _, err := service.CreateShortURL(ctx, "https://login.evil.test"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected ErrBlocked for a blocked original URL, got %v", err)
+	}
+	_, err := service.CreateShortURL(ctx, "https://example.com", WithVariants(model.Variants{
+		{Destination: "https://example.com/a", Weight: 1},
+		{Destination: "https://evil.test/b", Weight: 1},
+	}))
+	if !errors.Is(err, ErrBlocked) || err.Error() != "destination is blocked: stub: phishing" {
+		t.Errorf("expected ErrBlocked with the reason for a blocked variant, got %v", err)
+	}
+
+	clean, err := service.CreateShortURL(ctx, "https://example.com")
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	if _, err := service.UpdateURL(ctx, "", clean.Short, "https://evil.test"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected ErrBlocked when updating to a blocked URL, got %v", err)
+	}
+	if _, err := service.UpdateRules(ctx, "", clean.Short, model.Rules{{Destination: "https://evil.test", OS: []string{"ios"}}}); !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected ErrBlocked for a blocked rule destination, got %v", err)
+	}
+
+	// A destination that turns malicious after creation is disabled by the next rescan
+	turned, _ := service.CreateShortURL(ctx, "https://turned.test/page")
+	threats["turned.test"] = "malware"
+	result, err := service.Rescan(ctx)
+	if err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	if result != (ScanResult{Scanned: 2, Disabled: 1}) {
+		t.Errorf("unexpected scan result %+v", result)
+	}
+	got, _ := service.GetOriginalURL(ctx, "", turned.Short)
+	if !got.Disabled || got.DisabledReason != "stub: malware" {
+		t.Errorf("expected the link to be disabled with the reason, got %+v", got)
+	}
+
+	// Disabled links are skipped by later scans, and can be enabled again by hand
+	if result, _ := service.Rescan(ctx); result.Scanned != 1 {
+		t.Errorf("expected only enabled links to be scanned, got %+v", result)
+	}
+	if got, err := service.SetDisabled(ctx, "", turned.Short, ""); err != nil || got.Disabled {
+		t.Errorf("expected the link to be enabled, got %+v (err %v)", got, err)
+	}
+	if _, err := service.SetDisabled(ctx, "", "missing", "spam"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestScreeningFailsOpen(t *testing.T) {
+	unavailable := &screening.Stub{Err: errors.New("reputation API unavailable")}
+	service := NewURLService(setupTestDB(t), WithScreener(screening.New(unavailable)))
+
+	if _, err := service.CreateShortURL(context.Background(), "https://example.com"); err != nil {
+		t.Errorf("expected links to be created while a provider is down, got %v", err)
+	}
+}
@@ -1,16 +1,33 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
-	"log"
+	"log/slog"
+	neturl "net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/zen-flo/url-shortener/internal/clickfeed"
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/linkcheck"
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/metadata"
 	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/rules"
+	"github.com/zen-flo/url-shortener/internal/safehttp"
+	"github.com/zen-flo/url-shortener/internal/screening"
+	"github.com/zen-flo/url-shortener/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -27,52 +44,348 @@ var (
 			Help: "Current number of shortened URLs stored in the database.",
 		},
 	)
+
+	urlsByState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "urls_by_state",
+			Help: "Number of short links by state: active links, expired links that used up their clicks and links deleted so far.",
+		},
+		[]string{"state"},
+	)
+
+	urlsByOwner = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "urls_by_owner",
+			Help: "Number of stored short links of the owners with the most links; the links of all other owners are counted as \"other\" and links created without a token have an empty owner.",
+		},
+		[]string{"owner"},
+	)
+
+	urlCountDrift = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "urls_count_drift_total",
+			Help: "Total absolute difference corrected by URL count reconciliation.",
+		},
+	)
 )
 
+// Errors returned by the service. Handlers map them to HTTP status codes.
+var (
+	ErrEmptyURL     = errors.New("original URL cannot be empty")
+	ErrInvalidURL   = errors.New("original URL must be an absolute http or https URL")
+	ErrNotFound     = errors.New("URL not found")
+	ErrPasswordLong = errors.New("password must be at most 72 bytes")
+	ErrMaxClicks    = errors.New("maxClicks must be positive")
+	ErrGone         = errors.New("URL has reached its click limit")
+	ErrInvalidRules = errors.New("invalid redirect rules")
+	ErrInvalidSplit = errors.New("invalid A/B variants")
+	ErrInvalidOpts  = errors.New("invalid redirect options")
+	ErrBlocked      = errors.New("destination is blocked")
+)
+
+// ownerSeriesLimit caps the number of owners exported by urls_by_owner, which keeps its cardinality bounded.
+const ownerSeriesLimit = 20
+
+// otherOwners labels the links of the owners beyond ownerSeriesLimit.
+const otherOwners = "other"
+
+// ownerSeries are the owner labels urls_by_owner currently exports, so that stale ones can be deleted.
+var ownerSeries = struct {
+	sync.Mutex
+	labels map[string]bool
+}{labels: map[string]bool{}}
+
+// urlColumns selects a urls row together with the fields of model.URL derived from it.
+const urlColumns = "*, password_hash != '' AS protected, max_clicks - clicks AS clicks_left, disabled_reason != '' AS disabled, " +
+	"COALESCE((SELECT interstitial FROM domains WHERE name = urls.domain), 0) AS domain_interstitial"
+
 func init() {
 	prometheus.MustRegister(urlsTotal)
 	prometheus.MustRegister(urlsInDB)
+	prometheus.MustRegister(urlsByState)
+	prometheus.MustRegister(urlsByOwner)
+	prometheus.MustRegister(urlCountDrift)
 }
 
 // URLServiceInterface defines the behavior of the service for working with short URLs.
 // Is used to simplify testing and locking in the handler.
+// Every method takes a context so that request cancellation and deadlines reach the database.
+// Links are addressed by domain and short code; the empty domain is the default one.
 type URLServiceInterface interface {
-	CreateShortURL(original string) (*model.URL, error)
-	GetOriginalURL(short string) (*model.URL, error)
-	DeleteURL(short string) error
-	UpdateURLCount()
+	CreateShortURL(ctx context.Context, original string, opts ...CreateOption) (*model.URL, error)
+	GetOriginalURL(ctx context.Context, domain, short string) (*model.URL, error)
+	DeleteURL(ctx context.Context, domain, short string) error
+	UpdateRules(ctx context.Context, domain, short string, rs model.Rules) (*model.URL, error)
+	UpdateVariants(ctx context.Context, domain, short string, vs model.Variants) (*model.URL, error)
+	UpdateOptions(ctx context.Context, domain, short string, o model.RedirectOptions) (*model.URL, error)
+	RegisterClick(ctx context.Context, domain, short string, variant int) error
+	ListURLs(ctx context.Context, owner, query string, limit, offset int) ([]model.URL, int, error)
+	Stats(ctx context.Context, domain, short string) (*model.Stats, error)
+	SubscribeClicks(ctx context.Context, domain, short string) (*clickfeed.Subscription, error)
+	LinkHealth(ctx context.Context, domain, short string) (*model.LinkHealth, error)
+	ResolveDomain(ctx context.Context, host string) (string, error)
+	UpdateURLCount(ctx context.Context)
 }
 
+// DefaultBaseURL is the base of short links on the default domain.
+const DefaultBaseURL = "http://localhost:8080"
+
 /*
 URLService provides methods for creating, retrieving and deleting shortened URLs.
+Writes go through DB, the single writer connection; lookups use Reader when one is configured.
 */
 type URLService struct {
-	DB *sqlx.DB
+	DB     *sqlx.DB
+	Reader *sqlx.DB
+	// BaseURL is the scheme and host of short links on the default domain.
+	// Links on branded domains use the same scheme.
+	BaseURL string
+	// Screener checks destinations of new and updated links; nil accepts every destination.
+	Screener *screening.Screener
+	// Metadata fetches the pages of destinations in the background; nil leaves link metadata empty.
+	Metadata *metadata.Fetcher
+	// Checker requests destinations for CheckLinks; nil disables health checks.
+	Checker *linkcheck.Checker
+	// BrokenAfter is the number of consecutive failed checks after which a link counts as broken.
+	BrokenAfter int
+	// Notifier is told about links that break or recover; nil only logs them.
+	Notifier linkcheck.Notifier
+	// Webhooks controls the delivery of link events to webhook subscriptions.
+	Webhooks WebhookConfig
+	// Clicks publishes every counted redirect to live subscribers, see SubscribeClicks.
+	Clicks *clickfeed.Hub
+
+	writeStmts *db.StmtCache
+	readStmts  *db.StmtCache
+
+	metadataQueue chan linkRef
+
+	domainsMu sync.RWMutex
+	domains   map[string]bool // names of registered domains, nil until loaded
+
+	webhooksMu  sync.RWMutex
+	webhooks    []model.Webhook // active webhook subscriptions, nil until loaded
+	webhookWake chan struct{}
+}
+
+/*
+Option configures optional dependencies of URLService.
+*/
+type Option func(*URLService)
+
+/*
+WithReader routes read-only queries to a separate connection pool.
+*/
+func WithReader(reader *sqlx.DB) Option {
+	return func(s *URLService) {
+		s.Reader = reader
+	}
+}
+
+/*
+WithBaseURL sets the base of short links on the default domain, e.g. https://sho.rt.
+*/
+func WithBaseURL(base string) Option {
+	return func(s *URLService) {
+		s.BaseURL = strings.TrimRight(base, "/")
+	}
+}
+
+/*
+WithScreener rejects links to destinations the screener blocks.
+*/
+func WithScreener(screener *screening.Screener) Option {
+	return func(s *URLService) {
+		s.Screener = screener
+	}
+}
+
+/*
+WithClickFeed sets how many clicks a live subscriber may fall behind before it is disconnected.
+*/
+func WithClickFeed(buffer int) Option {
+	return func(s *URLService) {
+		s.Clicks = clickfeed.New(buffer)
+	}
 }
 
 /*
 NewURLService creates a new instance of URLService with the provided database connection.
 */
-func NewURLService(db *sqlx.DB) *URLService {
-	s := &URLService{DB: db}
-	s.UpdateURLCount()
+func NewURLService(database *sqlx.DB, opts ...Option) *URLService {
+	s := &URLService{DB: database, Reader: database, BaseURL: DefaultBaseURL, Clicks: clickfeed.New(clickfeed.DefaultBuffer)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Webhooks = s.Webhooks.withDefaults()
+	s.webhookWake = make(chan struct{}, 1)
+	s.writeStmts = db.NewStmtCache(s.DB)
+	s.readStmts = s.writeStmts
+	if s.Reader != s.DB {
+		s.readStmts = db.NewStmtCache(s.Reader)
+	}
+
+	s.UpdateURLCount(context.Background())
 	return s
 }
 
+/*
+Close releases the prepared statements. The databases are owned and closed by the caller.
+*/
+func (s *URLService) Close() error {
+	err := s.writeStmts.Close()
+	if s.readStmts != s.writeStmts {
+		if rerr := s.readStmts.Close(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+/*
+CreateOption sets optional properties of a link at creation.
+*/
+type CreateOption func(*createParams)
+
+type createParams struct {
+	domain    string
+	owner     string
+	password  string
+	maxClicks *int
+	rules     model.Rules
+	variants  model.Variants
+	options   model.RedirectOptions
+}
+
+/*
+WithDomain creates the link on a registered branded domain. The defaults of the domain
+apply to the settings not given by other options.
+*/
+func WithDomain(name string) CreateOption {
+	return func(p *createParams) {
+		p.domain = name
+	}
+}
+
+/*
+WithOwner records the display name of the authenticated creator, shown on the link preview.
+*/
+func WithOwner(name string) CreateOption {
+	return func(p *createParams) {
+		p.owner = name
+	}
+}
+
+/*
+WithPassword protects the link: the redirect asks for password before sending the visitor on.
+*/
+func WithPassword(password string) CreateOption {
+	return func(p *createParams) {
+		p.password = password
+	}
+}
+
+/*
+WithMaxClicks limits the link to n redirects, after which it is gone. One-time links use n = 1.
+*/
+func WithMaxClicks(n int) CreateOption {
+	return func(p *createParams) {
+		p.maxClicks = &n
+	}
+}
+
+/*
+WithRules sets conditional redirect rules, evaluated in order before falling back to the original URL.
+*/
+func WithRules(rs model.Rules) CreateOption {
+	return func(p *createParams) {
+		p.rules = rs
+	}
+}
+
+/*
+WithVariants splits the traffic of the link across weighted destinations.
+*/
+func WithVariants(vs model.Variants) CreateOption {
+	return func(p *createParams) {
+		p.variants = vs
+	}
+}
+
+/*
+WithRedirectOptions sets query forwarding, UTM parameters and path pass-through for the link.
+*/
+func WithRedirectOptions(o model.RedirectOptions) CreateOption {
+	return func(p *createParams) {
+		p.options = o
+	}
+}
+
 /*
 CreateShortURL generates a unique short code, saves it in the database and returns the shortened URL record.
+Returns ErrBlocked if the screener blocks the original URL or the destination of a rule or variant.
 */
-func (s *URLService) CreateShortURL(original string) (*model.URL, error) {
-	if original == "" {
-		return nil, errors.New("original URL cannot be empty")
+func (s *URLService) CreateShortURL(ctx context.Context, original string, opts ...CreateOption) (url *model.URL, err error) {
+	ctx, span := startSpan(ctx, "URLService.CreateShortURL")
+	defer func() { tracing.End(span, err) }()
+
+	if err := checkOriginal(original); err != nil {
+		return nil, err
+	}
+
+	var params createParams
+	for _, opt := range opts {
+		opt(&params)
+	}
+	var domainInterstitial int
+	if params.domain != "" {
+		d, err := s.GetDomain(ctx, params.domain)
+		if err != nil {
+			return nil, err
+		}
+		params.domain = d.Name
+		domainInterstitial = d.Interstitial
+		if params.maxClicks == nil {
+			params.maxClicks = d.Defaults.MaxClicks
+		}
+		if params.options == (model.RedirectOptions{}) {
+			params.options = d.Defaults.Options
+		}
+	}
+	if params.maxClicks != nil && *params.maxClicks <= 0 {
+		return nil, ErrMaxClicks
+	}
+	if err := rules.Validate(params.rules); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRules, err)
+	}
+	if err := rules.ValidateVariants(params.variants); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSplit, err)
+	}
+	if err := rules.ValidateOptions(params.options); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOpts, err)
+	}
+	if err := s.screen(ctx, destinations(original, params.rules, params.variants)...); err != nil {
+		return nil, err
+	}
+
+	var passwordHash string
+	if params.password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(params.password), bcrypt.DefaultCost)
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return nil, ErrPasswordLong
+		}
+		if err != nil {
+			return nil, err
+		}
+		passwordHash = string(hash)
 	}
 
 	short := generateShortCode(6)
 
-	// Ensure uniqueness of short code
+	// Ensure uniqueness of short code on the domain
 	for {
 		var exists int
-		err := s.DB.Get(&exists, "SELECT COUNT(*) FROM urls WHERE short = ?", short)
+		err := db.GetContext(ctx, s.writeStmts, &exists, "SELECT COUNT(*) FROM urls WHERE domain = ? AND short = ?", params.domain, short)
 		if err != nil {
 			return nil, err
 		}
@@ -82,54 +395,93 @@ func (s *URLService) CreateShortURL(original string) (*model.URL, error) {
 		short = generateShortCode(6)
 	}
 
-	url := &model.URL{
-		Original:  original,
-		Short:     short,
-		CreatedAt: time.Now(),
+	url = &model.URL{
+		Domain:       params.domain,
+		Original:     original,
+		Short:        short,
+		CreatedAt:    time.Now(),
+		Owner:        params.owner,
+		PasswordHash: passwordHash,
+		Protected:    passwordHash != "",
+		MaxClicks:    params.maxClicks,
+		ClicksLeft:   params.maxClicks,
+		Rules:        params.rules,
+		Variants:     params.variants,
+		Options:      params.options,
+
+		DomainInterstitial: domainInterstitial,
 	}
 
 	// Insert into database
-	query := `INSERT INTO urls (original, short, created_at) VALUES (?, ?, ?)`
-	result, err := s.DB.Exec(query, url.Original, url.Short, url.CreatedAt)
+	query := `INSERT INTO urls (domain, original, short, created_at, owner, password_hash, max_clicks, rules, variants, options)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := db.ExecContext(ctx, s.writeStmts, query,
+		url.Domain, url.Original, url.Short, url.CreatedAt, url.Owner, url.PasswordHash, url.MaxClicks, url.Rules, url.Variants, url.Options)
 	if err != nil {
 		return nil, err
 	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
-		log.Printf("Failed to get last insert ID: %v", err)
+		logger.FromContext(ctx).WarnContext(ctx, "failed to get last insert ID", "error", err)
 	}
+	span.SetAttributes(attribute.String("url.short", url.Short), attribute.String("url.domain", url.Domain))
 	url.ID = int(id)
+	s.setShortURL(url)
+	s.queueMetadata(ctx, url)
+	s.emit(ctx, EventLinkCreated, url, nil)
 
 	// Increase Prometheus counter and update gauge
 	urlsTotal.Inc()
-	s.UpdateURLCount()
+	s.UpdateURLCount(ctx)
 
 	return url, nil
 }
 
 /*
-GetOriginalURL retrieves the original URL by its short code.
-Returns an error if the URL does not exist.
+GetOriginalURL retrieves the original URL by its domain and short code.
+Returns ErrNotFound if the URL does not exist.
+*/
+func (s *URLService) GetOriginalURL(ctx context.Context, domain, short string) (_ *model.URL, err error) {
+	ctx, span := startSpan(ctx, "URLService.GetOriginalURL", linkAttrs(domain, short)...)
+	defer func() { tracing.End(span, err) }()
+
+	return s.getURL(ctx, s.readStmts, domain, short)
+}
+
+/*
+getURL loads a link through q. Returns ErrNotFound if the link does not exist.
 */
-func (s *URLService) GetOriginalURL(short string) (*model.URL, error) {
+func (s *URLService) getURL(ctx context.Context, q sqlx.QueryerContext, domain, short string) (*model.URL, error) {
 	var url model.URL
-	err := s.DB.Get(&url, "SELECT * FROM urls WHERE short = ?", short)
+	err := db.GetContext(ctx, q, &url, "SELECT "+urlColumns+" FROM urls WHERE domain = ? AND short = ?", domain, short)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("URL not found")
+			return nil, ErrNotFound
 		}
 		return nil, err
 	}
+	s.setShortURL(&url)
 	return &url, nil
 }
 
 /*
-DeleteURL removes a shortened URL from the database by its short code.
-Returns an error if the URL does not exist.
+DeleteURL removes a shortened URL from the database by its domain and short code.
+Returns ErrNotFound if the URL does not exist.
 */
-func (s *URLService) DeleteURL(short string) error {
-	result, err := s.DB.Exec("DELETE FROM urls WHERE short = ?", short)
+func (s *URLService) DeleteURL(ctx context.Context, domain, short string) (err error) {
+	ctx, span := startSpan(ctx, "URLService.DeleteURL", linkAttrs(domain, short)...)
+	defer func() { tracing.End(span, err) }()
+
+	// Subscribers get the link as it was, so it is loaded only if someone is listening
+	var deleted *model.URL
+	if len(s.subscribers(ctx, EventLinkDeleted)) > 0 {
+		if deleted, err = s.getURL(ctx, s.writeStmts, domain, short); err != nil {
+			return err
+		}
+	}
+
+	result, err := db.ExecContext(ctx, s.writeStmts, "DELETE FROM urls WHERE domain = ? AND short = ?", domain, short)
 	if err != nil {
 		return err
 	}
@@ -138,28 +490,507 @@ func (s *URLService) DeleteURL(short string) error {
 		return err
 	}
 	if rowsAffected == 0 {
-		return errors.New("URL not found")
+		return ErrNotFound
 	}
 
 	// Update the gauge after deletion
-	s.UpdateURLCount()
+	s.UpdateURLCount(ctx)
+	if deleted != nil {
+		s.emit(ctx, EventLinkDeleted, deleted, nil)
+	}
 
 	return nil
 }
 
 /*
-UpdateURLCount updates the Prometheus gauge with the current number of URLs in the database.
+UpdateRules replaces the redirect rules of a link. An empty list removes all rules.
+Returns ErrInvalidRules if a rule is malformed, ErrBlocked if a destination is blocked and ErrNotFound if the link does not exist.
+*/
+func (s *URLService) UpdateRules(ctx context.Context, domain, short string, rs model.Rules) (_ *model.URL, err error) {
+	ctx, span := startSpan(ctx, "URLService.UpdateRules", linkAttrs(domain, short)...)
+	defer func() { tracing.End(span, err) }()
+
+	if err := rules.Validate(rs); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRules, err)
+	}
+	if err := s.screen(ctx, destinations("", rs, nil)...); err != nil {
+		return nil, err
+	}
+
+	return s.updateColumn(ctx, domain, short, "rules", rs)
+}
+
+/*
+UpdateOptions replaces the redirect options of a link.
+Returns ErrInvalidOpts if they are malformed and ErrNotFound if the link does not exist.
+*/
+func (s *URLService) UpdateOptions(ctx context.Context, domain, short string, o model.RedirectOptions) (_ *model.URL, err error) {
+	ctx, span := startSpan(ctx, "URLService.UpdateOptions", linkAttrs(domain, short)...)
+	defer func() { tracing.End(span, err) }()
+
+	if err := rules.ValidateOptions(o); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOpts, err)
+	}
+	return s.updateColumn(ctx, domain, short, "options", o)
+}
+
+/*
+updateColumn sets one column of a link and returns the updated link.
+The column name must be a constant, never user input.
+*/
+func (s *URLService) updateColumn(ctx context.Context, domain, short, column string, value interface{}) (*model.URL, error) {
+	result, err := db.ExecContext(ctx, s.writeStmts, "UPDATE urls SET "+column+" = ? WHERE domain = ? AND short = ?", value, domain, short)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	url, err := s.getURL(ctx, s.writeStmts, domain, short)
+	if err != nil {
+		return nil, err
+	}
+	s.emit(ctx, EventLinkUpdated, url, nil)
+	return url, nil
+}
+
+/*
+UpdateVariants replaces the A/B variants of a link and resets their click counts,
+since counts of the old variants would not be comparable. An empty list removes the split.
+Returns ErrInvalidSplit if a variant is malformed, ErrBlocked if a destination is blocked and ErrNotFound if the link does not exist.
 */
-func (s *URLService) UpdateURLCount() {
-	var count int
-	err := s.DB.Get(&count, "SELECT COUNT(*) FROM urls")
+func (s *URLService) UpdateVariants(ctx context.Context, domain, short string, vs model.Variants) (_ *model.URL, err error) {
+	ctx, span := startSpan(ctx, "URLService.UpdateVariants", linkAttrs(domain, short)...)
+	defer func() { tracing.End(span, err) }()
+
+	if err := rules.ValidateVariants(vs); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSplit, err)
+	}
+	if err := s.screen(ctx, destinations("", nil, vs)...); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := db.ExecContext(ctx, tx, "UPDATE urls SET variants = ? WHERE domain = ? AND short = ?", vs, domain, short)
 	if err != nil {
-		if !errors.Is(err, sql.ErrNoRows) {
-			log.Printf("Failed to count URLs: %v", err)
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	if _, err := db.ExecContext(ctx, tx,
+		"DELETE FROM variant_clicks WHERE url_id = (SELECT id FROM urls WHERE domain = ? AND short = ?)", domain, short); err != nil {
+		return nil, err
+	}
+
+	url, err := s.getURL(ctx, tx, domain, short)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.emit(ctx, EventLinkUpdated, url, nil)
+	return url, nil
+}
+
+/*
+RegisterClick counts a redirect of the link, and of the given A/B variant unless it is negative.
+For links with a click limit the check and the increment are a single conditional UPDATE,
+so concurrent redirects can never exceed the limit.
+Returns ErrGone once the limit is reached and ErrNotFound if the link does not exist.
+*/
+func (s *URLService) RegisterClick(ctx context.Context, domain, short string, variant int) (err error) {
+	ctx, span := startSpan(ctx, "URLService.RegisterClick", linkAttrs(domain, short)...)
+	defer func() { tracing.End(span, err) }()
+
+	if variant >= 0 {
+		span.SetAttributes(attribute.Int("url.variant", variant))
+	}
+	rowsAffected, err := s.consumeClick(ctx, domain, short, variant)
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 1 {
+		s.publishClick(domain, short, variant)
+		s.emitClick(ctx, domain, short)
+		return nil
+	}
+
+	var exists int
+	if err := db.GetContext(ctx, s.writeStmts, &exists, "SELECT COUNT(*) FROM urls WHERE domain = ? AND short = ?", domain, short); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrNotFound
+	}
+	return ErrGone
+}
+
+/*
+emitClick queues link.clicked and, for the click that used up the limit, link.expired.
+The link is loaded only if one of the events has subscribers, so redirects stay cheap without them.
+*/
+func (s *URLService) emitClick(ctx context.Context, domain, short string) {
+	clicked := len(s.subscribers(ctx, EventLinkClicked)) > 0
+	expired := len(s.subscribers(ctx, EventLinkExpired)) > 0
+	if !clicked && !expired {
+		return
+	}
+	url, err := s.getURL(ctx, s.writeStmts, domain, short)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "failed to load clicked link", "domain", domain, "short", short, "error", err)
+		return
+	}
+	if clicked {
+		s.emit(ctx, EventLinkClicked, url, nil)
+	}
+	if expired && url.ClicksLeft != nil && *url.ClicksLeft <= 0 {
+		s.emit(ctx, EventLinkExpired, url, nil)
+	}
+}
+
+/*
+consumeClick increments the click counter if the limit allows it and returns the number of rows updated.
+The variant counter is incremented in the same transaction. The transaction is finished before returning,
+because the single writer connection is needed for any further query.
+*/
+func (s *URLService) consumeClick(ctx context.Context, domain, short string, variant int) (int64, error) {
+	const consume = "UPDATE urls SET clicks = clicks + 1 WHERE domain = ? AND short = ? AND (max_clicks IS NULL OR clicks < max_clicks)"
+
+	if variant < 0 {
+		result, err := db.ExecContext(ctx, s.writeStmts, consume, domain, short)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := db.ExecContext(ctx, tx, consume, domain, short)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return rowsAffected, err
+	}
+	_, err = db.ExecContext(ctx, tx, `
+		INSERT INTO variant_clicks (url_id, variant, clicks) SELECT id, ?, 1 FROM urls WHERE domain = ? AND short = ?
+		ON CONFLICT (url_id, variant) DO UPDATE SET clicks = clicks + 1`, variant, domain, short)
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, tx.Commit()
+}
+
+/*
+Stats returns the click counts of a link, including every A/B variant.
+Returns ErrNotFound if the link does not exist.
+*/
+func (s *URLService) Stats(ctx context.Context, domain, short string) (_ *model.Stats, err error) {
+	ctx, span := startSpan(ctx, "URLService.Stats", linkAttrs(domain, short)...)
+	defer func() { tracing.End(span, err) }()
+
+	url, err := s.getURL(ctx, s.readStmts, domain, short)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &model.Stats{Short: url.Short, Clicks: url.Clicks, MaxClicks: url.MaxClicks, ClicksLeft: url.ClicksLeft}
+	if len(url.Variants) == 0 {
+		return stats, nil
+	}
+
+	var counts []struct {
+		Variant int `db:"variant"`
+		Clicks  int `db:"clicks"`
+	}
+	if err := db.SelectContext(ctx, s.readStmts, &counts,
+		"SELECT variant, clicks FROM variant_clicks WHERE url_id = ?", url.ID); err != nil {
+		return nil, err
+	}
+	stats.Variants = make([]model.VariantStats, len(url.Variants))
+	for i, v := range url.Variants {
+		stats.Variants[i].Variant = v
+	}
+	for _, c := range counts {
+		if c.Variant >= 0 && c.Variant < len(stats.Variants) {
+			stats.Variants[c.Variant].Clicks = c.Clicks
 		}
+	}
+	return stats, nil
+}
+
+/*
+CheckPassword reports whether password unlocks the link. Links without a password accept any input.
+*/
+func CheckPassword(url *model.URL, password string) bool {
+	if url.PasswordHash == "" {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(url.PasswordHash), []byte(password)) == nil
+}
+
+/*
+ListURLs returns links of all domains whose original URL or short code contains query, newest first,
+together with the total number of matches for pagination. An empty query matches all links,
+and an empty owner the links of all owners.
+*/
+func (s *URLService) ListURLs(ctx context.Context, owner, query string, limit, offset int) (urls []model.URL, total int, err error) {
+	ctx, span := startSpan(ctx, "URLService.ListURLs", attribute.String("owner", owner), attribute.String("query", query))
+	defer func() { tracing.End(span, err) }()
+
+	pattern := "%" + escapeLike(query) + "%"
+	err = db.GetContext(ctx, s.readStmts, &total,
+		`SELECT COUNT(*) FROM urls WHERE (? = '' OR owner = ?) AND (original LIKE ? ESCAPE '\' OR short LIKE ? ESCAPE '\')`,
+		owner, owner, pattern, pattern)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = db.SelectContext(ctx, s.readStmts, &urls,
+		"SELECT "+urlColumns+` FROM urls WHERE (? = '' OR owner = ?) AND (original LIKE ? ESCAPE '\' OR short LIKE ? ESCAPE '\')
+		ORDER BY id DESC LIMIT ? OFFSET ?`,
+		owner, owner, pattern, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range urls {
+		s.setShortURL(&urls[i])
+	}
+	return urls, total, nil
+}
+
+/*
+UpdateURL changes the destination of an existing short link. Its metadata is cleared and fetched again.
+Returns ErrEmptyURL for an empty destination, ErrInvalidURL for one that is not http or https, ErrBlocked for a blocked one and ErrNotFound if the link does not exist.
+*/
+func (s *URLService) UpdateURL(ctx context.Context, domain, short, original string) (_ *model.URL, err error) {
+	ctx, span := startSpan(ctx, "URLService.UpdateURL", linkAttrs(domain, short)...)
+	defer func() { tracing.End(span, err) }()
+
+	if err := checkOriginal(original); err != nil {
+		return nil, err
+	}
+	if err := s.screen(ctx, original); err != nil {
+		return nil, err
+	}
+
+	// The metadata described the previous destination
+	result, err := db.ExecContext(ctx, s.writeStmts, "UPDATE urls SET original = ?, metadata = '' WHERE domain = ? AND short = ?", original, domain, short)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrNotFound
+	}
+	url, err := s.getURL(ctx, s.writeStmts, domain, short)
+	if err != nil {
+		return nil, err
+	}
+	s.queueMetadata(ctx, url)
+	s.emit(ctx, EventLinkUpdated, url, nil)
+	return url, nil
+}
+
+/*
+checkOriginal accepts only absolute http and https URLs as destinations. Other schemes, such as
+javascript: and data:, would run in the origin of the shortener on the interstitial page.
+*/
+func checkOriginal(original string) error {
+	if original == "" {
+		return ErrEmptyURL
+	}
+	// Stray percent signs, which browsers accept, do not make the destination invalid
+	u, err := neturl.Parse(strings.ReplaceAll(original, "%", "%25"))
+	if err != nil || safehttp.CheckURL(u) != nil {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+/*
+UpdateURLCount updates the Prometheus gauges from the url_counters and url_owner_counters tables.
+The counters are maintained by triggers in the same transaction as every insert, delete and click,
+so reading them is cheap regardless of the table size. The number of broken links is
+counted through a partial index on the few rows that are broken.
+*/
+func (s *URLService) UpdateURLCount(ctx context.Context) {
+	ctx, span := startSpan(ctx, "URLService.UpdateURLCount")
+	defer span.End()
+
+	var counters []struct {
+		Name  string `db:"name"`
+		Value int64  `db:"value"`
+	}
+	if err := db.SelectContext(ctx, s.readStmts, &counters, "SELECT name, value FROM url_counters"); err != nil {
+		span.RecordError(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "failed to read URL counters", "error", err)
+		return
+	}
+
+	// Expired links are stored and counted among the active ones, but reported separately
+	values := make(map[string]int64, len(counters))
+	for _, c := range counters {
+		values[c.Name] = c.Value
+	}
+	urlsInDB.Set(float64(values["active"]))
+	urlsByState.WithLabelValues("active").Set(float64(values["active"] - values["expired"]))
+	urlsByState.WithLabelValues("expired").Set(float64(values["expired"]))
+	urlsByState.WithLabelValues("deleted").Set(float64(values["deleted"]))
+
+	var broken int
+	if err := db.GetContext(ctx, s.readStmts, &broken, "SELECT COUNT(*) FROM link_health WHERE broken = 1"); err != nil {
+		span.RecordError(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "failed to count broken links", "error", err)
+		return
+	}
+	brokenLinks.Set(float64(broken))
+
+	var owners []ownerCount
+	if err := db.SelectContext(ctx, s.readStmts, &owners,
+		"SELECT owner, value FROM url_owner_counters WHERE value > 0 ORDER BY value DESC, owner LIMIT ?", ownerSeriesLimit); err != nil {
+		span.RecordError(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "failed to read owner counters", "error", err)
 		return
 	}
-	urlsInDB.Set(float64(count))
+	var owned int64
+	if err := db.GetContext(ctx, s.readStmts, &owned, "SELECT COALESCE(SUM(value), 0) FROM url_owner_counters"); err != nil {
+		span.RecordError(err)
+		logger.FromContext(ctx).ErrorContext(ctx, "failed to read owner counters", "error", err)
+		return
+	}
+	setOwnerSeries(owners, owned)
+}
+
+// ownerCount is a row of the url_owner_counters table.
+type ownerCount struct {
+	Owner string `db:"owner"`
+	Value int64  `db:"value"`
+}
+
+/*
+setOwnerSeries exports the link counts of the largest owners and sums up the links of all others,
+out of total, as "other". The series of owners that dropped out are deleted only after the new
+values are set, so that a scrape never finds the gauge empty.
+*/
+func setOwnerSeries(top []ownerCount, total int64) {
+	ownerSeries.Lock()
+	defer ownerSeries.Unlock()
+
+	labels := make(map[string]bool, len(top)+1)
+	rest := total
+	for _, o := range top {
+		urlsByOwner.WithLabelValues(o.Owner).Set(float64(o.Value))
+		labels[o.Owner] = true
+		rest -= o.Value
+	}
+	if rest > 0 {
+		urlsByOwner.WithLabelValues(otherOwners).Set(float64(rest))
+		labels[otherOwners] = true
+	}
+	for label := range ownerSeries.labels {
+		if !labels[label] {
+			urlsByOwner.DeleteLabelValues(label)
+		}
+	}
+	ownerSeries.labels = labels
+}
+
+/*
+ReconcileURLCount recounts the urls table and corrects the active and expired counters if they drifted,
+e.g. after rows were changed by hand with triggers disabled. The counts per owner are rebuilt as well.
+It performs full table scans and is meant to run rarely in the background.
+*/
+func (s *URLService) ReconcileURLCount(ctx context.Context) (err error) {
+	ctx, span := startSpan(ctx, "URLService.ReconcileURLCount")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	counters := []struct{ name, count string }{
+		{"active", "SELECT COUNT(*) FROM urls"},
+		{"expired", "SELECT COUNT(*) FROM urls WHERE max_clicks IS NOT NULL AND clicks >= max_clicks"},
+	}
+	for _, c := range counters {
+		var actual, stored int64
+		if err := db.GetContext(ctx, tx, &actual, c.count); err != nil {
+			return err
+		}
+		if err := db.GetContext(ctx, tx, &stored, "SELECT value FROM url_counters WHERE name = ?", c.name); err != nil {
+			return err
+		}
+
+		if drift := actual - stored; drift != 0 {
+			if _, err := db.ExecContext(ctx, tx, "UPDATE url_counters SET value = ? WHERE name = ?", actual, c.name); err != nil {
+				return err
+			}
+			if drift < 0 {
+				drift = -drift
+			}
+			urlCountDrift.Add(float64(drift))
+			logger.FromContext(ctx).WarnContext(ctx, "corrected URL count drift", "counter", c.name, "stored", stored, "actual", actual)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, tx, "DELETE FROM url_owner_counters"); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, tx, "INSERT INTO url_owner_counters (owner, value) SELECT owner, COUNT(*) FROM urls GROUP BY owner"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.UpdateURLCount(ctx)
+	return nil
+}
+
+/*
+startSpan opens an internal span for a service method.
+*/
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+/*
+linkAttrs returns the span attributes identifying a link.
+*/
+func linkAttrs(domain, short string) []attribute.KeyValue {
+	return []attribute.KeyValue{attribute.String("url.domain", domain), attribute.String("url.short", short)}
+}
+
+/*
+escapeLike escapes the LIKE wildcards in s so that it is matched literally.
+*/
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
 
 /*
@@ -168,7 +999,7 @@ generateShortCode creates a random, URL-safe short code of given length.
 func generateShortCode(length int) string {
 	b := make([]byte, length)
 	if _, err := rand.Read(b); err != nil {
-		log.Printf("Error generating random bytes: %v", err)
+		slog.Error("failed to generate random bytes", "error", err)
 	}
 
 	// Encode to URL-safe base64 and trim padding
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zen-flo/url-shortener/internal/db"
+)
+
+// setupFileService opens a file-backed database with a separate read pool,
+// the same way the server does in production.
+func setupFileService(tb testing.TB) *URLService {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "urls.db")
+
+	writer, err := db.InitDB(path)
+	if err != nil {
+		tb.Fatalf("InitDB failed: %v", err)
+	}
+	reader, err := db.OpenReader(path, db.DefaultReadConns)
+	if err != nil {
+		tb.Fatalf("OpenReader failed: %v", err)
+	}
+
+	s := NewURLService(writer, WithReader(reader))
+	tb.Cleanup(func() {
+		_ = s.Close()
+		_ = reader.Close()
+		_ = writer.Close()
+	})
+	return s
+}
+
+// Concurrent writers and readers must not fail with SQLITE_BUSY / "database is locked".
+func TestConcurrentCreateAndLookup(t *testing.T) {
+	s := setupFileService(t)
+	ctx := context.Background()
+
+	const workers, perWorker = 8, 25
+
+	var (
+		wg     sync.WaitGroup
+		shorts sync.Map
+		failed atomic.Int64
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				url, err := s.CreateShortURL(ctx, "https://example.com")
+				if err != nil {
+					t.Errorf("CreateShortURL failed: %v", err)
+					failed.Add(1)
+					continue
+				}
+				shorts.Store(url.Short, struct{}{})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				shorts.Range(func(key, _ interface{}) bool {
+					if _, err := s.GetOriginalURL(ctx, "", key.(string)); err != nil {
+						t.Errorf("GetOriginalURL failed: %v", err)
+						failed.Add(1)
+					}
+					return false
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if failed.Load() > 0 {
+		t.Fatalf("%d operations failed under concurrency", failed.Load())
+	}
+
+	var count int
+	if err := s.Reader.Get(&count, "SELECT COUNT(*) FROM urls"); err != nil {
+		t.Fatalf("failed to count urls: %v", err)
+	}
+	if count != workers*perWorker {
+		t.Errorf("expected %d urls, got %d", workers*perWorker, count)
+	}
+}
+
+// BenchmarkCreateLookupParallel measures throughput of a mixed workload:
+// one create for every nine lookups, as redirects dominate real traffic.
+//
+//	go test ./internal/service -run '^$' -bench CreateLookupParallel -cpu 1,4,8
+func BenchmarkCreateLookupParallel(b *testing.B) {
+	s := setupFileService(b)
+	ctx := context.Background()
+
+	seed, err := s.CreateShortURL(ctx, "https://example.com")
+	if err != nil {
+		b.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	var creates, lookups atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if i%10 == 0 {
+				if _, err := s.CreateShortURL(ctx, "https://example.com"); err != nil {
+					b.Errorf("CreateShortURL failed: %v", err)
+				}
+				creates.Add(1)
+			} else {
+				if _, err := s.GetOriginalURL(ctx, "", seed.Short); err != nil {
+					b.Errorf("GetOriginalURL failed: %v", err)
+				}
+				lookups.Add(1)
+			}
+			i++
+		}
+	})
+
+	elapsed := b.Elapsed().Seconds()
+	b.ReportMetric(float64(creates.Load())/elapsed, "creates/s")
+	b.ReportMetric(float64(lookups.Load())/elapsed, "lookups/s")
+}
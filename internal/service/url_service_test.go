@@ -1,39 +1,41 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	_ "modernc.org/sqlite" // SQLite driver
 )
 
 func setupTestDB(t *testing.T) *sqlx.DB {
-	db, err := sqlx.Connect("sqlite", ":memory:")
+	database, err := db.InitDB(":memory:")
 	if err != nil {
 		t.Fatalf("failed to connect to in-memory DB: %v", err)
 	}
-	schema := `
-	CREATE TABLE urls (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		original TEXT NOT NULL,
-		short TEXT NOT NULL UNIQUE,
-		created_at DATETIME NOT NULL
-	);`
-	if _, err := db.Exec(schema); err != nil {
-		t.Fatalf("failed to create table: %v", err)
-	}
-	return db
+	t.Cleanup(func() { _ = database.Close() })
+	return database
 }
 
 func TestCreateGetDeleteURL(t *testing.T) {
-	db := setupTestDB(t)
-	service := NewURLService(db)
+	service := NewURLService(setupTestDB(t))
+	ctx := context.Background()
 
 	original := "https://example.com"
 
 	// Test CreateShortURL
-	url, err := service.CreateShortURL(original)
+	url, err := service.CreateShortURL(ctx, original)
 	if err != nil {
 		t.Fatalf("CreateShortURL failed: %v", err)
 	}
@@ -49,7 +51,7 @@ func TestCreateGetDeleteURL(t *testing.T) {
 	}
 
 	// Test GetOriginalURL
-	got, err := service.GetOriginalURL(url.Short)
+	got, err := service.GetOriginalURL(ctx, "", url.Short)
 	if err != nil {
 		t.Fatalf("GetOriginalURL failed: %v", err)
 	}
@@ -58,14 +60,445 @@ func TestCreateGetDeleteURL(t *testing.T) {
 	}
 
 	// Test DeleteURL
-	err = service.DeleteURL(url.Short)
+	err = service.DeleteURL(ctx, "", url.Short)
 	if err != nil {
 		t.Fatalf("DeleteURL failed: %v", err)
 	}
 
 	// Verify deletion
-	_, err = service.GetOriginalURL(url.Short)
-	if err == nil {
-		t.Errorf("expected error after deletion, got nil")
+	_, err = service.GetOriginalURL(ctx, "", url.Short)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after deletion, got %v", err)
+	}
+
+	// Deleting again reports ErrNotFound
+	if err := service.DeleteURL(ctx, "", url.Short); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound on second delete, got %v", err)
+	}
+}
+
+func TestCreateShortURLEmpty(t *testing.T) {
+	service := NewURLService(setupTestDB(t))
+
+	if _, err := service.CreateShortURL(context.Background(), ""); !errors.Is(err, ErrEmptyURL) {
+		t.Errorf("expected ErrEmptyURL, got %v", err)
+	}
+}
+
+func TestCreateShortURLInvalid(t *testing.T) {
+	service := NewURLService(setupTestDB(t))
+	ctx := context.Background()
+
+	// Destinations other than web pages would run as script on the interstitial page
+	for _, original := range []string{"javascript:alert(document.cookie)", "data:text/html,<script>alert(1)</script>", "example.com", "https://"} {
+		if _, err := service.CreateShortURL(ctx, original); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("expected ErrInvalidURL for %q, got %v", original, err)
+		}
+	}
+
+	url, err := service.CreateShortURL(ctx, "https://example.com")
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	if _, err := service.UpdateURL(ctx, "", url.Short, "javascript:alert(1)"); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("expected ErrInvalidURL on update, got %v", err)
+	}
+	if _, err := service.UpdateRules(ctx, "", url.Short, model.Rules{{Destination: "javascript:alert(1)"}}); !errors.Is(err, ErrInvalidRules) {
+		t.Errorf("expected ErrInvalidRules for a javascript: rule, got %v", err)
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	service := NewURLService(setupTestDB(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := service.CreateShortURL(ctx, "https://example.com"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if _, err := service.GetOriginalURL(ctx, "", "abc123"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestServiceSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(sdktrace.NewTracerProvider()) })
+
+	service := NewURLService(setupTestDB(t))
+	exporter.Reset()
+
+	if _, err := service.GetOriginalURL(context.Background(), "", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	// The SQL span ends first and is a child of the service span
+	query, method := spans[0], spans[1]
+	if query.Name != "sqlite SELECT" {
+		t.Errorf("expected SQL span %q, got %q", "sqlite SELECT", query.Name)
+	}
+	if method.Name != "URLService.GetOriginalURL" {
+		t.Errorf("expected service span %q, got %q", "URLService.GetOriginalURL", method.Name)
+	}
+	if query.Parent.SpanID() != method.SpanContext.SpanID() {
+		t.Errorf("expected SQL span to be a child of the service span")
+	}
+}
+
+func TestURLCountMaintainedIncrementally(t *testing.T) {
+	database := setupTestDB(t)
+	service := NewURLService(database)
+	ctx := context.Background()
+
+	var shorts []string
+	for i := 0; i < 3; i++ {
+		url, err := service.CreateShortURL(ctx, "https://example.com")
+		if err != nil {
+			t.Fatalf("CreateShortURL failed: %v", err)
+		}
+		shorts = append(shorts, url.Short)
+	}
+	if err := service.DeleteURL(ctx, "", shorts[0]); err != nil {
+		t.Fatalf("DeleteURL failed: %v", err)
+	}
+
+	if got := testutil.ToFloat64(urlsInDB); got != 2 {
+		t.Errorf("expected urls_in_db=2, got %v", got)
+	}
+	if got := testutil.ToFloat64(urlsByState.WithLabelValues("deleted")); got != 1 {
+		t.Errorf("expected urls_by_state{state=deleted}=1, got %v", got)
+	}
+
+	// A link that used up its clicks is expired rather than active
+	once, err := service.CreateShortURL(ctx, "https://example.com", WithMaxClicks(1))
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	if err := service.RegisterClick(ctx, "", once.Short, -1); err != nil {
+		t.Fatalf("RegisterClick failed: %v", err)
+	}
+	service.UpdateURLCount(ctx)
+	if got := testutil.ToFloat64(urlsByState.WithLabelValues("expired")); got != 1 {
+		t.Errorf("expected urls_by_state{state=expired}=1, got %v", got)
+	}
+	if got := testutil.ToFloat64(urlsByState.WithLabelValues("active")); got != 2 {
+		t.Errorf("expected urls_by_state{state=active}=2, got %v", got)
+	}
+}
+
+func TestURLsByOwnerCapped(t *testing.T) {
+	database := setupTestDB(t)
+	service := NewURLService(database)
+	ctx := context.Background()
+
+	// The largest owner gets two links, so it is always among the exported ones
+	owners := []string{"key:big", "key:big"}
+	for i := 0; i < ownerSeriesLimit+2; i++ {
+		owners = append(owners, fmt.Sprintf("key:%02d", i))
+	}
+	for _, owner := range owners {
+		if _, err := service.CreateShortURL(ctx, "https://example.com", WithOwner(owner)); err != nil {
+			t.Fatalf("CreateShortURL failed: %v", err)
+		}
+	}
+
+	service.UpdateURLCount(ctx)
+	if got := testutil.ToFloat64(urlsByOwner.WithLabelValues("key:big")); got != 2 {
+		t.Errorf("expected urls_by_owner{owner=key:big}=2, got %v", got)
+	}
+	if got := testutil.ToFloat64(urlsByOwner.WithLabelValues(otherOwners)); got != 3 {
+		t.Errorf("expected the links beyond the top %d owners to count as other, got %v", ownerSeriesLimit, got)
+	}
+
+	// An owner that no longer has links loses its series
+	if _, err := database.Exec("DELETE FROM urls WHERE owner = 'key:big'"); err != nil {
+		t.Fatalf("failed to delete links: %v", err)
+	}
+	service.UpdateURLCount(ctx)
+	if urlsByOwner.DeleteLabelValues("key:big") {
+		t.Errorf("expected the series of an owner without links to be deleted")
+	}
+	if got := testutil.CollectAndCount(urlsByOwner); got != ownerSeriesLimit+1 {
+		t.Errorf("expected %d owner series, got %d", ownerSeriesLimit+1, got)
+	}
+}
+
+func TestReconcileURLCount(t *testing.T) {
+	database := setupTestDB(t)
+	service := NewURLService(database)
+	ctx := context.Background()
+
+	if _, err := service.CreateShortURL(ctx, "https://example.com"); err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	// Simulate drift, e.g. after a manual fix-up with triggers disabled
+	if _, err := database.Exec("UPDATE url_counters SET value = 42 WHERE name = 'active'"); err != nil {
+		t.Fatalf("failed to corrupt counter: %v", err)
+	}
+
+	if err := service.ReconcileURLCount(ctx); err != nil {
+		t.Fatalf("ReconcileURLCount failed: %v", err)
+	}
+
+	if got := testutil.ToFloat64(urlsInDB); got != 1 {
+		t.Errorf("expected urls_in_db=1 after reconciliation, got %v", got)
+	}
+}
+
+func TestListAndUpdateURLs(t *testing.T) {
+	service := NewURLService(setupTestDB(t))
+	ctx := context.Background()
+
+	for _, original := range []string{"https://example.com/a", "https://example.org/b", "https://example.com/100%_off"} {
+		if _, err := service.CreateShortURL(ctx, original); err != nil {
+			t.Fatalf("CreateShortURL failed: %v", err)
+		}
+	}
+
+	urls, total, err := service.ListURLs(ctx, "", "example.com", 10, 0)
+	if err != nil {
+		t.Fatalf("ListURLs failed: %v", err)
+	}
+	if total != 2 || len(urls) != 2 {
+		t.Fatalf("expected 2 matches, got total=%d len=%d", total, len(urls))
+	}
+	if urls[0].Original != "https://example.com/100%_off" {
+		t.Errorf("expected newest link first, got %s", urls[0].Original)
+	}
+
+	// Wildcards in the query are matched literally
+	if _, total, _ := service.ListURLs(ctx, "", "%_", 10, 0); total != 1 {
+		t.Errorf("expected 1 literal match for %%_, got %d", total)
+	}
+
+	urls, total, err = service.ListURLs(ctx, "", "", 1, 1)
+	if err != nil || total != 3 || len(urls) != 1 {
+		t.Fatalf("expected one link of 3 on the second page, got total=%d len=%d err=%v", total, len(urls), err)
+	}
+
+	// An owner sees only their own links
+	if _, err := service.CreateShortURL(ctx, "https://example.com/mine", WithOwner("marketing")); err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	if mine, total, _ := service.ListURLs(ctx, "marketing", "example.com", 10, 0); total != 1 || len(mine) != 1 || mine[0].Owner != "marketing" {
+		t.Errorf("expected only the link of marketing, got total=%d %+v", total, mine)
+	}
+	if _, total, _ := service.ListURLs(ctx, "sales", "", 10, 0); total != 0 {
+		t.Errorf("expected no links of sales, got %d", total)
+	}
+
+	updated, err := service.UpdateURL(ctx, "", urls[0].Short, "https://example.net")
+	if err != nil {
+		t.Fatalf("UpdateURL failed: %v", err)
+	}
+	if updated.Original != "https://example.net" || updated.Short != urls[0].Short {
+		t.Errorf("unexpected updated URL %+v", updated)
+	}
+
+	if _, err := service.UpdateURL(ctx, "", "missing", "https://example.net"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := service.UpdateURL(ctx, "", urls[0].Short, ""); !errors.Is(err, ErrEmptyURL) {
+		t.Errorf("expected ErrEmptyURL, got %v", err)
+	}
+}
+
+func TestCreatePasswordProtectedURL(t *testing.T) {
+	service := NewURLService(setupTestDB(t))
+	ctx := context.Background()
+
+	url, err := service.CreateShortURL(ctx, "https://example.com", WithPassword("s3cret"))
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	got, err := service.GetOriginalURL(ctx, "", url.Short)
+	if err != nil {
+		t.Fatalf("GetOriginalURL failed: %v", err)
+	}
+	if !got.Protected || got.PasswordHash == "s3cret" {
+		t.Fatalf("expected a hashed password, got %+v", got)
+	}
+	if !CheckPassword(got, "s3cret") || CheckPassword(got, "wrong") {
+		t.Error("CheckPassword must accept only the link password")
+	}
+
+	if _, err := service.CreateShortURL(ctx, "https://example.com", WithPassword(strings.Repeat("x", 73))); !errors.Is(err, ErrPasswordLong) {
+		t.Errorf("expected ErrPasswordLong, got %v", err)
+	}
+}
+
+func TestRegisterClickLimitUnderConcurrency(t *testing.T) {
+	service := setupFileService(t)
+	ctx := context.Background()
+
+	url, err := service.CreateShortURL(ctx, "https://example.com/onboarding", WithMaxClicks(3))
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	const workers = 20
+	var wg sync.WaitGroup
+	results := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- service.RegisterClick(ctx, "", url.Short, -1)
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var ok, gone int
+	for err := range results {
+		switch {
+		case err == nil:
+			ok++
+		case errors.Is(err, ErrGone):
+			gone++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if ok != 3 || gone != workers-3 {
+		t.Errorf("expected exactly 3 clicks to succeed, got %d ok and %d gone", ok, gone)
+	}
+
+	got, err := service.GetOriginalURL(ctx, "", url.Short)
+	if err != nil {
+		t.Fatalf("GetOriginalURL failed: %v", err)
+	}
+	if got.Clicks != 3 || got.ClicksLeft == nil || *got.ClicksLeft != 0 {
+		t.Errorf("expected 3 clicks and none left, got %+v", got)
+	}
+
+	if err := service.RegisterClick(ctx, "", "missing", -1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := service.CreateShortURL(ctx, "https://example.com", WithMaxClicks(0)); !errors.Is(err, ErrMaxClicks) {
+		t.Errorf("expected ErrMaxClicks, got %v", err)
+	}
+}
+
+func TestUpdateRules(t *testing.T) {
+	service := NewURLService(setupTestDB(t))
+	ctx := context.Background()
+
+	url, err := service.CreateShortURL(ctx, "https://example.com",
+		WithRules(model.Rules{{Destination: "https://example.de", Language: []string{"DE"}}}))
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	got, err := service.GetOriginalURL(ctx, "", url.Short)
+	if err != nil {
+		t.Fatalf("GetOriginalURL failed: %v", err)
+	}
+	if len(got.Rules) != 1 || got.Rules[0].Language[0] != "de" {
+		t.Fatalf("expected the normalized rule to be stored, got %+v", got.Rules)
+	}
+
+	updated, err := service.UpdateRules(ctx, "", url.Short, nil)
+	if err != nil || len(updated.Rules) != 0 {
+		t.Fatalf("expected rules to be removed, got %+v (err %v)", updated, err)
+	}
+
+	if _, err := service.UpdateRules(ctx, "", url.Short, model.Rules{{Destination: "nope"}}); !errors.Is(err, ErrInvalidRules) {
+		t.Errorf("expected ErrInvalidRules, got %v", err)
+	}
+	if _, err := service.UpdateRules(ctx, "", "missing", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestVariantStats(t *testing.T) {
+	service := NewURLService(setupTestDB(t))
+	ctx := context.Background()
+
+	url, err := service.CreateShortURL(ctx, "https://example.com", WithVariants(model.Variants{
+		{Destination: "https://example.com/a", Weight: 70},
+		{Destination: "https://example.com/b", Weight: 30},
+	}))
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	for _, variant := range []int{0, 0, 1, -1} {
+		if err := service.RegisterClick(ctx, "", url.Short, variant); err != nil {
+			t.Fatalf("RegisterClick failed: %v", err)
+		}
+	}
+
+	stats, err := service.Stats(ctx, "", url.Short)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Clicks != 4 || len(stats.Variants) != 2 || stats.Variants[0].Clicks != 2 || stats.Variants[1].Clicks != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats.Variants[0].Name != "A" || stats.Variants[1].Weight != 30 {
+		t.Errorf("expected variant details in stats, got %+v", stats.Variants)
+	}
+
+	// Replacing the variants resets their counts
+	if _, err := service.UpdateVariants(ctx, "", url.Short, model.Variants{{Destination: "https://example.com/c", Weight: 1}}); err != nil {
+		t.Fatalf("UpdateVariants failed: %v", err)
+	}
+	stats, _ = service.Stats(ctx, "", url.Short)
+	if len(stats.Variants) != 1 || stats.Variants[0].Clicks != 0 {
+		t.Errorf("expected reset variant counts, got %+v", stats.Variants)
+	}
+
+	if _, err := service.UpdateVariants(ctx, "", url.Short, model.Variants{{Destination: "https://example.com", Weight: -1}}); !errors.Is(err, ErrInvalidSplit) {
+		t.Errorf("expected ErrInvalidSplit, got %v", err)
+	}
+	if _, err := service.Stats(ctx, "", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// Variant counts are removed together with the link
+	if err := service.DeleteURL(ctx, "", url.Short); err != nil {
+		t.Fatalf("DeleteURL failed: %v", err)
+	}
+	var orphans int
+	if err := service.DB.Get(&orphans, "SELECT COUNT(*) FROM variant_clicks"); err != nil || orphans != 0 {
+		t.Errorf("expected no orphaned variant counts, got %d (err %v)", orphans, err)
+	}
+}
+
+func TestRedirectOptions(t *testing.T) {
+	service := NewURLService(setupTestDB(t))
+	ctx := context.Background()
+
+	opts := model.RedirectOptions{ForwardQuery: model.ForwardQueryMerge, UTM: model.UTM{Source: "newsletter"}}
+	url, err := service.CreateShortURL(ctx, "https://example.com", WithRedirectOptions(opts))
+	if err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	got, err := service.GetOriginalURL(ctx, "", url.Short)
+	if err != nil || got.Options != opts {
+		t.Fatalf("expected the options to be stored, got %+v (err %v)", got, err)
+	}
+
+	updated, err := service.UpdateOptions(ctx, "", url.Short, model.RedirectOptions{})
+	if err != nil || updated.Options != (model.RedirectOptions{}) {
+		t.Fatalf("expected options to be removed, got %+v (err %v)", updated, err)
+	}
+
+	if _, err := service.UpdateOptions(ctx, "", url.Short, model.RedirectOptions{ForwardQuery: "append"}); !errors.Is(err, ErrInvalidOpts) {
+		t.Errorf("expected ErrInvalidOpts, got %v", err)
+	}
+	if _, err := service.UpdateOptions(ctx, "", "missing", model.RedirectOptions{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
 	}
 }
@@ -0,0 +1,455 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/safehttp"
+	"github.com/zen-flo/url-shortener/internal/tracing"
+)
+
+// Defaults of WebhookConfig.
+const (
+	DefaultWebhookWorkers   = 4
+	DefaultWebhookAttempts  = 10
+	DefaultWebhookRetryBase = 30 * time.Second
+	DefaultWebhookRetryMax  = 6 * time.Hour
+	DefaultWebhookTimeout   = 10 * time.Second
+	DefaultWebhookRetention = 7 * 24 * time.Hour
+)
+
+// Headers of webhook requests.
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature" // See SignWebhook
+	WebhookEventHeader     = "X-Webhook-Event"     // Event type, e.g. link.created
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"  // ID of the delivery, the same across retries
+)
+
+const (
+	webhookBatch         = 50 // deliveries sent at a time by DeliverWebhooks
+	webhookPruneInterval = time.Hour
+	webhookErrorLength   = 500 // longest error message stored with a delivery
+	webhookUserAgent     = "url-shortener-webhooks/1.0"
+)
+
+var (
+	webhookEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_events_total",
+			Help: "Total number of webhook deliveries queued, by event type.",
+		},
+		[]string{"event"},
+	)
+
+	webhookAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_delivery_attempts_total",
+			Help: "Total number of webhook requests, by result: delivered, failed (retried later) or dead (given up).",
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(webhookEvents)
+	prometheus.MustRegister(webhookAttempts)
+}
+
+/*
+WebhookConfig controls how queued webhook events are delivered.
+*/
+type WebhookConfig struct {
+	Client       *http.Client  // Client sending the requests; nil uses one that refuses non-public addresses
+	Timeout      time.Duration // Limit for one request of the default client
+	AllowPrivate bool          // Permits subscribers on non-public addresses with the default client, e.g. for local setups
+	Workers      int           // Requests sent at the same time
+	MaxAttempts  int           // Attempts after which a delivery is dead
+	RetryBase    time.Duration // Delay before the first retry, doubled for every further one
+	RetryMax     time.Duration // Longest delay between retries
+	Retention    time.Duration // How long delivered deliveries are kept; dead ones are kept until deleted with their webhook
+}
+
+/*
+withDefaults returns the configuration with unset fields replaced by the defaults.
+*/
+func (c WebhookConfig) withDefaults() WebhookConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultWebhookTimeout
+	}
+	// Subscriber URLs are user input, so neither they nor their redirects may reach internal addresses
+	if c.Client == nil {
+		c.Client = safehttp.NewClient(safehttp.Config{Timeout: c.Timeout, AllowPrivate: c.AllowPrivate})
+	}
+	if c.Workers < 1 {
+		c.Workers = DefaultWebhookWorkers
+	}
+	if c.MaxAttempts < 1 {
+		c.MaxAttempts = DefaultWebhookAttempts
+	}
+	if c.RetryBase <= 0 {
+		c.RetryBase = DefaultWebhookRetryBase
+	}
+	if c.RetryMax <= 0 {
+		c.RetryMax = DefaultWebhookRetryMax
+	}
+	if c.Retention <= 0 {
+		c.Retention = DefaultWebhookRetention
+	}
+	return c
+}
+
+/*
+WithWebhooks sets how webhook events are delivered. Without it the defaults apply.
+*/
+func WithWebhooks(cfg WebhookConfig) Option {
+	return func(s *URLService) {
+		s.Webhooks = cfg
+	}
+}
+
+/*
+SignWebhook returns the X-Webhook-Signature header of a request body sent at t:
+"t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<body>">". Subscribers recompute
+the HMAC with their secret and reject old timestamps to prevent replays.
+*/
+func SignWebhook(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+dueDelivery is a pending delivery together with the endpoint and secret of its webhook.
+*/
+type dueDelivery struct {
+	ID        int    `db:"id"`
+	WebhookID int    `db:"webhook_id"`
+	Event     string `db:"event"`
+	Payload   string `db:"payload"`
+	Attempts  int    `db:"attempts"`
+	URL       string `db:"url"`
+	Secret    string `db:"secret"`
+}
+
+/*
+RunWebhookDispatcher delivers queued webhook events until ctx is done. It looks for due
+deliveries every interval and as soon as new events are queued, and removes old delivered
+deliveries once an hour. Events are delivered at least once and not necessarily in order.
+*/
+func (s *URLService) RunWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pruned time.Time
+	for {
+		for {
+			n, err := s.DeliverWebhooks(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.FromContext(ctx).ErrorContext(ctx, "failed to deliver webhooks", "error", err)
+				}
+				break
+			}
+			if n < webhookBatch {
+				break
+			}
+		}
+		if time.Since(pruned) >= webhookPruneInterval {
+			pruned = time.Now()
+			if _, err := s.PruneWebhookDeliveries(ctx); err != nil && ctx.Err() == nil {
+				logger.FromContext(ctx).ErrorContext(ctx, "failed to prune webhook deliveries", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.webhookWake:
+		}
+	}
+}
+
+/*
+wakeWebhooks tells the dispatcher that deliveries are due, without waiting for it.
+*/
+func (s *URLService) wakeWebhooks() {
+	select {
+	case s.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+/*
+DeliverWebhooks sends up to one batch of due deliveries of active webhooks and records
+the results. A failed delivery is retried with exponential backoff until it runs out of
+attempts and is marked dead. Returns the number of deliveries attempted.
+*/
+func (s *URLService) DeliverWebhooks(ctx context.Context) (n int, err error) {
+	ctx, span := startSpan(ctx, "URLService.DeliverWebhooks")
+	defer func() {
+		span.SetAttributes(attribute.Int("webhook.deliveries", n))
+		tracing.End(span, err)
+	}()
+
+	var due []dueDelivery
+	err = db.SelectContext(ctx, s.writeStmts, &due, `SELECT d.id, d.webhook_id, d.event, d.payload, d.attempts, w.url, w.secret
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= ? AND w.active = 1
+		ORDER BY d.next_attempt_at LIMIT ?`, time.Now().UTC(), webhookBatch)
+	if err != nil || len(due) == 0 {
+		return 0, err
+	}
+
+	// The requests run concurrently; the results are stored afterwards on the single writer connection
+	results := make([]error, len(due))
+	statuses := make([]int, len(due))
+	sem := make(chan struct{}, s.Webhooks.Workers)
+	var wg sync.WaitGroup
+	for i := range due {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			statuses[i], results[i] = s.sendWebhook(ctx, &due[i])
+		})
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		// Requests cut short by shutdown are not the subscriber's fault, they stay due
+		return 0, err
+	}
+
+	for i := range due {
+		if err := s.recordAttempt(ctx, &due[i], statuses[i], results[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(due), nil
+}
+
+/*
+sendWebhook posts the payload of a delivery, signed with the secret of its webhook.
+Any response other than 2xx is an error.
+*/
+func (s *URLService) sendWebhook(ctx context.Context, d *dueDelivery) (int, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set(WebhookEventHeader, d.Event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.Itoa(d.ID))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(d.Secret, time.Now(), body))
+
+	resp, err := s.Webhooks.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Draining a little lets the connection be reused without reading a huge body
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+/*
+recordAttempt stores the result of a request: the delivery is delivered, scheduled for
+a retry or, after the last attempt, dead.
+*/
+func (s *URLService) recordAttempt(ctx context.Context, d *dueDelivery, status int, sendErr error) error {
+	now := time.Now().UTC()
+	attempts := d.Attempts + 1
+	log := logger.FromContext(ctx)
+
+	if sendErr == nil {
+		webhookAttempts.WithLabelValues("delivered").Inc()
+		_, err := db.ExecContext(ctx, s.writeStmts, `UPDATE webhook_deliveries
+			SET status = 'delivered', attempts = ?, last_status = ?, last_error = '', delivered_at = ? WHERE id = ?`,
+			attempts, status, now, d.ID)
+		return err
+	}
+
+	msg := sendErr.Error()
+	if len(msg) > webhookErrorLength {
+		msg = msg[:webhookErrorLength]
+	}
+	if attempts >= s.Webhooks.MaxAttempts {
+		webhookAttempts.WithLabelValues("dead").Inc()
+		log.WarnContext(ctx, "webhook delivery failed for the last time",
+			"delivery", d.ID, "webhook", d.WebhookID, "event", d.Event, "attempts", attempts, "error", msg)
+		_, err := db.ExecContext(ctx, s.writeStmts, `UPDATE webhook_deliveries
+			SET status = 'dead', attempts = ?, last_status = ?, last_error = ? WHERE id = ?`,
+			attempts, status, msg, d.ID)
+		return err
+	}
+
+	webhookAttempts.WithLabelValues("failed").Inc()
+	next := now.Add(s.retryDelay(attempts))
+	log.InfoContext(ctx, "webhook delivery failed, retrying later",
+		"delivery", d.ID, "webhook", d.WebhookID, "event", d.Event, "attempts", attempts, "next", next, "error", msg)
+	_, err := db.ExecContext(ctx, s.writeStmts, `UPDATE webhook_deliveries
+		SET attempts = ?, last_status = ?, last_error = ?, next_attempt_at = ? WHERE id = ?`,
+		attempts, status, msg, next, d.ID)
+	return err
+}
+
+/*
+retryDelay returns the delay after the given number of failed attempts: the base delay
+doubled for each attempt after the first, capped at the maximum, plus up to 10% jitter
+so that deliveries failing together do not retry together.
+*/
+func (s *URLService) retryDelay(attempts int) time.Duration {
+	delay := s.Webhooks.RetryBase
+	for i := 1; i < attempts && delay < s.Webhooks.RetryMax; i++ {
+		delay *= 2
+	}
+	delay = min(delay, s.Webhooks.RetryMax)
+	return delay + rand.N(delay/10+1)
+}
+
+/*
+ListWebhookDeliveries returns the latest deliveries of a webhook, newest first, optionally
+only those with the given status, e.g. the dead ones.
+Returns ErrWebhookNotFound if the webhook does not exist and ErrInvalidWebhook for an unknown status.
+*/
+func (s *URLService) ListWebhookDeliveries(ctx context.Context, webhookID int, status string, limit int) (deliveries []model.WebhookDelivery, err error) {
+	ctx, span := startSpan(ctx, "URLService.ListWebhookDeliveries", attribute.Int("webhook.id", webhookID))
+	defer func() { tracing.End(span, err) }()
+
+	switch status {
+	case "", model.DeliveryPending, model.DeliveryDelivered, model.DeliveryDead:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidWebhook, status)
+	}
+	if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	err = db.SelectContext(ctx, s.readStmts, &deliveries,
+		"SELECT * FROM webhook_deliveries WHERE webhook_id = ? AND (? = '' OR status = ?) ORDER BY id DESC LIMIT ?",
+		webhookID, status, status, limit)
+	return deliveries, err
+}
+
+/*
+WebhookBacklog counts the pending deliveries of active webhooks, and among them the ones
+that have been due since before overdueBefore, that is, the ones the dispatcher should
+have attempted already.
+*/
+func (s *URLService) WebhookBacklog(ctx context.Context, overdueBefore time.Time) (pending, overdue int, err error) {
+	ctx, span := startSpan(ctx, "URLService.WebhookBacklog")
+	defer func() {
+		span.SetAttributes(attribute.Int("webhook.pending", pending), attribute.Int("webhook.overdue", overdue))
+		tracing.End(span, err)
+	}()
+
+	var counts struct {
+		Pending int `db:"pending"`
+		Overdue int `db:"overdue"`
+	}
+	err = db.GetContext(ctx, s.readStmts, &counts, `SELECT COUNT(*) AS pending,
+			COALESCE(SUM(d.next_attempt_at <= ?), 0) AS overdue
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = 'pending' AND w.active = 1`, overdueBefore.UTC())
+	return counts.Pending, counts.Overdue, err
+}
+
+/*
+RedeliverWebhook queues a delivery again with a fresh set of attempts, whatever its status.
+The payload is unchanged, so the subscriber sees the same event ID.
+Returns ErrDeliveryNotFound if the delivery does not exist.
+*/
+func (s *URLService) RedeliverWebhook(ctx context.Context, id int) (_ *model.WebhookDelivery, err error) {
+	ctx, span := startSpan(ctx, "URLService.RedeliverWebhook", attribute.Int("webhook.delivery", id))
+	defer func() { tracing.End(span, err) }()
+
+	result, err := db.ExecContext(ctx, s.writeStmts, `UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = ?, last_status = 0, last_error = '', delivered_at = NULL
+		WHERE id = ?`, time.Now().UTC(), id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrDeliveryNotFound
+	}
+	s.wakeWebhooks()
+
+	var d model.WebhookDelivery
+	if err := db.GetContext(ctx, s.writeStmts, &d, "SELECT * FROM webhook_deliveries WHERE id = ?", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+/*
+RedeliverDeadWebhooks queues all dead deliveries of a webhook again, e.g. after the
+subscriber fixed an outage. Returns the number of deliveries queued and
+ErrWebhookNotFound if the webhook does not exist.
+*/
+func (s *URLService) RedeliverDeadWebhooks(ctx context.Context, webhookID int) (n int, err error) {
+	ctx, span := startSpan(ctx, "URLService.RedeliverDeadWebhooks", attribute.Int("webhook.id", webhookID))
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+		return 0, err
+	}
+	result, err := db.ExecContext(ctx, s.writeStmts, `UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = ?, last_status = 0, last_error = ''
+		WHERE webhook_id = ? AND status = 'dead'`, time.Now().UTC(), webhookID)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	s.wakeWebhooks()
+	return int(affected), nil
+}
+
+/*
+PruneWebhookDeliveries removes delivered deliveries older than the retention period
+and returns how many were removed.
+*/
+func (s *URLService) PruneWebhookDeliveries(ctx context.Context) (n int64, err error) {
+	ctx, span := startSpan(ctx, "URLService.PruneWebhookDeliveries")
+	defer func() { tracing.End(span, err) }()
+
+	result, err := db.ExecContext(ctx, s.writeStmts,
+		"DELETE FROM webhook_deliveries WHERE status = 'delivered' AND delivered_at < ?",
+		time.Now().UTC().Add(-s.Webhooks.Retention))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
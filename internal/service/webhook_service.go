@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/zen-flo/url-shortener/internal/db"
+	"github.com/zen-flo/url-shortener/internal/linkcheck"
+	"github.com/zen-flo/url-shortener/internal/logger"
+	"github.com/zen-flo/url-shortener/internal/model"
+	"github.com/zen-flo/url-shortener/internal/tracing"
+)
+
+// Link lifecycle events delivered to webhooks. The health events of linkcheck are delivered as well.
+const (
+	EventLinkCreated = "link.created" // A link was created
+	EventLinkUpdated = "link.updated" // The destination, rules, variants, options or state of a link changed
+	EventLinkDeleted = "link.deleted" // A link was deleted
+	EventLinkExpired = "link.expired" // A link used up its click limit
+	EventLinkClicked = "link.clicked" // A visitor was redirected
+)
+
+// WebhookEvents lists the event types a webhook may subscribe to.
+var WebhookEvents = []string{
+	EventLinkCreated, EventLinkUpdated, EventLinkDeleted, EventLinkExpired, EventLinkClicked,
+	linkcheck.EventBroken, linkcheck.EventRecovered,
+}
+
+// webhookSecretPrefix marks strings as webhook signing secrets of this service.
+const webhookSecretPrefix = "whsec_"
+
+// Errors returned when managing webhooks.
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrInvalidWebhook   = errors.New("invalid webhook")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+/*
+CreateWebhook subscribes url to the given event types; "*" subscribes to all of them.
+The signing secret is returned only here and in the Secret field of the result.
+Returns ErrInvalidWebhook for a malformed URL or an unknown event type.
+*/
+func (s *URLService) CreateWebhook(ctx context.Context, rawURL string, events []string) (_ *model.Webhook, err error) {
+	ctx, span := startSpan(ctx, "URLService.CreateWebhook")
+	defer func() { tracing.End(span, err) }()
+
+	events, err = validateWebhook(rawURL, events)
+	if err != nil {
+		return nil, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	w := &model.Webhook{
+		URL:       rawURL,
+		Events:    events,
+		Active:    true,
+		Secret:    webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(secret),
+		CreatedAt: time.Now(),
+	}
+	result, err := db.ExecContext(ctx, s.writeStmts,
+		"INSERT INTO webhooks (url, secret, events, active, created_at) VALUES (?, ?, ?, ?, ?)",
+		w.URL, w.Secret, w.Events, w.Active, w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	w.ID = int(id)
+
+	s.invalidateWebhooks()
+	return w, nil
+}
+
+/*
+GetWebhook returns a subscription. Returns ErrWebhookNotFound if it does not exist.
+*/
+func (s *URLService) GetWebhook(ctx context.Context, id int) (_ *model.Webhook, err error) {
+	ctx, span := startSpan(ctx, "URLService.GetWebhook", attribute.Int("webhook.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	var w model.Webhook
+	err = db.GetContext(ctx, s.readStmts, &w, "SELECT * FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return &w, nil
+}
+
+/*
+ListWebhooks returns all subscriptions, newest first.
+*/
+func (s *URLService) ListWebhooks(ctx context.Context) (webhooks []model.Webhook, err error) {
+	ctx, span := startSpan(ctx, "URLService.ListWebhooks")
+	defer func() { tracing.End(span, err) }()
+
+	err = db.SelectContext(ctx, s.readStmts, &webhooks, "SELECT * FROM webhooks ORDER BY id DESC")
+	return webhooks, err
+}
+
+/*
+UpdateWebhook replaces the URL, event types and active flag of a subscription.
+Events of an inactive subscription are not queued; deliveries queued before stay pending
+until it is active again.
+Returns ErrInvalidWebhook for a malformed URL or an unknown event type and ErrWebhookNotFound if it does not exist.
+*/
+func (s *URLService) UpdateWebhook(ctx context.Context, id int, rawURL string, events []string, active bool) (_ *model.Webhook, err error) {
+	ctx, span := startSpan(ctx, "URLService.UpdateWebhook", attribute.Int("webhook.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	events, err = validateWebhook(rawURL, events)
+	if err != nil {
+		return nil, err
+	}
+	result, err := db.ExecContext(ctx, s.writeStmts,
+		"UPDATE webhooks SET url = ?, events = ?, active = ? WHERE id = ?", rawURL, model.WebhookEvents(events), active, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrWebhookNotFound
+	}
+
+	s.invalidateWebhooks()
+	s.wakeWebhooks()
+	return s.GetWebhook(ctx, id)
+}
+
+/*
+DeleteWebhook removes a subscription together with its deliveries.
+Returns ErrWebhookNotFound if it does not exist.
+*/
+func (s *URLService) DeleteWebhook(ctx context.Context, id int) (err error) {
+	ctx, span := startSpan(ctx, "URLService.DeleteWebhook", attribute.Int("webhook.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	result, err := db.ExecContext(ctx, s.writeStmts, "DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrWebhookNotFound
+	}
+
+	s.invalidateWebhooks()
+	return nil
+}
+
+/*
+validateWebhook checks the endpoint and event types of a subscription and returns the
+event types deduplicated.
+*/
+func validateWebhook(rawURL string, events []string) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	var out []string
+	for _, e := range events {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e != "*" && !slices.Contains(WebhookEvents, e) {
+			return nil, fmt.Errorf("%w: unknown event %q, expected * or one of %s", ErrInvalidWebhook, e, strings.Join(WebhookEvents, ", "))
+		}
+		if !slices.Contains(out, e) {
+			out = append(out, e)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: at least one event is required", ErrInvalidWebhook)
+	}
+	return out, nil
+}
+
+/*
+activeWebhooks returns the active subscriptions. They are cached, since every click
+checks them, and reloaded after they change.
+*/
+func (s *URLService) activeWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	s.webhooksMu.RLock()
+	webhooks := s.webhooks
+	s.webhooksMu.RUnlock()
+	if webhooks != nil {
+		return webhooks, nil
+	}
+
+	webhooks = []model.Webhook{}
+	if err := db.SelectContext(ctx, s.readStmts, &webhooks, "SELECT * FROM webhooks WHERE active = 1"); err != nil {
+		return nil, err
+	}
+	s.webhooksMu.Lock()
+	s.webhooks = webhooks
+	s.webhooksMu.Unlock()
+	return webhooks, nil
+}
+
+func (s *URLService) invalidateWebhooks() {
+	s.webhooksMu.Lock()
+	s.webhooks = nil
+	s.webhooksMu.Unlock()
+}
+
+/*
+subscribers returns the IDs of the active subscriptions receiving the event.
+Failures are logged and treated as no subscribers, so that they never fail the operation
+that caused the event.
+*/
+func (s *URLService) subscribers(ctx context.Context, event string) []int {
+	webhooks, err := s.activeWebhooks(ctx)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "failed to load webhooks", "error", err)
+		return nil
+	}
+	var ids []int
+	for i := range webhooks {
+		if webhooks[i].Matches(event) {
+			ids = append(ids, webhooks[i].ID)
+		}
+	}
+	return ids
+}
+
+/*
+emit queues the event for every subscription receiving it. The deliveries are stored in
+one transaction and sent by the dispatcher, see RunWebhookDispatcher. Failures are logged:
+the change the event reports has already happened.
+*/
+func (s *URLService) emit(ctx context.Context, event string, link *model.URL, health *model.LinkHealth) {
+	ids := s.subscribers(ctx, event)
+	if len(ids) == 0 {
+		return
+	}
+	if err := s.queueEvent(ctx, ids, event, link, health); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "failed to queue webhook event",
+			"event", event, "domain", link.Domain, "short", link.Short, "error", err)
+		return
+	}
+	s.wakeWebhooks()
+}
+
+func (s *URLService) queueEvent(ctx context.Context, ids []int, event string, link *model.URL, health *model.LinkHealth) error {
+	now := time.Now()
+	payload, err := json.Marshal(model.WebhookEvent{
+		ID:        "evt_" + generateShortCode(16),
+		Event:     event,
+		CreatedAt: now,
+		Link:      link,
+		Health:    health,
+	})
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, id := range ids {
+		// Due times are stored in UTC, so that they compare as text
+		_, err := db.ExecContext(ctx, tx, `INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at, created_at)
+			VALUES (?, ?, ?, ?, ?)`, id, event, string(payload), now.UTC(), now)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	webhookEvents.WithLabelValues(event).Add(float64(len(ids)))
+	return nil
+}
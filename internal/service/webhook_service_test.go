@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zen-flo/url-shortener/internal/model"
+)
+
+// webhookReceiver records the requests of a webhook endpoint, answering with status.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.requests = append(rcv.requests, r)
+	rcv.bodies = append(rcv.bodies, body)
+	if rcv.status != 0 {
+		w.WriteHeader(rcv.status)
+	}
+}
+
+func (rcv *webhookReceiver) events(t *testing.T) []model.WebhookEvent {
+	t.Helper()
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	var events []model.WebhookEvent
+	for _, body := range rcv.bodies {
+		var e model.WebhookEvent
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Fatalf("invalid payload %s: %v", body, err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestWebhookSubscriptions(t *testing.T) {
+	service := NewURLService(setupTestDB(t))
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		url    string
+		events []string
+	}{
+		{"ftp://crm.example.com", []string{EventLinkCreated}},
+		{"/hooks", []string{EventLinkCreated}},
+		{"https://crm.example.com", nil},
+		{"https://crm.example.com", []string{"link.renamed"}},
+	} {
+		if _, err := service.CreateWebhook(ctx, tc.url, tc.events); !errors.Is(err, ErrInvalidWebhook) {
+			t.Errorf("%s %v: expected ErrInvalidWebhook, got %v", tc.url, tc.events, err)
+		}
+	}
+
+	w, err := service.CreateWebhook(ctx, "https://crm.example.com/hooks", []string{" Link.Created", "link.created", "link.clicked"})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	if !strings.HasPrefix(w.Secret, webhookSecretPrefix) || !w.Active {
+		t.Errorf("unexpected webhook %+v", w)
+	}
+	if len(w.Events) != 2 || !w.Matches(EventLinkClicked) || w.Matches(EventLinkDeleted) {
+		t.Errorf("unexpected events %v", w.Events)
+	}
+
+	updated, err := service.UpdateWebhook(ctx, w.ID, "https://crm.example.com/v2", []string{"*"}, false)
+	if err != nil {
+		t.Fatalf("UpdateWebhook failed: %v", err)
+	}
+	if updated.URL != "https://crm.example.com/v2" || updated.Active || !updated.Matches(EventLinkDeleted) || updated.Secret != w.Secret {
+		t.Errorf("unexpected updated webhook %+v", updated)
+	}
+	if _, err := service.UpdateWebhook(ctx, 999, "https://crm.example.com", []string{"*"}, true); !errors.Is(err, ErrWebhookNotFound) {
+		t.Errorf("expected ErrWebhookNotFound, got %v", err)
+	}
+
+	webhooks, err := service.ListWebhooks(ctx)
+	if err != nil || len(webhooks) != 1 {
+		t.Fatalf("expected one webhook, got %v (err %v)", webhooks, err)
+	}
+	if err := service.DeleteWebhook(ctx, w.ID); err != nil {
+		t.Fatalf("DeleteWebhook failed: %v", err)
+	}
+	if _, err := service.GetWebhook(ctx, w.ID); !errors.Is(err, ErrWebhookNotFound) {
+		t.Errorf("expected ErrWebhookNotFound after deletion, got %v", err)
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	rcv := &webhookReceiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	service := NewURLService(setupTestDB(t), WithWebhooks(WebhookConfig{AllowPrivate: true}))
+	ctx := context.Background()
+
+	lifecycle, _ := service.CreateWebhook(ctx, srv.URL+"/lifecycle", []string{EventLinkCreated, EventLinkDeleted})
+	clicks, _ := service.CreateWebhook(ctx, srv.URL+"/clicks", []string{EventLinkClicked, EventLinkExpired})
+
+	url, _ := service.CreateShortURL(ctx, "https://example.com", WithMaxClicks(1))
+	if err := service.RegisterClick(ctx, "", url.Short, -1); err != nil {
+		t.Fatalf("RegisterClick failed: %v", err)
+	}
+	if err := service.DeleteURL(ctx, "", url.Short); err != nil {
+		t.Fatalf("DeleteURL failed: %v", err)
+	}
+
+	n, err := service.DeliverWebhooks(ctx)
+	if err != nil || n != 4 {
+		t.Fatalf("expected 4 deliveries, got %d (err %v)", n, err)
+	}
+
+	got := map[string]string{}
+	for i, e := range rcv.events(t) {
+		r, body := rcv.requests[i], rcv.bodies[i]
+		got[e.Event] = r.URL.Path
+		if e.Link == nil || e.Link.Short != url.Short || !strings.HasPrefix(e.ID, "evt_") {
+			t.Errorf("%s: unexpected payload %s", e.Event, body)
+		}
+		if r.Header.Get(WebhookEventHeader) != e.Event || r.Header.Get(WebhookDeliveryHeader) == "" {
+			t.Errorf("%s: unexpected headers %v", e.Event, r.Header)
+		}
+
+		// The subscriber recomputes the signature from the timestamp and its secret
+		secret := lifecycle.Secret
+		if r.URL.Path == "/clicks" {
+			secret = clicks.Secret
+		}
+		sig := r.Header.Get(WebhookSignatureHeader)
+		ts, _ := strconv.ParseInt(strings.TrimPrefix(strings.Split(sig, ",")[0], "t="), 10, 64)
+		if want := SignWebhook(secret, time.Unix(ts, 0), body); sig != want {
+			t.Errorf("%s: signature %q, expected %q", e.Event, sig, want)
+		}
+	}
+	want := map[string]string{
+		EventLinkCreated: "/lifecycle",
+		EventLinkDeleted: "/lifecycle",
+		EventLinkClicked: "/clicks",
+		EventLinkExpired: "/clicks",
+	}
+	for event, path := range want {
+		if got[event] != path {
+			t.Errorf("expected %s to be delivered to %s, got %v", event, path, got)
+		}
+	}
+
+	deliveries, err := service.ListWebhookDeliveries(ctx, lifecycle.ID, model.DeliveryDelivered, 10)
+	if err != nil || len(deliveries) != 2 || deliveries[0].DeliveredAt == nil || deliveries[0].Attempts != 1 {
+		t.Errorf("expected two delivered deliveries, got %+v (err %v)", deliveries, err)
+	}
+	if n, _ := service.DeliverWebhooks(ctx); n != 0 {
+		t.Errorf("expected nothing left to deliver, got %d", n)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	rcv := &webhookReceiver{status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	service := NewURLService(setupTestDB(t), WithWebhooks(WebhookConfig{AllowPrivate: true, MaxAttempts: 2, RetryBase: time.Millisecond}))
+	ctx := context.Background()
+
+	w, _ := service.CreateWebhook(ctx, srv.URL, []string{"*"})
+	if _, err := service.CreateShortURL(ctx, "https://example.com"); err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+
+	service.DeliverWebhooks(ctx)
+	pending, _ := service.ListWebhookDeliveries(ctx, w.ID, model.DeliveryPending, 10)
+	if len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastStatus != http.StatusServiceUnavailable {
+		t.Fatalf("expected a pending delivery after the first failure, got %+v", pending)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	service.DeliverWebhooks(ctx)
+	dead, _ := service.ListWebhookDeliveries(ctx, w.ID, model.DeliveryDead, 10)
+	if len(dead) != 1 || dead[0].Attempts != 2 || !strings.Contains(dead[0].LastError, "503") {
+		t.Fatalf("expected a dead delivery after the last attempt, got %+v", dead)
+	}
+
+	// Once the subscriber is fixed, the dead delivery is sent again with the same event
+	rcv.mu.Lock()
+	rcv.status = http.StatusNoContent
+	rcv.mu.Unlock()
+	if n, err := service.RedeliverDeadWebhooks(ctx, w.ID); err != nil || n != 1 {
+		t.Fatalf("expected one delivery queued again, got %d (err %v)", n, err)
+	}
+	if n, _ := service.DeliverWebhooks(ctx); n != 1 {
+		t.Fatalf("expected the delivery to be sent again, got %d", n)
+	}
+	events := rcv.events(t)
+	if len(events) != 3 || events[0].ID != events[2].ID {
+		t.Errorf("expected the redelivery to repeat the event, got %+v", events)
+	}
+
+	d, err := service.RedeliverWebhook(ctx, dead[0].ID)
+	if err != nil || d.Status != model.DeliveryPending || d.Attempts != 0 {
+		t.Errorf("expected the delivery to be pending again, got %+v (err %v)", d, err)
+	}
+	if _, err := service.RedeliverWebhook(ctx, 999); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("expected ErrDeliveryNotFound, got %v", err)
+	}
+	if _, err := service.ListWebhookDeliveries(ctx, w.ID, "failed", 10); !errors.Is(err, ErrInvalidWebhook) {
+		t.Errorf("expected ErrInvalidWebhook for an unknown status, got %v", err)
+	}
+}
+
+func TestWebhookPrivateAddressRefused(t *testing.T) {
+	rcv := &webhookReceiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	// The default client refuses subscribers on internal addresses
+	service := NewURLService(setupTestDB(t))
+	ctx := context.Background()
+
+	w, _ := service.CreateWebhook(ctx, srv.URL, []string{"*"})
+	if _, err := service.CreateShortURL(ctx, "https://example.com"); err != nil {
+		t.Fatalf("CreateShortURL failed: %v", err)
+	}
+	service.DeliverWebhooks(ctx)
+
+	pending, _ := service.ListWebhookDeliveries(ctx, w.ID, model.DeliveryPending, 10)
+	if len(pending) != 1 || !strings.Contains(pending[0].LastError, "address is not public") {
+		t.Fatalf("expected the delivery to a loopback address to fail, got %+v", pending)
+	}
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if len(rcv.requests) != 0 {
+		t.Errorf("expected no request to reach the subscriber, got %d", len(rcv.requests))
+	}
+}
+
+func TestWebhookInactive(t *testing.T) {
+	service := NewURLService(setupTestDB(t))
+	ctx := context.Background()
+
+	w, _ := service.CreateWebhook(ctx, "https://crm.example.com", []string{"*"})
+	if _, err := service.UpdateWebhook(ctx, w.ID, w.URL, w.Events, false); err != nil {
+		t.Fatalf("UpdateWebhook failed: %v", err)
+	}
+	service.CreateShortURL(ctx, "https://example.com")
+
+	if deliveries, _ := service.ListWebhookDeliveries(ctx, w.ID, "", 10); len(deliveries) != 0 {
+		t.Errorf("expected no events for an inactive webhook, got %+v", deliveries)
+	}
+}
+
+func TestWebhookBacklog(t *testing.T) {
+	service := NewURLService(setupTestDB(t))
+	ctx := context.Background()
+
+	w, _ := service.CreateWebhook(ctx, "https://crm.example.com", []string{"*"})
+	service.CreateShortURL(ctx, "https://example.com/a")
+	service.CreateShortURL(ctx, "https://example.com/b")
+
+	pending, overdue, err := service.WebhookBacklog(ctx, time.Now().Add(-time.Hour))
+	if err != nil || pending != 2 || overdue != 0 {
+		t.Fatalf("expected 2 pending and none overdue, got %d, %d (err %v)", pending, overdue, err)
+	}
+	if _, overdue, _ = service.WebhookBacklog(ctx, time.Now().Add(time.Hour)); overdue != 2 {
+		t.Errorf("expected both deliveries overdue an hour later, got %d", overdue)
+	}
+
+	// Deliveries of a paused webhook are not waiting for the dispatcher
+	service.UpdateWebhook(ctx, w.ID, w.URL, w.Events, false)
+	if pending, _, _ = service.WebhookBacklog(ctx, time.Now()); pending != 0 {
+		t.Errorf("expected no backlog for an inactive webhook, got %d", pending)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	service := NewURLService(setupTestDB(t), WithWebhooks(WebhookConfig{RetryBase: time.Second, RetryMax: 10 * time.Second}))
+
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 30: 10 * time.Second} {
+		if got := service.retryDelay(attempts); got < want || got > want+want/10 {
+			t.Errorf("attempt %d: delay %v, expected %v plus up to 10%%", attempts, got, want)
+		}
+	}
+}
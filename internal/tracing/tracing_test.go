@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceIDAndEnd(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	if id := TraceID(context.Background()); id != "" {
+		t.Errorf("expected empty trace ID without span, got %q", id)
+	}
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "op")
+	if id := TraceID(ctx); id != span.SpanContext().TraceID().String() {
+		t.Errorf("unexpected trace ID %q", id)
+	}
+	End(span, errors.New("boom"))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Status.Code != codes.Error {
+		t.Errorf("expected error status, got %v", spans[0].Status.Code)
+	}
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{Exporter: "zipkin"}); err == nil {
+		t.Errorf("expected error for unknown exporter")
+	}
+}